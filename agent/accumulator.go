@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/config"
 	"github.com/influxdata/telegraf/internal/models"
 )
 
@@ -25,7 +26,8 @@ func NewAccumulator(
 type accumulator struct {
 	metrics chan telegraf.Metric
 
-	defaultTags map[string]string
+	// globals holds the daemon-wide tags, see Config.MergeGlobalTags.
+	globals *config.Config
 
 	debug bool
 	// print every point added to the accumulator
@@ -33,8 +35,18 @@ type accumulator struct {
 
 	inputConfig *models.InputConfig
 
+	// buffer, if set, receives the metrics instead of the metrics channel.
+	buffer *models.RunningInput
+	// dedup, if set, suppresses the duplicate metrics of the input.
+	dedup *models.RunningInput
+
 	precision time.Duration
 
+	// timestampSource is the metric_timestamp_source agent option, and now
+	// returns the collection time. now is time.Now if nil.
+	timestampSource string
+	now             func() time.Time
+
 	errCount uint64
 }
 
@@ -45,7 +57,7 @@ func (ac *accumulator) AddFields(
 	t ...time.Time,
 ) {
 	if m := ac.makeMetric(measurement, fields, tags, telegraf.Untyped, t...); m != nil {
-		ac.metrics <- m
+		ac.addMetric(m)
 	}
 }
 
@@ -56,7 +68,7 @@ func (ac *accumulator) AddGauge(
 	t ...time.Time,
 ) {
 	if m := ac.makeMetric(measurement, fields, tags, telegraf.Gauge, t...); m != nil {
-		ac.metrics <- m
+		ac.addMetric(m)
 	}
 }
 
@@ -67,10 +79,23 @@ func (ac *accumulator) AddCounter(
 	t ...time.Time,
 ) {
 	if m := ac.makeMetric(measurement, fields, tags, telegraf.Counter, t...); m != nil {
-		ac.metrics <- m
+		ac.addMetric(m)
 	}
 }
 
+// addMetric sends a metric to the buffer of the input if it has one, and to
+// the metrics channel otherwise, unless it is a suppressed duplicate.
+func (ac *accumulator) addMetric(m telegraf.Metric) {
+	if ac.dedup != nil && ac.dedup.Deduplicate(m) {
+		return
+	}
+	if ac.buffer != nil {
+		ac.buffer.BufferMetric(m)
+		return
+	}
+	ac.metrics <- m
+}
+
 // makeMetric either returns a metric, or returns nil if the metric doesn't
 // need to be created (because of filtering, an error, etc.)
 func (ac *accumulator) makeMetric(
@@ -87,17 +112,8 @@ func (ac *accumulator) makeMetric(
 		tags = make(map[string]string)
 	}
 
-	// Override measurement name if set
-	if len(ac.inputConfig.NameOverride) != 0 {
-		measurement = ac.inputConfig.NameOverride
-	}
-	// Apply measurement prefix and suffix if set
-	if len(ac.inputConfig.MeasurementPrefix) != 0 {
-		measurement = ac.inputConfig.MeasurementPrefix + measurement
-	}
-	if len(ac.inputConfig.MeasurementSuffix) != 0 {
-		measurement = measurement + ac.inputConfig.MeasurementSuffix
-	}
+	// Rename fields before anything else sees them
+	ac.inputConfig.RenameFields(fields)
 
 	// Apply plugin-wide tags if set
 	for k, v := range ac.inputConfig.Tags {
@@ -106,9 +122,33 @@ func (ac *accumulator) makeMetric(
 		}
 	}
 	// Apply daemon-wide tags if set
-	for k, v := range ac.defaultTags {
-		if _, ok := tags[k]; !ok {
-			tags[k] = v
+	if ac.globals != nil {
+		ac.globals.MergeGlobalTags(tags)
+	}
+
+	if len(ac.inputConfig.NameTemplate) != 0 {
+		// The name template replaces all other measurement name options
+		name, err := ac.inputConfig.MetricName(measurement, tags, fields)
+		if err != nil {
+			log.Printf("E! Error executing name_template for input %s: %s\n",
+				ac.inputConfig.Name, err)
+			return nil
+		}
+		if len(name) == 0 {
+			return nil
+		}
+		measurement = name
+	} else {
+		// Override measurement name if set
+		if len(ac.inputConfig.NameOverride) != 0 {
+			measurement = ac.inputConfig.NameOverride
+		}
+		// Apply measurement prefix and suffix if set
+		if len(ac.inputConfig.MeasurementPrefix) != 0 {
+			measurement = ac.inputConfig.MeasurementPrefix + measurement
+		}
+		if len(ac.inputConfig.MeasurementSuffix) != 0 {
+			measurement = measurement + ac.inputConfig.MeasurementSuffix
 		}
 	}
 
@@ -117,6 +157,11 @@ func (ac *accumulator) makeMetric(
 		return nil
 	}
 
+	// Randomly drop metrics if sampling is configured
+	if !ac.inputConfig.Sample() {
+		return nil
+	}
+
 	for k, v := range fields {
 		// Validate uint64 and float64 fields
 		switch val := v.(type) {
@@ -145,12 +190,18 @@ func (ac *accumulator) makeMetric(
 	}
 
 	var timestamp time.Time
-	if len(t) > 0 {
-		timestamp = t[0]
-	} else {
-		timestamp = time.Now()
+	switch {
+	case ac.timestampSource == "zero":
+		// left zero, for the outputs to set
+	case len(t) > 0:
+		timestamp = t[0].Round(ac.precision)
+	case ac.timestampSource == "plugin":
+		// never the collection time, the outputs set it
+	case ac.now != nil:
+		timestamp = ac.now().Round(ac.precision)
+	default:
+		timestamp = time.Now().Round(ac.precision)
 	}
-	timestamp = timestamp.Round(ac.precision)
 
 	var m telegraf.Metric
 	var err error
@@ -226,13 +277,36 @@ func (ac *accumulator) DisablePrecision() {
 	ac.precision = time.Nanosecond
 }
 
-func (ac *accumulator) setDefaultTags(tags map[string]string) {
-	ac.defaultTags = tags
+func (ac *accumulator) setGlobalTags(c *config.Config) {
+	ac.globals = c
+}
+
+// setTimestampSource sets how the accumulator sets metric timestamps, see
+// the metric_timestamp_source agent option. now returns the collection time.
+func (ac *accumulator) setTimestampSource(source string, now func() time.Time) {
+	ac.timestampSource = source
+	ac.now = now
+}
+
+// setBuffer makes the accumulator add metrics to the buffer of input, if it
+// has a metric_buffer_limit.
+func (ac *accumulator) setBuffer(input *models.RunningInput) {
+	if input.Config.MetricBufferLimit > 0 {
+		ac.buffer = input
+	}
+}
+
+// setDedup makes the accumulator suppress the duplicate metrics of input, if
+// it has a deduplication table.
+func (ac *accumulator) setDedup(input *models.RunningInput) {
+	if input.Config.Dedup != nil {
+		ac.dedup = input
+	}
 }
 
 func (ac *accumulator) addDefaultTag(key, value string) {
-	if ac.defaultTags == nil {
-		ac.defaultTags = make(map[string]string)
+	if ac.globals == nil {
+		ac.globals = config.NewConfig()
 	}
-	ac.defaultTags[key] = value
+	ac.globals.Tags[key] = value
 }
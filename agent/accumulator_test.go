@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"math/rand"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -299,6 +301,27 @@ func TestAddDefaultTags(t *testing.T) {
 		actual)
 }
 
+func TestAddGlobalTagOverride(t *testing.T) {
+	a := accumulator{}
+	a.addDefaultTag("host", "global")
+	a.metrics = make(chan telegraf.Metric, 10)
+	defer close(a.metrics)
+	a.inputConfig = &models.InputConfig{}
+
+	a.AddFields("acctest",
+		map[string]interface{}{"value": float64(101)},
+		map[string]string{"host": "local"})
+	testm := <-a.metrics
+	assert.Equal(t, "local", testm.Tags()["host"])
+
+	a.globals.Agent.GlobalTagOverride = true
+	a.AddFields("acctest",
+		map[string]interface{}{"value": float64(101)},
+		map[string]string{"host": "local"})
+	testm = <-a.metrics
+	assert.Equal(t, "global", testm.Tags()["host"])
+}
+
 func TestAddFields(t *testing.T) {
 	a := accumulator{}
 	now := time.Now()
@@ -603,7 +626,7 @@ func TestAccAddError(t *testing.T) {
 	a.AddError(fmt.Errorf("baz"))
 
 	errs := bytes.Split(errBuf.Bytes(), []byte{'\n'})
-	assert.EqualValues(t, 3, a.errCount)
+	assert.EqualValues(t, 3, atomic.LoadUint64(&a.errCount))
 	require.Len(t, errs, 4) // 4 because of trailing newline
 	assert.Contains(t, string(errs[0]), "mock_plugin")
 	assert.Contains(t, string(errs[0]), "foo")
@@ -612,3 +635,151 @@ func TestAccAddError(t *testing.T) {
 	assert.Contains(t, string(errs[2]), "mock_plugin")
 	assert.Contains(t, string(errs[2]), "baz")
 }
+
+func TestAccSampling(t *testing.T) {
+	a := accumulator{}
+	a.metrics = make(chan telegraf.Metric, 100)
+	defer close(a.metrics)
+	a.inputConfig = &models.InputConfig{
+		SamplingRate: 0.5,
+		SamplingSeed: 42,
+	}
+
+	for i := 0; i < 100; i++ {
+		a.AddFields("acctest",
+			map[string]interface{}{"value": float64(i)},
+			map[string]string{})
+	}
+
+	// the same seed always keeps the same metrics
+	expected := 0
+	r := rand.New(rand.NewSource(42))
+	for i := 0; i < 100; i++ {
+		if r.Float64() < 0.5 {
+			expected++
+		}
+	}
+	assert.Len(t, a.metrics, expected)
+	assert.True(t, expected > 0 && expected < 100)
+}
+
+func TestAccNameTemplate(t *testing.T) {
+	a := accumulator{}
+	a.addDefaultTag("region", "us-west")
+	a.metrics = make(chan telegraf.Metric, 10)
+	defer close(a.metrics)
+	a.inputConfig = &models.InputConfig{
+		Name:              "cpu",
+		NameOverride:      "ignored",
+		MeasurementPrefix: "ignored_",
+		NameTemplate:      "{{.PluginName}}_{{.Tags.region}}_{{.Measurement}}",
+	}
+	assert.NoError(t, a.inputConfig.CompileNameTemplate())
+
+	a.AddFields("acctest",
+		map[string]interface{}{"value": float64(101)},
+		map[string]string{})
+
+	testm := <-a.metrics
+	assert.Equal(t, "cpu_us-west_acctest", testm.Name())
+
+	// metrics missing a tag of the template are dropped
+	a.inputConfig.NameTemplate = "{{.PluginName}}_{{.Tags.zone}}"
+	assert.NoError(t, a.inputConfig.CompileNameTemplate())
+	a.AddFields("acctest",
+		map[string]interface{}{"value": float64(101)},
+		map[string]string{})
+	assert.Len(t, a.metrics, 0)
+}
+
+func TestAccMetricBuffer(t *testing.T) {
+	input := &models.RunningInput{
+		Name:   "acctest",
+		Config: &models.InputConfig{MetricBufferLimit: 2},
+	}
+	a := accumulator{}
+	a.metrics = make(chan telegraf.Metric, 10)
+	defer close(a.metrics)
+	a.inputConfig = input.Config
+	a.setBuffer(input)
+
+	for i := 0; i < 5; i++ {
+		a.AddFields("acctest",
+			map[string]interface{}{"value": int64(i)},
+			map[string]string{})
+	}
+	// nothing reaches the metrics channel before being dispatched, and the
+	// oldest metrics are dropped when the buffer is full
+	assert.Len(t, a.metrics, 0)
+	assert.Equal(t, int64(3), input.Dropped())
+
+	shutdown := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		input.DispatchMetrics(shutdown, a.metrics)
+		close(done)
+	}()
+	for _, expected := range []int64{3, 4} {
+		select {
+		case m := <-a.metrics:
+			assert.Equal(t, expected, m.Fields()["value"])
+		case <-time.After(time.Second):
+			t.Fatal("buffered metric was not dispatched")
+		}
+	}
+	close(shutdown)
+	<-done
+}
+
+func TestAccFieldRename(t *testing.T) {
+	hits, err := models.NewFieldRename("get_hits", "hits")
+	require.NoError(t, err)
+	gets, err := models.NewFieldRename("get_*", "gets")
+	require.NoError(t, err)
+
+	a := accumulator{}
+	a.metrics = make(chan telegraf.Metric, 10)
+	defer close(a.metrics)
+	a.inputConfig = &models.InputConfig{
+		FieldRename: []models.FieldRename{hits, gets},
+		// filters see the renamed fields
+		Filter: models.Filter{FieldDrop: []string{"gets"}},
+	}
+	require.NoError(t, a.inputConfig.Filter.Compile())
+
+	a.AddFields("acctest", map[string]interface{}{
+		"get_hits":   int64(1),
+		"get_misses": int64(2),
+		"evictions":  int64(3),
+	}, nil)
+	m := <-a.metrics
+	assert.Equal(t, map[string]interface{}{
+		"hits":      int64(1),
+		"evictions": int64(3),
+	}, m.Fields())
+}
+
+func TestAccTimestampSource(t *testing.T) {
+	pluginTime := time.Unix(1500000000, 0)
+	collectionTime := time.Unix(1600000000, 0)
+	now := func() time.Time { return collectionTime }
+
+	for _, tt := range []struct {
+		source        string
+		withPlugin    time.Time
+		withoutPlugin time.Time
+	}{
+		{"agent", pluginTime, collectionTime},
+		{"ntp", pluginTime, collectionTime},
+		{"plugin", pluginTime, time.Time{}},
+		{"zero", time.Time{}, time.Time{}},
+	} {
+		a := NewAccumulator(&models.InputConfig{}, make(chan telegraf.Metric, 2))
+		a.setTimestampSource(tt.source, now)
+
+		a.AddFields("acctest", map[string]interface{}{"value": 1}, nil, pluginTime)
+		a.AddFields("acctest", map[string]interface{}{"value": 1}, nil)
+		assert.Equal(t, tt.withPlugin, (<-a.metrics).Time(), tt.source)
+		assert.Equal(t, tt.withoutPlugin, (<-a.metrics).Time(), tt.source)
+	}
+}
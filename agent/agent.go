@@ -3,20 +3,58 @@ package agent
 import (
 	"fmt"
 	"log"
-	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/internal/config"
 	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/logger"
 )
 
 // Agent runs telegraf and collects data based on the given config
 type Agent struct {
 	Config *config.Config
+
+	// gatherSem limits the number of concurrent gathers, see the
+	// max_goroutines agent option. nil means unlimited.
+	gatherSem *tickSemaphore
+
+	// collectSem limits the number of concurrent gathers across intervals,
+	// see the collection_concurrency agent option. nil means unlimited.
+	collectSem chan struct{}
+
+	// workers runs the gathers of all inputs, see the input_workers agent
+	// option. nil runs every gather in the goroutine of its input.
+	workers *gatherWorkers
+
+	// internalStats receives telegraf's own internal metrics, if they are
+	// enabled, and is given to plugins added while running.
+	internalStats chan telegraf.Metric
+
+	// outputChanges receives the outputs added and removed while running,
+	// see watchDirectories.
+	outputChanges chan outputChange
+
+	// omitFields matches the fields dropped from every metric, see the
+	// omit_fields agent option. nil means none.
+	omitFields filter.Filter
+
+	// labelPolicy drops or renames the tags whose key is not allowed, nil if
+	// all are.
+	labelPolicy *models.LabelPolicy
+
+	// tagLimiter limits the tag cardinality of the metrics sent to the
+	// outputs, nil if there are no limits.
+	tagLimiter *models.TagLimiter
+
+	// clock is the system clock corrected by NTP, with
+	// metric_timestamp_source = "ntp". nil otherwise.
+	clock *ntpClock
 }
 
 // NewAgent returns an Agent struct based off the given Config
@@ -25,9 +63,48 @@ func NewAgent(config *config.Config) (*Agent, error) {
 		Config: config,
 	}
 
+	if err := a.Config.Agent.Proxy.Apply(); err != nil {
+		return nil, err
+	}
+	// applied once to the loaded config, not after each of its files
+	err := a.Config.PreprocessGlobalTags()
+	if err != nil {
+		return nil, fmt.Errorf("Error applying tag_preprocessing, %s", err)
+	}
+
+	omitFields, err := filter.Compile(a.Config.Agent.OmitFields)
+	if err != nil {
+		return nil, fmt.Errorf("Error compiling omit_fields, %s", err)
+	}
+	a.omitFields = omitFields
+	a.labelPolicy, err = models.NewLabelPolicy(a.Config.Agent.MetricLabelWhitelist,
+		a.Config.Agent.MetricLabelBlacklist, a.Config.Agent.LabelWhitelistAction)
+	if err != nil {
+		return nil, fmt.Errorf("Error compiling metric_label_whitelist and "+
+			"metric_label_blacklist, %s", err)
+	}
+	a.tagLimiter = models.NewTagLimiter(a.Config.Agent.MaxTagValuesPerKey,
+		a.Config.Agent.MaxTagKeyCardinality,
+		a.Config.Agent.TagCardinalityLimitAction)
+	if a.Config.Agent.MetricTimestampSource == "ntp" {
+		a.clock = newNTPClock(a.Config.Agent.MetricTimestampNTPServer,
+			a.Config.Agent.Interval.Duration)
+	}
+
+	for _, input := range a.Config.InputsSnapshot() {
+		if err := a.setPluginLogger("inputs", input.Name, input.Input); err != nil {
+			return nil, err
+		}
+	}
+	for _, output := range a.Config.OutputsSnapshot() {
+		if err := a.setPluginLogger("outputs", output.Name, output.Output); err != nil {
+			return nil, err
+		}
+	}
+
 	if !a.Config.Agent.OmitHostname {
 		if a.Config.Agent.Hostname == "" {
-			hostname, err := os.Hostname()
+			hostname, err := lookupHostname(a.Config.Agent.HostnameLookup)
 			if err != nil {
 				return nil, err
 			}
@@ -35,51 +112,130 @@ func NewAgent(config *config.Config) (*Agent, error) {
 			a.Config.Agent.Hostname = hostname
 		}
 
-		config.Tags["host"] = a.Config.Agent.Hostname
+		config.Tags["host"] = decorateHostname(a.Config.Agent.Hostname,
+			a.Config.Agent.HostnamePrefix, a.Config.Agent.HostnameSuffix)
 	}
 
 	return a, nil
 }
 
+// setPluginLogger gives a logger to a plugin implementing
+// telegraf.LoggerSetter, with its level from per_plugin_log_level.
+func (a *Agent) setPluginLogger(
+	pluginType string,
+	name string,
+	plugin interface{},
+) error {
+	setter, ok := plugin.(telegraf.LoggerSetter)
+	if !ok {
+		return nil
+	}
+	level, ok := a.Config.Agent.PerPluginLogLevel[pluginType+"."+name]
+	if !ok {
+		level = a.Config.Agent.PerPluginLogLevel[name]
+	}
+	l, err := logger.NewPluginLogger(level)
+	if err != nil {
+		return fmt.Errorf("Error setting the logger of %s.%s, %s",
+			pluginType, name, err)
+	}
+	setter.SetLogger(l)
+	return nil
+}
+
 // Connect connects to all configured outputs
 func (a *Agent) Connect() error {
-	for _, o := range a.Config.Outputs {
+	var outputs []*models.RunningOutput
+	for _, o := range a.Config.OutputsSnapshot() {
 		o.Quiet = a.Config.Agent.Quiet
 
-		switch ot := o.Output.(type) {
-		case telegraf.ServiceOutput:
-			if err := ot.Start(); err != nil {
-				log.Printf("E! Service for output %s failed to start, exiting\n%s\n",
-					o.Name, err.Error())
+		started, err := startOutput(o)
+		if err != nil {
+			interval := a.startupRetryInterval(o.Config.StartupRetryInterval)
+			switch {
+			case interval > 0:
+				log.Printf("E! Output %s failed to start, retrying every %s\n",
+					o.Name, interval)
+				o.SetConnectRetry(interval, retryOutput(o, started))
+			case a.Config.Agent.StartupErrorBehavior == "skip":
+				log.Printf("E! Output %s failed to start, skipping it\n", o.Name)
+				continue
+			default:
 				return err
 			}
 		}
+		outputs = append(outputs, o)
+	}
+	a.Config.SetOutputs(outputs)
+	return nil
+}
 
-		log.Printf("D! Attempting connection to output: %s\n", o.Name)
-		err := o.Output.Connect()
+// startupRetryInterval returns how often a plugin that failed to start is
+// retried, given its own startup_retry_interval. 0 means it is not retried.
+func (a *Agent) startupRetryInterval(interval time.Duration) time.Duration {
+	if interval > 0 {
+		return interval
+	}
+	if a.Config.Agent.StartupErrorBehavior == "retry" {
+		return a.Config.Agent.StartupRetryInterval.Duration
+	}
+	return 0
+}
+
+// startOutput starts the service of a service output, and connects the
+// output. started reports whether the service could be started.
+func startOutput(o *models.RunningOutput) (started bool, err error) {
+	switch ot := o.Output.(type) {
+	case telegraf.ServiceOutput:
+		if err := ot.Start(); err != nil {
+			log.Printf("E! Service for output %s failed to start\n%s\n",
+				o.Name, err.Error())
+			return false, err
+		}
+	}
+
+	log.Printf("D! Attempting connection to output: %s\n", o.Name)
+	err = o.Output.Connect()
+	if err != nil {
+		log.Printf("E! Failed to connect to output %s, retrying in 15s, "+
+			"error was '%s' \n", o.Name, err)
+		time.Sleep(15 * time.Second)
+		err = o.Output.Connect()
 		if err != nil {
-			log.Printf("E! Failed to connect to output %s, retrying in 15s, "+
-				"error was '%s' \n", o.Name, err)
-			time.Sleep(15 * time.Second)
-			err = o.Output.Connect()
-			if err != nil {
+			return true, err
+		}
+	}
+	log.Printf("D! Successfully connected to output: %s\n", o.Name)
+	return true, nil
+}
+
+// retryOutput returns a function finishing the start of an output that
+// startOutput failed to start.
+func retryOutput(o *models.RunningOutput, started bool) func() error {
+	return func() error {
+		if so, ok := o.Output.(telegraf.ServiceOutput); ok && !started {
+			if err := so.Start(); err != nil {
 				return err
 			}
+			started = true
 		}
-		log.Printf("D! Successfully connected to output: %s\n", o.Name)
+		return o.Output.Connect()
 	}
-	return nil
 }
 
 // Close closes the connection to all configured outputs
 func (a *Agent) Close() error {
 	var err error
-	for _, o := range a.Config.Outputs {
-		err = o.Output.Close()
-		switch ot := o.Output.(type) {
-		case telegraf.ServiceOutput:
-			ot.Stop()
-		}
+	for _, o := range a.Config.OutputsSnapshot() {
+		// outputs disabled at runtime are already closed
+		output := o
+		o.Pause(func() {
+			err = output.Output.Close()
+			switch ot := output.Output.(type) {
+			case telegraf.ServiceOutput:
+				ot.Stop()
+			}
+		})
 	}
 	return err
 }
@@ -96,6 +252,15 @@ func panicRecover(input *models.RunningInput) {
 	}
 }
 
+// now returns the collection time of metrics, corrected by NTP with
+// metric_timestamp_source = "ntp".
+func (a *Agent) now() time.Time {
+	if a.clock != nil {
+		return a.clock.Now()
+	}
+	return time.Now()
+}
+
 // gatherer runs the inputs that have been configured with their own
 // reporting interval.
 func (a *Agent) gatherer(
@@ -112,16 +277,65 @@ func (a *Agent) gatherer(
 	for {
 		var outerr error
 
+		// inputs disabled at runtime are skipped, and the services of
+		// service inputs enabled again are restarted, see
+		// config.DisablePlugin
+		if input.Paused() {
+			select {
+			case <-shutdown:
+				return nil
+			case <-ticker.C:
+				continue
+			}
+		}
+		// a gather abandoned at its collection timeout may still be
+		// running, the input is not gathered from again until it returns
+		if input.Gathering() {
+			log.Printf("E! ERROR: input [%s] is still running its abandoned "+
+				"collection, skipping this interval\n", input.Name)
+			select {
+			case <-shutdown:
+				return nil
+			case <-ticker.C:
+				continue
+			}
+		}
+		err := input.RestartService(func() error {
+			return a.startServiceInput(input, metricC)
+		})
+		if err != nil {
+			log.Printf("E! Service for input %s failed to restart: %s\n",
+				input.Name, err)
+		}
+
 		acc := NewAccumulator(input.Config, metricC)
 		acc.SetPrecision(a.Config.Agent.Precision.Duration,
 			a.Config.Agent.Interval.Duration)
-		acc.setDefaultTags(a.Config.Tags)
+		acc.setGlobalTags(a.Config)
+		acc.setTimestampSource(a.Config.Agent.MetricTimestampSource, a.now)
+		acc.setBuffer(input)
+		acc.setDedup(input)
 
 		internal.RandomSleep(a.Config.Agent.CollectionJitter.Duration, shutdown)
 
-		start := time.Now()
-		gatherWithTimeout(shutdown, input, acc, interval)
-		elapsed := time.Since(start)
+		var (
+			elapsed  time.Duration
+			gathered bool
+		)
+		queued := time.Now()
+		gather := func() {
+			elapsed, gathered = a.gatherOnce(shutdown, input, acc, interval,
+				queued)
+		}
+		if a.workers != nil {
+			a.workers.dispatch(shutdown, gather)
+		} else {
+			gather()
+		}
+		if !gathered {
+			return nil
+		}
+		input.RecordGather(elapsed, atomic.LoadUint64(&acc.errCount))
 
 		if outerr != nil {
 			return outerr
@@ -138,24 +352,78 @@ func (a *Agent) gatherer(
 	}
 }
 
+// gatherOnce gathers from the given input once there is room for it, see
+// max_goroutines and collection_concurrency, and returns how long it
+// gathered for. It returns false, without gathering, if shutdown is closed
+// while waiting. queued is when the gather was due.
+func (a *Agent) gatherOnce(
+	shutdown chan struct{},
+	input *models.RunningInput,
+	acc *accumulator,
+	interval time.Duration,
+	queued time.Time,
+) (time.Duration, bool) {
+	release := func() {}
+	if a.gatherSem != nil {
+		var ok bool
+		if release, ok = a.gatherSem.acquire(shutdown); !ok {
+			return 0, false
+		}
+	}
+	defer release()
+	if a.collectSem != nil {
+		select {
+		case a.collectSem <- struct{}{}:
+		case <-shutdown:
+			return 0, false
+		}
+		defer func() { <-a.collectSem }()
+	}
+
+	if a.clock != nil {
+		a.clock.Sync()
+	}
+	start := time.Now()
+	gatherWithTimeout(shutdown, input, acc, interval, start.Sub(queued))
+	return time.Since(start), true
+}
+
 // gatherWithTimeout gathers from the given input, with the given timeout.
 //   when the given timeout is reached, gatherWithTimeout logs an error message
 //   but continues waiting for it to return. This is to avoid leaving behind
 //   hung processes, and to prevent re-calling the same hung process over and
-//   over.
+//   over. waited is how long the gather waited for its turn, see
+//   collection_concurrency, and counts towards the first timeout. The input
+//   is marked as gathering until the gather returns, even once abandoned.
 func gatherWithTimeout(
 	shutdown chan struct{},
 	input *models.RunningInput,
 	acc *accumulator,
 	timeout time.Duration,
+	waited time.Duration,
 ) {
-	ticker := time.NewTicker(timeout)
-	defer ticker.Stop()
-	done := make(chan error)
+	first := timeout - waited
+	if first < 0 {
+		first = 0
+	}
+	warn := time.NewTimer(first)
+	defer warn.Stop()
+	// done is buffered, so that an abandoned gather can still return.
+	done := make(chan error, 1)
+	input.SetGathering(true)
 	go func() {
-		done <- input.Input.Gather(acc)
+		err := input.Input.Gather(acc)
+		input.SetGathering(false)
+		done <- err
 	}()
 
+	var expired <-chan time.Time
+	if input.Config.CollectionTimeout > 0 {
+		timer := time.NewTimer(input.Config.CollectionTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
 	for {
 		select {
 		case err := <-done:
@@ -163,17 +431,57 @@ func gatherWithTimeout(
 				log.Printf("E! ERROR in input [%s]: %s", input.Name, err)
 			}
 			return
-		case <-ticker.C:
+		case <-warn.C:
 			log.Printf("E! ERROR: input [%s] took longer to collect than "+
 				"collection interval (%s)",
 				input.Name, timeout)
+			warn.Reset(timeout)
 			continue
+		case <-expired:
+			log.Printf("E! ERROR: input [%s] did not complete within its "+
+				"collection timeout (%s), abandoning it",
+				input.Name, input.Config.CollectionTimeout)
+			return
 		case <-shutdown:
 			return
 		}
 	}
 }
 
+// retryServiceInput retries to start the service of a service input every
+// startup_retry_interval, until it starts, it has been retried
+// startup_retry_max times, or shutdown is closed. It returns true if the
+// service was started.
+func (a *Agent) retryServiceInput(
+	shutdown chan struct{},
+	input *models.RunningInput,
+	metricC chan telegraf.Metric,
+) bool {
+	interval := a.startupRetryInterval(input.Config.StartupRetryInterval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for retries := 1; ; retries++ {
+		select {
+		case <-shutdown:
+			return false
+		case <-ticker.C:
+		}
+
+		err := a.startServiceInput(input, metricC)
+		if err == nil {
+			log.Printf("I! Service for input %s started\n", input.Name)
+			return true
+		}
+		if max := input.Config.StartupRetryMax; max > 0 && retries >= max {
+			log.Printf("E! Service for input %s failed to start after %d "+
+				"retries, giving up\n%s\n", input.Name, max, err.Error())
+			return false
+		}
+		log.Printf("E! Service for input %s failed to start, retrying in %s\n%s\n",
+			input.Name, interval, err.Error())
+	}
+}
+
 // Test verifies that we can 'Gather' from all inputs with their configured
 // Config struct
 func (a *Agent) Test() error {
@@ -193,12 +501,16 @@ func (a *Agent) Test() error {
 		}
 	}()
 
-	for _, input := range a.Config.Inputs {
+	if a.clock != nil {
+		a.clock.Sync()
+	}
+	for _, input := range a.Config.InputsSnapshot() {
 		acc := NewAccumulator(input.Config, metricC)
 		acc.SetTrace(true)
 		acc.SetPrecision(a.Config.Agent.Precision.Duration,
 			a.Config.Agent.Interval.Duration)
-		acc.setDefaultTags(a.Config.Tags)
+		acc.setGlobalTags(a.Config)
+		acc.setTimestampSource(a.Config.Agent.MetricTimestampSource, a.now)
 
 		fmt.Printf("* Plugin: %s, Collection 1\n", input.Name)
 		if input.Config.Interval != 0 {
@@ -208,7 +520,7 @@ func (a *Agent) Test() error {
 		if err := input.Input.Gather(acc); err != nil {
 			return err
 		}
-		if acc.errCount > 0 {
+		if atomic.LoadUint64(&acc.errCount) > 0 {
 			return fmt.Errorf("Errors encountered during processing")
 		}
 
@@ -231,8 +543,9 @@ func (a *Agent) Test() error {
 func (a *Agent) flush() {
 	var wg sync.WaitGroup
 
-	wg.Add(len(a.Config.Outputs))
-	for _, o := range a.Config.Outputs {
+	outputs := a.Config.OutputsSnapshot()
+	wg.Add(len(outputs))
+	for _, o := range outputs {
 		go func(output *models.RunningOutput) {
 			defer wg.Done()
 			err := output.Write()
@@ -263,9 +576,24 @@ func (a *Agent) flusher(shutdown chan struct{}, metricC chan telegraf.Metric) er
 		case <-ticker.C:
 			internal.RandomSleep(a.Config.Agent.FlushJitter.Duration, shutdown)
 			a.flush()
+		case change := <-a.outputChanges:
+			a.applyOutputChange(change)
 		case m := <-metricC:
-			for i, o := range a.Config.Outputs {
-				if i == len(a.Config.Outputs)-1 {
+			m = replaceName(m, a.Config.Agent.MetricNameReplace)
+			if m = dropFields(m, a.omitFields); m == nil {
+				continue
+			}
+			if m = a.labelPolicy.Apply(m); m == nil {
+				continue
+			}
+			if m = a.tagLimiter.Apply(m); m == nil {
+				continue
+			}
+			m = renameFields(m, a.Config.Agent.GlobalFieldPrefix,
+				a.Config.Agent.GlobalFieldSuffix)
+			outputs := routedOutputs(a.Config.OutputsSnapshot())
+			for i, o := range outputs {
+				if i == len(outputs)-1 {
 					o.AddMetric(m)
 				} else {
 					o.AddMetric(copyMetric(m))
@@ -275,6 +603,19 @@ func (a *Agent) flusher(shutdown chan struct{}, metricC chan telegraf.Metric) er
 	}
 }
 
+// routedOutputs returns the outputs that the metrics of the inputs are sent
+// to: outputs on standby only receive the metrics of the outputs failing over
+// to them.
+func routedOutputs(outputs []*models.RunningOutput) []*models.RunningOutput {
+	routed := make([]*models.RunningOutput, 0, len(outputs))
+	for _, o := range outputs {
+		if !o.Standby() {
+			routed = append(routed, o)
+		}
+	}
+	return routed
+}
+
 func copyMetric(m telegraf.Metric) telegraf.Metric {
 	t := time.Time(m.Time())
 
@@ -291,6 +632,92 @@ func copyMetric(m telegraf.Metric) telegraf.Metric {
 	return out
 }
 
+// renameFields adds prefix and suffix to the field names of a metric.
+func renameFields(m telegraf.Metric, prefix, suffix string) telegraf.Metric {
+	if prefix == "" && suffix == "" {
+		return m
+	}
+	fields := make(map[string]interface{})
+	for k, v := range m.Fields() {
+		fields[prefix+k+suffix] = v
+	}
+
+	out, err := withFields(m, fields)
+	if err != nil {
+		log.Printf("E! Could not rename the fields of %s: %s\n", m.Name(), err)
+		return m
+	}
+	return out
+}
+
+// replaceName renames a metric with the first of rules matching its name.
+func replaceName(m telegraf.Metric, rules []models.NameReplace) telegraf.Metric {
+	name := models.ReplaceName(rules, m.Name())
+	if name == m.Name() {
+		return m
+	}
+
+	var out telegraf.Metric
+	var err error
+	switch m.Type() {
+	case telegraf.Gauge:
+		out, err = telegraf.NewGaugeMetric(name, m.Tags(), m.Fields(), m.Time())
+	case telegraf.Counter:
+		out, err = telegraf.NewCounterMetric(name, m.Tags(), m.Fields(), m.Time())
+	default:
+		out, err = telegraf.NewMetric(name, m.Tags(), m.Fields(), m.Time())
+	}
+	if err != nil {
+		log.Printf("E! Could not rename %s to %s: %s\n", m.Name(), name, err)
+		return m
+	}
+	return out
+}
+
+// dropFields removes the fields matching omit from a metric. It returns nil if
+// no field is left.
+func dropFields(m telegraf.Metric, omit filter.Filter) telegraf.Metric {
+	if omit == nil {
+		return m
+	}
+	fields := make(map[string]interface{})
+	for k, v := range m.Fields() {
+		if !omit.Match(k) {
+			fields[k] = v
+		}
+	}
+	if len(fields) == len(m.Fields()) {
+		return m
+	}
+	if len(fields) == 0 {
+		log.Printf("D! Dropped metric %s, omit_fields matched all of its "+
+			"fields\n", m.Name())
+		return nil
+	}
+
+	out, err := withFields(m, fields)
+	if err != nil {
+		log.Printf("E! Could not omit the fields of %s, dropping it: %s\n",
+			m.Name(), err)
+		return nil
+	}
+	return out
+}
+
+// withFields returns a copy of m, of the same type, with the given fields.
+func withFields(
+	m telegraf.Metric,
+	fields map[string]interface{},
+) (telegraf.Metric, error) {
+	switch m.Type() {
+	case telegraf.Gauge:
+		return telegraf.NewGaugeMetric(m.Name(), m.Tags(), fields, m.Time())
+	case telegraf.Counter:
+		return telegraf.NewCounterMetric(m.Name(), m.Tags(), fields, m.Time())
+	}
+	return telegraf.NewMetric(m.Name(), m.Tags(), fields, m.Time())
+}
+
 // Run runs the agent daemon, gathering every Interval
 func (a *Agent) Run(shutdown chan struct{}) error {
 	var wg sync.WaitGroup
@@ -300,26 +727,113 @@ func (a *Agent) Run(shutdown chan struct{}) error {
 		a.Config.Agent.Interval.Duration, a.Config.Agent.Quiet,
 		a.Config.Agent.Hostname, a.Config.Agent.FlushInterval.Duration)
 
+	if a.Config.Agent.PidFile != "" {
+		remove, err := writePidFile(a.Config.Agent.PidFile)
+		if err != nil {
+			log.Printf("W! Could not write pid file %s: %s\n",
+				a.Config.Agent.PidFile, err)
+		} else {
+			defer remove()
+		}
+	}
+
 	// channel shared between all input threads for accumulating metrics
 	metricC := make(chan telegraf.Metric, 10000)
+	a.outputChanges = make(chan outputChange)
+
+	// the inputs of the config at startup, inputs added later by
+	// watchDirectories are started by it
+	configured := a.Config.InputsSnapshot()
+
+	if a.Config.Agent.InternalStatsdPort != 0 ||
+		a.Config.Agent.SelfMonitorInterval.Duration > 0 {
+		// channel shared between all inputs and outputs for reporting
+		// telegraf's own internal metrics
+		statsC := make(chan telegraf.Metric, 1000)
+		if a.tagLimiter != nil {
+			a.tagLimiter.InternalStats = statsC
+		}
+		a.internalStats = statsC
+		for _, input := range configured {
+			a.instrumentInput(input)
+		}
+		for _, output := range a.Config.OutputsSnapshot() {
+			a.instrumentOutput(output)
+		}
+
+		var consumers []chan telegraf.Metric
+		if a.Config.Agent.InternalStatsdPort != 0 {
+			statsdC := make(chan telegraf.Metric, 1000)
+			consumers = append(consumers, statsdC)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := a.internalStatsd(shutdown, statsdC); err != nil {
+					log.Printf("E! " + err.Error())
+				}
+			}()
+		}
+		if a.Config.Agent.SelfMonitorInterval.Duration > 0 {
+			selfC := make(chan telegraf.Metric, 1000)
+			consumers = append(consumers, selfC)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				a.selfMonitor(shutdown, selfC, metricC)
+			}()
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			teeStats(shutdown, statsC, consumers)
+		}()
+	}
 
-	for _, input := range a.Config.Inputs {
+	// each input runs until shutdown, or until it is stopped by the removal
+	// of its config file
+	stops := make(map[*models.RunningInput]*inputStop)
+	for _, input := range configured {
+		stops[input] = newInputStop(shutdown)
+	}
+
+	// move the metrics of inputs with their own buffer to the shared channel
+	for _, input := range configured {
+		a.dispatchBuffer(&wg, stops[input].C, input, metricC)
+	}
+
+	// service inputs that failed to start, and are retried
+	retried := make(map[*models.RunningInput]bool)
+	var inputs []*models.RunningInput
+	for _, input := range configured {
 		// Start service of any ServicePlugins
-		switch p := input.Input.(type) {
-		case telegraf.ServiceInput:
-			acc := NewAccumulator(input.Config, metricC)
-			// Service input plugins should set their own precision of their
-			// metrics.
-			acc.DisablePrecision()
-			acc.setDefaultTags(a.Config.Tags)
-			if err := p.Start(acc); err != nil {
+		if err := a.startServiceInput(input, metricC); err != nil {
+			interval := a.startupRetryInterval(input.Config.StartupRetryInterval)
+			switch {
+			case interval > 0:
+				log.Printf("E! Service for input %s failed to start, "+
+					"retrying every %s\n%s\n", input.Name, interval,
+					err.Error())
+				retried[input] = true
+			case a.Config.Agent.StartupErrorBehavior == "skip":
+				log.Printf("E! Service for input %s failed to start, "+
+					"skipping it\n%s\n", input.Name, err.Error())
+				stops[input].Stop()
+				continue
+			default:
 				log.Printf("E! Service for input %s failed to start, exiting\n%s\n",
 					input.Name, err.Error())
+				for _, in := range inputs {
+					if p, ok := in.Input.(telegraf.ServiceInput); ok && !retried[in] {
+						p.Stop()
+					}
+				}
 				return err
 			}
-			defer p.Stop()
 		}
+		inputs = append(inputs, input)
 	}
+	a.Config.SetInputs(inputs)
 
 	// Round collection to nearest interval by sleeping
 	if a.Config.Agent.RoundInterval {
@@ -336,19 +850,32 @@ func (a *Agent) Run(shutdown chan struct{}) error {
 		}
 	}()
 
-	wg.Add(len(a.Config.Inputs))
-	for _, input := range a.Config.Inputs {
-		interval := a.Config.Agent.Interval.Duration
-		// overwrite global interval if this plugin has it's own.
-		if input.Config.Interval != 0 {
-			interval = input.Config.Interval
-		}
-		go func(in *models.RunningInput, interv time.Duration) {
+	if a.Config.Agent.MaxGoroutines > 0 {
+		a.gatherSem = newTickSemaphore(a.Config.Agent.MaxGoroutines)
+		wg.Add(1)
+		go func() {
 			defer wg.Done()
-			if err := a.gatherer(shutdown, in, interv, metricC); err != nil {
-				log.Printf("E! " + err.Error())
-			}
-		}(input, interval)
+			a.gatherSem.run(shutdown, a.Config.Agent.Interval.Duration)
+		}()
+	}
+	if a.Config.Agent.CollectionConcurrency > 0 {
+		a.collectSem = make(chan struct{}, a.Config.Agent.CollectionConcurrency)
+	}
+	if a.Config.Agent.InputWorkers > 1 {
+		a.workers = newGatherWorkers(a.Config.Agent.InputWorkers)
+		a.workers.run(&wg, shutdown)
+	}
+
+	for _, input := range inputs {
+		a.startGatherer(&wg, stops[input].C, input, metricC, retried[input])
+	}
+
+	if a.Config.Agent.WatchDirectoryInterval.Duration > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.watchDirectories(shutdown, &wg, stops, metricC)
+		}()
 	}
 
 	wg.Wait()
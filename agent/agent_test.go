@@ -1,9 +1,20 @@
 package agent
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
 	"github.com/influxdata/telegraf/internal/config"
+	"github.com/influxdata/telegraf/internal/models"
 
 	// needing to load the plugins
 	_ "github.com/influxdata/telegraf/plugins/inputs/all"
@@ -11,6 +22,7 @@ import (
 	_ "github.com/influxdata/telegraf/plugins/outputs/all"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAgent_OmitHostname(t *testing.T) {
@@ -109,3 +121,333 @@ func TestAgent_LoadOutput(t *testing.T) {
 	a, _ = NewAgent(c)
 	assert.Equal(t, 3, len(a.Config.Outputs))
 }
+
+func TestAgent_RenameFields(t *testing.T) {
+	now := time.Now()
+	m, err := telegraf.NewGaugeMetric("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": float64(99)}, now)
+	assert.NoError(t, err)
+
+	assert.Equal(t, m, renameFields(m, "", ""))
+
+	out := renameFields(m, "app_", "_v1")
+	assert.Equal(t, "cpu", out.Name())
+	assert.Equal(t, map[string]string{"cpu": "cpu0"}, out.Tags())
+	assert.Equal(t, map[string]interface{}{"app_usage_idle_v1": float64(99)},
+		out.Fields())
+	assert.Equal(t, telegraf.Gauge, out.Type())
+	assert.Equal(t, now.UnixNano(), out.UnixNano())
+}
+
+func TestAgent_DropFields(t *testing.T) {
+	now := time.Now()
+	m, err := telegraf.NewCounterMetric("login",
+		map[string]string{"host": "a"},
+		map[string]interface{}{
+			"count":         int64(3),
+			"password":      "hunter2",
+			"internal_hits": int64(1),
+		}, now)
+	assert.NoError(t, err)
+
+	assert.Equal(t, m, dropFields(m, nil))
+
+	omit, err := filter.Compile([]string{"password", "internal_*"})
+	assert.NoError(t, err)
+	out := dropFields(m, omit)
+	assert.Equal(t, map[string]interface{}{"count": int64(3)}, out.Fields())
+	assert.Equal(t, map[string]string{"host": "a"}, out.Tags())
+	assert.Equal(t, telegraf.Counter, out.Type())
+
+	omit, err = filter.Compile([]string{"*"})
+	assert.NoError(t, err)
+	assert.Nil(t, dropFields(m, omit))
+}
+
+func TestAgent_OmitFieldsInvalid(t *testing.T) {
+	c := config.NewConfig()
+	c.Agent.OmitHostname = true
+	c.Agent.OmitFields = []string{"[a-"}
+	_, err := NewAgent(c)
+	assert.Error(t, err)
+}
+
+// slowInput blocks in Gather until release is closed, counting its gathers.
+type slowInput struct {
+	release chan struct{}
+	gathers int32
+}
+
+func (i *slowInput) SampleConfig() string { return "" }
+func (i *slowInput) Description() string  { return "" }
+func (i *slowInput) Gather(acc telegraf.Accumulator) error {
+	atomic.AddInt32(&i.gathers, 1)
+	<-i.release
+	return nil
+}
+
+func TestGatherWithTimeout_CollectionTimeout(t *testing.T) {
+	slow := &slowInput{release: make(chan struct{})}
+	defer close(slow.release)
+	input := &models.RunningInput{
+		Name:  "slow",
+		Input: slow,
+		Config: &models.InputConfig{
+			Name:              "slow",
+			CollectionTimeout: 10 * time.Millisecond,
+		},
+	}
+	acc := NewAccumulator(input.Config, make(chan telegraf.Metric, 10))
+
+	done := make(chan struct{})
+	go func() {
+		gatherWithTimeout(make(chan struct{}), input, acc, time.Hour, 0)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("gather was not abandoned after its collection timeout")
+	}
+}
+
+func TestAgent_GathererSkipsAbandonedGather(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	slow := &slowInput{release: make(chan struct{})}
+	input := &models.RunningInput{
+		Name:  "slow",
+		Input: slow,
+		Config: &models.InputConfig{
+			Name:              "slow",
+			CollectionTimeout: 10 * time.Millisecond,
+		},
+	}
+	c := config.NewConfig()
+	c.Agent.OmitHostname = true
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	shutdown := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		a.gatherer(shutdown, input, 20*time.Millisecond,
+			make(chan telegraf.Metric, 10))
+		close(done)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	// the abandoned gather still runs, no other gather was started
+	assert.True(t, input.Gathering())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&slow.gathers))
+
+	// once it returns, the input is gathered from again
+	close(slow.release)
+	time.Sleep(100 * time.Millisecond)
+	close(shutdown)
+	<-done
+	assert.True(t, atomic.LoadInt32(&slow.gathers) > 1)
+}
+
+func TestGatherWithTimeout_Waited(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	slow := &slowInput{release: make(chan struct{})}
+	input := &models.RunningInput{
+		Name:   "slow",
+		Input:  slow,
+		Config: &models.InputConfig{Name: "slow"},
+	}
+	acc := NewAccumulator(input.Config, make(chan telegraf.Metric, 10))
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(slow.release)
+	}()
+	// waiting a whole interval for its turn leaves no time to gather
+	gatherWithTimeout(make(chan struct{}), input, acc, time.Hour, time.Hour)
+	assert.Contains(t, buf.String(),
+		"input [slow] took longer to collect than collection interval")
+}
+
+// loggingInput is an input with a logger of its own.
+type loggingInput struct {
+	logger *log.Logger
+}
+
+func (i *loggingInput) SampleConfig() string                  { return "" }
+func (i *loggingInput) Description() string                   { return "" }
+func (i *loggingInput) Gather(acc telegraf.Accumulator) error { return nil }
+func (i *loggingInput) SetLogger(logger *log.Logger)          { i.logger = logger }
+
+func TestAgent_PerPluginLogLevel(t *testing.T) {
+	c := config.NewConfig()
+	c.Agent.OmitHostname = true
+	c.Agent.PerPluginLogLevel = map[string]string{"inputs.noisy": "debug"}
+	noisy := &loggingInput{}
+	quiet := &loggingInput{}
+	c.Inputs = append(c.Inputs,
+		&models.RunningInput{Name: "noisy", Input: noisy},
+		&models.RunningInput{Name: "quiet", Input: quiet})
+	_, err := NewAgent(c)
+	assert.NoError(t, err)
+	assert.NotNil(t, noisy.logger)
+	assert.NotNil(t, quiet.logger)
+
+	c.Agent.PerPluginLogLevel["quiet"] = "verbose"
+	_, err = NewAgent(c)
+	assert.Error(t, err)
+}
+
+// failingService is a service input whose service never starts.
+type failingService struct {
+	starts int
+}
+
+func (i *failingService) SampleConfig() string                  { return "" }
+func (i *failingService) Description() string                   { return "" }
+func (i *failingService) Gather(acc telegraf.Accumulator) error { return nil }
+func (i *failingService) Stop()                                 {}
+func (i *failingService) Start(acc telegraf.Accumulator) error {
+	i.starts++
+	return errors.New("connection refused")
+}
+
+func TestAgent_RetryServiceInputMax(t *testing.T) {
+	c := config.NewConfig()
+	service := &failingService{}
+	input := &models.RunningInput{
+		Name:  "failing",
+		Input: service,
+		Config: &models.InputConfig{
+			Name:                 "failing",
+			StartupRetryInterval: time.Millisecond,
+			StartupRetryMax:      3,
+		},
+	}
+	a := &Agent{Config: c}
+
+	done := make(chan bool)
+	go func() {
+		done <- a.retryServiceInput(make(chan struct{}), input,
+			make(chan telegraf.Metric, 10))
+	}()
+	select {
+	case started := <-done:
+		assert.False(t, started)
+	case <-time.After(5 * time.Second):
+		t.Fatal("service input was retried past startup_retry_max")
+	}
+	assert.Equal(t, 3, service.starts)
+}
+
+func TestAgent_HostnamePrefixSuffix(t *testing.T) {
+	c := config.NewConfig()
+	c.Agent.Hostname = "myhost"
+	c.Agent.HostnamePrefix = "dc1-"
+	c.Agent.HostnameSuffix = "-prod"
+	_, err := NewAgent(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "dc1-myhost-prod", c.Tags["host"])
+
+	// the hostname is decorated only once
+	_, err = NewAgent(c)
+	assert.NoError(t, err)
+	assert.Equal(t, "dc1-myhost-prod", c.Tags["host"])
+}
+
+func TestAgent_TagPreprocessing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-tags")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	// the rule is not idempotent, it must be applied once
+	main := filepath.Join(dir, "telegraf.conf")
+	require.NoError(t, ioutil.WriteFile(main, []byte(`
+[global_tags]
+  dc = "us-east"
+
+[agent]
+  omit_hostname = true
+  [[agent.tag_preprocessing]]
+    key = "dc"
+    action = "replace"
+    args = ["-", "--"]
+`), 0644))
+	confd := filepath.Join(dir, "telegraf.d")
+	require.NoError(t, os.Mkdir(confd, 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(confd, "mem.conf"),
+		[]byte("[[inputs.mem]]\n"), 0644))
+
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfig(main))
+	require.NoError(t, c.LoadDirectory(confd))
+	assert.Equal(t, "us-east", c.Tags["dc"])
+	_, err = NewAgent(c)
+	require.NoError(t, err)
+	assert.Equal(t, "us--east", c.Tags["dc"])
+
+	// nor again when the directory is rescanned
+	require.NoError(t, ioutil.WriteFile(filepath.Join(confd, "swap.conf"),
+		[]byte("[[inputs.swap]]\n"), 0644))
+	change, err := c.RescanDirectories()
+	require.NoError(t, err)
+	assert.Len(t, change.AddedInputs, 1)
+	assert.Equal(t, "us--east", c.Tags["dc"])
+}
+
+func TestAgent_FlushWhileAddingOutputs(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	c := config.NewConfig()
+	c.Agent.OmitHostname = true
+	a, err := NewAgent(c)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			assert.NoError(t, c.AddOutputFromTOML(
+				"[[outputs.file]]\n  files = [\"stdout\"]\n"))
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		a.flush()
+	}
+	<-done
+	assert.NoError(t, a.Close())
+	assert.Len(t, c.OutputsSnapshot(), 20)
+}
+
+func TestAgent_RoutedOutputs(t *testing.T) {
+	primary := models.NewRunningOutput("primary", nil,
+		&models.OutputConfig{FailoverThreshold: 1}, 0, 0)
+	secondary := models.NewRunningOutput("secondary", nil,
+		&models.OutputConfig{}, 0, 0)
+	other := models.NewRunningOutput("other", nil, &models.OutputConfig{}, 0, 0)
+	primary.SetFailover(secondary)
+
+	outputs := []*models.RunningOutput{primary, secondary, other}
+	assert.Equal(t, []*models.RunningOutput{primary, other},
+		routedOutputs(outputs))
+}
+
+func TestAgent_ApplyOutputChangeLink(t *testing.T) {
+	c := config.NewConfig()
+	a := &Agent{Config: c}
+	primary := models.NewRunningOutput("primary", nil,
+		&models.OutputConfig{FailoverTo: "backup", FailoverThreshold: 1}, 0, 0)
+	backup := models.NewRunningOutput("backup", nil,
+		&models.OutputConfig{Alias: "backup"}, 0, 0)
+
+	// outputs of a rescan are linked once they are all added
+	a.applyOutputChange(outputChange{output: primary})
+	a.applyOutputChange(outputChange{output: backup})
+	assert.False(t, backup.Standby())
+	a.applyOutputChange(outputChange{link: true})
+	assert.True(t, backup.Standby())
+}
@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"sync"
+)
+
+// gatherWorkers is a pool of workers that the gathers of all inputs are
+// dispatched to, see the input_workers agent option. The semaphores of
+// max_goroutines and collection_concurrency are acquired by the gathers once
+// they run on a worker, so they still limit them.
+type gatherWorkers struct {
+	size int
+	jobs chan func()
+}
+
+func newGatherWorkers(size int) *gatherWorkers {
+	return &gatherWorkers{
+		size: size,
+		jobs: make(chan func()),
+	}
+}
+
+// run starts the workers, which run the gathers dispatched to them until
+// shutdown is closed.
+func (w *gatherWorkers) run(wg *sync.WaitGroup, shutdown chan struct{}) {
+	for i := 0; i < w.size; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-shutdown:
+					return
+				case job := <-w.jobs:
+					job()
+				}
+			}
+		}()
+	}
+}
+
+// dispatch runs gather on the first free worker, and waits for it to return.
+// If shutdown is closed before a worker is free, gather is not run and
+// dispatch returns false.
+func (w *gatherWorkers) dispatch(shutdown chan struct{}, gather func()) bool {
+	done := make(chan struct{})
+	job := func() {
+		defer close(done)
+		gather()
+	}
+	select {
+	case w.jobs <- job:
+	case <-shutdown:
+		return false
+	}
+	<-done
+	return true
+}
@@ -0,0 +1,50 @@
+package agent
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGatherWorkers(t *testing.T) {
+	shutdown := make(chan struct{})
+	var wg sync.WaitGroup
+	w := newGatherWorkers(2)
+	w.run(&wg, shutdown)
+
+	var (
+		lock    sync.Mutex
+		running int
+		max     int
+	)
+	release := make(chan struct{})
+	var gathers sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		gathers.Add(1)
+		go func() {
+			defer gathers.Done()
+			assert.True(t, w.dispatch(shutdown, func() {
+				lock.Lock()
+				running++
+				if running > max {
+					max = running
+				}
+				lock.Unlock()
+				<-release
+				lock.Lock()
+				running--
+				lock.Unlock()
+			}))
+		}()
+	}
+	close(release)
+	gathers.Wait()
+	assert.True(t, max <= 2)
+
+	close(shutdown)
+	wg.Wait()
+	assert.False(t, w.dispatch(shutdown, func() {
+		t.Error("gathered after shutdown")
+	}))
+}
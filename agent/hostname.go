@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// maxHostnameLength is the longest hostname allowed by DNS.
+const maxHostnameLength = 255
+
+// Resolver functions, replaced in tests.
+var (
+	osHostname  = os.Hostname
+	lookupCNAME = net.LookupCNAME
+	lookupIP    = net.LookupIP
+	lookupAddr  = net.LookupAddr
+)
+
+// lookupHostname returns the hostname used for the host tag, according to
+// the hostname_lookup agent option. "hostname" (or empty) returns
+// os.Hostname(), "fqdn" its fully qualified domain name, and "dns" the
+// reverse PTR name of its first A record.
+func lookupHostname(method string) (string, error) {
+	hostname, err := osHostname()
+	if err != nil {
+		return "", err
+	}
+
+	switch method {
+	case "", "hostname":
+		return hostname, nil
+	case "fqdn":
+		fqdn, err := lookupCNAME(hostname)
+		if err != nil {
+			return "", fmt.Errorf("Could not look up the FQDN of %s: %s",
+				hostname, err)
+		}
+		return strings.TrimSuffix(fqdn, "."), nil
+	case "dns":
+		ips, err := lookupIP(hostname)
+		if err != nil {
+			return "", fmt.Errorf("Could not resolve %s: %s", hostname, err)
+		}
+		for _, ip := range ips {
+			if ip.To4() == nil {
+				continue
+			}
+			names, err := lookupAddr(ip.String())
+			if err != nil || len(names) == 0 {
+				return "", fmt.Errorf("Could not reverse resolve %s (%s): %v",
+					hostname, ip, err)
+			}
+			return strings.TrimSuffix(names[0], "."), nil
+		}
+		return "", fmt.Errorf("No A record found for %s", hostname)
+	default:
+		return "", fmt.Errorf("Invalid hostname_lookup %q, must be "+
+			"\"hostname\", \"fqdn\" or \"dns\"", method)
+	}
+}
+
+// decorateHostname returns hostname with the hostname_prefix and
+// hostname_suffix agent options added, truncated to the DNS limit of 255
+// characters.
+func decorateHostname(hostname, prefix, suffix string) string {
+	hostname = prefix + hostname + suffix
+	if len(hostname) > maxHostnameLength {
+		hostname = hostname[:maxHostnameLength]
+	}
+	return hostname
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupHostname(t *testing.T) {
+	defer func() {
+		osHostname = os.Hostname
+		lookupCNAME = net.LookupCNAME
+		lookupIP = net.LookupIP
+		lookupAddr = net.LookupAddr
+	}()
+	osHostname = func() (string, error) { return "myhost", nil }
+	lookupCNAME = func(host string) (string, error) {
+		return host + ".example.com.", nil
+	}
+	lookupIP = func(host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("::1"), net.ParseIP("10.0.0.1")}, nil
+	}
+	lookupAddr = func(addr string) ([]string, error) {
+		if addr != "10.0.0.1" {
+			return nil, errors.New("unknown address")
+		}
+		return []string{"host-10-0-0-1.example.com."}, nil
+	}
+
+	for method, expected := range map[string]string{
+		"":         "myhost",
+		"hostname": "myhost",
+		"fqdn":     "myhost.example.com",
+		"dns":      "host-10-0-0-1.example.com",
+	} {
+		hostname, err := lookupHostname(method)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, hostname)
+	}
+
+	_, err := lookupHostname("nis")
+	assert.Error(t, err)
+
+	lookupIP = func(host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("::1")}, nil
+	}
+	_, err = lookupHostname("dns")
+	assert.Error(t, err)
+}
+
+func TestDecorateHostname(t *testing.T) {
+	assert.Equal(t, "myhost", decorateHostname("myhost", "", ""))
+	assert.Equal(t, "dc1-myhost-prod",
+		decorateHostname("myhost", "dc1-", "-prod"))
+
+	long := decorateHostname(strings.Repeat("a", 252), "", "-prod")
+	assert.Len(t, long, 255)
+	assert.Equal(t, "-pr", long[252:])
+}
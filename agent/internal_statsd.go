@@ -0,0 +1,176 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// maxStatsdSubscribers is how many addresses internal statsd sends to. UDP
+// senders that went away are not noticed, so they are never unsubscribed.
+const maxStatsdSubscribers = 64
+
+// internalStatsd receives telegraf's own internal metrics on statsC, and
+// listens on UDP localhost at the port given by the internal_statsd_port agent
+// option. Any datagram received on that port subscribes its sender, which is
+// then sent the metrics in statsd format once every flush interval. Only the
+// latest value of each statsd bucket is sent, as a gauge.
+func (a *Agent) internalStatsd(
+	shutdown chan struct{},
+	statsC chan telegraf.Metric,
+) error {
+	conn, err := listenInternalStatsd(a.Config.Agent.InternalStatsdPort)
+	if err != nil {
+		return err
+	}
+	log.Printf("I! Internal statsd listening on %s\n", conn.LocalAddr())
+	a.serveInternalStatsd(shutdown, conn, statsC)
+	return nil
+}
+
+// listenInternalStatsd listens on UDP localhost at port.
+func listenInternalStatsd(port int) (*net.UDPConn, error) {
+	addr := fmt.Sprintf("localhost:%d", port)
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Could not resolve internal statsd address "+
+			"%s: %s", addr, err)
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, fmt.Errorf("Could not listen for internal statsd on %s: %s",
+			addr, err)
+	}
+	return conn, nil
+}
+
+// serveInternalStatsd subscribes the senders of the datagrams received on
+// conn, and sends them the metrics of statsC every flush interval, until
+// shutdown is closed. It closes conn.
+func (a *Agent) serveInternalStatsd(
+	shutdown chan struct{},
+	conn *net.UDPConn,
+	statsC chan telegraf.Metric,
+) {
+	subscribeC := make(chan *net.UDPAddr)
+	go readStatsdSubscribers(shutdown, conn, subscribeC)
+	// closing conn stops readStatsdSubscribers
+	defer conn.Close()
+
+	ticker := time.NewTicker(a.Config.Agent.FlushInterval.Duration)
+	defer ticker.Stop()
+
+	subscribers := make(map[string]*net.UDPAddr)
+	gauges := make(map[string]interface{})
+	for {
+		select {
+		case <-shutdown:
+			return
+		case sub := <-subscribeC:
+			if _, ok := subscribers[sub.String()]; ok {
+				continue
+			}
+			if len(subscribers) >= maxStatsdSubscribers {
+				log.Printf("E! Not subscribing %s to internal statsd, it "+
+					"already has %d subscribers\n", sub, maxStatsdSubscribers)
+				continue
+			}
+			log.Printf("D! Internal statsd subscriber %s\n", sub)
+			subscribers[sub.String()] = sub
+		case m := <-statsC:
+			for bucket, value := range statsdGauges(m) {
+				gauges[bucket] = value
+			}
+		case <-ticker.C:
+			lines := statsdLines(gauges)
+			for key, sub := range subscribers {
+				if err := writeStatsdLines(conn, sub, lines); err != nil {
+					log.Printf("E! Error writing internal statsd metrics to "+
+						"%s, unsubscribing it: %s\n", sub, err)
+					delete(subscribers, key)
+				}
+			}
+			gauges = make(map[string]interface{})
+		}
+	}
+}
+
+// readStatsdSubscribers sends the sender of every datagram received on conn
+// to subscribeC, until conn is closed.
+func readStatsdSubscribers(
+	shutdown chan struct{},
+	conn *net.UDPConn,
+	subscribeC chan *net.UDPAddr,
+) {
+	buf := make([]byte, 512)
+	for {
+		_, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// the listener was closed
+			return
+		}
+		select {
+		case subscribeC <- addr:
+		case <-shutdown:
+			return
+		}
+	}
+}
+
+// writeStatsdLines sends the statsd lines to addr, one per datagram.
+func writeStatsdLines(conn *net.UDPConn, addr *net.UDPAddr, lines []string) error {
+	for _, line := range lines {
+		if _, err := conn.WriteToUDP([]byte(line), addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statsdGauges converts a metric into statsd gauge buckets. The bucket name
+// is built from "telegraf", the measurement name, the tag values sorted by
+// tag key and the field name, joined with dots.
+func statsdGauges(m telegraf.Metric) map[string]interface{} {
+	var keys []string
+	for k := range m.Tags() {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{"telegraf", m.Name()}
+	for _, k := range keys {
+		parts = append(parts, statsdSanitize(m.Tags()[k]))
+	}
+	prefix := strings.Join(parts, ".")
+
+	gauges := make(map[string]interface{})
+	for field, value := range m.Fields() {
+		switch value.(type) {
+		case int64, uint64, float64:
+			gauges[prefix+"."+field] = value
+		}
+	}
+	return gauges
+}
+
+// statsdLines returns the statsd gauge lines for the given buckets, sorted by
+// bucket name.
+func statsdLines(gauges map[string]interface{}) []string {
+	var lines []string
+	for bucket, value := range gauges {
+		lines = append(lines, fmt.Sprintf("%s:%v|g", bucket, value))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// statsdSanitize replaces characters that have a meaning in the statsd
+// protocol or in bucket names.
+func statsdSanitize(s string) string {
+	return strings.NewReplacer(".", "_", ":", "_", "|", "_", " ", "_").Replace(s)
+}
@@ -0,0 +1,89 @@
+package agent
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsdGauges(t *testing.T) {
+	m, _ := telegraf.NewMetric("internal_write",
+		map[string]string{"output": "influxdb.udp"},
+		map[string]interface{}{
+			"buffer_size":  int64(10),
+			"write_errors": int64(2),
+			"ignored":      "string",
+		},
+		time.Now(),
+	)
+
+	gauges := statsdGauges(m)
+	assert.Equal(t, map[string]interface{}{
+		"telegraf.internal_write.influxdb_udp.buffer_size":  int64(10),
+		"telegraf.internal_write.influxdb_udp.write_errors": int64(2),
+	}, gauges)
+
+	assert.Equal(t, []string{
+		"telegraf.internal_write.influxdb_udp.buffer_size:10|g",
+		"telegraf.internal_write.influxdb_udp.write_errors:2|g",
+	}, statsdLines(gauges))
+}
+
+func TestAgent_InternalStatsd(t *testing.T) {
+	// listen before subscribing, so that the subscription is not refused
+	listener, err := listenInternalStatsd(0)
+	require.NoError(t, err)
+
+	c := config.NewConfig()
+	c.Agent.FlushInterval.Duration = 10 * time.Millisecond
+	a := &Agent{Config: c}
+
+	shutdown := make(chan struct{})
+	statsC := make(chan telegraf.Metric, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.serveInternalStatsd(shutdown, listener, statsC)
+	}()
+
+	conn, err := net.DialUDP("udp", nil, listener.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("subscribe"))
+	require.NoError(t, err)
+
+	m, _ := telegraf.NewMetric("internal_write",
+		map[string]string{"output": "file"},
+		map[string]interface{}{"buffer_size": int64(10)},
+		time.Now(),
+	)
+	buf := make([]byte, 512)
+	var line string
+	deadline := time.Now().Add(5 * time.Second)
+	for line == "" && time.Now().Before(deadline) {
+		// the metric is dropped if it is flushed before the subscription is
+		// handled, so send it again until it is received
+		select {
+		case statsC <- m:
+		default:
+		}
+		conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if n, err := conn.Read(buf); err == nil {
+			line = string(buf[:n])
+		}
+	}
+	assert.Equal(t, "telegraf.internal_write.file.buffer_size:10|g", line)
+
+	close(shutdown)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("internal statsd did not stop")
+	}
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// ntpTimeout is how long an NTP server is waited for.
+const ntpTimeout = 5 * time.Second
+
+// ntpEpochOffset is the number of seconds between the NTP epoch, 1900, and
+// the Unix epoch, 1970.
+const ntpEpochOffset = 2208988800
+
+// ntpClock is the system clock, corrected by its offset to an NTP server.
+type ntpClock struct {
+	server string
+	// minSync is the minimum time between two queries of the server.
+	minSync time.Duration
+
+	sync.Mutex
+	offset time.Duration
+	synced time.Time
+}
+
+func newNTPClock(server string, minSync time.Duration) *ntpClock {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	return &ntpClock{server: server, minSync: minSync}
+}
+
+// Now returns the current time, corrected by the last offset measured.
+func (c *ntpClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return time.Now().Add(c.offset)
+}
+
+// Sync measures the offset of the system clock to the NTP server, unless it
+// was measured less than minSync ago. If the server cannot be reached, the
+// last offset is kept.
+func (c *ntpClock) Sync() {
+	c.Lock()
+	if !c.synced.IsZero() && time.Since(c.synced) < c.minSync {
+		c.Unlock()
+		return
+	}
+	// set first, so that the other gatherers do not query the server too
+	c.synced = time.Now()
+	c.Unlock()
+
+	offset, err := queryNTPOffset(c.server)
+	if err != nil {
+		log.Printf("W! Could not sync metric timestamps to NTP server %s: %s\n",
+			c.server, err)
+		return
+	}
+	c.Lock()
+	c.offset = offset
+	c.Unlock()
+}
+
+// queryNTPOffset returns the offset of the system clock to the NTP server,
+// with a single SNTP request.
+func queryNTPOffset(server string) (time.Duration, error) {
+	conn, err := net.DialTimeout("udp", server, ntpTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ntpTimeout))
+
+	req := make([]byte, 48)
+	// leap indicator 0, version 3, mode 3 (client)
+	req[0] = 0x1b
+	sent := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+	resp := make([]byte, 48)
+	if _, err := conn.Read(resp); err != nil {
+		return 0, err
+	}
+	received := time.Now()
+
+	if mode := resp[0] & 0x7; mode != 4 {
+		return 0, fmt.Errorf("invalid NTP response mode %d", mode)
+	}
+	if stratum := resp[1]; stratum == 0 || stratum > 15 {
+		return 0, fmt.Errorf("NTP server is not synchronized, stratum %d", stratum)
+	}
+	serverReceived := ntpTime(resp[32:40])
+	serverSent := ntpTime(resp[40:48])
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+// ntpTime decodes an NTP timestamp, 32 bits of seconds since 1900 followed by
+// 32 bits of fraction of second.
+func ntpTime(b []byte) time.Time {
+	secs := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	frac := uint64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(secs, int64(frac*1e9>>32))
+}
@@ -0,0 +1,67 @@
+package agent
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNTPServer answers NTP requests with a clock ahead of the system clock
+// by offset, and returns its address.
+func fakeNTPServer(t *testing.T, offset time.Duration) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		buf := make([]byte, 48)
+		for {
+			_, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := make([]byte, 48)
+			// version 3, mode 4 (server), stratum 2
+			resp[0] = 0x1c
+			resp[1] = 2
+			putNTPTime(resp[32:40], time.Now().Add(offset))
+			putNTPTime(resp[40:48], time.Now().Add(offset))
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String(), func() { conn.Close() }
+}
+
+func putNTPTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b[0:4], uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:8],
+		uint32((uint64(t.Nanosecond())<<32)/1e9))
+}
+
+func TestNTPClock(t *testing.T) {
+	addr, stop := fakeNTPServer(t, time.Hour)
+	defer stop()
+
+	clock := newNTPClock(addr, time.Minute)
+	assert.WithinDuration(t, time.Now(), clock.Now(), time.Second)
+	clock.Sync()
+	assert.WithinDuration(t, time.Now().Add(time.Hour), clock.Now(), time.Second)
+
+	// the server is not queried again before minSync, and a server that
+	// cannot be reached keeps the last offset.
+	stop()
+	clock.Sync()
+	clock.synced = time.Time{}
+	clock.Sync()
+	assert.WithinDuration(t, time.Now().Add(time.Hour), clock.Now(), time.Second)
+}
+
+func TestNTPTime(t *testing.T) {
+	b := make([]byte, 8)
+	ts := time.Unix(1500000000, 250000000)
+	putNTPTime(b, ts)
+	assert.Equal(t, ts, ntpTime(b))
+	assert.Equal(t, "pool.ntp.org:123", newNTPClock("pool.ntp.org", 0).server)
+}
@@ -0,0 +1,43 @@
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// writePidFile writes the PID of telegraf to path. If path holds the PID of
+// another running process, it is overwritten with a warning. The returned
+// function removes the file.
+func writePidFile(path string) (func(), error) {
+	if contents, err := ioutil.ReadFile(path); err == nil {
+		pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+		if err == nil && pid != os.Getpid() && processRunning(pid) {
+			log.Printf("W! Overwriting pid file %s of running process %d\n",
+				path, pid)
+		}
+	}
+
+	err := ioutil.WriteFile(path, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("W! Could not remove pid file %s: %s\n", path, err)
+		}
+	}, nil
+}
+
+// processRunning reports whether a process with the given PID exists.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePidFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-pidfile")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "telegraf.pid")
+
+	// a stale pid file of a running process is overwritten
+	require.NoError(t, ioutil.WriteFile(path,
+		[]byte(fmt.Sprintf("%d\n", os.Getppid())), 0644))
+
+	remove, err := writePidFile(path)
+	require.NoError(t, err)
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("%d\n", os.Getpid()), string(contents))
+
+	remove()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWritePidFileError(t *testing.T) {
+	_, err := writePidFile("/nonexistent/dir/telegraf.pid")
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package agent
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+// selfMonitor receives telegraf's own internal metrics on statsC, and adds the
+// latest metric of each series to metricC once every SelfMonitorInterval,
+// with the global tags.
+func (a *Agent) selfMonitor(
+	shutdown chan struct{},
+	statsC chan telegraf.Metric,
+	metricC chan telegraf.Metric,
+) {
+	ticker := time.NewTicker(a.Config.Agent.SelfMonitorInterval.Duration)
+	defer ticker.Stop()
+
+	latest := make(map[string]telegraf.Metric)
+	for {
+		select {
+		case <-shutdown:
+			return
+		case m := <-statsC:
+			latest[seriesKey(m)] = m
+		case <-ticker.C:
+			now := time.Now()
+			for _, m := range latest {
+				out, err := telegraf.NewMetric(m.Name(), m.Tags(), m.Fields(), now)
+				if err != nil {
+					log.Printf("E! Could not create self monitoring metric "+
+						"%s: %s\n", m.Name(), err)
+					continue
+				}
+				select {
+				case metricC <- a.Config.ApplyGlobalTags(out):
+				case <-shutdown:
+					return
+				}
+			}
+			latest = make(map[string]telegraf.Metric)
+		}
+	}
+}
+
+// seriesKey identifies the series of a metric by its name and tags.
+func seriesKey(m telegraf.Metric) string {
+	var keys []string
+	for k := range m.Tags() {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{m.Name()}
+	for _, k := range keys {
+		parts = append(parts, k+"="+m.Tags()[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// teeStats copies every internal metric received on statsC to each of outs.
+// Like sendStat, it drops metrics instead of blocking on a full channel.
+func teeStats(
+	shutdown chan struct{},
+	statsC chan telegraf.Metric,
+	outs []chan telegraf.Metric,
+) {
+	for {
+		select {
+		case <-shutdown:
+			return
+		case m := <-statsC:
+			for _, out := range outs {
+				select {
+				case out <- m:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// instrumentInput makes input report its internal metrics, with its gather
+// latency histogram, if telegraf's internal metrics are collected.
+func (a *Agent) instrumentInput(input *models.RunningInput) {
+	if a.internalStats == nil {
+		return
+	}
+	input.InternalStats = a.internalStats
+	histogram, err := models.NewLatencyHistogram(
+		a.Config.Agent.HistogramConfig.GatherLatencyBuckets)
+	if err != nil {
+		log.Printf("E! Invalid gather_latency_buckets: %s\n", err)
+		return
+	}
+	input.GatherLatency = histogram
+}
+
+// instrumentOutput makes output report its internal metrics, with its write
+// latency histogram, if telegraf's internal metrics are collected.
+func (a *Agent) instrumentOutput(output *models.RunningOutput) {
+	if a.internalStats == nil {
+		return
+	}
+	output.InternalStats = a.internalStats
+	histogram, err := models.NewLatencyHistogram(
+		a.Config.Agent.HistogramConfig.WriteLatencyBuckets)
+	if err != nil {
+		log.Printf("E! Invalid write_latency_buckets: %s\n", err)
+		return
+	}
+	output.WriteLatency = histogram
+}
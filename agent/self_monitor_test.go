@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfMonitor(t *testing.T) {
+	c := config.NewConfig()
+	c.Agent.SelfMonitorInterval.Duration = 50 * time.Millisecond
+	c.Tags = map[string]string{"host": "localhost"}
+	a := &Agent{Config: c}
+
+	statsC := make(chan telegraf.Metric, 10)
+	metricC := make(chan telegraf.Metric, 10)
+	shutdown := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		a.selfMonitor(shutdown, statsC, metricC)
+		close(done)
+	}()
+
+	for _, size := range []int64{1, 2} {
+		m, _ := telegraf.NewMetric("internal_write",
+			map[string]string{"output": "file"},
+			map[string]interface{}{"buffer_size": size},
+			time.Now())
+		statsC <- m
+	}
+
+	// only the latest metric of the series is sent
+	select {
+	case m := <-metricC:
+		assert.Equal(t, "internal_write", m.Name())
+		assert.Equal(t, map[string]string{
+			"output": "file",
+			"host":   "localhost",
+		}, m.Tags())
+		assert.Equal(t, map[string]interface{}{"buffer_size": int64(2)},
+			m.Fields())
+	case <-time.After(time.Second):
+		t.Fatal("no self monitoring metric was sent")
+	}
+
+	close(shutdown)
+	<-done
+	assert.Len(t, metricC, 0)
+}
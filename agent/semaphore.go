@@ -0,0 +1,60 @@
+package agent
+
+import (
+	"sync"
+	"time"
+)
+
+// tickSemaphore limits the number of gathers running at the same time within
+// a single collection interval. A fresh semaphore is used for every interval,
+// so that gathers which are still running from a previous interval do not
+// starve the gathers of the next one.
+type tickSemaphore struct {
+	sync.Mutex
+	size    int
+	current chan struct{}
+}
+
+func newTickSemaphore(size int) *tickSemaphore {
+	return &tickSemaphore{
+		size:    size,
+		current: make(chan struct{}, size),
+	}
+}
+
+// acquire blocks until a slot is available in the current interval's
+// semaphore, and returns the function that releases it. If the shutdown
+// channel is closed while waiting, acquire returns false.
+func (s *tickSemaphore) acquire(shutdown chan struct{}) (func(), bool) {
+	s.Lock()
+	sem := s.current
+	s.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, true
+	case <-shutdown:
+		return nil, false
+	}
+}
+
+// rotate replaces the current semaphore with an empty one.
+func (s *tickSemaphore) rotate() {
+	s.Lock()
+	s.current = make(chan struct{}, s.size)
+	s.Unlock()
+}
+
+// run rotates the semaphore every interval until shutdown is closed.
+func (s *tickSemaphore) run(shutdown chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-shutdown:
+			return
+		case <-ticker.C:
+			s.rotate()
+		}
+	}
+}
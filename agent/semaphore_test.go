@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTickSemaphore(t *testing.T) {
+	shutdown := make(chan struct{})
+	s := newTickSemaphore(1)
+
+	release, ok := s.acquire(shutdown)
+	assert.True(t, ok)
+
+	// the only slot of this interval is taken
+	select {
+	case s.current <- struct{}{}:
+		t.Fatal("semaphore should be full")
+	default:
+	}
+
+	// a new interval gets its own slots
+	s.rotate()
+	release2, ok := s.acquire(shutdown)
+	assert.True(t, ok)
+
+	// releasing returns the slot to the semaphore it was taken from
+	release()
+	release2()
+	assert.Len(t, s.current, 0)
+
+	// waiting for a slot stops on shutdown
+	_, ok = s.acquire(shutdown)
+	assert.True(t, ok)
+	close(shutdown)
+	_, ok = s.acquire(shutdown)
+	assert.False(t, ok)
+}
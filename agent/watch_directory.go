@@ -0,0 +1,269 @@
+package agent
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+// inputStop stops a single input, at shutdown or when its config file is
+// removed.
+type inputStop struct {
+	C    chan struct{}
+	once sync.Once
+}
+
+func newInputStop(shutdown chan struct{}) *inputStop {
+	s := &inputStop{C: make(chan struct{})}
+	go func() {
+		select {
+		case <-shutdown:
+			s.Stop()
+		case <-s.C:
+		}
+	}()
+	return s
+}
+
+// Stop closes C, it may be called more than once.
+func (s *inputStop) Stop() {
+	s.once.Do(func() { close(s.C) })
+}
+
+// outputChange adds or removes an output. It is applied by the flusher, so
+// that outputs are not changed while metrics are written to them. link links
+// the failover outputs again instead, once the outputs of a rescan are all
+// changed, see config.LinkFailoverOutputs.
+type outputChange struct {
+	output *models.RunningOutput
+	remove bool
+	link   bool
+}
+
+func (a *Agent) applyOutputChange(change outputChange) {
+	if change.link {
+		if err := a.Config.LinkFailoverOutputs(); err != nil {
+			log.Printf("E! Error linking failover outputs: %s\n", err)
+		}
+		return
+	}
+	if !change.remove {
+		a.Config.AppendOutput(change.output)
+		return
+	}
+	a.Config.RemoveOutput(change.output)
+
+	o := change.output
+	if err := o.Write(); err != nil {
+		log.Printf("E! Error writing to output [%s]: %s\n", o.Name, err.Error())
+	}
+	// outputs disabled at runtime are already stopped
+	o.Pause(func() {
+		o.Output.Close()
+		if so, ok := o.Output.(telegraf.ServiceOutput); ok {
+			so.Stop()
+		}
+	})
+}
+
+// dispatchBuffer moves the metrics of an input with its own buffer to
+// metricC, until stop is closed.
+func (a *Agent) dispatchBuffer(
+	wg *sync.WaitGroup,
+	stop chan struct{},
+	input *models.RunningInput,
+	metricC chan telegraf.Metric,
+) {
+	if input.Config.MetricBufferLimit <= 0 {
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		input.DispatchMetrics(stop, metricC)
+	}()
+}
+
+// startServiceInput starts the service of a service input, other inputs are
+// left alone.
+func (a *Agent) startServiceInput(
+	input *models.RunningInput,
+	metricC chan telegraf.Metric,
+) error {
+	p, ok := input.Input.(telegraf.ServiceInput)
+	if !ok {
+		return nil
+	}
+	acc := NewAccumulator(input.Config, metricC)
+	// Service input plugins should set their own precision of their
+	// metrics.
+	acc.DisablePrecision()
+	acc.setGlobalTags(a.Config)
+	acc.setTimestampSource(a.Config.Agent.MetricTimestampSource, a.now)
+	acc.setBuffer(input)
+	acc.setDedup(input)
+	return input.StartService(func() error { return p.Start(acc) })
+}
+
+// startGatherer gathers from input in a new goroutine until stop is closed,
+// and then stops its service if it is a service input. retry is set for a
+// service input whose service failed to start, it is retried first.
+func (a *Agent) startGatherer(
+	wg *sync.WaitGroup,
+	stop chan struct{},
+	input *models.RunningInput,
+	metricC chan telegraf.Metric,
+	retry bool,
+) {
+	interval := a.Config.Agent.Interval.Duration
+	// overwrite global interval if this plugin has it's own.
+	if input.Config.Interval != 0 {
+		interval = input.Config.Interval
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, ok := input.Input.(telegraf.ServiceInput); ok {
+			if retry && !a.retryServiceInput(stop, input, metricC) {
+				return
+			}
+			defer input.StopService()
+		}
+		if err := a.gatherer(stop, input, interval, metricC); err != nil {
+			log.Printf("E! " + err.Error())
+		}
+	}()
+}
+
+// watchDirectories rescans the config directories every
+// watch_directory_interval, starting the plugins of the config files added
+// since the last rescan and stopping those of the removed files.
+func (a *Agent) watchDirectories(
+	shutdown chan struct{},
+	wg *sync.WaitGroup,
+	stops map[*models.RunningInput]*inputStop,
+	metricC chan telegraf.Metric,
+) {
+	ticker := time.NewTicker(a.Config.Agent.WatchDirectoryInterval.Duration)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-shutdown:
+			return
+		case <-ticker.C:
+		}
+
+		change, err := a.Config.RescanDirectories()
+		if err != nil {
+			log.Printf("E! Error rescanning config directories: %s\n", err)
+		}
+		if change == nil {
+			continue
+		}
+
+		for _, input := range change.RemovedInputs {
+			if stop, ok := stops[input]; ok {
+				log.Printf("I! Config file of input %s was removed, stopping "+
+					"it\n", input.Name)
+				stop.Stop()
+				delete(stops, input)
+			}
+			a.Config.RemoveInput(input)
+		}
+		for _, output := range change.RemovedOutputs {
+			log.Printf("I! Config file of output %s was removed, stopping "+
+				"it\n", output.Name)
+			select {
+			case a.outputChanges <- outputChange{output: output, remove: true}:
+			case <-shutdown:
+				return
+			}
+		}
+
+		// plugins that fail to start are retried by the next rescan
+		var failedInputs []*models.RunningInput
+		var failedOutputs []*models.RunningOutput
+		outputsChanged := len(change.RemovedOutputs) > 0
+		for _, output := range change.AddedOutputs {
+			output.Quiet = a.Config.Agent.Quiet
+			a.instrumentOutput(output)
+			if err := a.setPluginLogger("outputs", output.Name,
+				output.Output); err != nil {
+				log.Printf("E! %s\n", err)
+			}
+			if started, err := startOutput(output); err != nil {
+				log.Printf("E! Output %s of a new config file failed to "+
+					"start, retrying at the next rescan: %s\n", output.Name,
+					err)
+				if so, ok := output.Output.(telegraf.ServiceOutput); ok && started {
+					so.Stop()
+				}
+				failedOutputs = append(failedOutputs, output)
+				continue
+			}
+			log.Printf("I! Started output %s of a new config file\n",
+				output.Name)
+			select {
+			case a.outputChanges <- outputChange{output: output}:
+			case <-shutdown:
+				return
+			}
+			outputsChanged = true
+		}
+		if outputsChanged {
+			select {
+			case a.outputChanges <- outputChange{link: true}:
+			case <-shutdown:
+				return
+			}
+		}
+		for _, input := range change.AddedInputs {
+			a.instrumentInput(input)
+			if err := a.setPluginLogger("inputs", input.Name,
+				input.Input); err != nil {
+				log.Printf("E! %s\n", err)
+			}
+			stop := newInputStop(shutdown)
+			a.dispatchBuffer(wg, stop.C, input, metricC)
+			retry := false
+			if err := a.startServiceInput(input, metricC); err != nil {
+				// the same policy as at startup, except that the agent
+				// keeps running, and retries the input at the next rescan
+				interval := a.startupRetryInterval(
+					input.Config.StartupRetryInterval)
+				switch {
+				case interval > 0:
+					log.Printf("E! Service for input %s of a new config "+
+						"file failed to start, retrying every %s\n%s\n",
+						input.Name, interval, err.Error())
+					retry = true
+				case a.Config.Agent.StartupErrorBehavior == "skip":
+					log.Printf("E! Service for input %s of a new config "+
+						"file failed to start, skipping it\n%s\n",
+						input.Name, err.Error())
+					stop.Stop()
+					continue
+				default:
+					log.Printf("E! Service for input %s of a new config "+
+						"file failed to start, retrying at the next "+
+						"rescan\n%s\n", input.Name, err.Error())
+					stop.Stop()
+					failedInputs = append(failedInputs, input)
+					continue
+				}
+			}
+			stops[input] = stop
+			a.Config.AppendInput(input)
+			a.startGatherer(wg, stop.C, input, metricC, retry)
+			log.Printf("I! Started input %s of a new config file\n", input.Name)
+		}
+		if len(failedInputs) > 0 || len(failedOutputs) > 0 {
+			a.Config.DirectoryPluginsFailed(failedInputs, failedOutputs)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/config"
+	"github.com/influxdata/telegraf/internal/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Run with -race: the watcher changes the inputs of the config while they
+// are read through its accessors.
+func TestAgent_WatchDirectoriesConcurrentAccess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := config.NewConfig()
+	c.Agent.WatchDirectoryInterval.Duration = time.Millisecond
+	require.NoError(t, c.LoadDirectory(dir))
+	a := &Agent{Config: c}
+
+	shutdown := make(chan struct{})
+	metricC := make(chan telegraf.Metric, 100)
+	go func() {
+		for range metricC {
+		}
+	}()
+
+	var wg sync.WaitGroup
+	stops := make(map[*models.RunningInput]*inputStop)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.watchDirectories(shutdown, &wg, stops, metricC)
+	}()
+
+	path := filepath.Join(dir, "mem.conf")
+	for i := 0; i < 20; i++ {
+		if i%2 == 0 {
+			require.NoError(t, ioutil.WriteFile(path,
+				[]byte("[[inputs.mem]]\n  interval = \"1h\"\n"), 0644))
+		} else {
+			require.NoError(t, os.Remove(path))
+		}
+		deadline := time.Now().Add(5 * time.Millisecond)
+		for time.Now().Before(deadline) {
+			c.InputNames()
+			c.OutputNames()
+			require.NoError(t, c.LinkFailoverOutputs())
+		}
+	}
+
+	close(shutdown)
+	<-done
+	wg.Wait()
+	close(metricC)
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/influxdata/telegraf/agent"
 	"github.com/influxdata/telegraf/internal/config"
@@ -32,6 +35,12 @@ var fConfigDirectory = flag.String("config-directory", "",
 var fVersion = flag.Bool("version", false, "display the version")
 var fSampleConfig = flag.Bool("sample-config", false,
 	"print out full sample configuration")
+var fMigrateConfig = flag.Bool("migrate-config", false,
+	"print the config migrated to the latest config_format_version and exit")
+var fConfigCheck = flag.Bool("config-check", false,
+	"load and validate the config, and exit")
+var fVerifyConnectivity = flag.Bool("verify-connectivity", false,
+	"with -config-check, also check that the outputs can connect")
 var fPidfile = flag.String("pidfile", "", "file to write our pid to")
 var fInputFilters = flag.String("input-filter", "",
 	"filter the inputs to enable, separator is :")
@@ -75,6 +84,9 @@ The flags are:
   -config <file>     configuration file to load
   -test              gather metrics once, print them to stdout, and exit
   -sample-config     print out full sample configuration to stdout
+  -migrate-config    print the config migrated to the latest format and exit
+  -config-check      load and validate the config, and exit
+  -verify-connectivity  with -config-check, check that outputs can connect
   -config-directory  directory containing additional *.conf files
   -input-filter      filter the input plugins to enable, separator is :
   -input-list        print all the plugins inputs
@@ -92,6 +104,8 @@ an environment variable or default location. Precedence is:
   2. $TELEGRAF_CONFIG_PATH environment variable
   3. $HOME/.telegraf/telegraf.conf
   4. /etc/telegraf/telegraf.conf
+     On Windows, in order: C:\Program Files\Telegraf\telegraf.conf,
+     %PROGRAMDATA%\Telegraf\telegraf.conf, %APPDATA%\Telegraf\telegraf.conf
 
 Examples:
 
@@ -101,6 +115,9 @@ Examples:
   # generate config with only cpu input & influxdb output plugins defined
   telegraf -sample-config -input-filter cpu -output-filter influxdb
 
+  # print the config upgraded to the latest config_format_version
+  telegraf -config telegraf.conf -migrate-config
+
   # run a single telegraf collection, outputing metrics to stdout
   telegraf -config telegraf.conf -test
 
@@ -115,6 +132,63 @@ var stop chan struct{}
 
 var srvc service.Service
 
+// configPaths returns the config file and directory given on the command
+// line.
+func configPaths() []string {
+	var paths []string
+	if *fConfig != "" {
+		paths = append(paths, *fConfig)
+	}
+	if *fConfigDirectory != "" {
+		paths = append(paths, *fConfigDirectory)
+	}
+	return paths
+}
+
+// loadConfig loads the config files, the config directory and the remote
+// configs into a new config.
+func loadConfig(inputFilters, outputFilters []string) (*config.Config, error) {
+	c := config.NewConfig()
+	c.OutputFilters = outputFilters
+	c.InputFilters = inputFilters
+	c.Version = version
+	if err := c.LoadConfig(*fConfig); err != nil {
+		return nil, err
+	}
+	if *fConfigDirectory != "" {
+		if err := c.LoadDirectory(*fConfigDirectory); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.LoadRemoteConfigs(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// validateConfig loads the config files again and validates them, so that a
+// reload on config change does not stop telegraf on an invalid config.
+func validateConfig(inputFilters, outputFilters []string) error {
+	c, err := loadConfig(inputFilters, outputFilters)
+	if err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+// reloadConfig switches c to the config loaded again, or keeps c if the new
+// config fails to load or to start.
+func reloadConfig(c *config.Config, inputFilters, outputFilters []string) {
+	newCfg, err := loadConfig(inputFilters, outputFilters)
+	if err == nil {
+		err = c.ReloadSafely(newCfg)
+	}
+	if err != nil {
+		log.Printf("E! Error reloading the config, keeping the running "+
+			"config: %s\n", err)
+	}
+}
+
 type program struct{}
 
 func reloadLoop(stop chan struct{}, s service.Service) {
@@ -126,6 +200,8 @@ func reloadLoop(stop chan struct{}, s service.Service) {
 	}()
 	reload := make(chan bool, 1)
 	reload <- true
+	// c is the running config, switched to the new config on each reload
+	var c *config.Config
 	for <-reload {
 		reload <- false
 		flag.Usage = func() { usageExit(0) }
@@ -184,26 +260,45 @@ func reloadLoop(stop chan struct{}, s service.Service) {
 		}
 
 		// If no other options are specified, load the config file and run.
-		c := config.NewConfig()
-		c.OutputFilters = outputFilters
-		c.InputFilters = inputFilters
-		err := c.LoadConfig(*fConfig)
-		if err != nil {
+		// On a reload the outputs of the config are already connected, by
+		// ReloadSafely, or still connected if the running config is kept.
+		reloaded := c != nil
+		var err error
+		if reloaded {
+			reloadConfig(c, inputFilters, outputFilters)
+		} else if c, err = loadConfig(inputFilters, outputFilters); err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
-
-		if *fConfigDirectory != "" {
-			err = c.LoadDirectory(*fConfigDirectory)
+		if *fMigrateConfig {
+			migrated, err := c.MigrateConfig(config.LatestConfigFormatVersion)
 			if err != nil {
 				log.Fatal(err)
 			}
+			if err := migrated.PrintEffectiveConfig(os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		if err := c.Validate(); err != nil {
+			log.Fatalf("Error: %s", err)
 		}
-		if len(c.Outputs) == 0 {
-			log.Fatalf("Error: no outputs found, did you provide a valid config file?")
+		for _, warning := range c.LintConfig() {
+			log.Printf("W! Config: %s\n", warning)
 		}
-		if len(c.Inputs) == 0 {
-			log.Fatalf("Error: no inputs found, did you provide a valid config file?")
+		if *fConfigCheck {
+			if *fVerifyConnectivity {
+				failed := c.VerifyConnectivity(context.Background(),
+					10*time.Second)
+				for _, err := range failed {
+					log.Printf("E! Connectivity check failed: %s\n", err)
+				}
+				if len(failed) > 0 {
+					os.Exit(1)
+				}
+			}
+			fmt.Println("Config OK")
+			return
 		}
 
 		ag, err := agent.NewAgent(c)
@@ -226,14 +321,87 @@ func reloadLoop(stop chan struct{}, s service.Service) {
 			return
 		}
 
-		err = ag.Connect()
-		if err != nil {
-			log.Fatal(err)
+		if !reloaded {
+			if err := ag.Connect(); err != nil {
+				log.Fatal(err)
+			}
 		}
 
 		shutdown := make(chan struct{})
 		signals := make(chan os.Signal)
 		signal.Notify(signals, os.Interrupt, syscall.SIGHUP)
+
+		// Reload when a Kubernetes ConfigMap holding the config is updated.
+		var configDirs []string
+		if *fConfig != "" {
+			configDirs = append(configDirs, filepath.Dir(*fConfig))
+		}
+		if *fConfigDirectory != "" {
+			configDirs = append(configDirs, *fConfigDirectory)
+		}
+		configMapChanged := make(chan struct{}, 1)
+		for _, dir := range configDirs {
+			if !config.IsKubernetesConfigMap(dir) {
+				continue
+			}
+			changed := config.WatchKubernetesConfigMap(dir, 10*time.Second, shutdown)
+			go func() {
+				select {
+				case <-changed:
+					select {
+					case configMapChanged <- struct{}{}:
+					default:
+					}
+				case <-shutdown:
+				}
+			}()
+		}
+
+		// Reload when the config files change, if the new config is valid.
+		configFilesChanged := make(chan struct{}, 1)
+		if c.Agent.WatchConfig {
+			changed := config.WatchConfigFiles(configPaths(), time.Second,
+				c.Agent.WatchConfigDebounce.Duration, shutdown)
+			go func() {
+				for {
+					select {
+					case <-changed:
+						if err := validateConfig(inputFilters, outputFilters); err != nil {
+							log.Printf("E! Config files changed but the new "+
+								"config is invalid, not reloading: %s\n", err)
+							continue
+						}
+						configFilesChanged <- struct{}{}
+						return
+					case <-shutdown:
+						return
+					}
+				}
+			}()
+		}
+
+		// Reload when a remote config has changed, if the new config is
+		// valid.
+		remoteConfigChanged := make(chan struct{}, 1)
+		if changed := c.WatchRemoteConfigs(shutdown); changed != nil {
+			go func() {
+				for {
+					select {
+					case <-changed:
+						if err := validateConfig(inputFilters, outputFilters); err != nil {
+							log.Printf("E! Remote config changed but the new "+
+								"config is invalid, not reloading: %s\n", err)
+							continue
+						}
+						remoteConfigChanged <- struct{}{}
+						return
+					case <-shutdown:
+						return
+					}
+				}
+			}()
+		}
+
 		go func() {
 			select {
 			case sig := <-signals:
@@ -246,6 +414,21 @@ func reloadLoop(stop chan struct{}, s service.Service) {
 					reload <- true
 					close(shutdown)
 				}
+			case <-configMapChanged:
+				log.Printf("I! Kubernetes ConfigMap updated, reloading Telegraf config\n")
+				<-reload
+				reload <- true
+				close(shutdown)
+			case <-configFilesChanged:
+				log.Printf("I! Config files changed, reloading Telegraf config\n")
+				<-reload
+				reload <- true
+				close(shutdown)
+			case <-remoteConfigChanged:
+				log.Printf("I! Remote config changed, reloading Telegraf config\n")
+				<-reload
+				reload <- true
+				close(shutdown)
 			case <-stop:
 				close(shutdown)
 			}
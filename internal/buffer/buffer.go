@@ -11,6 +11,8 @@ type Buffer struct {
 	drops int
 	// total metrics added
 	total int
+	// drop the metrics being added instead of the oldest ones when full
+	dropNewest bool
 }
 
 // NewBuffer returns a Buffer
@@ -22,6 +24,12 @@ func NewBuffer(size int) *Buffer {
 	}
 }
 
+// SetDropNewest sets whether Add drops the metrics being added, rather than
+// the oldest metrics in the buffer, when the buffer is full.
+func (b *Buffer) SetDropNewest(dropNewest bool) {
+	b.dropNewest = dropNewest
+}
+
 // IsEmpty returns true if Buffer is empty.
 func (b *Buffer) IsEmpty() bool {
 	return len(b.buf) == 0
@@ -51,6 +59,9 @@ func (b *Buffer) Add(metrics ...telegraf.Metric) {
 		case b.buf <- metrics[i]:
 		default:
 			b.drops++
+			if b.dropNewest {
+				continue
+			}
 			<-b.buf
 			b.buf <- metrics[i]
 		}
@@ -92,3 +92,17 @@ func TestGettingBatches(t *testing.T) {
 	assert.Equal(t, b.Drops(), 0)
 	assert.Equal(t, b.Total(), 10)
 }
+
+func TestDroppingNewestMetrics(t *testing.T) {
+	b := NewBuffer(5)
+	b.SetDropNewest(true)
+
+	b.Add(metricList...)
+	b.Add(testutil.TestMetric(100, "dropped"))
+	assert.Equal(t, b.Len(), 5)
+	assert.Equal(t, b.Drops(), 1)
+	assert.Equal(t, b.Total(), 6)
+
+	// the oldest metrics are kept
+	assert.Equal(t, metricList, b.Batch(5))
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+// TestCollection gathers once from all the inputs of the config, and returns
+// the metrics they produce, without writing them to any output. The options
+// of each input, the global tags and the metric_name_replace agent table are
+// applied, as they are before the metrics are sent to the outputs. It returns
+// an error if an input fails to gather, or if ctx is done before all inputs
+// have gathered.
+func (c *Config) TestCollection(ctx context.Context) ([]telegraf.Metric, error) {
+	acc := &collectAccumulator{config: c}
+	c.mu.RLock()
+	inputs := append([]*models.RunningInput(nil), c.Inputs...)
+	c.mu.RUnlock()
+
+	done := make(chan error, 1)
+	go func() {
+		for _, input := range inputs {
+			acc.input = input.Config
+			if err := input.Input.Gather(acc); err != nil {
+				done <- fmt.Errorf("Error gathering from input %s: %s",
+					input.Name, err)
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	acc.Lock()
+	defer acc.Unlock()
+	if len(acc.errs) > 0 {
+		return acc.metrics, fmt.Errorf("Errors encountered during collection: %s",
+			acc.errs[0])
+	}
+	return acc.metrics, nil
+}
+
+// collectAccumulator is the telegraf.Accumulator of TestCollection, which
+// keeps the metrics added by the inputs.
+type collectAccumulator struct {
+	sync.Mutex
+
+	config *Config
+	// input is the config of the input gathering.
+	input *models.InputConfig
+
+	metrics []telegraf.Metric
+	errs    []error
+}
+
+func (ac *collectAccumulator) AddFields(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.addMetric(measurement, fields, tags, telegraf.Untyped, t...)
+}
+
+func (ac *collectAccumulator) AddGauge(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.addMetric(measurement, fields, tags, telegraf.Gauge, t...)
+}
+
+func (ac *collectAccumulator) AddCounter(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	ac.addMetric(measurement, fields, tags, telegraf.Counter, t...)
+}
+
+// addMetric keeps a metric with the options of the input gathering, the
+// global tags and the metric_name_replace agent table applied, unless it is
+// filtered out.
+func (ac *collectAccumulator) addMetric(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	mType telegraf.ValueType,
+	t ...time.Time,
+) {
+	if len(fields) == 0 || len(measurement) == 0 {
+		return
+	}
+
+	// the input may reuse its maps
+	f := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		f[k] = v
+	}
+	tg := make(map[string]string, len(tags))
+	for k, v := range tags {
+		tg[k] = v
+	}
+
+	ac.input.RenameFields(f)
+	for k, v := range ac.input.Tags {
+		if _, ok := tg[k]; !ok {
+			tg[k] = v
+		}
+	}
+	ac.config.MergeGlobalTags(tg)
+
+	if len(ac.input.NameTemplate) != 0 {
+		name, err := ac.input.MetricName(measurement, tg, f)
+		if err != nil {
+			log.Printf("E! Error executing name_template for input %s: %s\n",
+				ac.input.Name, err)
+			return
+		}
+		if len(name) == 0 {
+			return
+		}
+		measurement = name
+	} else {
+		if len(ac.input.NameOverride) != 0 {
+			measurement = ac.input.NameOverride
+		}
+		measurement = ac.input.MeasurementPrefix + measurement +
+			ac.input.MeasurementSuffix
+	}
+
+	if !ac.input.Filter.Apply(measurement, f, tg) || !ac.input.Sample() {
+		return
+	}
+	measurement = models.ReplaceName(ac.config.Agent.MetricNameReplace,
+		measurement)
+
+	timestamp := time.Now()
+	if len(t) > 0 {
+		timestamp = t[0]
+	}
+
+	var m telegraf.Metric
+	var err error
+	switch mType {
+	case telegraf.Counter:
+		m, err = telegraf.NewCounterMetric(measurement, tg, f, timestamp)
+	case telegraf.Gauge:
+		m, err = telegraf.NewGaugeMetric(measurement, tg, f, timestamp)
+	default:
+		m, err = telegraf.NewMetric(measurement, tg, f, timestamp)
+	}
+	if err != nil {
+		log.Printf("E! Error adding point [%s]: %s\n", measurement, err)
+		return
+	}
+
+	ac.Lock()
+	defer ac.Unlock()
+	ac.metrics = append(ac.metrics, m)
+}
+
+func (ac *collectAccumulator) AddError(err error) {
+	if err == nil {
+		return
+	}
+	ac.Lock()
+	defer ac.Unlock()
+	ac.errs = append(ac.errs, err)
+}
+
+func (ac *collectAccumulator) Debug() bool                     { return false }
+func (ac *collectAccumulator) SetDebug(enabled bool)           {}
+func (ac *collectAccumulator) SetPrecision(p, i time.Duration) {}
+func (ac *collectAccumulator) DisablePrecision()               {}
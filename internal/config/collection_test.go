@@ -0,0 +1,68 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type collectInput struct {
+	delay time.Duration
+}
+
+func (i *collectInput) SampleConfig() string { return "" }
+func (i *collectInput) Description() string  { return "" }
+func (i *collectInput) Gather(acc telegraf.Accumulator) error {
+	time.Sleep(i.delay)
+	acc.AddGauge("docker_container_cpu", map[string]interface{}{"usage": 1.5},
+		map[string]string{"id": "abc"})
+	acc.AddFields("docker_container_mem", map[string]interface{}{"rss": 10},
+		nil)
+	return nil
+}
+
+func TestConfig_TestCollection(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(`
+[global_tags]
+  dc = "east"
+
+[agent]
+  [agent.metric_name_replace]
+    "^docker_container_(.*)$" = "container_$1"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+  namedrop = ["*_mem"]
+  [inputs.memcached.tags]
+    role = "test"
+`))
+	c.Inputs[0].Input = &collectInput{}
+
+	metrics, err := c.TestCollection(context.Background())
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "container_cpu", metrics[0].Name())
+	assert.Equal(t, telegraf.Gauge, metrics[0].Type())
+	assert.Equal(t, map[string]string{"id": "abc", "dc": "east", "role": "test"},
+		metrics[0].Tags())
+}
+
+func TestConfig_TestCollectionTimeout(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+`))
+	c.Inputs[0].Input = &collectInput{delay: time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	_, err := c.TestCollection(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
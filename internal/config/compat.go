@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Severities of a CompatibilityWarning.
+const (
+	SeverityWarn  = "warn"
+	SeverityError = "error"
+)
+
+// CompatibilityWarning describes a plugin built for another telegraf version
+// than the running agent.
+type CompatibilityWarning struct {
+	// PluginType is either "inputs" or "outputs".
+	PluginType    string
+	PluginName    string
+	PluginVersion string
+	AgentVersion  string
+	// Severity is SeverityError when the plugin cannot work with the agent,
+	// and SeverityWarn when it may not.
+	Severity string
+}
+
+func (w CompatibilityWarning) String() string {
+	return fmt.Sprintf("[%s.%s] plugin version %q may not be compatible with "+
+		"telegraf %q (%s)", w.PluginType, w.PluginName, w.PluginVersion,
+		w.AgentVersion, w.Severity)
+}
+
+// CheckPluginVersionCompatibility compares the version reported by every
+// loaded plugin implementing telegraf.PluginVersioner with the Version of the
+// agent. Plugins built for another major version are errors, and plugins built
+// for a newer minor version, or with an unknown version, are warnings. Nothing
+// is checked if the agent version is unknown.
+func (c *Config) CheckPluginVersionCompatibility() []CompatibilityWarning {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	agent, ok := parseVersion(c.Version)
+	if !ok {
+		return nil
+	}
+
+	var warnings []CompatibilityWarning
+	check := func(pluginType, name string, plugin interface{}) {
+		pv, ok := plugin.(telegraf.PluginVersioner)
+		if !ok {
+			return
+		}
+		version := pv.PluginVersion()
+		severity := versionSeverity(agent, version)
+		if severity == "" {
+			return
+		}
+		warnings = append(warnings, CompatibilityWarning{
+			PluginType:    pluginType,
+			PluginName:    name,
+			PluginVersion: version,
+			AgentVersion:  c.Version,
+			Severity:      severity,
+		})
+	}
+	for _, input := range c.Inputs {
+		check("inputs", input.Name, input.Input)
+	}
+	for _, output := range c.Outputs {
+		check("outputs", output.Name, output.Output)
+	}
+	return warnings
+}
+
+// versionSeverity returns the severity of running a plugin built for version
+// in an agent of version agent, or an empty string if they are compatible.
+func versionSeverity(agent [3]int, version string) string {
+	plugin, ok := parseVersion(version)
+	switch {
+	case !ok:
+		return SeverityWarn
+	case plugin[0] != agent[0]:
+		return SeverityError
+	case plugin[1] > agent[1]:
+		return SeverityWarn
+	}
+	return ""
+}
+
+// parseVersion parses a "major.minor.patch" version, with an optional "v"
+// prefix and pre-release or build suffix. Missing minor and patch numbers
+// are 0.
+func parseVersion(version string) ([3]int, bool) {
+	var v [3]int
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+~ "); i >= 0 {
+		version = version[:i]
+	}
+	if version == "" {
+		return v, false
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) > 3 {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+type versionedInput struct {
+	version string
+}
+
+func (i *versionedInput) SampleConfig() string                  { return "" }
+func (i *versionedInput) Description() string                   { return "" }
+func (i *versionedInput) Gather(acc telegraf.Accumulator) error { return nil }
+func (i *versionedInput) PluginVersion() string                 { return i.version }
+
+func TestConfig_CheckPluginVersionCompatibility(t *testing.T) {
+	c := NewConfig()
+	c.Version = "v1.2.0-rc1"
+	for name, version := range map[string]string{
+		"same":    "1.2.0",
+		"older":   "1.1.3",
+		"newer":   "1.3.0",
+		"major":   "2.0.0",
+		"unknown": "dev",
+	} {
+		c.Inputs = append(c.Inputs, &models.RunningInput{
+			Name:   name,
+			Input:  &versionedInput{version: version},
+			Config: &models.InputConfig{Name: name},
+		})
+	}
+
+	severities := make(map[string]string)
+	for _, w := range c.CheckPluginVersionCompatibility() {
+		assert.Equal(t, "inputs", w.PluginType)
+		assert.Equal(t, "v1.2.0-rc1", w.AgentVersion)
+		severities[w.PluginName] = w.Severity
+	}
+	assert.Equal(t, map[string]string{
+		"newer":   SeverityWarn,
+		"major":   SeverityError,
+		"unknown": SeverityWarn,
+	}, severities)
+
+	// nothing is checked when the agent version is unknown
+	c.Version = ""
+	assert.Empty(t, c.CheckPluginVersionCompatibility())
+}
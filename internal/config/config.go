@@ -4,15 +4,20 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
@@ -20,6 +25,7 @@ import (
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/plugins/parsers/xpath"
 	"github.com/influxdata/telegraf/plugins/serializers"
 
 	"github.com/influxdata/config"
@@ -35,8 +41,9 @@ var (
 	// Default output plugins
 	outputDefaults = []string{"influxdb"}
 
-	// envVarRe is a regex to find environment variables in the config file
-	envVarRe = regexp.MustCompile(`\$\w+`)
+	// envVarRe is a regex to find environment variables in the config file,
+	// as $VAR, ${VAR} or ${env:VAR}
+	envVarRe = regexp.MustCompile(`\$(?:\{(?:env:)?(\w+)\}|(\w+))`)
 )
 
 // Config specifies the URL/user/password for the database that telegraf
@@ -47,9 +54,62 @@ type Config struct {
 	InputFilters  []string
 	OutputFilters []string
 
+	// Version is the version of the running telegraf, plugins reporting
+	// another version are checked by CheckPluginVersionCompatibility.
+	Version string
+
 	Agent   *AgentConfig
 	Inputs  []*models.RunningInput
 	Outputs []*models.RunningOutput
+
+	// DisabledInputs and DisabledOutputs hold the plugins configured with
+	// "enabled = false". They are fully parsed, but are not run.
+	DisabledInputs  []*models.RunningInput
+	DisabledOutputs []*models.RunningOutput
+
+	// secretStores are the [[secret_store]] backends, by name.
+	secretStores map[string]*secretStore
+
+	// defaultsApplied is set by NewConfig and ApplyDefaults, so that the
+	// defaults are applied before the first config file only, and options set
+	// to zero by a file are not reset by the next one.
+	defaultsApplied bool
+
+	// instanceMetadataLoaded is set once the instance metadata tags are
+	// added, so that they are fetched only once per config.
+	instanceMetadataLoaded bool
+	// prometheusLabelsLoaded is the same for the Prometheus labels tags.
+	prometheusLabelsLoaded bool
+	// tagsPreprocessed is set once the tag_preprocessing rules are applied
+	// to Tags, see PreprocessGlobalTags.
+	tagsPreprocessed bool
+
+	// directories are the config directories loaded by LoadDirectory, and
+	// directoryFiles the plugins of each of their files, see
+	// RescanDirectories.
+	directories    []string
+	directoryFiles map[string]*directoryFile
+
+	// disabled are the plugins disabled at runtime, see DisablePlugin.
+	// toggleMu serializes DisablePlugin and EnablePlugin, which stop and
+	// start plugins without holding mu.
+	disabled map[string]bool
+	toggleMu sync.Mutex
+
+	// sources are the config files loaded, to load them again migrated by
+	// MigrateConfig.
+	sources []configSource
+
+	// unregistered are the plugins of the loaded config missing from the
+	// plugin registries, as "inputs.name" or "outputs.name", see
+	// VerifyPluginRegistrations.
+	unregistered []string
+
+	// mu guards Inputs, Outputs, DisabledInputs, DisabledOutputs and disabled
+	// while plugins are added to the config, switched by ReloadSafely, or
+	// disabled at runtime. The methods reading the plugins hold it for
+	// reading, see RLock.
+	mu sync.RWMutex
 }
 
 func NewConfig() *Config {
@@ -59,13 +119,46 @@ func NewConfig() *Config {
 			Interval:      internal.Duration{Duration: 10 * time.Second},
 			RoundInterval: true,
 			FlushInterval: internal.Duration{Duration: 10 * time.Second},
+
+			MetricOverflowStrategy: models.OVERFLOW_DROP_OLDEST,
+			WatchConfigDebounce:    internal.Duration{Duration: 3 * time.Second},
+			StartupErrorBehavior:   "exit",
+			StartupRetryInterval:   internal.Duration{Duration: 30 * time.Second},
+
+			MetricTimestampSource:    "agent",
+			MetricTimestampNTPServer: "pool.ntp.org",
+
+			TagCardinalityLimitAction: models.TAG_LIMIT_REPLACE,
+			LabelWhitelistAction:      models.LABEL_POLICY_DROP,
+			ConfigFormatVersion:       1,
+			InputWorkers:              1,
+			AvgMetricSizeBytes:        DEFAULT_AVG_METRIC_SIZE_BYTES,
+
+			GitConfig: GitConfig{
+				Branch:          "master",
+				PollingInterval: internal.Duration{Duration: time.Minute},
+			},
+			EtcdConfig: EtcdConfig{
+				DialTimeout:     internal.Duration{Duration: defaultEtcdDialTimeout},
+				PollingInterval: internal.Duration{Duration: time.Minute},
+			},
+			HistogramConfig: HistogramConfig{
+				GatherLatencyBuckets: models.DefaultGatherLatencyBuckets,
+				WriteLatencyBuckets:  models.DefaultWriteLatencyBuckets,
+			},
 		},
 
-		Tags:          make(map[string]string),
-		Inputs:        make([]*models.RunningInput, 0),
-		Outputs:       make([]*models.RunningOutput, 0),
-		InputFilters:  make([]string, 0),
-		OutputFilters: make([]string, 0),
+		Tags:            make(map[string]string),
+		Inputs:          make([]*models.RunningInput, 0),
+		Outputs:         make([]*models.RunningOutput, 0),
+		DisabledInputs:  make([]*models.RunningInput, 0),
+		DisabledOutputs: make([]*models.RunningOutput, 0),
+		InputFilters:    make([]string, 0),
+		OutputFilters:   make([]string, 0),
+		secretStores:    make(map[string]*secretStore),
+		directoryFiles:  make(map[string]*directoryFile),
+		disabled:        make(map[string]bool),
+		defaultsApplied: true,
 	}
 	return c
 }
@@ -92,6 +185,10 @@ type AgentConfig struct {
 	// same time, which can have a measurable effect on the system.
 	CollectionJitter internal.Duration
 
+	// InputTimeout is the collection_timeout of the inputs that do not set
+	// their own. 0 disables it.
+	InputTimeout internal.Duration
+
 	// FlushInterval is the Interval at which to flush data
 	FlushInterval internal.Duration
 
@@ -112,11 +209,23 @@ type AgentConfig struct {
 	// not be less than 2 times MetricBatchSize.
 	MetricBufferLimit int
 
-	// FlushBufferWhenFull tells Telegraf to flush the metric buffer whenever
-	// it fills up, regardless of FlushInterval. Setting this option to true
-	// does _not_ deactivate FlushInterval.
+	// AvgMetricSizeBytes is the average size in memory of a metric, used by
+	// EstimateMemoryUsage.
+	AvgMetricSizeBytes int
+
+	// FlushBufferWhenFull is deprecated, the buffer is always flushed when it
+	// fills up. Setting it to true is translated to MetricOverflowStrategy
+	// "drop_oldest".
 	FlushBufferWhenFull bool
 
+	// MetricOverflowStrategy chooses which metrics are dropped when an output
+	// metric buffer is full: "drop_oldest" (the default) or "drop_newest".
+	MetricOverflowStrategy string
+
+	// Deprecated holds a message for each deprecated option used in the
+	// config, with instructions to migrate away from it.
+	Deprecated []string `toml:"-"`
+
 	// TODO(cam): Remove UTC and parameter, they are no longer
 	// valid for the agent config. Leaving them here for now for backwards-
 	// compatability
@@ -128,14 +237,291 @@ type AgentConfig struct {
 	// Logfile specifies the file to send logs to
 	Logfile string
 
+	// PerPluginLogLevel sets the log level, "debug", "info", "warn" or
+	// "error", of the plugins implementing telegraf.LoggerSetter, by plugin
+	// name, ie "cpu" or "inputs.cpu". Other plugins use the global level.
+	PerPluginLogLevel map[string]string `toml:"per_plugin_log_level"`
+
 	// Quiet is the option for running in quiet mode
 	Quiet        bool
 	Hostname     string
 	OmitHostname bool
+
+	// HostnameLookup is how the hostname is found when Hostname is empty:
+	// "hostname" (the default) uses os.Hostname(), "fqdn" its fully qualified
+	// domain name, and "dns" the reverse DNS name of its first A record.
+	HostnameLookup string
+
+	// HostnamePrefix and HostnameSuffix are added to the hostname, whether
+	// it is set or looked up, for the host tag.
+	HostnamePrefix string
+	HostnameSuffix string
+
+	// PidFile is the file telegraf writes its PID to while running.
+	PidFile string
+
+	// GlobalFieldPrefix and GlobalFieldSuffix are added to the field names of
+	// every metric, before it is sent to the outputs.
+	GlobalFieldPrefix string
+	GlobalFieldSuffix string
+
+	// OmitFields are glob patterns of field names dropped from every metric,
+	// after the filters of its input and before it is sent to the outputs.
+	OmitFields []string
+
+	// MetricLabelWhitelist and MetricLabelBlacklist are glob patterns of the
+	// tag keys allowed, and not allowed, on the metrics sent to the outputs.
+	// LabelWhitelistAction is what happens to the other tags: "drop" (the
+	// default) or "rename", which prefixes their key with "unknown_".
+	MetricLabelWhitelist []string
+	MetricLabelBlacklist []string
+	LabelWhitelistAction string
+
+	// MetricNameReplace renames the metrics sent to the outputs, with the
+	// first of its rules matching the measurement name. It is read from the
+	// [agent.metric_name_replace] table, in the order of the config file.
+	MetricNameReplace []models.NameReplace `toml:"-"`
+
+	// MaxTagValuesPerKey limits the number of values of each tag key, and
+	// MaxTagKeyCardinality the number of tag keys, of the metrics sent to the
+	// outputs. 0 disables a limit. TagCardinalityLimitAction is what happens
+	// to a metric with a tag over a limit: "replace" (the default) replaces
+	// the tag value with "_exceeded", "drop" drops the metric.
+	MaxTagValuesPerKey        int
+	MaxTagKeyCardinality      int
+	TagCardinalityLimitAction string
+
+	// StartupErrorBehavior is what happens when a plugin fails to initialize:
+	// "exit" (the default) stops telegraf, "skip" logs the error and runs
+	// without the plugin, and "retry" retries to start outputs and service
+	// inputs every StartupRetryInterval. Config errors, such as unknown
+	// plugins or invalid options, always fail the config.
+	StartupErrorBehavior string
+	StartupRetryInterval internal.Duration
+
+	// WatchConfig makes telegraf reload when its config files change, once
+	// they have been left unchanged for WatchConfigDebounce. The new config
+	// is only used if it is valid.
+	WatchConfig         bool
+	WatchConfigDebounce internal.Duration
+
+	// WatchDirectoryInterval, when nonzero, makes telegraf rescan the
+	// directories given with -config-directory at this interval, starting the
+	// plugins of added .conf files and stopping those of removed ones.
+	WatchDirectoryInterval internal.Duration
+
+	// InstanceMetadataURL is a cloud instance metadata JSON document, such as
+	// http://169.254.169.254/latest/dynamic/instance-identity/document.
+	// InstanceMetadataMap maps dot separated paths in this document to the
+	// global tags to set from them.
+	InstanceMetadataURL string `toml:"instance_metadata_url"`
+	InstanceMetadataMap map[string]string
+	// InstanceMetadataTokenTTL, when set, requests an IMDSv2 session token
+	// with this TTL before fetching the metadata document.
+	InstanceMetadataTokenTTL internal.Duration `toml:"instance_metadata_token_ttl"`
+
+	// PrometheusLabelsURL is a Prometheus /metrics endpoint, the labels of its
+	// PrometheusLabelsMetric metric, "telegraf_info" by default, are added to
+	// the global tags.
+	PrometheusLabelsURL    string `toml:"prometheus_labels_url"`
+	PrometheusLabelsMetric string
+
+	// TagsFile is a file of key=value global tags, one per line.
+	// TagsMergeStrategy decides which tag wins when a tag of TagsFile is also
+	// set in [global_tags]: "keep_existing" (the default) or "overwrite".
+	TagsFile          string
+	TagsMergeStrategy string
+
+	// TagPreprocessing are the rules of the [[agent.tag_preprocessing]]
+	// tables, applied to the global tags by PreprocessGlobalTags once the
+	// config is loaded, when the agent is created.
+	TagPreprocessing []TagRule `toml:"tag_preprocessing"`
+
+	// GlobalTagOverride makes global tags replace the tags of the same name
+	// set by inputs, which are kept by default.
+	GlobalTagOverride bool
+
+	// InternalStatsdPort, when nonzero, makes telegraf listen on UDP
+	// localhost at this port, and send its own internal metrics (buffer
+	// fullness, write errors, gather times) in statsd format to every address
+	// that sent it a datagram, once every FlushInterval.
+	InternalStatsdPort int
+
+	// SelfMonitorInterval, when nonzero, makes telegraf add its own internal
+	// metrics to the metrics sent to the outputs, once every
+	// SelfMonitorInterval.
+	SelfMonitorInterval internal.Duration
+
+	// MaxGoroutines limits how many input gathers may run at the same time
+	// within one collection interval. Zero means unlimited.
+	MaxGoroutines int
+
+	// CollectionConcurrency limits how many input gathers may run at the
+	// same time, including gathers still running from a previous interval.
+	// The time an input waits for its turn counts towards its collection
+	// interval warning. Zero means unlimited.
+	CollectionConcurrency int
+
+	// InputWorkers is the size of the pool of workers the gathers of all
+	// inputs are dispatched to, when greater than 1. 1 (the default) gathers
+	// from every input in its own goroutine. Gathers are still limited by
+	// MaxGoroutines and CollectionConcurrency.
+	InputWorkers int
+
+	// MetricTimestampSource is how metric timestamps are set: "agent" (the
+	// default) uses the timestamp set by the plugin, or the collection time,
+	// "ntp" the same, with the collection time corrected by the offset of the
+	// system clock to MetricTimestampNTPServer, "plugin" only the timestamp
+	// set by the plugin, and "zero" none, leaving them to the outputs.
+	MetricTimestampSource    string
+	MetricTimestampNTPServer string `toml:"metric_timestamp_ntp_server"`
+
+	// ConfigFormatVersion is the version of the config schema, 1 (the
+	// default) or 2. Version 2 rejects the legacy [plugins] section and the
+	// pass and drop aliases of fieldpass and fielddrop.
+	ConfigFormatVersion int
+
+	// Proxy holds the HTTP proxy settings of the [agent.proxy] table, shared
+	// by every plugin.
+	Proxy ProxyConfig
+
+	// TLSConfig holds the TLS settings of the [agent.tls] table, the defaults
+	// of the TLS options of every plugin implementing
+	// internal.DefaultTLSSetter.
+	TLSConfig internal.TLSConfig `toml:"tls"`
+
+	// S3Config holds the settings of the [agent.s3] table, used when loading
+	// the config from S3 with LoadConfigFromS3.
+	S3Config S3Config `toml:"s3"`
+
+	// GitConfig holds the settings of the [agent.git_config] table, used when
+	// loading the config from Git with LoadConfigFromGit.
+	GitConfig GitConfig `toml:"git_config"`
+
+	// EtcdConfig holds the settings of the [agent.etcd] table, used when
+	// loading the config from etcd with LoadConfigFromEtcd.
+	EtcdConfig EtcdConfig `toml:"etcd"`
+
+	// HistogramConfig holds the settings of the [agent.histogram] table, the
+	// buckets of the latency histograms of telegraf's internal metrics.
+	HistogramConfig HistogramConfig `toml:"histogram"`
+}
+
+// HistogramConfig holds the bucket upper bounds, in milliseconds, of the
+// gather latency histogram of each input and the write latency histogram of
+// each output, reported as internal_gather_latency and
+// internal_write_latency.
+type HistogramConfig struct {
+	GatherLatencyBuckets []float64 `toml:"gather_latency_buckets"`
+	WriteLatencyBuckets  []float64 `toml:"write_latency_buckets"`
+}
+
+// Lock locks the plugins of c for writing, for callers changing Inputs,
+// Outputs, DisabledInputs or DisabledOutputs while the config is in use. The
+// methods of c reading the plugins must not be called until Unlock.
+func (c *Config) Lock() {
+	c.mu.Lock()
+}
+
+// Unlock unlocks the plugins of c locked by Lock.
+func (c *Config) Unlock() {
+	c.mu.Unlock()
+}
+
+// RLock locks the plugins of c for reading, for callers reading Inputs,
+// Outputs, DisabledInputs or DisabledOutputs across several operations while
+// plugins may be added or switched. The methods of c reading or adding
+// plugins must not be called until RUnlock: they lock the plugins themselves,
+// which blocks while a writer is waiting.
+func (c *Config) RLock() {
+	c.mu.RLock()
+}
+
+// RUnlock unlocks the plugins of c locked by RLock.
+func (c *Config) RUnlock() {
+	c.mu.RUnlock()
+}
+
+// SetInputs replaces the inputs of c.
+func (c *Config) SetInputs(inputs []*models.RunningInput) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Inputs = inputs
+}
+
+// SetOutputs replaces the outputs of c.
+func (c *Config) SetOutputs(outputs []*models.RunningOutput) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Outputs = outputs
+}
+
+// AppendInput adds input to the inputs of c.
+func (c *Config) AppendInput(input *models.RunningInput) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Inputs = append(c.Inputs, input)
+}
+
+// AppendOutput adds output to the outputs of c.
+func (c *Config) AppendOutput(output *models.RunningOutput) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Outputs = append(c.Outputs, output)
+}
+
+// InputsSnapshot returns a copy of the inputs of c, to range over while
+// inputs may be added to c or removed.
+func (c *Config) InputsSnapshot() []*models.RunningInput {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	inputs := make([]*models.RunningInput, len(c.Inputs))
+	copy(inputs, c.Inputs)
+	return inputs
+}
+
+// OutputsSnapshot returns a copy of the outputs of c, to range over while
+// outputs may be added to c or switched.
+func (c *Config) OutputsSnapshot() []*models.RunningOutput {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	outputs := make([]*models.RunningOutput, len(c.Outputs))
+	copy(outputs, c.Outputs)
+	return outputs
+}
+
+// RemoveInput removes input from the inputs of c. The slice is copied, so
+// that callers ranging over the previous inputs are not affected.
+func (c *Config) RemoveInput(input *models.RunningInput) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var inputs []*models.RunningInput
+	for _, in := range c.Inputs {
+		if in != input {
+			inputs = append(inputs, in)
+		}
+	}
+	c.Inputs = inputs
+}
+
+// RemoveOutput removes output from the outputs of c. The slice is copied, so
+// that callers ranging over the previous outputs are not affected.
+func (c *Config) RemoveOutput(output *models.RunningOutput) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var outputs []*models.RunningOutput
+	for _, o := range c.Outputs {
+		if o != output {
+			outputs = append(outputs, o)
+		}
+	}
+	c.Outputs = outputs
 }
 
 // Inputs returns a list of strings of the configured inputs.
 func (c *Config) InputNames() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	var name []string
 	for _, input := range c.Inputs {
 		name = append(name, input.Name)
@@ -143,8 +529,52 @@ func (c *Config) InputNames() []string {
 	return name
 }
 
+// InputFilterByTag returns the inputs that have the plugin tag key set to
+// value, ie with "[inputs.cpu.tags]" holding key = "value".
+func (c *Config) InputFilterByTag(key, value string) []*models.RunningInput {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var inputs []*models.RunningInput
+	for _, input := range c.Inputs {
+		if v, ok := input.Config.Tags[key]; ok && v == value {
+			inputs = append(inputs, input)
+		}
+	}
+	return inputs
+}
+
+// ComputeEffectiveInterval returns the minimum and maximum time between two
+// collections of the named input, from its own interval, or the agent
+// interval, and the collection jitter. Each collection is delayed by a random
+// jitter of up to collection_jitter after its tick, so two collections are
+// between interval - jitter and interval + jitter apart. round_interval only
+// aligns the first collection, and does not change the time between
+// collections. Both durations are zero if the input is not loaded.
+func (c *Config) ComputeEffectiveInterval(inputName string) (min, max time.Duration) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, input := range c.Inputs {
+		if input.Name != inputName {
+			continue
+		}
+		interval := c.Agent.Interval.Duration
+		if input.Config.Interval != 0 {
+			interval = input.Config.Interval
+		}
+		jitter := c.Agent.CollectionJitter.Duration
+		min = interval - jitter
+		if min < 0 {
+			min = 0
+		}
+		return min, interval + jitter
+	}
+	return 0, 0
+}
+
 // Outputs returns a list of strings of the configured outputs.
 func (c *Config) OutputNames() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	var name []string
 	for _, output := range c.Outputs {
 		name = append(name, output.Name)
@@ -152,6 +582,259 @@ func (c *Config) OutputNames() []string {
 	return name
 }
 
+// GetInputConfig returns the config of the first input with the given name,
+// ie "cpu", and false if there is none. It is safe to call while plugins are
+// added to the config.
+func (c *Config) GetInputConfig(name string) (*models.InputConfig, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, input := range c.Inputs {
+		if input.Name == name {
+			return input.Config, true
+		}
+	}
+	return nil, false
+}
+
+// GetOutputConfig returns the config of the first output with the given
+// name, ie "influxdb", and false if there is none. It is safe to call while
+// plugins are added to the config.
+func (c *Config) GetOutputConfig(name string) (*models.OutputConfig, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, output := range c.Outputs {
+		if output.Name == name {
+			return output.Config, true
+		}
+	}
+	return nil, false
+}
+
+// SectionEnabled reports whether the given plugin section, ie "inputs.cpu"
+// or "outputs.influxdb", is enabled. A section is disabled only when it was
+// configured and every instance of it has "enabled = false".
+func (c *Config) SectionEnabled(section string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	parts := strings.SplitN(section, ".", 2)
+	if len(parts) != 2 {
+		return true
+	}
+
+	var enabled, disabled int
+	switch parts[0] {
+	case "inputs", "plugins":
+		for _, input := range c.Inputs {
+			if input.Name == parts[1] {
+				enabled++
+			}
+		}
+		for _, input := range c.DisabledInputs {
+			if input.Name == parts[1] {
+				disabled++
+			}
+		}
+	case "outputs":
+		for _, output := range c.Outputs {
+			if output.Name == parts[1] {
+				enabled++
+			}
+		}
+		for _, output := range c.DisabledOutputs {
+			if output.Name == parts[1] {
+				disabled++
+			}
+		}
+	}
+	return enabled > 0 || disabled == 0
+}
+
+// MergeConfig appends the inputs and outputs of other to c, and merges the
+// global tags of other into c. When a tag is set in both configs, the value
+// from c is kept. A warning is logged for every plugin instance of other that
+// is identical to an instance already in c; it is merged nonetheless.
+//
+// The plugins are not copied: c and other share the same RunningInput and
+// RunningOutput instances, so other must not be run or reloaded once merged.
+// The secret stores, config directories and sources of other are merged too,
+// those of c are kept when both have the same name or path.
+//
+// The [agent] settings of other are not merged, c keeps its own: the plugins
+// of other are run with the interval, buffer and other settings of c.
+func (c *Config) MergeConfig(other *Config) error {
+	if other == nil {
+		return errors.New("Cannot merge a nil config")
+	}
+	if other == c {
+		return errors.New("Cannot merge a config into itself")
+	}
+
+	// other is copied before c is locked, so that merging two configs into
+	// each other at the same time does not deadlock
+	other.mu.RLock()
+	tags := make(map[string]string, len(other.Tags))
+	for k, v := range other.Tags {
+		tags[k] = v
+	}
+	inputs := append([]*models.RunningInput(nil), other.Inputs...)
+	outputs := append([]*models.RunningOutput(nil), other.Outputs...)
+	disabledInputs := append([]*models.RunningInput(nil),
+		other.DisabledInputs...)
+	disabledOutputs := append([]*models.RunningOutput(nil),
+		other.DisabledOutputs...)
+	secretStores := make(map[string]*secretStore, len(other.secretStores))
+	for name, store := range other.secretStores {
+		secretStores[name] = store
+	}
+	directories := append([]string(nil), other.directories...)
+	directoryFiles := make(map[string]*directoryFile,
+		len(other.directoryFiles))
+	for path, file := range other.directoryFiles {
+		directoryFiles[path] = file
+	}
+	sources := append([]configSource(nil), other.sources...)
+	unregistered := append([]string(nil), other.unregistered...)
+	other.mu.RUnlock()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, v := range tags {
+		if _, ok := c.Tags[k]; !ok {
+			c.Tags[k] = v
+		}
+	}
+
+	for _, input := range inputs {
+		for _, existing := range c.Inputs {
+			if existing.Name == input.Name &&
+				reflect.DeepEqual(existing.Input, input.Input) &&
+				reflect.DeepEqual(existing.Config, input.Config) {
+				log.Printf("W! Merged config contains a duplicate of input %s\n",
+					input.Name)
+				break
+			}
+		}
+		c.Inputs = append(c.Inputs, input)
+	}
+	c.DisabledInputs = append(c.DisabledInputs, disabledInputs...)
+
+	for _, output := range outputs {
+		for _, existing := range c.Outputs {
+			if existing.Name == output.Name &&
+				reflect.DeepEqual(existing.Output, output.Output) &&
+				reflect.DeepEqual(existing.Config, output.Config) {
+				log.Printf("W! Merged config contains a duplicate of output %s\n",
+					output.Name)
+				break
+			}
+		}
+		c.Outputs = append(c.Outputs, output)
+	}
+	c.DisabledOutputs = append(c.DisabledOutputs, disabledOutputs...)
+
+	if c.secretStores == nil {
+		c.secretStores = make(map[string]*secretStore)
+	}
+	for name, store := range secretStores {
+		if _, ok := c.secretStores[name]; !ok {
+			c.secretStores[name] = store
+		}
+	}
+
+	for _, dir := range directories {
+		if !sliceContains(dir, c.directories) {
+			c.directories = append(c.directories, dir)
+		}
+	}
+	if c.directoryFiles == nil {
+		c.directoryFiles = make(map[string]*directoryFile)
+	}
+	for path, file := range directoryFiles {
+		if _, ok := c.directoryFiles[path]; !ok {
+			c.directoryFiles[path] = file
+		}
+	}
+
+	c.sources = append(c.sources, sources...)
+	for _, name := range unregistered {
+		if !sliceContains(name, c.unregistered) {
+			c.unregistered = append(c.unregistered, name)
+		}
+	}
+	return nil
+}
+
+// TagsFromFile reads global tags from a file of key=value lines. Blank lines
+// and lines starting with # are skipped. Tags already set are kept, unless
+// the tags_merge_strategy agent option is "overwrite".
+func (c *Config) TagsFromFile(path string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	overwrite := c.Agent.TagsMergeStrategy == "overwrite"
+	for i, line := range strings.Split(string(trimBOM(contents)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" {
+			return fmt.Errorf("%s:%d: expected key=value, got %q", path, i+1, line)
+		}
+		if _, ok := c.Tags[key]; ok && !overwrite {
+			continue
+		}
+		c.Tags[key] = strings.TrimSpace(parts[1])
+	}
+	return nil
+}
+
+// MergeGlobalTags adds the global tags of c to tags. A tag already set in
+// tags is kept, unless the global_tag_override agent option is set.
+func (c *Config) MergeGlobalTags(tags map[string]string) {
+	for k, v := range c.Tags {
+		if _, ok := tags[k]; ok && !c.Agent.GlobalTagOverride {
+			continue
+		}
+		tags[k] = v
+	}
+}
+
+// ApplyGlobalTags returns metric with the global tags of c added, see
+// MergeGlobalTags. Metrics cannot be changed, so a new metric is returned
+// when tags are added.
+func (c *Config) ApplyGlobalTags(metric telegraf.Metric) telegraf.Metric {
+	tags := metric.Tags()
+	c.MergeGlobalTags(tags)
+	if reflect.DeepEqual(tags, metric.Tags()) {
+		return metric
+	}
+
+	var out telegraf.Metric
+	var err error
+	switch metric.Type() {
+	case telegraf.Counter:
+		out, err = telegraf.NewCounterMetric(metric.Name(), tags,
+			metric.Fields(), metric.Time())
+	case telegraf.Gauge:
+		out, err = telegraf.NewGaugeMetric(metric.Name(), tags,
+			metric.Fields(), metric.Time())
+	default:
+		out, err = telegraf.NewMetric(metric.Name(), tags, metric.Fields(),
+			metric.Time())
+	}
+	if err != nil {
+		log.Printf("E! Could not add the global tags to %s: %s\n",
+			metric.Name(), err)
+		return metric
+	}
+	return out
+}
+
 // ListTags returns a string of tags specified in the config,
 // line-protocol style
 func (c *Config) ListTags() string {
@@ -180,6 +863,9 @@ var header = `# Telegraf Configuration
 # Environment variables can be used anywhere in this config file, simply prepend
 # them with $. For strings the variable must be within quotes (ie, "$STR_VAR"),
 # for numbers and booleans they should be plain (ie, $INT_VAR, $BOOL_VAR)
+# The ${VAR} and ${env:VAR} forms are also supported.
+#
+# Config files ending in .jsonnet are evaluated as Jsonnet (https://jsonnet.org).
 
 
 # Global tags can be specified here in key="value" format.
@@ -209,11 +895,18 @@ var header = `# Telegraf Configuration
   ## This buffer only fills when writes fail to output plugin(s).
   metric_buffer_limit = 10000
 
+  ## Average size in memory of a metric, used to estimate the memory needed by
+  ## the metric buffers.
+  # avg_metric_size_bytes = 200
+
   ## Collection jitter is used to jitter the collection by a random amount.
   ## Each plugin will sleep for a random time within jitter before collecting.
   ## This can be used to avoid many plugins querying things like sysfs at the
   ## same time, which can have a measurable effect on the system.
   collection_jitter = "0s"
+  ## Default collection timeout for all inputs, see the collection_timeout
+  ## input option. 0s disables it.
+  input_timeout = "0s"
 
   ## Default flushing interval for all outputs. You shouldn't set this below
   ## interval. Maximum flush_interval will be flush_interval + flush_jitter
@@ -229,6 +922,14 @@ var header = `# Telegraf Configuration
   ## Valid values are "ns", "us" (or "µs"), "ms", "s".
   precision = ""
 
+  ## How metric timestamps are set: "agent" uses the timestamp set by the
+  ## plugin, or the collection time, "ntp" also, with the collection time
+  ## corrected by the offset of the system clock to metric_timestamp_ntp_server,
+  ## "plugin" only the timestamp set by the plugin, and "zero" none, leaving
+  ## the outputs to set them.
+  metric_timestamp_source = "agent"
+  # metric_timestamp_ntp_server = "pool.ntp.org"
+
   ## Logging configuration:
   ## Run telegraf with debug log messages.
   debug = false
@@ -241,6 +942,196 @@ var header = `# Telegraf Configuration
   hostname = ""
   ## If set to true, do no set the "host" tag in the telegraf agent.
   omit_hostname = false
+  ## How to find the hostname when hostname is empty: "hostname" uses the
+  ## os hostname, "fqdn" its fully qualified domain name, and "dns" the
+  ## reverse DNS name of its first A record.
+  hostname_lookup = "hostname"
+  ## Add a prefix and a suffix to the hostname of the host tag, ie "-prod".
+  hostname_prefix = ""
+  hostname_suffix = ""
+
+  ## Write the PID of telegraf to this file while it is running.
+  pid_file = ""
+
+  ## Add a prefix and a suffix to every field name, ie "app_".
+  global_field_prefix = ""
+  global_field_suffix = ""
+
+  ## Fields dropped from every metric, whatever plugin produced it. Accepts
+  ## glob patterns, ie "password*". Matched before global_field_prefix and
+  ## global_field_suffix are added.
+  omit_fields = []
+
+  ## Only allow the tag keys matching metric_label_whitelist, if set, and not
+  ## matching metric_label_blacklist. Other tags are dropped, or renamed to
+  ## "unknown_<key>" if label_whitelist_action is "rename".
+  # metric_label_whitelist = ["host", "dc", "service"]
+  # metric_label_blacklist = []
+  # label_whitelist_action = "drop"
+
+  ## Rename the measurements matching regular expressions, ie during a
+  ## migration. The first matching rule, in the order below, is applied, and
+  ## $1, $2, ... refer to the capture groups of the expression.
+  # [agent.metric_name_replace]
+  #   "^docker_container_(.*)$" = "container_$1"
+
+  ## Limit the number of values of each tag key, and the number of tag keys,
+  ## of the metrics sent to the outputs. The first values and keys seen are
+  ## kept. 0 disables a limit.
+  max_tag_values_per_key = 0
+  max_tag_key_cardinality = 0
+  ## What to do with a metric whose tag is over a limit: "replace" replaces
+  ## the tag value with "_exceeded", "drop" drops the metric.
+  tag_cardinality_limit_action = "replace"
+
+  ## What to do when a plugin fails to start: "exit" stops telegraf, "skip"
+  ## runs without the plugin, and "retry" retries to start outputs and
+  ## service inputs every startup_retry_interval.
+  startup_error_behavior = "exit"
+  startup_retry_interval = "30s"
+
+  ## Reload telegraf when its config files change, once they have been left
+  ## unchanged for watch_config_debounce. An invalid new config is ignored.
+  watch_config = false
+  watch_config_debounce = "3s"
+
+  ## If nonzero, rescan the -config-directory directories at this interval,
+  ## starting the plugins of added .conf files and stopping those of removed
+  ## files.
+  watch_directory_interval = "0s"
+
+  ## Add global tags from a file of key=value lines. Tags already set in
+  ## [global_tags] are kept, unless tags_merge_strategy is "overwrite".
+  # tags_file = "/etc/telegraf/tags"
+  # tags_merge_strategy = "keep_existing"
+
+  ## Rules applied in order to the values of the global tags, once the config
+  ## is loaded. The action is "lower", "upper", "trim" (of the characters of
+  ## args, whitespace by default) or "replace" (of args[0] by args[1]), and
+  ## the key "*" applies the rule to every tag.
+  # [[agent.tag_preprocessing]]
+  #   key = "*"
+  #   action = "trim"
+  # [[agent.tag_preprocessing]]
+  #   key = "dc"
+  #   action = "replace"
+  #   args = [" ", "-"]
+
+  ## Global tags replace the tags of the same name set by inputs if true, by
+  ## default the tags of the inputs are kept.
+  global_tag_override = false
+
+  ## Add global tags from a cloud instance metadata JSON document. Set
+  ## instance_metadata_token_ttl to use IMDSv2 session tokens. Tags set in
+  ## [global_tags] take precedence.
+  # instance_metadata_url = "http://169.254.169.254/latest/dynamic/instance-identity/document"
+  # instance_metadata_token_ttl = "6h"
+  # [agent.instance_metadata_map]
+  #   instanceId = "instance_id"
+  #   region = "region"
+  #   availabilityZone = "availability_zone"
+
+  ## Add global tags from the labels of a metric of a Prometheus /metrics
+  ## endpoint, telegraf_info by default. Tags set in [global_tags] take
+  ## precedence.
+  # prometheus_labels_url = "http://localhost:9100/metrics"
+  # prometheus_labels_metric = "telegraf_info"
+
+  ## If nonzero, listen on this UDP port of localhost, and send telegraf's own
+  ## internal metrics in statsd format every flush_interval to every address
+  ## that sent a datagram to it.
+  internal_statsd_port = 0
+
+  ## If nonzero, add telegraf's own internal metrics to the metrics sent to
+  ## the outputs at this interval.
+  self_monitor_interval = "0s"
+
+  ## Which metrics to drop when an output metric buffer is full, either
+  ## "drop_oldest" or "drop_newest".
+  metric_overflow_strategy = "drop_oldest"
+
+  ## Maximum number of inputs gathering at the same time within one collection
+  ## interval. 0 means unlimited.
+  max_goroutines = 0
+
+  ## Maximum number of inputs gathering at the same time, whichever interval
+  ## they gather for. 0 means unlimited.
+  collection_concurrency = 0
+
+  ## Number of workers the gathers of all inputs are dispatched to. 1 gathers
+  ## from every input in its own goroutine.
+  input_workers = 1
+
+  ## Version of the config schema. Version 2 rejects the legacy [plugins]
+  ## section, and the pass and drop aliases of fieldpass and fielddrop.
+  config_format_version = 1
+
+  ## Log level of single plugins, by plugin name, ie "cpu" or "inputs.cpu":
+  ## "debug", "info", "warn" or "error". Only applies to plugins with a
+  ## logger of their own, the others use the global log level.
+  # [agent.per_plugin_log_level]
+  #   "inputs.exec" = "debug"
+
+  ## HTTP proxy settings used by all plugins, set as the HTTP_PROXY,
+  ## HTTPS_PROXY and NO_PROXY environment variables.
+  # [agent.proxy]
+  #   http_proxy = "http://proxy.example.com:3128"
+  #   https_proxy = "http://proxy.example.com:3128"
+  #   no_proxy = "localhost,127.0.0.1"
+
+  ## TLS settings used by every plugin with TLS options, unless the plugin
+  ## sets them itself. Each option set for a plugin overrides its default.
+  # [agent.tls]
+  #   ssl_ca = "/etc/telegraf/ca.pem"
+  #   ssl_cert = "/etc/telegraf/cert.pem"
+  #   ssl_key = "/etc/telegraf/key.pem"
+  #   insecure_skip_verify = false
+
+  ## Load the rest of the config from an S3 object at startup, with the
+  ## credentials of the standard AWS credential chain.
+  # [agent.s3]
+  #   bucket = "telegraf-configs"
+  #   key = "telegraf.conf"
+  #   region = "us-east-1"
+  #   ## Local copy of the last config fetched from S3, loaded instead when
+  #   ## S3 cannot be reached.
+  #   s3_cache_path = "/var/lib/telegraf/s3-config.conf"
+
+  ## Load the rest of the config from a file or directory of a Git
+  ## repository at startup, and reload telegraf when the branch has a new
+  ## commit. The git command, which is not bundled with telegraf, must be
+  ## installed and in the PATH.
+  # [agent.git_config]
+  #   repository = "https://git.example.com/telegraf-configs.git"
+  #   branch = "master"
+  #   path = "telegraf.conf"
+  #   ## Private key for ssh repository URLs.
+  #   ssh_key_path = "/etc/telegraf/git_id_rsa"
+  #   ## Credentials for http and https repository URLs.
+  #   username = "telegraf"
+  #   password = "$GIT_PASSWORD"
+  #   ## How often the branch is checked for new commits.
+  #   polling_interval = "1m"
+
+  ## Load the rest of the config from a key of etcd 3.4 or newer at startup,
+  ## and reload telegraf when the key is put again.
+  # [agent.etcd]
+  #   endpoints = ["http://127.0.0.1:2379"]
+  #   key = "/telegraf/config"
+  #   ## How long connecting to etcd and fetching the config may take.
+  #   dial_timeout = "5s"
+  #   username = "telegraf"
+  #   password = "$ETCD_PASSWORD"
+  #   ## How often the key is checked for a new config.
+  #   polling_interval = "1m"
+  #   [agent.etcd.tls]
+  #     ssl_ca = "/etc/telegraf/etcd-ca.pem"
+
+  ## Bucket upper bounds, in milliseconds, of the gather and write latency
+  ## histograms of telegraf's own internal metrics.
+  # [agent.histogram]
+  #   gather_latency_buckets = [1.0, 5.0, 10.0, 50.0, 100.0, 500.0, 1000.0, 5000.0, 10000.0]
+  #   write_latency_buckets = [5.0, 10.0, 50.0, 100.0, 250.0, 500.0, 1000.0, 2500.0, 5000.0, 10000.0]
 
 
 ###############################################################################
@@ -298,6 +1189,164 @@ func PrintSampleConfig(inputFilters []string, outputFilters []string) {
 	}
 }
 
+// PrintAgentConfig writes the [agent] table to w, with every AgentConfig
+// option set to its default value and documented with the comments of the
+// sample config.
+func PrintAgentConfig(w io.Writer) error {
+	docs := agentConfigDocs()
+	var buf bytes.Buffer
+	buf.WriteString("# Configuration for telegraf agent\n[agent]\n")
+	printAgentTable(&buf, reflect.ValueOf(*NewConfig().Agent), "agent", docs,
+		reflect.Value{})
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// printAgentTable writes the options of v, a struct of the agent config. When
+// def is valid, it holds the default options, used to annotate the options of
+// v left at zero.
+func printAgentTable(
+	buf *bytes.Buffer,
+	v reflect.Value,
+	table string,
+	docs map[string][]string,
+	def reflect.Value,
+) {
+	var subTables []int
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" || field.Tag.Get("toml") == "-" {
+			continue
+		}
+		if field.Type.Kind() == reflect.Map ||
+			(field.Type.Kind() == reflect.Struct &&
+				field.Type != reflect.TypeOf(internal.Duration{})) {
+			subTables = append(subTables, i)
+			continue
+		}
+
+		key := tomlKey(field)
+		for _, line := range docs[table+"."+key] {
+			fmt.Fprintf(buf, "  %s\n", line)
+		}
+		fmt.Fprintf(buf, "  %s = %s", key, tomlValue(v.Field(i)))
+		if def.IsValid() {
+			writeDefault(buf, v.Field(i), def.Field(i))
+		}
+		buf.WriteString("\n")
+	}
+
+	for _, i := range subTables {
+		key := tomlKey(v.Type().Field(i))
+		buf.WriteString("\n")
+		for _, line := range docs[table+"."+key] {
+			fmt.Fprintf(buf, "  %s\n", line)
+		}
+		fmt.Fprintf(buf, "  [%s.%s]\n", table, key)
+		if v.Field(i).Kind() == reflect.Map {
+			var keys []string
+			for _, k := range v.Field(i).MapKeys() {
+				keys = append(keys, k.String())
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(buf, "    %s = %s\n", k,
+					tomlValue(v.Field(i).MapIndex(reflect.ValueOf(k))))
+			}
+			continue
+		}
+		var sub bytes.Buffer
+		var subDef reflect.Value
+		if def.IsValid() {
+			subDef = def.Field(i)
+		}
+		printAgentTable(&sub, v.Field(i), table+"."+key, docs, subDef)
+		for _, line := range strings.SplitAfter(sub.String(), "\n") {
+			if line != "" {
+				buf.WriteString("  " + line)
+			}
+		}
+	}
+}
+
+// agentConfigDocs returns the comment lines of the sample [agent] table, by
+// "agent.<key>" for each option and sub table.
+func agentConfigDocs() map[string][]string {
+	docs := make(map[string][]string)
+	start := strings.Index(header, "[agent]\n")
+	if start < 0 {
+		return docs
+	}
+	var comments []string
+	for _, line := range strings.Split(header[start:], "\n")[1:] {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "## "):
+			comments = append(comments, line)
+		case strings.HasPrefix(line, "# [agent.") && strings.HasSuffix(line, "]"):
+			docs[strings.Trim(line, "# []")] = comments
+			comments = nil
+		case strings.HasPrefix(line, "# ") && strings.Contains(line, " = "):
+			// commented out example options share the comments above them
+			key := strings.TrimSpace(strings.SplitN(line[2:], "=", 2)[0])
+			if _, ok := docs["agent."+key]; !ok {
+				docs["agent."+key] = comments
+			}
+		case strings.Contains(line, " = ") && !strings.HasPrefix(line, "#"):
+			key := strings.TrimSpace(strings.SplitN(line, "=", 2)[0])
+			docs["agent."+key] = comments
+			comments = nil
+		case line == "" || strings.HasPrefix(line, "###"):
+			comments = nil
+		}
+	}
+	return docs
+}
+
+// tomlKey returns the config key of a struct field: its toml tag, or its name
+// in snake case.
+func tomlKey(field reflect.StructField) string {
+	if tag := field.Tag.Get("toml"); tag != "" {
+		return tag
+	}
+	var key []rune
+	name := []rune(field.Name)
+	for i, r := range name {
+		upper := unicode.IsUpper(r)
+		if i > 0 && upper && (!unicode.IsUpper(name[i-1]) ||
+			(i+1 < len(name) && unicode.IsLower(name[i+1]))) {
+			key = append(key, '_')
+		}
+		key = append(key, unicode.ToLower(r))
+	}
+	return string(key)
+}
+
+// tomlValue formats a config value as TOML.
+func tomlValue(v reflect.Value) string {
+	if d, ok := v.Interface().(internal.Duration); ok {
+		return fmt.Sprintf("%q", d.Duration.String())
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	case reflect.Float32, reflect.Float64:
+		s := strconv.FormatFloat(v.Float(), 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	case reflect.Slice:
+		var values []string
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, tomlValue(v.Index(i)))
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	default:
+		return fmt.Sprintf("%v", v.Interface())
+	}
+}
+
 func printFilteredInputs(inputFilters []string, commented bool) {
 	// Filter inputs
 	var pnames []string
@@ -414,37 +1463,130 @@ func PrintOutputConfig(name string) error {
 	return nil
 }
 
+// PrintPluginDoc prints the documentation of a single plugin in Markdown.
+// pluginType is either "inputs" or "outputs".
+func (c *Config) PrintPluginDoc(pluginType, pluginName string) error {
+	return c.WritePluginDoc(os.Stdout, pluginType, pluginName)
+}
+
+// WritePluginDoc writes the documentation of a single plugin in Markdown to w,
+// with a Description section and a Configuration section holding the sample
+// config as a fenced TOML block.
+func (c *Config) WritePluginDoc(
+	w io.Writer,
+	pluginType string,
+	pluginName string,
+) error {
+	var p printer
+	switch pluginType {
+	case "inputs", "input":
+		creator, ok := inputs.Inputs[pluginName]
+		if !ok {
+			return fmt.Errorf("Input %s not found", pluginName)
+		}
+		p = creator()
+		pluginType = "inputs"
+	case "outputs", "output":
+		creator, ok := outputs.Outputs[pluginName]
+		if !ok {
+			return fmt.Errorf("Output %s not found", pluginName)
+		}
+		p = creator()
+		pluginType = "outputs"
+	default:
+		return fmt.Errorf("Unknown plugin type %s", pluginType)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# %s\n\n", pluginName)
+	fmt.Fprintf(&buf, "## Description\n\n%s\n\n", p.Description())
+	fmt.Fprintf(&buf, "## Configuration\n\n```toml\n[[%s.%s]]\n",
+		pluginType, pluginName)
+	config := strings.Trim(p.SampleConfig(), "\n")
+	if config == "" {
+		buf.WriteString("  # no configuration\n")
+	} else {
+		for _, line := range strings.Split(config, "\n") {
+			buf.WriteString(strings.TrimRight(line, " ") + "\n")
+		}
+	}
+	buf.WriteString("```\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
 func (c *Config) LoadDirectory(path string) error {
-	walkfn := func(thispath string, info os.FileInfo, _ error) error {
+	files, err := configFilesIn(path)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		inputs, outputs := len(c.Inputs), len(c.Outputs)
+		if err := c.LoadConfig(file); err != nil {
+			return err
+		}
+		c.directoryFiles[file] = &directoryFile{
+			inputs:  append([]*models.RunningInput(nil), c.Inputs[inputs:]...),
+			outputs: append([]*models.RunningOutput(nil), c.Outputs[outputs:]...),
+		}
+	}
+	c.directories = append(c.directories, path)
+	return nil
+}
+
+// configFilesIn returns the .conf files of the directory at path and of its
+// subdirectories, in lexical order.
+func configFilesIn(path string) ([]string, error) {
+	var files []string
+	walkfn := func(thispath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
 		if info.IsDir() {
+			// Skip hidden directories, such as the timestamped directories
+			// of Kubernetes ConfigMap volumes, whose files are already
+			// linked into the top-level directory.
+			if thispath != path && strings.HasPrefix(name, "..") {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		name := info.Name()
 		if len(name) < 6 || name[len(name)-5:] != ".conf" {
 			return nil
 		}
-		err := c.LoadConfig(thispath)
-		if err != nil {
-			return err
+		// Follow symlinks to .conf files, but not to directories.
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(thispath)
+			if err != nil {
+				return err
+			}
+			if target.IsDir() {
+				return nil
+			}
 		}
+		files = append(files, thispath)
 		return nil
 	}
-	return filepath.Walk(path, walkfn)
+	if err := filepath.Walk(path, walkfn); err != nil {
+		return nil, err
+	}
+	return files, nil
 }
 
-// Try to find a default config file at these locations (in order):
+// Try to find a default config file at the locations of defaultConfigPaths,
+// in order:
 //   1. $TELEGRAF_CONFIG_PATH
 //   2. $HOME/.telegraf/telegraf.conf
-//   3. /etc/telegraf/telegraf.conf
+//   3. /etc/telegraf/telegraf.conf, or on Windows
+//      C:\Program Files\Telegraf\telegraf.conf,
+//      %PROGRAMDATA%\Telegraf\telegraf.conf, then
+//      %APPDATA%\Telegraf\telegraf.conf
 //
 func getDefaultConfigPath() (string, error) {
-	envfile := os.Getenv("TELEGRAF_CONFIG_PATH")
-	homefile := os.ExpandEnv("${HOME}/.telegraf/telegraf.conf")
-	etcfile := "/etc/telegraf/telegraf.conf"
-	if runtime.GOOS == "windows" {
-		etcfile = `C:\Program Files\Telegraf\telegraf.conf`
-	}
-	for _, path := range []string{envfile, homefile, etcfile} {
+	paths := defaultConfigPaths(runtime.GOOS)
+	for _, path := range paths {
 		if _, err := os.Stat(path); err == nil {
 			log.Printf("I! Using config file: %s", path)
 			return path, nil
@@ -453,19 +1595,89 @@ func getDefaultConfigPath() (string, error) {
 
 	// if we got here, we didn't find a file in a default location
 	return "", fmt.Errorf("No config file specified, and could not find one"+
-		" in $TELEGRAF_CONFIG_PATH, %s, or %s", homefile, etcfile)
+		" in any of: %s", strings.Join(paths, ", "))
+}
+
+// defaultConfigPaths returns the locations searched for a config file, in
+// order of priority. Locations built from unset environment variables are
+// left out.
+func defaultConfigPaths(goos string) []string {
+	var paths []string
+	if envfile := os.Getenv("TELEGRAF_CONFIG_PATH"); envfile != "" {
+		paths = append(paths, envfile)
+	}
+	if home := os.Getenv("HOME"); home != "" {
+		paths = append(paths, filepath.Join(home, ".telegraf", "telegraf.conf"))
+	}
+
+	if goos != "windows" {
+		return append(paths, "/etc/telegraf/telegraf.conf")
+	}
+
+	// Any user may create files in %PROGRAMDATA%, so Program Files, which
+	// only administrators can write to, comes first. %APPDATA% is used for
+	// per-user installs.
+	paths = append(paths, `C:\Program Files\Telegraf\telegraf.conf`)
+	if programData := os.Getenv("PROGRAMDATA"); programData != "" {
+		paths = append(paths, programData+`\Telegraf\telegraf.conf`)
+	}
+	if appData := os.Getenv("APPDATA"); appData != "" {
+		paths = append(paths, appData+`\Telegraf\telegraf.conf`)
+	}
+	return paths
 }
 
 // LoadConfig loads the given config file and applies it to c
 func (c *Config) LoadConfig(path string) error {
+	return c.LoadConfigWithOverrides(path, nil)
+}
+
+// LoadFromReader loads a config from r, the same way LoadConfig loads a
+// config file.
+func (c *Config) LoadFromReader(r io.Reader) error {
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	tbl, err := parseContents(contents)
+	if err != nil {
+		return fmt.Errorf("Error parsing config, %s", err)
+	}
+	if err = c.loadTable("config", tbl); err != nil {
+		return err
+	}
+	c.sources = append(c.sources, configSource{path: "config",
+		contents: contents})
+	return nil
+}
+
+// LoadConfigString loads a config from a TOML string.
+func (c *Config) LoadConfigString(content string) error {
+	return c.LoadFromReader(strings.NewReader(content))
+}
+
+// loadTable loads the parsed config tbl, read from path.
+func (c *Config) loadTable(path string, tbl *ast.Table) error {
 	var err error
-	if path == "" {
-		if path, err = getDefaultConfigPath(); err != nil {
-			return err
+	if !c.defaultsApplied {
+		c.ApplyDefaults()
+	}
+
+	// Register secret stores, then resolve the secrets they hold, before
+	// anything else is parsed:
+	if val, ok := tbl.Fields["secret_store"]; ok {
+		subTables, ok := val.([]*ast.Table)
+		if !ok {
+			return fmt.Errorf("%s: invalid configuration, secret_store must "+
+				"be an array of tables", path)
+		}
+		for _, subTable := range subTables {
+			if err = c.addSecretStore(subTable); err != nil {
+				return fmt.Errorf("Error parsing %s, %s", path, err)
+			}
 		}
 	}
-	tbl, err := parseFile(path)
-	if err != nil {
+	if err = c.resolveSecrets(tbl); err != nil {
 		return fmt.Errorf("Error parsing %s, %s", path, err)
 	}
 
@@ -489,14 +1701,65 @@ func (c *Config) LoadConfig(path string) error {
 		if !ok {
 			return fmt.Errorf("%s: invalid configuration", path)
 		}
+		if node, ok := subTable.Fields["metric_name_replace"]; ok {
+			if rulesTbl, ok := node.(*ast.Table); ok {
+				c.Agent.MetricNameReplace, err = buildNameReplace(rulesTbl)
+				if err != nil {
+					return fmt.Errorf("Error parsing %s, metric_name_replace: %s",
+						path, err)
+				}
+			}
+			delete(subTable.Fields, "metric_name_replace")
+		}
 		if err = config.UnmarshalTable(subTable, c.Agent); err != nil {
 			log.Printf("E! Could not parse [agent] config\n")
 			return fmt.Errorf("Error parsing %s, %s", path, err)
 		}
+		c.restoreAgentTableDefaults(subTable)
+		c.translateDeprecatedAgentOptions(path)
+
+		if c.Agent.InstanceMetadataURL != "" && !c.instanceMetadataLoaded {
+			if err = c.loadInstanceMetadataTags(); err != nil {
+				return fmt.Errorf("Error loading instance metadata tags, %s",
+					err)
+			}
+			c.instanceMetadataLoaded = true
+		}
+
+		if c.Agent.PrometheusLabelsURL != "" && !c.prometheusLabelsLoaded {
+			err = c.LoadGlobalTagsFromPrometheusLabels(
+				c.Agent.PrometheusLabelsURL)
+			if err != nil {
+				return fmt.Errorf("Error loading Prometheus labels tags, %s",
+					err)
+			}
+			c.prometheusLabelsLoaded = true
+		}
+
+		if c.Agent.TagsFile != "" {
+			if err = c.TagsFromFile(c.Agent.TagsFile); err != nil {
+				return fmt.Errorf("Error parsing %s, %s", path, err)
+			}
+		}
+	}
+	if err = checkTagRules(c.Agent.TagPreprocessing); err != nil {
+		return fmt.Errorf("Error parsing %s, tag_preprocessing: %s", path, err)
+	}
+	// The version is known before any plugin is parsed, as it changes how
+	// they are parsed:
+	switch c.Agent.ConfigFormatVersion {
+	case 1, 2:
+	default:
+		return fmt.Errorf("%s: invalid config_format_version %d, must be 1 "+
+			"or 2", path, c.Agent.ConfigFormatVersion)
 	}
 
 	// Parse all the rest of the plugins:
+	inputs, outputs := len(c.Inputs), len(c.Outputs)
 	for name, val := range tbl.Fields {
+		if name == "secret_store" {
+			continue
+		}
 		subTable, ok := val.(*ast.Table)
 		if !ok {
 			return fmt.Errorf("%s: invalid configuration", path)
@@ -523,6 +1786,10 @@ func (c *Config) LoadConfig(path string) error {
 				}
 			}
 		case "inputs", "plugins":
+			if name == "plugins" && c.Agent.ConfigFormatVersion >= 2 {
+				return fmt.Errorf("%s: the [plugins] section is not "+
+					"supported by config_format_version 2, use [inputs]", path)
+			}
 			for pluginName, pluginVal := range subTable.Fields {
 				switch pluginSubTable := pluginVal.(type) {
 				case *ast.Table:
@@ -548,34 +1815,296 @@ func (c *Config) LoadConfig(path string) error {
 			}
 		}
 	}
+
+	for _, input := range c.Inputs[inputs:] {
+		input.Source = path
+	}
+	for _, output := range c.Outputs[outputs:] {
+		output.Source = path
+	}
 	return nil
 }
 
-// trimBOM trims the Byte-Order-Marks from the beginning of the file.
-// this is for Windows compatability only.
+// AddInputFromTOML parses a TOML fragment holding one or more input tables,
+// such as "[[inputs.cpu]]\n  percpu = true", and adds the inputs to the
+// config. It returns an error if the TOML is malformed, if it holds anything
+// other than inputs, or if a plugin is unknown. On error no input is added.
+func (c *Config) AddInputFromTOML(fragment string) error {
+	return c.addPluginsFromTOML(fragment, []string{"inputs", "plugins"},
+		(*Config).addInput)
+}
+
+// AddOutputFromTOML parses a TOML fragment holding one or more output tables
+// and adds the outputs to the config, like AddInputFromTOML does for inputs.
+func (c *Config) AddOutputFromTOML(fragment string) error {
+	return c.addPluginsFromTOML(fragment, []string{"outputs"},
+		(*Config).addOutput)
+}
+
+func (c *Config) addPluginsFromTOML(
+	fragment string,
+	sections []string,
+	add func(c *Config, name string, table *ast.Table) error,
+) error {
+	tbl, err := parseContents([]byte(fragment))
+	if err != nil {
+		return fmt.Errorf("Error parsing TOML fragment, %s", err)
+	}
+	if err = c.resolveSecrets(tbl); err != nil {
+		return err
+	}
+	if len(tbl.Fields) == 0 {
+		return fmt.Errorf("TOML fragment does not contain any %s", sections[0])
+	}
+
+	// Build the plugins into a scratch config first, so that nothing is added
+	// if one of them fails. They are built with the [agent] settings of c.
+	tmp := NewConfig()
+	tmp.InputFilters = c.InputFilters
+	tmp.OutputFilters = c.OutputFilters
+	tmp.Version = c.Version
+	tmp.secretStores = c.secretStores
+	agent := *c.Agent
+	tmp.Agent = &agent
+
+	for name, val := range tbl.Fields {
+		subTable, ok := val.(*ast.Table)
+		if !ok || !sliceContains(name, sections) {
+			return fmt.Errorf("TOML fragment may only contain %s, found %s",
+				sections[0], name)
+		}
+		for pluginName, pluginVal := range subTable.Fields {
+			switch pluginSubTable := pluginVal.(type) {
+			case *ast.Table:
+				if err = add(tmp, pluginName, pluginSubTable); err != nil {
+					return err
+				}
+			case []*ast.Table:
+				for _, t := range pluginSubTable {
+					if err = add(tmp, pluginName, t); err != nil {
+						return err
+					}
+				}
+			default:
+				return fmt.Errorf("Unsupported config format: %s", pluginName)
+			}
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	outputs := c.Outputs
+	c.Outputs = append(c.Outputs, tmp.Outputs...)
+	if err = c.linkFailoverOutputs(); err != nil {
+		// link the outputs of c again, which linked before
+		c.Outputs = outputs
+		c.linkFailoverOutputs()
+		return err
+	}
+	c.Inputs = append(c.Inputs, tmp.Inputs...)
+	c.DisabledInputs = append(c.DisabledInputs, tmp.DisabledInputs...)
+	c.DisabledOutputs = append(c.DisabledOutputs, tmp.DisabledOutputs...)
+	return c.rebuildFilters()
+}
+
+// translateDeprecatedAgentOptions replaces deprecated [agent] options by
+// their newer equivalent, and records a deprecation message for each.
+func (c *Config) translateDeprecatedAgentOptions(path string) {
+	if c.Agent.FlushBufferWhenFull {
+		msg := fmt.Sprintf("%s: flush_buffer_when_full is deprecated, the "+
+			"buffer is always flushed when full. Remove it, and set "+
+			"metric_overflow_strategy = \"drop_oldest\" instead", path)
+		log.Printf("W! %s\n", msg)
+		c.Agent.Deprecated = append(c.Agent.Deprecated, msg)
+		c.Agent.FlushBufferWhenFull = false
+		if c.Agent.MetricOverflowStrategy == "" {
+			c.Agent.MetricOverflowStrategy = models.OVERFLOW_DROP_OLDEST
+		}
+	}
+}
+
+// Validate checks the loaded config for settings that cannot work together.
+// Deprecated options do not fail validation, they are listed in
+// Agent.Deprecated.
+func (c *Config) Validate() error {
+	switch c.Agent.MetricOverflowStrategy {
+	case "", models.OVERFLOW_DROP_OLDEST, models.OVERFLOW_DROP_NEWEST:
+	default:
+		return fmt.Errorf("Invalid metric_overflow_strategy %q, must be %q "+
+			"or %q", c.Agent.MetricOverflowStrategy,
+			models.OVERFLOW_DROP_OLDEST, models.OVERFLOW_DROP_NEWEST)
+	}
+	switch c.Agent.TagsMergeStrategy {
+	case "", "keep_existing", "overwrite":
+	default:
+		return fmt.Errorf("Invalid tags_merge_strategy %q, must be "+
+			"\"keep_existing\" or \"overwrite\"", c.Agent.TagsMergeStrategy)
+	}
+	switch c.Agent.LabelWhitelistAction {
+	case "", models.LABEL_POLICY_DROP, models.LABEL_POLICY_RENAME:
+	default:
+		return fmt.Errorf("Invalid label_whitelist_action %q, must be %q or %q",
+			c.Agent.LabelWhitelistAction, models.LABEL_POLICY_DROP,
+			models.LABEL_POLICY_RENAME)
+	}
+	switch c.Agent.TagCardinalityLimitAction {
+	case "", models.TAG_LIMIT_REPLACE, models.TAG_LIMIT_DROP:
+	default:
+		return fmt.Errorf("Invalid tag_cardinality_limit_action %q, must be "+
+			"%q or %q", c.Agent.TagCardinalityLimitAction,
+			models.TAG_LIMIT_REPLACE, models.TAG_LIMIT_DROP)
+	}
+	for name, level := range c.Agent.PerPluginLogLevel {
+		switch strings.ToLower(level) {
+		case "debug", "info", "warn", "error":
+		default:
+			return fmt.Errorf("Invalid per_plugin_log_level %q for %s, must "+
+				"be \"debug\", \"info\", \"warn\" or \"error\"", level, name)
+		}
+	}
+	switch c.Agent.MetricTimestampSource {
+	case "", "agent", "ntp", "plugin", "zero":
+	default:
+		return fmt.Errorf("Invalid metric_timestamp_source %q, must be "+
+			"\"agent\", \"ntp\", \"plugin\" or \"zero\"",
+			c.Agent.MetricTimestampSource)
+	}
+	switch c.Agent.StartupErrorBehavior {
+	case "", "exit", "skip", "retry":
+	default:
+		return fmt.Errorf("Invalid startup_error_behavior %q, must be \"exit\", "+
+			"\"skip\" or \"retry\"", c.Agent.StartupErrorBehavior)
+	}
+	if c.Agent.StartupErrorBehavior == "retry" &&
+		c.Agent.StartupRetryInterval.Duration <= 0 {
+		return fmt.Errorf("startup_retry_interval must be positive")
+	}
+	switch c.Agent.HostnameLookup {
+	case "", "hostname", "fqdn", "dns":
+	default:
+		return fmt.Errorf("Invalid hostname_lookup %q, must be \"hostname\", "+
+			"\"fqdn\" or \"dns\"", c.Agent.HostnameLookup)
+	}
+	if err := models.ValidateLatencyBuckets(
+		c.Agent.HistogramConfig.GatherLatencyBuckets); err != nil {
+		return fmt.Errorf("Invalid gather_latency_buckets, %s", err)
+	}
+	if err := models.ValidateLatencyBuckets(
+		c.Agent.HistogramConfig.WriteLatencyBuckets); err != nil {
+		return fmt.Errorf("Invalid write_latency_buckets, %s", err)
+	}
+	if err := c.validatePlugins(); err != nil {
+		return err
+	}
+	for _, warning := range c.CheckPluginVersionCompatibility() {
+		if warning.Severity == SeverityError {
+			return fmt.Errorf("Incompatible plugin: %s", warning)
+		}
+		log.Printf("W! Config: %s\n", warning)
+	}
+	return nil
+}
+
+// validatePlugins checks that c has inputs and outputs, and links their
+// failover outputs.
+func (c *Config) validatePlugins() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.Outputs) == 0 {
+		return errors.New("no outputs found, did you provide a valid config file?")
+	}
+	if len(c.Inputs) == 0 {
+		return errors.New("no inputs found, did you provide a valid config file?")
+	}
+	return c.linkFailoverOutputs()
+}
+
+// LinkFailoverOutputs sets the failover output of every output configured
+// with failover_to, looking it up by its alias, and puts the outputs failed
+// over to on standby. Unknown aliases and failover chains that loop back on
+// themselves are errors.
+func (c *Config) LinkFailoverOutputs() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.linkFailoverOutputs()
+}
+
+func (c *Config) linkFailoverOutputs() error {
+	aliases := make(map[string]*models.RunningOutput)
+	for _, ro := range c.Outputs {
+		if ro.Config.Alias == "" {
+			continue
+		}
+		if _, ok := aliases[ro.Config.Alias]; ok {
+			return fmt.Errorf("Duplicate output alias %s", ro.Config.Alias)
+		}
+		aliases[ro.Config.Alias] = ro
+	}
+
+	// outputs that are failed over to are on standby, they only receive the
+	// metrics of the outputs failing over to them
+	standby := make(map[*models.RunningOutput]bool)
+	for _, ro := range c.Outputs {
+		if ro.Config.FailoverTo == "" {
+			continue
+		}
+		failover, ok := aliases[ro.Config.FailoverTo]
+		if !ok {
+			return fmt.Errorf("Output %s fails over to unknown alias %s",
+				ro.Name, ro.Config.FailoverTo)
+		}
+		ro.SetFailover(failover)
+		standby[failover] = true
+	}
+	for _, ro := range c.Outputs {
+		ro.SetStandby(standby[ro])
+	}
+
+	for _, ro := range c.Outputs {
+		seen := map[*models.RunningOutput]bool{ro: true}
+		for next := aliases[ro.Config.FailoverTo]; next != nil; next = aliases[next.Config.FailoverTo] {
+			if seen[next] {
+				return fmt.Errorf("Output %s has a failover_to loop", ro.Name)
+			}
+			seen[next] = true
+		}
+	}
+	return nil
+}
+
+// trimBOM trims the Byte-Order-Marks from the beginning of the file.
+// this is for Windows compatability only.
 // see https://github.com/influxdata/telegraf/issues/1378
 func trimBOM(f []byte) []byte {
 	return bytes.TrimPrefix(f, []byte("\xef\xbb\xbf"))
 }
 
-// parseFile loads a TOML configuration from a provided path and
-// returns the AST produced from the TOML parser. When loading the file, it
-// will find environment variables and replace them.
-func parseFile(fpath string) (*ast.Table, error) {
-	contents, err := ioutil.ReadFile(fpath)
-	if err != nil {
-		return nil, err
+// readConfigFile returns the TOML contents of the config file at fpath,
+// evaluating Jsonnet files.
+func readConfigFile(fpath string) ([]byte, error) {
+	if filepath.Ext(fpath) == ".jsonnet" {
+		return jsonnetToTOML(fpath)
 	}
+	return ioutil.ReadFile(fpath)
+}
+
+// parseContents parses TOML config contents, after trimming the BOM and
+// substituting environment variables.
+func parseContents(contents []byte) (*ast.Table, error) {
 	// ugh windows why
 	contents = trimBOM(contents)
 
-	env_vars := envVarRe.FindAll(contents, -1)
-	for _, env_var := range env_vars {
-		env_val := os.Getenv(strings.TrimPrefix(string(env_var), "$"))
-		if env_val != "" {
-			contents = bytes.Replace(contents, env_var, []byte(env_val), 1)
+	contents = envVarRe.ReplaceAllFunc(contents, func(env_var []byte) []byte {
+		match := envVarRe.FindSubmatch(env_var)
+		name := match[1]
+		if len(name) == 0 {
+			name = match[2]
 		}
-	}
+		if env_val := os.Getenv(string(name)); env_val != "" {
+			return []byte(env_val)
+		}
+		return env_var
+	})
 
 	return toml.Parse(contents)
 }
@@ -586,19 +2115,28 @@ func (c *Config) addOutput(name string, table *ast.Table) error {
 	}
 	creator, ok := outputs.Outputs[name]
 	if !ok {
+		c.unregistered = append(c.unregistered, "outputs."+name)
 		return fmt.Errorf("Undefined but requested output: %s", name)
 	}
 	output := creator()
+	// hashed first, building the plugin removes options from table
+	hash := hashTable(table)
+	if err := c.checkFormatVersion(table); err != nil {
+		return err
+	}
 
 	// If the output has a SetSerializer function, then this means it can write
 	// arbitrary types of output, so build the serializer and set it.
+	var formatOptions []string
 	switch t := output.(type) {
 	case serializers.SerializerOutput:
+		options := tableOptions(table)
 		serializer, err := buildSerializer(name, table)
 		if err != nil {
 			return err
 		}
 		t.SetSerializer(serializer)
+		formatOptions = removedOptions(table, options)
 	}
 
 	outputConfig, err := buildOutput(name, table)
@@ -606,12 +2144,22 @@ func (c *Config) addOutput(name string, table *ast.Table) error {
 		return err
 	}
 
+	c.setDefaultTLS(output)
 	if err := config.UnmarshalTable(table, output); err != nil {
 		return err
 	}
 
 	ro := models.NewRunningOutput(name, output, outputConfig,
 		c.Agent.MetricBatchSize, c.Agent.MetricBufferLimit)
+	ro.SetOverflowStrategy(c.Agent.MetricOverflowStrategy)
+	ro.ConfigHash = hash
+	ro.DataFormatOptions = formatOptions
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if outputConfig.Disabled {
+		c.DisabledOutputs = append(c.DisabledOutputs, ro)
+		return nil
+	}
 	c.Outputs = append(c.Outputs, ro)
 	return nil
 }
@@ -627,43 +2175,124 @@ func (c *Config) addInput(name string, table *ast.Table) error {
 
 	creator, ok := inputs.Inputs[name]
 	if !ok {
+		c.unregistered = append(c.unregistered, "inputs."+name)
 		return fmt.Errorf("Undefined but requested input: %s", name)
 	}
 	input := creator()
+	// hashed first, building the plugin removes options from table
+	hash := hashTable(table)
+	if err := c.checkFormatVersion(table); err != nil {
+		return err
+	}
 
 	// If the input has a SetParser function, then this means it can accept
 	// arbitrary types of input, so build the parser and set it.
+	var formatOptions []string
 	switch t := input.(type) {
 	case parsers.ParserInput:
+		options := tableOptions(table)
 		parser, err := buildParser(name, table)
 		if err != nil {
 			return err
 		}
 		t.SetParser(parser)
+		formatOptions = removedOptions(table, options)
 	}
 
 	pluginConfig, err := buildInput(name, table)
 	if err != nil {
 		return err
 	}
+	if pluginConfig.CollectionTimeout == 0 {
+		pluginConfig.CollectionTimeout = c.Agent.InputTimeout.Duration
+	}
 
+	c.setDefaultTLS(input)
 	if err := config.UnmarshalTable(table, input); err != nil {
 		return err
 	}
 
 	rp := &models.RunningInput{
-		Name:   name,
-		Input:  input,
-		Config: pluginConfig,
+		Name:              name,
+		Input:             input,
+		Config:            pluginConfig,
+		ConfigHash:        hash,
+		DataFormatOptions: formatOptions,
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pluginConfig.Disabled {
+		c.DisabledInputs = append(c.DisabledInputs, rp)
+		return nil
 	}
 	c.Inputs = append(c.Inputs, rp)
 	return nil
 }
 
+// tableOptions returns the options of tbl, by name.
+func tableOptions(tbl *ast.Table) map[string]*ast.KeyValue {
+	options := make(map[string]*ast.KeyValue)
+	for key, node := range tbl.Fields {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			options[key] = kv
+		}
+	}
+	return options
+}
+
+// removedOptions returns the options that were removed from tbl since
+// tableOptions returned them, as sorted "key = value" TOML lines.
+func removedOptions(tbl *ast.Table, options map[string]*ast.KeyValue) []string {
+	var lines []string
+	for key, kv := range options {
+		if _, ok := tbl.Fields[key]; ok {
+			continue
+		}
+		value := kv.Value.Source()
+		if str, ok := kv.Value.(*ast.String); ok {
+			// resolved secrets are only set in the value
+			value = fmt.Sprintf("%q", str.Value)
+		}
+		lines = append(lines, key+" = "+value)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// setDefaultTLS sets the [agent.tls] settings as the defaults of the TLS
+// options of plugin, before its own options are loaded over them.
+func (c *Config) setDefaultTLS(plugin interface{}) {
+	if c.Agent.TLSConfig == (internal.TLSConfig{}) {
+		return
+	}
+	if t, ok := plugin.(internal.DefaultTLSSetter); ok {
+		t.SetDefaultTLS(c.Agent.TLSConfig)
+	}
+}
+
+// checkFormatVersion returns an error if a plugin table uses options removed
+// from the config_format_version of c.
+func (c *Config) checkFormatVersion(tbl *ast.Table) error {
+	if c.Agent.ConfigFormatVersion < 2 {
+		return nil
+	}
+	for _, alias := range [][2]string{
+		{"pass", "fieldpass"},
+		{"drop", "fielddrop"},
+	} {
+		if _, ok := tbl.Fields[alias[0]]; ok {
+			return fmt.Errorf("%s is not supported by config_format_version "+
+				"2, use %s", alias[0], alias[1])
+		}
+	}
+	return nil
+}
+
 // buildFilter builds a Filter
 // (tagpass/tagdrop/namepass/namedrop/fieldpass/fielddrop) to
 // be inserted into the models.OutputConfig/models.InputConfig
-// to be used for glob filtering on tags and measurements
+// to be used for glob filtering on tags and measurements, and
+// namematch/namedropmatch for regular expression filtering on measurements
 func buildFilter(tbl *ast.Table) (models.Filter, error) {
 	f := models.Filter{}
 
@@ -691,6 +2320,30 @@ func buildFilter(tbl *ast.Table) (models.Filter, error) {
 		}
 	}
 
+	if node, ok := tbl.Fields["namematch"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						f.NameMatch = append(f.NameMatch, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["namedropmatch"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						f.NameDropMatch = append(f.NameDropMatch, str.Value)
+					}
+				}
+			}
+		}
+	}
+
 	fields := []string{"pass", "fieldpass"}
 	for _, field := range fields {
 		if node, ok := tbl.Fields[field]; ok {
@@ -786,6 +2439,8 @@ func buildFilter(tbl *ast.Table) (models.Filter, error) {
 
 	delete(tbl.Fields, "namedrop")
 	delete(tbl.Fields, "namepass")
+	delete(tbl.Fields, "namematch")
+	delete(tbl.Fields, "namedropmatch")
 	delete(tbl.Fields, "fielddrop")
 	delete(tbl.Fields, "fieldpass")
 	delete(tbl.Fields, "drop")
@@ -815,6 +2470,19 @@ func buildInput(name string, tbl *ast.Table) (*models.InputConfig, error) {
 		}
 	}
 
+	if node, ok := tbl.Fields["collection_timeout"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				dur, err := time.ParseDuration(str.Value)
+				if err != nil {
+					return nil, err
+				}
+
+				cp.CollectionTimeout = dur
+			}
+		}
+	}
+
 	if node, ok := tbl.Fields["name_prefix"]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
 			if str, ok := kv.Value.(*ast.String); ok {
@@ -839,141 +2507,778 @@ func buildInput(name string, tbl *ast.Table) (*models.InputConfig, error) {
 		}
 	}
 
-	cp.Tags = make(map[string]string)
-	if node, ok := tbl.Fields["tags"]; ok {
-		if subtbl, ok := node.(*ast.Table); ok {
-			if err := config.UnmarshalTable(subtbl, cp.Tags); err != nil {
-				log.Printf("E! Could not parse tags for input %s\n", name)
-			}
-		}
-	}
-
-	delete(tbl.Fields, "name_prefix")
-	delete(tbl.Fields, "name_suffix")
-	delete(tbl.Fields, "name_override")
-	delete(tbl.Fields, "interval")
-	delete(tbl.Fields, "tags")
-	var err error
-	cp.Filter, err = buildFilter(tbl)
-	if err != nil {
-		return cp, err
-	}
-	return cp, nil
-}
-
-// buildParser grabs the necessary entries from the ast.Table for creating
-// a parsers.Parser object, and creates it, which can then be added onto
-// an Input object.
-func buildParser(name string, tbl *ast.Table) (parsers.Parser, error) {
-	c := &parsers.Config{}
-
-	if node, ok := tbl.Fields["data_format"]; ok {
+	if node, ok := tbl.Fields["name_template"]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
 			if str, ok := kv.Value.(*ast.String); ok {
-				c.DataFormat = str.Value
+				cp.NameTemplate = str.Value
+				if err := cp.CompileNameTemplate(); err != nil {
+					return nil, fmt.Errorf("Invalid name_template for input %s, %s",
+						name, err)
+				}
 			}
 		}
 	}
 
-	// Legacy support, exec plugin originally parsed JSON by default.
-	if name == "exec" && c.DataFormat == "" {
-		c.DataFormat = "json"
-	} else if c.DataFormat == "" {
-		c.DataFormat = "influx"
-	}
-
-	if node, ok := tbl.Fields["separator"]; ok {
+	if node, ok := tbl.Fields["enabled"]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
-			if str, ok := kv.Value.(*ast.String); ok {
-				c.Separator = str.Value
+			b, ok := kv.Value.(*ast.Boolean)
+			if !ok {
+				return nil, fmt.Errorf("enabled must be a boolean, got %s",
+					kv.Value.Source())
 			}
+			enabled, err := b.Boolean()
+			if err != nil {
+				return nil, err
+			}
+			cp.Disabled = !enabled
 		}
 	}
 
-	if node, ok := tbl.Fields["templates"]; ok {
+	if node, ok := tbl.Fields["sampling_rate"]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
-			if ary, ok := kv.Value.(*ast.Array); ok {
-				for _, elem := range ary.Value {
-					if str, ok := elem.(*ast.String); ok {
-						c.Templates = append(c.Templates, str.Value)
-					}
-				}
+			var rate float64
+			var err error
+			switch v := kv.Value.(type) {
+			case *ast.Float:
+				rate, err = v.Float()
+			case *ast.Integer:
+				var i int64
+				i, err = v.Int()
+				rate = float64(i)
+			default:
+				return nil, fmt.Errorf("sampling_rate must be a number, got %s",
+					kv.Value.Source())
 			}
+			if err != nil {
+				return nil, err
+			}
+			// 0 would drop every metric, an input that is not wanted is
+			// disabled with enabled = false instead
+			if rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("sampling_rate must be greater than 0 "+
+					"and at most 1, got %v", rate)
+			}
+			cp.SamplingRate = rate
 		}
 	}
 
-	if node, ok := tbl.Fields["tag_keys"]; ok {
+	if node, ok := tbl.Fields["sampling_seed"]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
-			if ary, ok := kv.Value.(*ast.Array); ok {
-				for _, elem := range ary.Value {
-					if str, ok := elem.(*ast.String); ok {
-						c.TagKeys = append(c.TagKeys, str.Value)
-					}
+			if integer, ok := kv.Value.(*ast.Integer); ok {
+				seed, err := integer.Int()
+				if err != nil {
+					return nil, err
 				}
+				cp.SamplingSeed = seed
 			}
 		}
 	}
 
-	if node, ok := tbl.Fields["data_type"]; ok {
+	if node, ok := tbl.Fields["metric_buffer_limit"]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
-			if str, ok := kv.Value.(*ast.String); ok {
-				c.DataType = str.Value
+			if integer, ok := kv.Value.(*ast.Integer); ok {
+				limit, err := integer.Int()
+				if err != nil {
+					return nil, err
+				}
+				if limit < 0 {
+					return nil, fmt.Errorf("metric_buffer_limit must not be "+
+						"negative, got %d for input %s", limit, name)
+				}
+				cp.MetricBufferLimit = int(limit)
 			}
 		}
 	}
 
-	c.MetricName = name
-
-	delete(tbl.Fields, "data_format")
-	delete(tbl.Fields, "separator")
-	delete(tbl.Fields, "templates")
-	delete(tbl.Fields, "tag_keys")
-	delete(tbl.Fields, "data_type")
-
-	return parsers.NewParser(c)
-}
-
-// buildSerializer grabs the necessary entries from the ast.Table for creating
-// a serializers.Serializer object, and creates it, which can then be added onto
-// an Output object.
-func buildSerializer(name string, tbl *ast.Table) (serializers.Serializer, error) {
-	c := &serializers.Config{}
-
-	if node, ok := tbl.Fields["data_format"]; ok {
-		if kv, ok := node.(*ast.KeyValue); ok {
-			if str, ok := kv.Value.(*ast.String); ok {
-				c.DataFormat = str.Value
-			}
+	if node, ok := tbl.Fields["deduplication"]; ok {
+		subtbl, ok := node.(*ast.Table)
+		if !ok {
+			return nil, fmt.Errorf("Invalid deduplication for input %s, "+
+				"must be a table", name)
 		}
+		dedup, err := buildDedup(subtbl)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid deduplication for input %s, %s",
+				name, err)
+		}
+		cp.Dedup = dedup
 	}
 
-	if c.DataFormat == "" {
-		c.DataFormat = "influx"
+	if node, ok := tbl.Fields["field_rename"]; ok {
+		subtbl, ok := node.(*ast.Table)
+		if !ok {
+			return nil, fmt.Errorf("Invalid field_rename for input %s, "+
+				"must be a table", name)
+		}
+		renames, err := buildFieldRename(subtbl)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid field_rename for input %s, %s",
+				name, err)
+		}
+		cp.FieldRename = renames
 	}
 
-	if node, ok := tbl.Fields["prefix"]; ok {
-		if kv, ok := node.(*ast.KeyValue); ok {
-			if str, ok := kv.Value.(*ast.String); ok {
-				c.Prefix = str.Value
+	cp.Tags = make(map[string]string)
+	if node, ok := tbl.Fields["tags"]; ok {
+		if subtbl, ok := node.(*ast.Table); ok {
+			if err := config.UnmarshalTable(subtbl, cp.Tags); err != nil {
+				log.Printf("E! Could not parse tags for input %s\n", name)
 			}
 		}
 	}
 
-	if node, ok := tbl.Fields["template"]; ok {
+	if node, ok := tbl.Fields["tags_from_env"]; ok {
 		if kv, ok := node.(*ast.KeyValue); ok {
-			if str, ok := kv.Value.(*ast.String); ok {
-				c.Template = str.Value
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						cp.TagsFromEnv = append(cp.TagsFromEnv, str.Value)
+					}
+				}
 			}
 		}
 	}
+	addTagsFromEnv(name, cp.Tags, cp.TagsFromEnv)
 
-	delete(tbl.Fields, "data_format")
-	delete(tbl.Fields, "prefix")
-	delete(tbl.Fields, "template")
-	return serializers.NewSerializer(c)
-}
-
-// buildOutput parses output specific items from the ast.Table,
+	delete(tbl.Fields, "name_prefix")
+	delete(tbl.Fields, "name_suffix")
+	delete(tbl.Fields, "name_override")
+	delete(tbl.Fields, "name_template")
+	delete(tbl.Fields, "interval")
+	delete(tbl.Fields, "collection_timeout")
+	delete(tbl.Fields, "enabled")
+	delete(tbl.Fields, "sampling_rate")
+	delete(tbl.Fields, "sampling_seed")
+	delete(tbl.Fields, "metric_buffer_limit")
+	delete(tbl.Fields, "deduplication")
+	delete(tbl.Fields, "field_rename")
+	delete(tbl.Fields, "tags")
+	delete(tbl.Fields, "tags_from_env")
+	var err error
+	cp.StartupRetryInterval, cp.StartupRetryMax, err = buildStartupRetry(tbl)
+	if err != nil {
+		return nil, err
+	}
+	cp.Filter, err = buildFilter(tbl)
+	if err != nil {
+		return cp, err
+	}
+	return cp, nil
+}
+
+// buildFieldRename parses the field_rename table of an input, in the order
+// its patterns are written in the config, so that the first pattern matching
+// a field renames it.
+func buildFieldRename(tbl *ast.Table) ([]models.FieldRename, error) {
+	var kvs []*ast.KeyValue
+	for pattern, node := range tbl.Fields {
+		kv, ok := node.(*ast.KeyValue)
+		if !ok {
+			return nil, fmt.Errorf("%q must be a string", pattern)
+		}
+		kvs = append(kvs, kv)
+	}
+	sort.Sort(keyValuesByLine(kvs))
+
+	renames := make([]models.FieldRename, 0, len(kvs))
+	for _, kv := range kvs {
+		str, ok := kv.Value.(*ast.String)
+		if !ok {
+			return nil, fmt.Errorf("%q must be a string", kv.Key)
+		}
+		rename, err := models.NewFieldRename(kv.Key, str.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %s", kv.Key, err)
+		}
+		renames = append(renames, rename)
+	}
+	return renames, nil
+}
+
+// buildNameMapping parses the name_mapping table of an output, in the order
+// of the config file.
+func buildNameMapping(tbl *ast.Table) ([]models.NameMapping, error) {
+	var kvs []*ast.KeyValue
+	for pattern, node := range tbl.Fields {
+		kv, ok := node.(*ast.KeyValue)
+		if !ok {
+			return nil, fmt.Errorf("%q must be a string", pattern)
+		}
+		kvs = append(kvs, kv)
+	}
+	sort.Sort(keyValuesByLine(kvs))
+
+	mappings := make([]models.NameMapping, 0, len(kvs))
+	for _, kv := range kvs {
+		str, ok := kv.Value.(*ast.String)
+		if !ok {
+			return nil, fmt.Errorf("%q must be a string", kv.Key)
+		}
+		mapping, err := models.NewNameMapping(kv.Key, str.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %s", kv.Key, err)
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings, nil
+}
+
+// buildNameReplace parses the [agent.metric_name_replace] table of regular
+// expressions to replacements, in the order of the config file.
+func buildNameReplace(tbl *ast.Table) ([]models.NameReplace, error) {
+	var kvs []*ast.KeyValue
+	for from, node := range tbl.Fields {
+		kv, ok := node.(*ast.KeyValue)
+		if !ok {
+			return nil, fmt.Errorf("%q must be a string", from)
+		}
+		kvs = append(kvs, kv)
+	}
+	sort.Sort(keyValuesByLine(kvs))
+
+	rules := make([]models.NameReplace, 0, len(kvs))
+	for _, kv := range kvs {
+		str, ok := kv.Value.(*ast.String)
+		if !ok {
+			return nil, fmt.Errorf("%q must be a string", kv.Key)
+		}
+		rule, err := models.NewNameReplace(kv.Key, str.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %s", kv.Key, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// keyValuesByLine sorts key/values in the order of the config file.
+type keyValuesByLine []*ast.KeyValue
+
+func (k keyValuesByLine) Len() int           { return len(k) }
+func (k keyValuesByLine) Less(i, j int) bool { return k[i].Line < k[j].Line }
+func (k keyValuesByLine) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+
+// addTagsFromEnv adds the tags_from_env of an input to its tags. Each entry
+// is either the name of an environment variable, used as the tag key too, or
+// "TAG_KEY=ENV_VAR_NAME". Tags set in the tags table of the input are kept,
+// and unset variables are skipped with a warning.
+func addTagsFromEnv(name string, tags map[string]string, entries []string) {
+	for _, entry := range entries {
+		key, env := entry, entry
+		if i := strings.Index(entry, "="); i >= 0 {
+			key, env = entry[:i], entry[i+1:]
+		}
+		if _, ok := tags[key]; ok {
+			continue
+		}
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			log.Printf("W! Environment variable %s of tags_from_env is not "+
+				"set for input %s, skipping tag %s\n", env, name, key)
+			continue
+		}
+		tags[key] = value
+	}
+}
+
+// buildDedup parses the [inputs.x.deduplication] table of an input.
+func buildDedup(tbl *ast.Table) (*models.DedupConfig, error) {
+	dc := &models.DedupConfig{}
+	if node, ok := tbl.Fields["dedup_interval"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				dur, err := time.ParseDuration(str.Value)
+				if err != nil {
+					return nil, err
+				}
+				dc.Interval = dur
+			}
+		}
+	}
+	if dc.Interval <= 0 {
+		return nil, fmt.Errorf("dedup_interval must be positive")
+	}
+
+	if node, ok := tbl.Fields["dedup_fields"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						dc.Fields = append(dc.Fields, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["dedup_cache_size"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if integer, ok := kv.Value.(*ast.Integer); ok {
+				v, err := integer.Int()
+				if err != nil {
+					return nil, err
+				}
+				if v < 0 {
+					return nil, fmt.Errorf("dedup_cache_size must not be "+
+						"negative, got %d", v)
+				}
+				dc.CacheSize = int(v)
+			}
+		}
+	}
+	return dc, nil
+}
+
+// buildStartupRetry parses the startup_retry_interval and startup_retry_max
+// options of an input or output.
+func buildStartupRetry(tbl *ast.Table) (time.Duration, int, error) {
+	var interval time.Duration
+	var max int
+	if node, ok := tbl.Fields["startup_retry_interval"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				dur, err := time.ParseDuration(str.Value)
+				if err != nil {
+					return 0, 0, err
+				}
+				if dur < 0 {
+					return 0, 0, fmt.Errorf("startup_retry_interval must not "+
+						"be negative, got %s", dur)
+				}
+				interval = dur
+			}
+		}
+	}
+	if node, ok := tbl.Fields["startup_retry_max"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if integer, ok := kv.Value.(*ast.Integer); ok {
+				v, err := integer.Int()
+				if err != nil {
+					return 0, 0, err
+				}
+				if v < 0 {
+					return 0, 0, fmt.Errorf("startup_retry_max must not be "+
+						"negative, got %d", v)
+				}
+				max = int(v)
+			}
+		}
+	}
+	delete(tbl.Fields, "startup_retry_interval")
+	delete(tbl.Fields, "startup_retry_max")
+	return interval, max, nil
+}
+
+// buildParser grabs the necessary entries from the ast.Table for creating
+// a parsers.Parser object, and creates it, which can then be added onto
+// an Input object.
+func buildParser(name string, tbl *ast.Table) (parsers.Parser, error) {
+	c := &parsers.Config{}
+
+	if node, ok := tbl.Fields["data_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.DataFormat = str.Value
+			}
+		}
+	}
+
+	// Legacy support, exec plugin originally parsed JSON by default.
+	if name == "exec" && c.DataFormat == "" {
+		c.DataFormat = "json"
+	} else if c.DataFormat == "" {
+		c.DataFormat = "influx"
+	}
+
+	if node, ok := tbl.Fields["separator"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.Separator = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["templates"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.Templates = append(c.Templates, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["tag_keys"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.TagKeys = append(c.TagKeys, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_query"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.JSONQuery = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_query_missing"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.JSONQueryMissing = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_time_key"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.JSONTimeKey = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_time_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.JSONTimeFormat = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_timezone"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.JSONTimezone = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_path_prefix"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.JSONPathPrefix = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["json_strict_types"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if b, ok := kv.Value.(*ast.Boolean); ok {
+				var err error
+				c.JSONStrictTypes, err = b.Boolean()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["data_type"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.DataType = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["binary_layout"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.BinaryLayout = append(c.BinaryLayout, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["binary_endianness"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.BinaryEndianness = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["msgpack_tag_keys"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.MsgpackTagKeys = append(c.MsgpackTagKeys, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["msgpack_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.MsgpackFormat = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["collectd_security_level"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CollectdSecurityLevel = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["collectd_auth_file"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.CollectdAuthFile = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["collectd_typesdb"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.CollectdTypesDB = append(c.CollectdTypesDB, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["prometheus_metric_version"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if integer, ok := kv.Value.(*ast.Integer); ok {
+				v, err := integer.Int()
+				if err != nil {
+					return nil, err
+				}
+				c.PrometheusMetricVersion = int(v)
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["prometheus_ignore_timestamp"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if b, ok := kv.Value.(*ast.Boolean); ok {
+				var err error
+				c.PrometheusIgnoreTimestamp, err = b.Boolean()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["prometheus_metric_types"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.PrometheusMetricTypes = append(
+							c.PrometheusMetricTypes, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["prometheus_include_help"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if b, ok := kv.Value.(*ast.Boolean); ok {
+				var err error
+				c.PrometheusIncludeHelp, err = b.Boolean()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	c.MetricName = name
+
+	delete(tbl.Fields, "data_format")
+	delete(tbl.Fields, "separator")
+	delete(tbl.Fields, "templates")
+	if node, ok := tbl.Fields["xpath_config"]; ok {
+		if subtbls, ok := node.([]*ast.Table); ok {
+			for _, subtbl := range subtbls {
+				xc := xpath.Config{}
+				if err := config.UnmarshalTable(subtbl, &xc); err != nil {
+					return nil, fmt.Errorf("Error parsing xpath_config, %s", err)
+				}
+				c.XPathConfig = append(c.XPathConfig, xc)
+			}
+		}
+	}
+
+	delete(tbl.Fields, "tag_keys")
+	delete(tbl.Fields, "json_query")
+	delete(tbl.Fields, "json_query_missing")
+	delete(tbl.Fields, "json_time_key")
+	delete(tbl.Fields, "json_time_format")
+	delete(tbl.Fields, "json_timezone")
+	delete(tbl.Fields, "json_strict_types")
+	delete(tbl.Fields, "json_path_prefix")
+	delete(tbl.Fields, "data_type")
+	delete(tbl.Fields, "xpath_config")
+	delete(tbl.Fields, "binary_layout")
+	delete(tbl.Fields, "binary_endianness")
+	delete(tbl.Fields, "msgpack_tag_keys")
+	delete(tbl.Fields, "msgpack_format")
+	delete(tbl.Fields, "collectd_security_level")
+	delete(tbl.Fields, "collectd_auth_file")
+	delete(tbl.Fields, "collectd_typesdb")
+	delete(tbl.Fields, "prometheus_metric_version")
+	delete(tbl.Fields, "prometheus_ignore_timestamp")
+	delete(tbl.Fields, "prometheus_metric_types")
+	delete(tbl.Fields, "prometheus_include_help")
+
+	return parsers.NewParser(c)
+}
+
+// buildSerializer grabs the necessary entries from the ast.Table for creating
+// a serializers.Serializer object, and creates it, which can then be added onto
+// an Output object.
+func buildSerializer(name string, tbl *ast.Table) (serializers.Serializer, error) {
+	c := &serializers.Config{}
+
+	if node, ok := tbl.Fields["data_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.DataFormat = str.Value
+			}
+		}
+	}
+
+	if c.DataFormat == "" {
+		c.DataFormat = "influx"
+	}
+
+	if node, ok := tbl.Fields["prefix"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.Prefix = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["template"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.Template = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["field_include"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.FieldInclude = append(c.FieldInclude, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["field_exclude"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if ary, ok := kv.Value.(*ast.Array); ok {
+				for _, elem := range ary.Value {
+					if str, ok := elem.(*ast.String); ok {
+						c.FieldExclude = append(c.FieldExclude, str.Value)
+					}
+				}
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["msgpack_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.MsgpackFormat = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["splunk_source"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.SplunkSource = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["splunk_sourcetype"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.SplunkSourceType = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["splunk_index"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.SplunkIndex = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["splunk_metric_name_field"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.SplunkMetricNameField = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["timestamp_format"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.TimestampFormat = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["timestamp_timezone"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				c.TimestampTimezone = str.Value
+			}
+		}
+	}
+
+	delete(tbl.Fields, "data_format")
+	delete(tbl.Fields, "prefix")
+	delete(tbl.Fields, "template")
+	delete(tbl.Fields, "field_include")
+	delete(tbl.Fields, "field_exclude")
+	delete(tbl.Fields, "msgpack_format")
+	delete(tbl.Fields, "splunk_source")
+	delete(tbl.Fields, "splunk_sourcetype")
+	delete(tbl.Fields, "splunk_index")
+	delete(tbl.Fields, "splunk_metric_name_field")
+	delete(tbl.Fields, "timestamp_format")
+	delete(tbl.Fields, "timestamp_timezone")
+	return serializers.NewSerializer(c)
+}
+
+// buildOutput parses output specific items from the ast.Table,
 // builds the filter and returns an
 // models.OutputConfig to be inserted into models.RunningInput
 // Note: error exists in the return for future calls that might require error
@@ -986,6 +3291,81 @@ func buildOutput(name string, tbl *ast.Table) (*models.OutputConfig, error) {
 		Name:   name,
 		Filter: filter,
 	}
+
+	if node, ok := tbl.Fields["enabled"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			b, ok := kv.Value.(*ast.Boolean)
+			if !ok {
+				return nil, fmt.Errorf("enabled must be a boolean, got %s",
+					kv.Value.Source())
+			}
+			enabled, err := b.Boolean()
+			if err != nil {
+				return nil, err
+			}
+			oc.Disabled = !enabled
+		}
+	}
+	delete(tbl.Fields, "enabled")
+
+	if node, ok := tbl.Fields["alias"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				oc.Alias = str.Value
+			}
+		}
+	}
+
+	if node, ok := tbl.Fields["failover_to"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if str, ok := kv.Value.(*ast.String); ok {
+				oc.FailoverTo = str.Value
+			}
+		}
+	}
+
+	if oc.FailoverTo != "" {
+		oc.FailoverThreshold = 3
+	}
+	if node, ok := tbl.Fields["failover_threshold"]; ok {
+		if kv, ok := node.(*ast.KeyValue); ok {
+			if integer, ok := kv.Value.(*ast.Integer); ok {
+				v, err := integer.Int()
+				if err != nil {
+					return nil, err
+				}
+				if v < 1 {
+					return nil, fmt.Errorf("Invalid failover_threshold %d, "+
+						"must be at least 1", v)
+				}
+				oc.FailoverThreshold = int(v)
+			}
+		}
+	}
+	delete(tbl.Fields, "alias")
+	delete(tbl.Fields, "failover_to")
+	delete(tbl.Fields, "failover_threshold")
+
+	oc.StartupRetryInterval, oc.StartupRetryMax, err = buildStartupRetry(tbl)
+	if err != nil {
+		return nil, err
+	}
+
+	if node, ok := tbl.Fields["name_mapping"]; ok {
+		subtbl, ok := node.(*ast.Table)
+		if !ok {
+			return nil, fmt.Errorf("Invalid name_mapping for output %s, "+
+				"must be a table", name)
+		}
+		mappings, err := buildNameMapping(subtbl)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid name_mapping for output %s, %s",
+				name, err)
+		}
+		oc.NameMapping = mappings
+	}
+	delete(tbl.Fields, "name_mapping")
+
 	// Outputs don't support FieldDrop/FieldPass, so set to NameDrop/NamePass
 	if len(oc.Filter.FieldDrop) > 0 {
 		oc.Filter.NameDrop = oc.Filter.FieldDrop
@@ -1,15 +1,22 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal/models"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/inputs/exec"
 	"github.com/influxdata/telegraf/plugins/inputs/memcached"
 	"github.com/influxdata/telegraf/plugins/inputs/procstat"
+	"github.com/influxdata/telegraf/plugins/inputs/prometheus"
+	_ "github.com/influxdata/telegraf/plugins/outputs/file"
 	"github.com/influxdata/telegraf/plugins/parsers"
 
 	"github.com/stretchr/testify/assert"
@@ -58,6 +65,28 @@ func TestConfig_LoadSingleInputWithEnvVars(t *testing.T) {
 		"Testdata did not produce correct memcached metadata.")
 }
 
+func TestConfig_EnvVarForms(t *testing.T) {
+	os.Setenv("TEST_ENV_HOST", "192.168.1.1")
+	os.Setenv("TEST_ENV_HOST2", "192.168.1.2")
+	os.Setenv("TEST_ENV_PORT", "11211")
+	defer os.Unsetenv("TEST_ENV_HOST")
+	defer os.Unsetenv("TEST_ENV_HOST2")
+	defer os.Unsetenv("TEST_ENV_PORT")
+
+	c := NewConfig()
+	err := c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["$TEST_ENV_HOST", "${TEST_ENV_HOST2}:${env:TEST_ENV_PORT}",
+    "${TEST_ENV_HOST}_backup", "$TEST_ENV_UNSET"]
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"192.168.1.1", "192.168.1.2:11211",
+		"192.168.1.1_backup", "$TEST_ENV_UNSET"},
+		c.Inputs[0].Input.(*memcached.Memcached).Servers)
+}
+
 func TestConfig_LoadSingleInput(t *testing.T) {
 	c := NewConfig()
 	c.LoadConfig("./testdata/single_plugin.toml")
@@ -174,3 +203,1027 @@ func TestConfig_LoadDirectory(t *testing.T) {
 	assert.Equal(t, pConfig, c.Inputs[3].Config,
 		"Merged Testdata did not produce correct procstat metadata.")
 }
+
+func TestConfig_LoadDisabledPlugins(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfig("./testdata/disabled_plugins.toml")
+	assert.NoError(t, err)
+
+	assert.Len(t, c.Inputs, 1)
+	assert.Len(t, c.Outputs, 0)
+	assert.Len(t, c.DisabledInputs, 2)
+	assert.Len(t, c.DisabledOutputs, 1)
+
+	memcached := inputs.Inputs["memcached"]().(*memcached.Memcached)
+	memcached.Servers = []string{"192.168.1.1"}
+	for _, input := range c.DisabledInputs {
+		if input.Name == "memcached" {
+			assert.Equal(t, memcached, input.Input,
+				"Disabled input was not fully parsed.")
+		}
+	}
+
+	// enabled must be a boolean, not the string "false"
+	err = c.AddInputFromTOML("[[inputs.memcached]]\n  enabled = \"false\"\n")
+	assert.Error(t, err)
+	err = c.AddOutputFromTOML("[[outputs.file]]\n  enabled = 0\n")
+	assert.Error(t, err)
+	assert.Len(t, c.Inputs, 1)
+	assert.Len(t, c.Outputs, 0)
+
+	assert.True(t, c.SectionEnabled("inputs.memcached"))
+	assert.False(t, c.SectionEnabled("inputs.exec"))
+	assert.False(t, c.SectionEnabled("outputs.file"))
+	assert.True(t, c.SectionEnabled("inputs.cpu"))
+}
+
+func TestConfig_MergeConfig(t *testing.T) {
+	c := NewConfig()
+	c.Tags["dc"] = "us-east-1"
+	err := c.LoadConfig("./testdata/single_plugin.toml")
+	assert.NoError(t, err)
+
+	other := NewConfig()
+	other.Tags["dc"] = "us-west-2"
+	other.Tags["rack"] = "1a"
+	other.Agent.Interval.Duration = time.Minute
+	err = other.LoadDirectory("./testdata/subconfig")
+	assert.NoError(t, err)
+
+	assert.NoError(t, c.MergeConfig(other))
+	assert.Equal(t, map[string]string{"dc": "us-east-1", "rack": "1a"}, c.Tags)
+	// the agent settings of c are kept
+	assert.Equal(t, 10*time.Second, c.Agent.Interval.Duration)
+	assert.Equal(t, []string{"memcached", "exec", "memcached", "procstat"},
+		c.InputNames())
+	assert.Equal(t, other.Inputs[0], c.Inputs[1])
+	// the config directories of other are rescanned by c
+	assert.Equal(t, other.directories, c.directories)
+	assert.Len(t, c.directoryFiles, len(other.directoryFiles))
+
+	assert.Error(t, c.MergeConfig(nil))
+
+	// the secret stores of other resolve the secrets of c
+	other = NewConfig()
+	assert.NoError(t, other.LoadConfig("./testdata/secret_store.toml"))
+	c = NewConfig()
+	assert.NoError(t, c.MergeConfig(other))
+	err = c.AddInputFromTOML("[[inputs.memcached]]\n" +
+		"  servers = [\"secret:files:memcached_server\"]\n")
+	assert.NoError(t, err)
+	assert.Len(t, c.sources, 1)
+}
+
+func TestConfig_LoadOutputTagFilters(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfig("./testdata/output_tag_filters.toml")
+	assert.NoError(t, err)
+	assert.Len(t, c.Outputs, 2)
+
+	exclude := models.Filter{TagExclude: []string{"host", "rack"}}
+	assert.NoError(t, exclude.Compile())
+	assert.Equal(t, &models.OutputConfig{Name: "file", Filter: exclude},
+		c.Outputs[0].Config)
+
+	include := models.Filter{TagInclude: []string{"cpu"}}
+	assert.NoError(t, include.Compile())
+	assert.Equal(t, &models.OutputConfig{Name: "file", Filter: include},
+		c.Outputs[1].Config)
+}
+
+func TestConfig_LoadSampling(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfig("./testdata/sampling.toml")
+	assert.NoError(t, err)
+
+	mConfig := &models.InputConfig{
+		Name:         "memcached",
+		SamplingRate: 0.25,
+		SamplingSeed: 42,
+	}
+	mConfig.Tags = make(map[string]string)
+	assert.Equal(t, mConfig, c.Inputs[0].Config)
+
+	for _, rate := range []string{"0", "0.0", "-0.5", "1.5", "\"half\""} {
+		err = c.AddInputFromTOML(
+			"[[inputs.memcached]]\n  sampling_rate = " + rate + "\n")
+		assert.Error(t, err, rate)
+	}
+	assert.Len(t, c.Inputs, 1)
+}
+
+func TestConfig_WritePluginDoc(t *testing.T) {
+	c := NewConfig()
+	var buf bytes.Buffer
+	err := c.WritePluginDoc(&buf, "inputs", "memcached")
+	assert.NoError(t, err)
+
+	doc := buf.String()
+	assert.True(t, strings.HasPrefix(doc, "# memcached\n\n## Description\n\n"))
+	assert.Contains(t, doc, "## Configuration\n\n```toml\n[[inputs.memcached]]\n")
+	assert.Contains(t, doc, "servers = [\"localhost:11211\"]")
+	assert.True(t, strings.HasSuffix(doc, "\n```\n"))
+
+	assert.Error(t, c.WritePluginDoc(&buf, "inputs", "nonexistent"))
+	assert.Error(t, c.WritePluginDoc(&buf, "widgets", "memcached"))
+}
+
+func TestConfig_DefaultConfigPathsWindows(t *testing.T) {
+	for k, v := range map[string]string{
+		"TELEGRAF_CONFIG_PATH": "",
+		"HOME":                 "",
+		"PROGRAMDATA":          `C:\ProgramData`,
+		"APPDATA":              `C:\Users\telegraf\AppData\Roaming`,
+	} {
+		old := os.Getenv(k)
+		os.Setenv(k, v)
+		defer os.Setenv(k, old)
+	}
+
+	assert.Equal(t, []string{
+		`C:\Program Files\Telegraf\telegraf.conf`,
+		`C:\ProgramData\Telegraf\telegraf.conf`,
+		`C:\Users\telegraf\AppData\Roaming\Telegraf\telegraf.conf`,
+	}, defaultConfigPaths("windows"))
+	assert.Equal(t, []string{"/etc/telegraf/telegraf.conf"},
+		defaultConfigPaths("linux"))
+
+	// $TELEGRAF_CONFIG_PATH and $HOME come first
+	os.Setenv("TELEGRAF_CONFIG_PATH", `D:\telegraf.conf`)
+	os.Setenv("HOME", `C:\Users\telegraf`)
+	os.Setenv("APPDATA", "")
+	assert.Equal(t, []string{
+		`D:\telegraf.conf`,
+		filepath.Join(`C:\Users\telegraf`, ".telegraf", "telegraf.conf"),
+		`C:\Program Files\Telegraf\telegraf.conf`,
+		`C:\ProgramData\Telegraf\telegraf.conf`,
+	}, defaultConfigPaths("windows"))
+}
+
+func TestConfig_AddInputFromTOML(t *testing.T) {
+	c := NewConfig()
+	err := c.AddInputFromTOML("[[inputs.memcached]]\n  servers = [\"192.168.1.1\"]\n")
+	assert.NoError(t, err)
+	assert.Len(t, c.Inputs, 1)
+	assert.Equal(t, []string{"192.168.1.1"},
+		c.Inputs[0].Input.(*memcached.Memcached).Servers)
+
+	// malformed TOML, unknown plugins and other sections add nothing
+	assert.Error(t, c.AddInputFromTOML("[[inputs.memcached]\n"))
+	assert.Error(t, c.AddInputFromTOML(
+		"[[inputs.memcached]]\n[[inputs.nonexistent]]\n"))
+	assert.Error(t, c.AddInputFromTOML("[[outputs.file]]\n"))
+	assert.Error(t, c.AddInputFromTOML(""))
+	assert.Len(t, c.Inputs, 1)
+}
+
+func TestConfig_AddOutputFromTOML(t *testing.T) {
+	c := NewConfig()
+	err := c.AddOutputFromTOML("[[outputs.file]]\n  files = [\"stdout\"]\n")
+	assert.NoError(t, err)
+	assert.Len(t, c.Outputs, 1)
+	assert.Equal(t, "file", c.Outputs[0].Name)
+
+	assert.Error(t, c.AddOutputFromTOML("[[inputs.memcached]]\n"))
+	assert.Len(t, c.Outputs, 1)
+}
+
+func TestConfig_AddPluginsFromTOMLAgentSettings(t *testing.T) {
+	c := NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[agent]
+  metric_batch_size = 7
+  metric_buffer_limit = 70
+  input_timeout = "3s"
+
+[[outputs.file]]
+  files = ["stdout"]
+  alias = "backup"
+`))
+
+	assert.NoError(t, c.AddInputFromTOML("[[inputs.memcached]]\n"))
+	assert.Equal(t, 3*time.Second, c.Inputs[0].Config.CollectionTimeout)
+
+	assert.NoError(t, c.AddOutputFromTOML(
+		"[[outputs.file]]\n  files = [\"stderr\"]\n  failover_to = \"backup\"\n"))
+	if !assert.Len(t, c.Outputs, 2) {
+		return
+	}
+	assert.Equal(t, 7, c.Outputs[1].MetricBatchSize)
+	assert.Equal(t, 70, c.Outputs[1].MetricBufferLimit)
+	// the failover output of the added output is linked
+	assert.True(t, c.Outputs[0].Standby())
+
+	// an unknown failover output adds nothing
+	assert.Error(t, c.AddOutputFromTOML(
+		"[[outputs.file]]\n  files = [\"stderr\"]\n  failover_to = \"missing\"\n"))
+	assert.Len(t, c.Outputs, 2)
+}
+
+func TestConfig_InvalidNameTemplate(t *testing.T) {
+	c := NewConfig()
+	err := c.AddInputFromTOML(
+		"[[inputs.memcached]]\n  name_template = \"{{.PluginName\"\n")
+	assert.Error(t, err)
+
+	err = c.AddInputFromTOML(
+		"[[inputs.memcached]]\n  name_template = \"{{.PluginName}}_{{.Tags.region}}\"\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "{{.PluginName}}_{{.Tags.region}}",
+		c.Inputs[0].Config.NameTemplate)
+}
+
+func TestConfig_InputFilterByTag(t *testing.T) {
+	c := NewConfig()
+	err := c.AddInputFromTOML(`
+[[inputs.memcached]]
+  servers = ["fast"]
+  [inputs.memcached.tags]
+    tier = "fast"
+[[inputs.memcached]]
+  servers = ["slow"]
+  [inputs.memcached.tags]
+    tier = "slow"
+[[inputs.exec]]
+  commands = ["true"]
+`)
+	assert.NoError(t, err)
+
+	fast := c.InputFilterByTag("tier", "fast")
+	assert.Len(t, fast, 1)
+	assert.Equal(t, []string{"fast"},
+		fast[0].Input.(*memcached.Memcached).Servers)
+	assert.Empty(t, c.InputFilterByTag("tier", "medium"))
+	assert.Empty(t, c.InputFilterByTag("region", ""))
+}
+
+func TestConfig_AgentProxy(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfig("./testdata/agent_proxy.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, ProxyConfig{
+		HTTPProxy: "http://proxy.example.com:3128",
+		NoProxy:   "localhost",
+	}, c.Agent.Proxy)
+
+	for _, env := range []string{"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY"} {
+		old, ok := os.LookupEnv(env)
+		defer func(env string) {
+			if ok {
+				os.Setenv(env, old)
+			} else {
+				os.Unsetenv(env)
+			}
+		}(env)
+	}
+	os.Setenv("HTTPS_PROXY", "http://other.example.com")
+
+	assert.NoError(t, c.Agent.Proxy.Apply())
+	assert.Equal(t, "http://proxy.example.com:3128", os.Getenv("HTTP_PROXY"))
+	assert.Equal(t, "http://other.example.com", os.Getenv("HTTPS_PROXY"))
+	assert.Equal(t, "localhost", os.Getenv("NO_PROXY"))
+
+	// a config without proxy settings restores the previous values
+	assert.NoError(t, ProxyConfig{HTTPSProxy: "http://proxy2.example.com"}.Apply())
+	assert.Equal(t, "http://proxy2.example.com", os.Getenv("HTTPS_PROXY"))
+	assert.NoError(t, ProxyConfig{}.Apply())
+	_, ok := os.LookupEnv("HTTP_PROXY")
+	assert.False(t, ok)
+	assert.Equal(t, "http://other.example.com", os.Getenv("HTTPS_PROXY"))
+}
+
+func TestConfig_PrintAgentConfig(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, PrintAgentConfig(&buf))
+	out := buf.String()
+
+	assert.True(t, strings.HasPrefix(out,
+		"# Configuration for telegraf agent\n[agent]\n"+
+			"  ## Default data collection interval for all inputs\n"+
+			"  interval = \"10s\"\n"))
+	assert.Contains(t, out, "  round_interval = true\n")
+	assert.Contains(t, out, "  flush_buffer_when_full = false\n")
+	assert.Contains(t, out, "  utc = false\n")
+	assert.Contains(t, out, "  ## that sent a datagram to it.\n"+
+		"  internal_statsd_port = 0\n")
+	assert.Contains(t, out, "  [agent.proxy]\n    http_proxy = \"\"\n")
+
+	// The output must parse back as TOML
+	tbl, err := parseContents(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Contains(t, tbl.Fields, "agent")
+}
+
+func TestConfig_FlushBufferWhenFullDeprecated(t *testing.T) {
+	c := NewConfig()
+	c.Agent.MetricOverflowStrategy = ""
+	err := c.LoadConfig("./testdata/deprecated_agent.toml")
+	assert.NoError(t, err)
+
+	assert.False(t, c.Agent.FlushBufferWhenFull)
+	assert.Equal(t, "drop_oldest", c.Agent.MetricOverflowStrategy)
+	assert.Len(t, c.Agent.Deprecated, 1)
+	assert.Contains(t, c.Agent.Deprecated[0], "flush_buffer_when_full")
+	assert.NoError(t, c.Validate())
+}
+
+func TestConfig_Validate(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfig("./testdata/deprecated_agent.toml")
+	assert.NoError(t, err)
+	assert.NoError(t, c.Validate())
+
+	c.Agent.MetricOverflowStrategy = "block"
+	assert.Error(t, c.Validate())
+
+	c = NewConfig()
+	assert.NoError(t, c.LoadConfig("./testdata/single_plugin.toml"))
+	assert.Error(t, c.Validate())
+}
+
+func TestConfig_LoadConfigString(t *testing.T) {
+	os.Setenv("MY_TEST_SERVER", "192.168.1.1")
+	defer os.Unsetenv("MY_TEST_SERVER")
+
+	c := NewConfig()
+	err := c.LoadConfigString("\xef\xbb\xbf" + `
+[agent]
+  interval = "5s"
+
+[[inputs.memcached]]
+  servers = ["$MY_TEST_SERVER"]
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, c.Agent.Interval.Duration)
+	assert.Equal(t, []string{"192.168.1.1"},
+		c.Inputs[0].Input.(*memcached.Memcached).Servers)
+
+	assert.Error(t, c.LoadConfigString("[[inputs.memcached]"))
+}
+
+func TestConfig_StartupErrorBehavior(t *testing.T) {
+	contents := `
+[agent]
+  startup_error_behavior = "%s"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[inputs.no_such_input]]
+
+[[outputs.file]]
+  files = ["stdout"]
+`
+	// config errors are fatal whatever the behavior, only plugins failing to
+	// start are skipped or retried
+	for _, behavior := range []string{"exit", "skip", "retry"} {
+		c := NewConfig()
+		assert.Error(t, c.LoadConfigString(fmt.Sprintf(contents, behavior)))
+	}
+
+	c := NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[agent]
+  startup_error_behavior = "retry"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[outputs.file]]
+  files = ["stdout"]
+`))
+	assert.NoError(t, c.Validate())
+
+	c.Agent.StartupErrorBehavior = "ignore"
+	assert.Error(t, c.Validate())
+}
+
+func TestConfig_MetricTimestampSource(t *testing.T) {
+	c := NewConfig()
+	assert.Equal(t, "agent", c.Agent.MetricTimestampSource)
+	assert.NoError(t, c.LoadConfigString(`
+[agent]
+  metric_timestamp_source = "ntp"
+  metric_timestamp_ntp_server = "time.example.com"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[outputs.file]]
+  files = ["stdout"]
+`))
+	assert.Equal(t, "ntp", c.Agent.MetricTimestampSource)
+	assert.Equal(t, "time.example.com", c.Agent.MetricTimestampNTPServer)
+	assert.NoError(t, c.Validate())
+
+	c.Agent.MetricTimestampSource = "gps"
+	assert.Error(t, c.Validate())
+}
+
+func TestConfig_DefaultTLS(t *testing.T) {
+	c := NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[agent.tls]
+  ssl_ca = "/etc/telegraf/ca.pem"
+  ssl_cert = "/etc/telegraf/cert.pem"
+  ssl_key = "/etc/telegraf/key.pem"
+
+[[inputs.prometheus]]
+  urls = ["https://localhost:9100/metrics"]
+
+[[inputs.prometheus]]
+  urls = ["https://localhost:9273/metrics"]
+  ssl_ca = "/etc/telegraf/other-ca.pem"
+  insecure_skip_verify = true
+`))
+	assert.Len(t, c.Inputs, 2)
+
+	p := c.Inputs[0].Input.(*prometheus.Prometheus)
+	assert.Equal(t, "/etc/telegraf/ca.pem", p.SSLCA)
+	assert.Equal(t, "/etc/telegraf/cert.pem", p.SSLCert)
+	assert.False(t, p.InsecureSkipVerify)
+
+	// options set for the plugin override the defaults one by one
+	p = c.Inputs[1].Input.(*prometheus.Prometheus)
+	assert.Equal(t, "/etc/telegraf/other-ca.pem", p.SSLCA)
+	assert.Equal(t, "/etc/telegraf/cert.pem", p.SSLCert)
+	assert.Equal(t, "/etc/telegraf/key.pem", p.SSLKey)
+	assert.True(t, p.InsecureSkipVerify)
+}
+
+func TestConfig_ComputeEffectiveInterval(t *testing.T) {
+	c := NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[agent]
+  interval = "10s"
+  collection_jitter = "2s"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[inputs.exec]]
+  commands = ["true"]
+  interval = "1s"
+`))
+
+	min, max := c.ComputeEffectiveInterval("memcached")
+	assert.Equal(t, 8*time.Second, min)
+	assert.Equal(t, 12*time.Second, max)
+
+	// the jitter is longer than the interval of the input
+	min, max = c.ComputeEffectiveInterval("exec")
+	assert.Equal(t, time.Duration(0), min)
+	assert.Equal(t, 3*time.Second, max)
+
+	min, max = c.ComputeEffectiveInterval("cpu")
+	assert.Equal(t, time.Duration(0), min)
+	assert.Equal(t, time.Duration(0), max)
+}
+
+func TestConfig_XPathParser(t *testing.T) {
+	tbl, err := parseContents([]byte(`
+data_format = "xpath_json"
+
+[[xpath_config]]
+  metric_name = "disk"
+  metric_selection = "/disks"
+  [xpath_config.fields]
+    used = "used"
+  [xpath_config.tags]
+    device = "device"
+`))
+	assert.NoError(t, err)
+	p, err := buildParser("exec", tbl)
+	assert.NoError(t, err)
+	assert.NotContains(t, tbl.Fields, "xpath_config")
+
+	metrics, err := p.Parse([]byte(`{"disks": [{"device": "sda", "used": 55}]}`))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "disk", metrics[0].Name())
+	assert.Equal(t, map[string]string{"device": "sda"}, metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{"used": int64(55)},
+		metrics[0].Fields())
+
+	c := NewConfig()
+	err = c.LoadConfigString(`
+[[inputs.exec]]
+  data_format = "xpath_json"
+  [[inputs.exec.xpath_config]]
+    [inputs.exec.xpath_config.fields]
+      used = "/disks["
+`)
+	assert.Error(t, err)
+}
+
+func TestConfig_TagsFromFile(t *testing.T) {
+	c := NewConfig()
+	c.Tags["dc"] = "eu-west-1"
+	assert.NoError(t, c.TagsFromFile("./testdata/tags"))
+	assert.Equal(t, map[string]string{
+		"dc":   "eu-west-1",
+		"rack": "1a",
+		"role": "web=frontend",
+	}, c.Tags)
+
+	c = NewConfig()
+	err := c.LoadConfigString(`
+[global_tags]
+  dc = "eu-west-1"
+
+[agent]
+  tags_file = "./testdata/tags"
+  tags_merge_strategy = "overwrite"
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1", c.Tags["dc"])
+
+	assert.Error(t, c.TagsFromFile("./testdata/nonexistent"))
+	assert.Error(t, c.TagsFromFile("./testdata/single_plugin.toml"))
+}
+
+func TestConfig_FailoverOutputs(t *testing.T) {
+	c := NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[outputs.file]]
+  files = ["stdout"]
+  failover_to = "backup"
+  failover_threshold = 5
+
+[[outputs.file]]
+  files = ["/tmp/backup.out"]
+  alias = "backup"
+`))
+	assert.NoError(t, c.Validate())
+	if !assert.Len(t, c.Outputs, 2) {
+		return
+	}
+	assert.Equal(t, "backup", c.Outputs[0].Config.FailoverTo)
+	assert.Equal(t, 5, c.Outputs[0].Config.FailoverThreshold)
+	assert.Equal(t, "backup", c.Outputs[1].Config.Alias)
+	// the output failed over to only receives the metrics of the primary
+	assert.False(t, c.Outputs[0].Standby())
+	assert.True(t, c.Outputs[1].Standby())
+
+	c = NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[outputs.file]]
+  files = ["stdout"]
+  failover_to = "missing"
+`))
+	assert.Equal(t, 3, c.Outputs[0].Config.FailoverThreshold)
+	assert.Error(t, c.Validate())
+
+	c = NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[outputs.file]]
+  files = ["stdout"]
+  alias = "a"
+  failover_to = "b"
+
+[[outputs.file]]
+  files = ["stderr"]
+  alias = "b"
+  failover_to = "a"
+`))
+	assert.Error(t, c.Validate())
+}
+
+func TestConfig_LoadConfigWithOverrides(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigWithOverrides("./testdata/overrides.toml",
+		map[string]interface{}{
+			"agent.interval":          2 * time.Second,
+			"agent.debug":             true,
+			"agent.metric_batch_size": 50,
+			"inputs[0].tags.env":      "test",
+			"inputs.exec[1].timeout":  "1s",
+		})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2*time.Second, c.Agent.Interval.Duration)
+	assert.True(t, c.Agent.Debug)
+	assert.Equal(t, 50, c.Agent.MetricBatchSize)
+	if !assert.Len(t, c.Inputs, 3) {
+		return
+	}
+	for _, input := range c.Inputs {
+		if input.Name == "memcached" {
+			assert.Equal(t, "test", input.Config.Tags["env"])
+		}
+	}
+
+	var timeouts []time.Duration
+	for _, input := range c.Inputs {
+		if input.Name == "exec" {
+			timeouts = append(timeouts,
+				input.Input.(*exec.Exec).Timeout.Duration)
+		}
+	}
+	assert.Equal(t, []time.Duration{5 * time.Second, time.Second}, timeouts)
+}
+
+func TestConfig_LoadConfigWithOverridesInvalid(t *testing.T) {
+	for _, overrides := range []map[string]interface{}{
+		{"global_tags.dc": "us-east-1"},
+		{"inputs[3].interval": "1s"},
+		{"inputs.exec.timeout": "1s"},
+		{"inputs.memcached[0].tags": "test"},
+		{"agent.interval": []string{"1s"}},
+	} {
+		c := NewConfig()
+		err := c.LoadConfigWithOverrides("./testdata/overrides.toml", overrides)
+		assert.Error(t, err, "%v", overrides)
+	}
+}
+
+func TestConfig_ApplyGlobalTags(t *testing.T) {
+	c := NewConfig()
+	c.Tags = map[string]string{"host": "global", "dc": "us-east-1"}
+	now := time.Now()
+	m, _ := telegraf.NewGaugeMetric("cpu",
+		map[string]string{"host": "local"},
+		map[string]interface{}{"usage": float64(1)},
+		now)
+
+	out := c.ApplyGlobalTags(m)
+	assert.Equal(t, map[string]string{"host": "local", "dc": "us-east-1"},
+		out.Tags())
+	assert.Equal(t, telegraf.Gauge, out.Type())
+	assert.Equal(t, now.UnixNano(), out.UnixNano())
+
+	c.Agent.GlobalTagOverride = true
+	out = c.ApplyGlobalTags(m)
+	assert.Equal(t, map[string]string{"host": "global", "dc": "us-east-1"},
+		out.Tags())
+
+	// metrics that already have the global tags are returned as is
+	assert.True(t, out == c.ApplyGlobalTags(out))
+}
+
+func TestConfig_InputTimeout(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigString(`
+[agent]
+  input_timeout = "30s"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+  collection_timeout = "5s"
+`)
+	if !assert.NoError(t, err) || !assert.Len(t, c.Inputs, 2) {
+		return
+	}
+	assert.Equal(t, 30*time.Second, c.Inputs[0].Config.CollectionTimeout)
+	assert.Equal(t, 5*time.Second, c.Inputs[1].Config.CollectionTimeout)
+
+	c = NewConfig()
+	err = c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Duration(0), c.Inputs[0].Config.CollectionTimeout)
+	}
+}
+
+func TestConfig_ConfigFormatVersion(t *testing.T) {
+	legacy := `
+[[plugins.memcached]]
+  servers = ["localhost"]
+`
+	c := NewConfig()
+	assert.NoError(t, c.LoadConfigString(legacy))
+
+	c = NewConfig()
+	err := c.LoadConfigString("[agent]\n  config_format_version = 2\n" +
+		legacy)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "[plugins] section is not supported")
+	}
+
+	c = NewConfig()
+	err = c.LoadConfigString(`
+[agent]
+  config_format_version = 2
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+  drop = ["uptime"]
+`)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "drop is not supported by "+
+			"config_format_version 2, use fielddrop")
+	}
+
+	c = NewConfig()
+	err = c.LoadConfigString(`
+[agent]
+  config_format_version = 2
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+  fielddrop = ["uptime"]
+`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"uptime"},
+			c.Inputs[0].Config.Filter.FieldDrop)
+	}
+
+	c = NewConfig()
+	err = c.LoadConfigString("[agent]\n  config_format_version = 3\n")
+	assert.Error(t, err)
+}
+
+func TestConfig_StartupRetry(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  startup_retry_interval = "10s"
+  startup_retry_max = 3
+
+[[outputs.file]]
+  startup_retry_interval = "1m"
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 10*time.Second, c.Inputs[0].Config.StartupRetryInterval)
+	assert.Equal(t, 3, c.Inputs[0].Config.StartupRetryMax)
+	assert.Equal(t, time.Minute, c.Outputs[0].Config.StartupRetryInterval)
+	assert.Equal(t, 0, c.Outputs[0].Config.StartupRetryMax)
+
+	c = NewConfig()
+	err = c.LoadConfigString(`
+[[inputs.memcached]]
+  startup_retry_max = -1
+`)
+	assert.Error(t, err)
+}
+
+func TestConfig_Deduplication(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  [inputs.memcached.deduplication]
+    dedup_interval = "5m"
+    dedup_fields = ["uptime"]
+    dedup_cache_size = 10
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &models.DedupConfig{
+		Interval:  5 * time.Minute,
+		Fields:    []string{"uptime"},
+		CacheSize: 10,
+	}, c.Inputs[0].Config.Dedup)
+
+	c = NewConfig()
+	err = c.LoadConfigString(`
+[[inputs.memcached]]
+  [inputs.memcached.deduplication]
+    dedup_fields = ["uptime"]
+`)
+	assert.Error(t, err)
+}
+
+func TestConfig_TagsFromEnv(t *testing.T) {
+	os.Setenv("TEST_RACK_ID", "r42")
+	os.Setenv("TEST_CLUSTER_NAME", "prod")
+	defer os.Unsetenv("TEST_RACK_ID")
+	defer os.Unsetenv("TEST_CLUSTER_NAME")
+
+	c := NewConfig()
+	err := c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  tags_from_env = ["TEST_RACK_ID", "cluster=TEST_CLUSTER_NAME",
+    "missing=TEST_UNSET_VAR", "dc=TEST_CLUSTER_NAME"]
+  [inputs.memcached.tags]
+    dc = "us-east-1"
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]string{
+		"TEST_RACK_ID": "r42",
+		"cluster":      "prod",
+		"dc":           "us-east-1",
+	}, c.Inputs[0].Config.Tags)
+}
+
+func TestConfig_FieldRename(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  [inputs.memcached.field_rename]
+    "get_hits" = "hits"
+    "get_*" = "gets"
+    "cmd_*" = "commands"
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var patterns []string
+	for _, rename := range c.Inputs[0].Config.FieldRename {
+		patterns = append(patterns, rename.Pattern+"="+rename.Name)
+	}
+	assert.Equal(t, []string{"get_hits=hits", "get_*=gets", "cmd_*=commands"},
+		patterns)
+
+	err = NewConfig().LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  [inputs.memcached.field_rename]
+    "get_hits" = 1
+`)
+	assert.Error(t, err)
+}
+
+func TestConfig_PerPluginLogLevel(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigString(`
+[agent]
+  [agent.per_plugin_log_level]
+    "inputs.memcached" = "debug"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[outputs.file]]
+  files = ["stdout"]
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, map[string]string{"inputs.memcached": "debug"},
+		c.Agent.PerPluginLogLevel)
+	assert.NoError(t, c.Validate())
+
+	c.Agent.PerPluginLogLevel["memcached"] = "verbose"
+	assert.Error(t, c.Validate())
+}
+
+func TestConfig_LabelWhitelistAction(t *testing.T) {
+	c := NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[agent]
+  metric_label_whitelist = ["host", "dc"]
+  label_whitelist_action = "rename"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[outputs.file]]
+  files = ["stdout"]
+`))
+	assert.Equal(t, []string{"host", "dc"}, c.Agent.MetricLabelWhitelist)
+	assert.NoError(t, c.Validate())
+
+	c.Agent.LabelWhitelistAction = "replace"
+	assert.Error(t, c.Validate())
+}
+
+func TestConfig_GetPluginConfig(t *testing.T) {
+	c := NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  name_override = "first"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+  name_override = "second"
+
+[[outputs.file]]
+  files = ["stdout"]
+`))
+
+	ic, ok := c.GetInputConfig("memcached")
+	if assert.True(t, ok) {
+		assert.Equal(t, c.Inputs[0].Config, ic)
+	}
+	_, ok = c.GetInputConfig("cpu")
+	assert.False(t, ok)
+
+	oc, ok := c.GetOutputConfig("file")
+	if assert.True(t, ok) {
+		assert.Equal(t, "file", oc.Name)
+	}
+	_, ok = c.GetOutputConfig("influxdb")
+	assert.False(t, ok)
+}
+
+func TestConfig_ConcurrentPluginAccess(t *testing.T) {
+	c := NewConfig()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			assert.NoError(t, c.AddInputFromTOML(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+`))
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		c.InputNames()
+		c.SectionEnabled("inputs.memcached")
+		c.EstimateMemoryUsage()
+	}
+	<-done
+
+	c.RLock()
+	n := len(c.Inputs)
+	c.RUnlock()
+	assert.Equal(t, 20, n)
+}
+
+func TestConfig_MetricNameReplace(t *testing.T) {
+	c := NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[agent]
+  interval = "5s"
+
+  [agent.metric_name_replace]
+    "^docker_container_(.*)$" = "container_$1"
+    "^docker_(.*)$" = "engine_$1"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+`))
+	assert.Equal(t, 5*time.Second, c.Agent.Interval.Duration)
+	if assert.Len(t, c.Agent.MetricNameReplace, 2) {
+		assert.Equal(t, "^docker_container_(.*)$",
+			c.Agent.MetricNameReplace[0].From)
+		assert.Equal(t, "^docker_(.*)$", c.Agent.MetricNameReplace[1].From)
+	}
+
+	c = NewConfig()
+	assert.Error(t, c.LoadConfigString(`
+[agent]
+  [agent.metric_name_replace]
+    "docker_(" = "container"
+`))
+}
+
+func TestConfig_OutputNameMapping(t *testing.T) {
+	c := NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[[outputs.file]]
+  files = ["stdout"]
+  [outputs.file.name_mapping]
+    "docker_container_*" = "container"
+    "mem" = "memory"
+`))
+	if assert.Len(t, c.Outputs, 1) {
+		mappings := c.Outputs[0].Config.NameMapping
+		if assert.Len(t, mappings, 2) {
+			assert.Equal(t, "docker_container_*", mappings[0].Pattern)
+			assert.Equal(t, "container", mappings[0].Name)
+			assert.Equal(t, "mem", mappings[1].Pattern)
+		}
+	}
+
+	c = NewConfig()
+	assert.Error(t, c.LoadConfigString(`
+[[outputs.file]]
+  files = ["stdout"]
+  name_mapping = "memory"
+`))
+}
+
+func TestConfig_HistogramConfig(t *testing.T) {
+	c := NewConfig()
+	assert.NoError(t, c.LoadConfigString(`
+[agent]
+  [agent.histogram]
+    gather_latency_buckets = [0.1, 1.0, 10.0]
+`))
+	assert.Equal(t, []float64{0.1, 1, 10},
+		c.Agent.HistogramConfig.GatherLatencyBuckets)
+	assert.Equal(t, models.DefaultWriteLatencyBuckets,
+		c.Agent.HistogramConfig.WriteLatencyBuckets)
+
+	c.Agent.HistogramConfig.WriteLatencyBuckets = []float64{10, 1}
+	err := c.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "write_latency_buckets")
+	}
+}
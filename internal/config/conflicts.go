@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ConflictWarning describes two plugins of the same type that cannot be told
+// apart, most likely the same plugin defined in two config files.
+type ConflictWarning struct {
+	// Plugin is the type of the plugins, ie "inputs.cpu".
+	Plugin string
+	// FirstSource and SecondSource are the config files the plugins were
+	// loaded from.
+	FirstSource  string
+	SecondSource string
+}
+
+func (w ConflictWarning) String() string {
+	return fmt.Sprintf("[%s] is defined twice, with the same alias, tags and "+
+		"measurement names, in %s and in %s, its metrics may be duplicated",
+		w.Plugin, w.FirstSource, w.SecondSource)
+}
+
+// CheckForConflictingInputOutputNames returns a warning for every pair of
+// plugins of the same type with the same alias, or no alias, the same tags,
+// and overlapping measurement names, as inferred by PrintDependencyGraph.
+// Such inputs produce duplicate metric streams, and such outputs write the
+// same metrics twice.
+func (c *Config) CheckForConflictingInputOutputNames() []ConflictWarning {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var warnings []ConflictWarning
+	for i, a := range c.Inputs {
+		for _, b := range c.Inputs[i+1:] {
+			if a.Name != b.Name ||
+				!reflect.DeepEqual(a.Config.Tags, b.Config.Tags) ||
+				!anyPatternsOverlap(inputNamePatterns(a), inputNamePatterns(b)) {
+				continue
+			}
+			warnings = append(warnings, ConflictWarning{
+				Plugin:       "inputs." + a.Name,
+				FirstSource:  a.Source,
+				SecondSource: b.Source,
+			})
+		}
+	}
+	for i, a := range c.Outputs {
+		for _, b := range c.Outputs[i+1:] {
+			if a.Name != b.Name || a.Config.Alias != b.Config.Alias ||
+				!anyPatternsOverlap(outputNamePatterns(a.Config.Filter.NamePass),
+					outputNamePatterns(b.Config.Filter.NamePass)) {
+				continue
+			}
+			warnings = append(warnings, ConflictWarning{
+				Plugin:       "outputs." + a.Name,
+				FirstSource:  a.Source,
+				SecondSource: b.Source,
+			})
+		}
+	}
+	return warnings
+}
+
+// outputNamePatterns returns glob patterns of the measurement names an output
+// with the namepass filter lets through.
+func outputNamePatterns(namePass []string) []string {
+	if len(namePass) == 0 {
+		return []string{"*"}
+	}
+	return namePass
+}
+
+func anyPatternsOverlap(a, b []string) bool {
+	for _, pattern := range a {
+		if anyGlobsOverlap(pattern, b) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_CheckForConflictingInputOutputNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-conflicts")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"a.conf": `
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[inputs.exec]]
+  commands = ["/bin/true"]
+  name_override = "app"
+
+[[outputs.file]]
+  files = ["stdout"]
+`,
+		"b.conf": `
+[[inputs.memcached]]
+  servers = ["cache1"]
+
+[[inputs.exec]]
+  commands = ["/bin/false"]
+  name_override = "other_app"
+
+[[outputs.file]]
+  files = ["stderr"]
+  alias = "errors"
+`,
+	} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name),
+			[]byte(content), 0644))
+	}
+
+	c := NewConfig()
+	require.NoError(t, c.LoadDirectory(dir))
+
+	// the exec inputs produce different measurements, and the outputs have
+	// different aliases
+	assert.Equal(t, []ConflictWarning{{
+		Plugin:       "inputs.memcached",
+		FirstSource:  filepath.Join(dir, "a.conf"),
+		SecondSource: filepath.Join(dir, "b.conf"),
+	}}, c.CheckForConflictingInputOutputNames())
+
+	for _, input := range c.Inputs {
+		if input.Source == filepath.Join(dir, "b.conf") {
+			input.Config.Tags["cluster"] = "b"
+		}
+	}
+	assert.Empty(t, c.CheckForConflictingInputOutputNames())
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// ConnectivityError is an output that failed its connectivity check.
+type ConnectivityError struct {
+	// Plugin is the output, as "outputs.name", or its alias if it has one.
+	Plugin string
+	Err    error
+}
+
+func (e ConnectivityError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Plugin, e.Err)
+}
+
+// VerifyConnectivity checks that the remote services of the outputs
+// implementing telegraf.ConnectivityTester can be reached, in parallel, each
+// within timeout, and returns the outputs that failed, in config order. The
+// other outputs are skipped. The outputs need not be started.
+func (c *Config) VerifyConnectivity(
+	ctx context.Context,
+	timeout time.Duration,
+) []ConnectivityError {
+	c.mu.RLock()
+	outputs := c.Outputs
+	c.mu.RUnlock()
+
+	errs := make([]error, len(outputs))
+	var wg sync.WaitGroup
+	for i, output := range outputs {
+		tester, ok := output.Output.(telegraf.ConnectivityTester)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, tester telegraf.ConnectivityTester) {
+			defer wg.Done()
+			errs[i] = testConnect(ctx, tester, timeout)
+		}(i, tester)
+	}
+	wg.Wait()
+
+	var failed []ConnectivityError
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		plugin := "outputs." + outputs[i].Name
+		if outputs[i].Config.Alias != "" {
+			plugin = outputs[i].Config.Alias
+		}
+		failed = append(failed, ConnectivityError{Plugin: plugin, Err: err})
+	}
+	return failed
+}
+
+// testConnect runs the connectivity check of tester, giving up after timeout
+// even if the check does not return.
+func testConnect(
+	ctx context.Context,
+	tester telegraf.ConnectivityTester,
+	timeout time.Duration,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- tester.TestConnect(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("no connection within %s, %s", timeout, ctx.Err())
+	}
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testerOutput is a reloadOutput with a connectivity check.
+type testerOutput struct {
+	reloadOutput
+	err   error
+	block bool
+}
+
+func (o *testerOutput) TestConnect(ctx context.Context) error {
+	if o.block {
+		<-ctx.Done()
+		time.Sleep(time.Second)
+	}
+	return o.err
+}
+
+func TestConfig_VerifyConnectivity(t *testing.T) {
+	c := reloadConfig(
+		&testerOutput{},
+		&testerOutput{err: errors.New("connection refused")},
+		&reloadOutput{},
+		&testerOutput{block: true},
+	)
+	c.Outputs[3] = models.NewRunningOutput("slow", c.Outputs[3].Output,
+		&models.OutputConfig{Name: "slow", Alias: "backup"}, 0, 0)
+
+	failed := c.VerifyConnectivity(context.Background(), 50*time.Millisecond)
+	if assert.Len(t, failed, 2) {
+		assert.Equal(t, "outputs.test", failed[0].Plugin)
+		assert.EqualError(t, failed[0].Err, "connection refused")
+		assert.Equal(t, "backup", failed[1].Plugin)
+		assert.Contains(t, failed[1].Error(), "backup: no connection within")
+	}
+}
@@ -0,0 +1,223 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+// secretFields matches the option names whose values DebugPlugins redacts.
+var secretFields, _ = filter.Compile([]string{
+	"*password*", "*passwd*", "*secret*", "*token*", "*api_key*",
+	"*private_key*", "*credentials*",
+})
+
+// DebugPlugins writes the resolved config of every loaded input and output
+// to w, as plain text: environment variables and secrets are expanded, global
+// tags merged and filters compiled. Options that look like secrets, such as
+// passwords and tokens, are redacted.
+func (c *Config) DebugPlugins(w io.Writer) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, input := range c.Inputs {
+		if err := debugInput(w, input); err != nil {
+			return err
+		}
+	}
+	for _, output := range c.Outputs {
+		if err := debugOutput(w, output); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func debugInput(w io.Writer, input *models.RunningInput) error {
+	ic := input.Config
+	var lines []string
+	if ic.Interval != 0 {
+		lines = append(lines, fmt.Sprintf("interval: %s", ic.Interval))
+	}
+	if ic.NameOverride != "" {
+		lines = append(lines, fmt.Sprintf("name_override: %s", ic.NameOverride))
+	}
+	if ic.MeasurementPrefix != "" {
+		lines = append(lines, fmt.Sprintf("name_prefix: %s", ic.MeasurementPrefix))
+	}
+	if ic.MeasurementSuffix != "" {
+		lines = append(lines, fmt.Sprintf("name_suffix: %s", ic.MeasurementSuffix))
+	}
+	if ic.NameTemplate != "" {
+		lines = append(lines, fmt.Sprintf("name_template: %s", ic.NameTemplate))
+	}
+	if len(ic.Tags) > 0 {
+		lines = append(lines, "tags: "+debugTags(ic.Tags))
+	}
+	lines = append(lines, debugFilter(ic.Filter)...)
+	lines = append(lines, debugFields(input.Input)...)
+	return writeDebugPlugin(w, "input", input.Name, lines)
+}
+
+func debugOutput(w io.Writer, output *models.RunningOutput) error {
+	lines := debugFilter(output.Config.Filter)
+	lines = append(lines, debugFields(output.Output)...)
+	return writeDebugPlugin(w, "output", output.Name, lines)
+}
+
+func writeDebugPlugin(w io.Writer, pluginType, name string, lines []string) error {
+	if _, err := fmt.Fprintf(w, "%s: %s\n", pluginType, name); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(w, "  %s\n", line); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// debugTags formats tags as sorted key=value pairs.
+func debugTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+tags[k])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+func debugFilter(f models.Filter) []string {
+	var lines []string
+	for _, option := range filterOptions(f) {
+		lines = append(lines, fmt.Sprintf("%s: %s", option.name,
+			strings.Join(option.patterns, ", ")))
+	}
+	return lines
+}
+
+type filterOption struct {
+	name     string
+	patterns []string
+}
+
+// filterOptions returns the non-empty options of a filter, tagpass and
+// tagdrop having one option per tag, ie "tagpass.cpu".
+func filterOptions(f models.Filter) []filterOption {
+	var options []filterOption
+	add := func(name string, patterns []string) {
+		if len(patterns) > 0 {
+			options = append(options, filterOption{name, patterns})
+		}
+	}
+	add("namepass", f.NamePass)
+	add("namedrop", f.NameDrop)
+	add("namematch", f.NameMatch)
+	add("namedropmatch", f.NameDropMatch)
+	add("fieldpass", f.FieldPass)
+	add("fielddrop", f.FieldDrop)
+	add("taginclude", f.TagInclude)
+	add("tagexclude", f.TagExclude)
+	for _, tf := range f.TagPass {
+		add("tagpass."+tf.Name, tf.Filter)
+	}
+	for _, tf := range f.TagDrop {
+		add("tagdrop."+tf.Name, tf.Filter)
+	}
+	return options
+}
+
+// SummarizeFilters returns a table of the filters of the loaded inputs and
+// outputs, with one row per non-empty filter option and columns aligned with
+// spaces, so that it can be pasted into an issue. Plugins without filters are
+// left out, the table only has its header if no filter is set.
+func (c *Config) SummarizeFilters() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TYPE\tNAME\tFILTER\tPATTERNS")
+	addRows := func(pluginType, name string, f models.Filter) {
+		for _, option := range filterOptions(f) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", pluginType, name, option.name,
+				strings.Join(option.patterns, ", "))
+		}
+	}
+	for _, input := range c.Inputs {
+		addRows("input", input.Name, input.Config.Filter)
+	}
+	for _, output := range c.Outputs {
+		addRows("output", output.Name, output.Config.Filter)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// debugFields returns the string, number, bool, duration and string list
+// options of a plugin. Other options are left out.
+func debugFields(plugin interface{}) []string {
+	v := reflect.ValueOf(plugin)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var lines []string
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" || field.Tag.Get("toml") == "-" {
+			continue
+		}
+		value, ok := debugValue(v.Field(i))
+		if !ok {
+			continue
+		}
+		key := tomlKey(field)
+		if value != "" && secretFields.Match(key) {
+			value = "<redacted>"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", key, value))
+	}
+	return lines
+}
+
+func debugValue(v reflect.Value) (string, bool) {
+	if d, ok := v.Interface().(internal.Duration); ok {
+		return d.Duration.String(), true
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16,
+		reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("%v", v.Interface()), true
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return "", false
+		}
+		values := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, v.Index(i).String())
+		}
+		return "[" + strings.Join(values, ", ") + "]", true
+	}
+	return "", false
+}
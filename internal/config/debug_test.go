@@ -0,0 +1,99 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_DebugPlugins(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  interval = "5s"
+  namepass = ["memcached*"]
+  [inputs.memcached.tags]
+    dc = "us-east-1"
+
+[[outputs.file]]
+  files = ["stdout"]
+  tagexclude = ["host"]
+`))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.DebugPlugins(&buf))
+	assert.Equal(t, `input: memcached
+  interval: 5s
+  tags: dc=us-east-1
+  namepass: memcached*
+  servers: [localhost]
+  unix_sockets: []
+
+output: file
+  tagexclude: host
+  files: [stdout]
+
+`, buf.String())
+}
+
+func TestConfig_DebugFieldsRedactsSecrets(t *testing.T) {
+	plugin := &struct {
+		URL      string
+		Username string
+		Password string
+		APIToken string `toml:"api_token"`
+		Timeout  internal.Duration
+		Retries  int
+		Headers  map[string]string
+		secret   string
+	}{
+		URL:      "http://localhost",
+		Username: "telegraf",
+		Password: "hunter2",
+		APIToken: "abcdef",
+		Timeout:  internal.Duration{Duration: 5 * time.Second},
+		Retries:  3,
+		secret:   "hidden",
+	}
+
+	assert.Equal(t, []string{
+		"url: http://localhost",
+		"username: telegraf",
+		"password: <redacted>",
+		"api_token: <redacted>",
+		"timeout: 5s",
+		"retries: 3",
+	}, debugFields(plugin))
+}
+
+func TestConfig_SummarizeFilters(t *testing.T) {
+	c := NewConfig()
+	assert.Equal(t, "TYPE  NAME  FILTER  PATTERNS\n", c.SummarizeFilters())
+
+	require.NoError(t, c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  namepass = ["memcached*"]
+  fielddrop = ["uptime", "pid"]
+  [inputs.memcached.tagdrop]
+    server = ["test*"]
+
+[[inputs.memcached]]
+  servers = ["otherhost"]
+
+[[outputs.file]]
+  files = ["stdout"]
+  tagexclude = ["host"]
+`))
+	assert.Equal(t, `TYPE    NAME       FILTER          PATTERNS
+input   memcached  namepass        memcached*
+input   memcached  fielddrop       uptime, pid
+input   memcached  tagdrop.server  test*
+output  file       tagexclude      host
+`, c.SummarizeFilters())
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/influxdata/toml/ast"
+)
+
+// ApplyDefaults sets the fields of the agent config left at their zero value
+// to the defaults of NewConfig, and creates the maps of c that are nil. It is
+// for configs not created by NewConfig, ie unmarshaled from JSON, and is
+// called before the first config file is loaded into such a config, so that
+// the values of the files are kept. It is applied only once: options set to
+// zero by a file are not reset by the next one.
+//
+// Boolean fields are left as is, since false cannot be told apart from an
+// option turned off: round_interval is only true by default with NewConfig.
+func (c *Config) ApplyDefaults() {
+	def := NewConfig()
+	c.defaultsApplied = true
+	if c.Agent == nil {
+		c.Agent = def.Agent
+	} else {
+		applyDefaultFields(reflect.ValueOf(c.Agent).Elem(),
+			reflect.ValueOf(def.Agent).Elem())
+	}
+
+	if c.Tags == nil {
+		c.Tags = def.Tags
+	}
+	if c.secretStores == nil {
+		c.secretStores = def.secretStores
+	}
+	if c.directoryFiles == nil {
+		c.directoryFiles = def.directoryFiles
+	}
+}
+
+// applyDefaultFields sets the fields of the struct v that are zero to those of
+// def, recursing into nested structs.
+func applyDefaultFields(v, def reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanSet() || field.Kind() == reflect.Bool {
+			continue
+		}
+		if field.Kind() == reflect.Struct {
+			applyDefaultFields(field, def.Field(i))
+			continue
+		}
+		if isZeroValue(field) {
+			field.Set(def.Field(i))
+		}
+	}
+}
+
+// restoreAgentTableDefaults sets the options of the sub-tables of the [agent]
+// table tbl that are left empty back to the defaults of NewConfig. The toml
+// package decodes a table into a new zero struct, so its defaults are lost as
+// soon as the table is in a config file.
+func (c *Config) restoreAgentTableDefaults(tbl *ast.Table) {
+	def := NewConfig().Agent
+	tables := map[string][2]interface{}{
+		"etcd":       {&c.Agent.EtcdConfig, &def.EtcdConfig},
+		"git_config": {&c.Agent.GitConfig, &def.GitConfig},
+		"histogram":  {&c.Agent.HistogramConfig, &def.HistogramConfig},
+	}
+	for name, structs := range tables {
+		if _, ok := tbl.Fields[name]; ok {
+			applyDefaultFields(reflect.ValueOf(structs[0]).Elem(),
+				reflect.ValueOf(structs[1]).Elem())
+		}
+	}
+}
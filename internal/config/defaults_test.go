@@ -0,0 +1,85 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ApplyDefaults(t *testing.T) {
+	c := &Config{Agent: &AgentConfig{
+		Interval:             internal.Duration{Duration: 5 * time.Second},
+		StartupErrorBehavior: "skip",
+	}}
+	c.ApplyDefaults()
+
+	assert.Equal(t, 5*time.Second, c.Agent.Interval.Duration)
+	assert.Equal(t, "skip", c.Agent.StartupErrorBehavior)
+	assert.Equal(t, 10*time.Second, c.Agent.FlushInterval.Duration)
+	assert.Equal(t, models.OVERFLOW_DROP_OLDEST, c.Agent.MetricOverflowStrategy)
+	assert.Equal(t, time.Minute, c.Agent.GitConfig.PollingInterval.Duration)
+	assert.Equal(t, 1, c.Agent.ConfigFormatVersion)
+	// booleans are left as is
+	assert.False(t, c.Agent.RoundInterval)
+	assert.NotNil(t, c.Tags)
+}
+
+func TestConfig_ApplyDefaultsLoad(t *testing.T) {
+	c := &Config{}
+	require.NoError(t, c.LoadConfigString(`
+[agent]
+  flush_interval = "30s"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+`))
+	assert.Equal(t, 10*time.Second, c.Agent.Interval.Duration)
+	assert.Equal(t, 30*time.Second, c.Agent.FlushInterval.Duration)
+	assert.Equal(t, []string{"memcached"}, c.InputNames())
+}
+
+func TestConfig_ApplyDefaultsOnce(t *testing.T) {
+	c := &Config{}
+	require.NoError(t, c.LoadConfigString(`
+[agent]
+  input_workers = 0
+  flush_interval = "0s"
+`))
+	require.NoError(t, c.LoadConfigString(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+`))
+	assert.Equal(t, 0, c.Agent.InputWorkers)
+	assert.Equal(t, time.Duration(0), c.Agent.FlushInterval.Duration)
+	assert.Equal(t, 10*time.Second, c.Agent.Interval.Duration)
+}
+
+func TestConfig_ApplyDefaultsDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-defaults")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.conf"),
+		[]byte("[agent]\n  flush_interval = \"0s\"\n  input_workers = 0\n"),
+		0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.conf"),
+		[]byte("[[inputs.memcached]]\n  servers = [\"localhost\"]\n"), 0644))
+
+	c := NewConfig()
+	require.NoError(t, c.LoadDirectory(dir))
+	assert.Equal(t, time.Duration(0), c.Agent.FlushInterval.Duration)
+	assert.Equal(t, 0, c.Agent.InputWorkers)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c.conf"),
+		[]byte("[[inputs.memcached]]\n  servers = [\"otherhost\"]\n"), 0644))
+	_, err = c.RescanDirectories()
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), c.Agent.FlushInterval.Duration)
+	assert.Equal(t, 0, c.Agent.InputWorkers)
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+// directoryFile holds the plugins loaded from one file of a config directory.
+type directoryFile struct {
+	inputs  []*models.RunningInput
+	outputs []*models.RunningOutput
+
+	// failedInputs and failedOutputs are the plugins that failed to start,
+	// see DirectoryPluginsFailed.
+	failedInputs  []*models.RunningInput
+	failedOutputs []*models.RunningOutput
+
+	// missed is set when the file was not found by the last rescan.
+	missed bool
+}
+
+// DirectoryChange lists the plugins of the config files added to, and
+// removed from, the config directories. The plugins that failed to start are
+// added again, see DirectoryPluginsFailed.
+type DirectoryChange struct {
+	AddedInputs    []*models.RunningInput
+	AddedOutputs   []*models.RunningOutput
+	RemovedInputs  []*models.RunningInput
+	RemovedOutputs []*models.RunningOutput
+}
+
+// RescanDirectories looks for .conf files added to, or removed from, the
+// directories loaded by LoadDirectory. The plugins of added files are loaded
+// and returned, files that fail to load are reported in the error and tried
+// again on the next rescan. A file missing from a rescan is only removed if it
+// is still missing from the next one, so that a file being replaced does not
+// stop its plugins.
+//
+// c.Inputs and c.Outputs are left unchanged: the caller runs the added
+// plugins, and stops the removed ones.
+func (c *Config) RescanDirectories() (*DirectoryChange, error) {
+	present := make(map[string]bool)
+	for _, dir := range c.directories {
+		files, err := configFilesIn(dir)
+		if err != nil {
+			return nil, fmt.Errorf("Error scanning %s, %s", dir, err)
+		}
+		for _, file := range files {
+			present[file] = true
+		}
+	}
+
+	var paths []string
+	for path := range present {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	change := &DirectoryChange{}
+	var errs []string
+	for _, path := range paths {
+		if file, ok := c.directoryFiles[path]; ok {
+			file.missed = false
+			change.AddedInputs = append(change.AddedInputs,
+				file.failedInputs...)
+			change.AddedOutputs = append(change.AddedOutputs,
+				file.failedOutputs...)
+			file.failedInputs, file.failedOutputs = nil, nil
+			continue
+		}
+		file, err := c.loadDirectoryFile(path)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		c.directoryFiles[path] = file
+		change.AddedInputs = append(change.AddedInputs, file.inputs...)
+		change.AddedOutputs = append(change.AddedOutputs, file.outputs...)
+	}
+
+	for path, file := range c.directoryFiles {
+		if present[path] {
+			continue
+		}
+		if !file.missed {
+			file.missed = true
+			continue
+		}
+		delete(c.directoryFiles, path)
+		// plugins that never started have nothing to stop
+		for _, input := range file.inputs {
+			if !containsInput(file.failedInputs, input) {
+				change.RemovedInputs = append(change.RemovedInputs, input)
+			}
+		}
+		for _, output := range file.outputs {
+			if !containsOutput(file.failedOutputs, output) {
+				change.RemovedOutputs = append(change.RemovedOutputs, output)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return change, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return change, nil
+}
+
+// DirectoryPluginsFailed records the plugins added by RescanDirectories that
+// failed to start. The next rescan adds them again, while their file is still
+// there, and they are not reported as removed with their file.
+func (c *Config) DirectoryPluginsFailed(
+	inputs []*models.RunningInput,
+	outputs []*models.RunningOutput,
+) {
+	for _, file := range c.directoryFiles {
+		for _, input := range inputs {
+			if containsInput(file.inputs, input) {
+				file.failedInputs = append(file.failedInputs, input)
+			}
+		}
+		for _, output := range outputs {
+			if containsOutput(file.outputs, output) {
+				file.failedOutputs = append(file.failedOutputs, output)
+			}
+		}
+	}
+}
+
+func containsInput(
+	inputs []*models.RunningInput,
+	input *models.RunningInput,
+) bool {
+	for _, i := range inputs {
+		if i == input {
+			return true
+		}
+	}
+	return false
+}
+
+func containsOutput(
+	outputs []*models.RunningOutput,
+	output *models.RunningOutput,
+) bool {
+	for _, o := range outputs {
+		if o == output {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDirectoryFile loads the plugins of a config file into a scratch config,
+// so that nothing is added to c if the file is invalid.
+func (c *Config) loadDirectoryFile(path string) (*directoryFile, error) {
+	tmp := NewConfig()
+	tmp.InputFilters = c.InputFilters
+	tmp.OutputFilters = c.OutputFilters
+	tmp.Version = c.Version
+	tmp.secretStores = c.secretStores
+	agent := *c.Agent
+	tmp.Agent = &agent
+
+	if err := tmp.LoadConfig(path); err != nil {
+		return nil, err
+	}
+	for _, warning := range tmp.CheckPluginVersionCompatibility() {
+		if warning.Severity == SeverityError {
+			return nil, fmt.Errorf("Incompatible plugin in %s: %s", path,
+				warning)
+		}
+	}
+	return &directoryFile{inputs: tmp.Inputs, outputs: tmp.Outputs}, nil
+}
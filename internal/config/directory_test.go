@@ -0,0 +1,102 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/influxdata/telegraf/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_RescanDirectories(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-rescan")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	memcached := filepath.Join(dir, "memcached.conf")
+	require.NoError(t, ioutil.WriteFile(memcached,
+		[]byte("[[inputs.memcached]]\n  servers = [\"localhost\"]\n"), 0644))
+
+	c := NewConfig()
+	require.NoError(t, c.LoadDirectory(dir))
+	require.Len(t, c.Inputs, 1)
+
+	change, err := c.RescanDirectories()
+	require.NoError(t, err)
+	assert.Equal(t, &DirectoryChange{}, change)
+
+	// the plugins of new files are loaded
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "exec.conf"),
+		[]byte("[[inputs.exec]]\n  commands = [\"true\"]\n"+
+			"[[outputs.file]]\n  files = [\"stdout\"]\n"), 0644))
+	change, err = c.RescanDirectories()
+	require.NoError(t, err)
+	require.Len(t, change.AddedInputs, 1)
+	assert.Equal(t, "exec", change.AddedInputs[0].Name)
+	require.Len(t, change.AddedOutputs, 1)
+	assert.Equal(t, "file", change.AddedOutputs[0].Name)
+	assert.Len(t, c.Inputs, 1)
+
+	// invalid files are reported, and tried again on the next rescan
+	invalid := filepath.Join(dir, "invalid.conf")
+	require.NoError(t, ioutil.WriteFile(invalid,
+		[]byte("[[inputs.exec]]\n  commands = 1\n"), 0644))
+	change, err = c.RescanDirectories()
+	assert.Error(t, err)
+	assert.Len(t, change.AddedInputs, 0)
+	require.NoError(t, os.Remove(invalid))
+
+	// removed files are only reported missing from two rescans in a row
+	require.NoError(t, os.Remove(memcached))
+	change, err = c.RescanDirectories()
+	require.NoError(t, err)
+	assert.Len(t, change.RemovedInputs, 0)
+	change, err = c.RescanDirectories()
+	require.NoError(t, err)
+	require.Len(t, change.RemovedInputs, 1)
+	assert.Equal(t, "memcached", change.RemovedInputs[0].Name)
+	assert.Equal(t, c.Inputs[0], change.RemovedInputs[0])
+}
+
+func TestConfig_DirectoryPluginsFailed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-rescan")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := NewConfig()
+	require.NoError(t, c.LoadDirectory(dir))
+
+	path := filepath.Join(dir, "exec.conf")
+	require.NoError(t, ioutil.WriteFile(path,
+		[]byte("[[inputs.exec]]\n  commands = [\"true\"]\n"+
+			"[[outputs.file]]\n  files = [\"stdout\"]\n"), 0644))
+	change, err := c.RescanDirectories()
+	require.NoError(t, err)
+	require.Len(t, change.AddedInputs, 1)
+	require.Len(t, change.AddedOutputs, 1)
+	output := change.AddedOutputs[0]
+
+	// plugins that failed to start are added again by the next rescan
+	c.DirectoryPluginsFailed(nil, change.AddedOutputs)
+	change, err = c.RescanDirectories()
+	require.NoError(t, err)
+	assert.Len(t, change.AddedInputs, 0)
+	assert.Equal(t, []*models.RunningOutput{output}, change.AddedOutputs)
+	change, err = c.RescanDirectories()
+	require.NoError(t, err)
+	assert.Equal(t, &DirectoryChange{}, change)
+
+	// and are not removed with their file, as they never started
+	c.DirectoryPluginsFailed(nil, []*models.RunningOutput{output})
+	require.NoError(t, os.Remove(path))
+	_, err = c.RescanDirectories()
+	require.NoError(t, err)
+	change, err = c.RescanDirectories()
+	require.NoError(t, err)
+	assert.Len(t, change.RemovedInputs, 1)
+	assert.Len(t, change.RemovedOutputs, 0)
+}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+const effectiveHeader = `# Effective telegraf configuration, as loaded: environment variables and
+# secrets are expanded, and defaults are filled in.
+#
+# WARNING: this output may contain secrets, such as passwords and tokens.
+# Do not share it without removing them first.
+
+`
+
+// PrintEffectiveConfig writes the loaded config to w as TOML: the global tags,
+// the agent table and every input and output, with environment variables and
+// secrets expanded and defaults filled in. Options left at their zero value
+// while their default is not, often an environment variable expanding to
+// nothing, are annotated with "# (default: X)".
+//
+// Plugin options are written as DebugPlugins reads them, other options are
+// left out. The parser and serializer options are written as loaded. Unlike
+// DebugPlugins, secrets are not redacted.
+func (c *Config) PrintEffectiveConfig(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString(effectiveHeader)
+
+	buf.WriteString("[global_tags]\n")
+	writeEffectiveTags(&buf, "  ", c.Tags)
+
+	buf.WriteString("\n# Configuration for telegraf agent\n[agent]\n")
+	printAgentTable(&buf, reflect.ValueOf(*c.Agent), "agent",
+		agentConfigDocs(), reflect.ValueOf(*NewConfig().Agent))
+
+	c.writeEffectivePlugins(&buf, false)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeEffectivePlugins writes the inputs and the outputs. When minimal is
+// set, plugin options left at their default are left out, and so is the
+// interval of the inputs without their own.
+func (c *Config) writeEffectivePlugins(buf *bytes.Buffer, minimal bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, input := range c.Inputs {
+		c.writeEffectiveInput(buf, input, minimal)
+	}
+	for _, input := range c.DisabledInputs {
+		c.writeEffectiveInput(buf, input, minimal)
+	}
+	for _, output := range c.Outputs {
+		writeEffectiveOutput(buf, output, minimal)
+	}
+	for _, output := range c.DisabledOutputs {
+		writeEffectiveOutput(buf, output, minimal)
+	}
+}
+
+func (c *Config) writeEffectiveInput(
+	buf *bytes.Buffer,
+	input *models.RunningInput,
+	minimal bool,
+) {
+	ic := input.Config
+	fmt.Fprintf(buf, "\n[[inputs.%s]]\n", input.Name)
+	if ic.Disabled {
+		buf.WriteString("  enabled = false\n")
+	}
+	interval := ic.Interval
+	if interval == 0 && !minimal {
+		interval = c.Agent.Interval.Duration
+	}
+	if interval != 0 {
+		fmt.Fprintf(buf, "  interval = %q\n", interval.String())
+	}
+	if ic.CollectionTimeout != 0 {
+		fmt.Fprintf(buf, "  collection_timeout = %q\n",
+			ic.CollectionTimeout.String())
+	}
+	writeEffectiveString(buf, "name_override", ic.NameOverride)
+	writeEffectiveString(buf, "name_prefix", ic.MeasurementPrefix)
+	writeEffectiveString(buf, "name_suffix", ic.MeasurementSuffix)
+	writeEffectiveString(buf, "name_template", ic.NameTemplate)
+	if ic.SamplingRate != 0 {
+		fmt.Fprintf(buf, "  sampling_rate = %s\n",
+			tomlValue(reflect.ValueOf(ic.SamplingRate)))
+	}
+	if ic.SamplingSeed != 0 {
+		fmt.Fprintf(buf, "  sampling_seed = %d\n", ic.SamplingSeed)
+	}
+	if ic.MetricBufferLimit != 0 {
+		fmt.Fprintf(buf, "  metric_buffer_limit = %d\n", ic.MetricBufferLimit)
+	}
+	writeEffectiveStartupRetry(buf, ic.StartupRetryInterval, ic.StartupRetryMax)
+	writeEffectiveFilter(buf, ic.Filter)
+
+	var def interface{}
+	if creator, ok := inputs.Inputs[input.Name]; ok {
+		def = creator()
+	}
+	writeEffectiveFields(buf, input.Input, def, minimal)
+	writeEffectiveLines(buf, input.DataFormatOptions)
+
+	if len(ic.Tags) > 0 {
+		fmt.Fprintf(buf, "  [inputs.%s.tags]\n", input.Name)
+		writeEffectiveTags(buf, "    ", ic.Tags)
+	}
+	if len(ic.FieldRename) > 0 {
+		fmt.Fprintf(buf, "  [inputs.%s.field_rename]\n", input.Name)
+		for _, rename := range ic.FieldRename {
+			fmt.Fprintf(buf, "    %q = %q\n", rename.Pattern, rename.Name)
+		}
+	}
+	if ic.Dedup != nil {
+		fmt.Fprintf(buf, "  [inputs.%s.deduplication]\n", input.Name)
+		fmt.Fprintf(buf, "    dedup_interval = %q\n", ic.Dedup.Interval.String())
+		if len(ic.Dedup.Fields) > 0 {
+			fmt.Fprintf(buf, "    dedup_fields = %s\n",
+				tomlValue(reflect.ValueOf(ic.Dedup.Fields)))
+		}
+		if ic.Dedup.CacheSize > 0 {
+			fmt.Fprintf(buf, "    dedup_cache_size = %d\n", ic.Dedup.CacheSize)
+		}
+	}
+	writeEffectiveTagFilters(buf, "inputs."+input.Name, ic.Filter)
+}
+
+func writeEffectiveOutput(
+	buf *bytes.Buffer,
+	output *models.RunningOutput,
+	minimal bool,
+) {
+	oc := output.Config
+	fmt.Fprintf(buf, "\n[[outputs.%s]]\n", output.Name)
+	if oc.Disabled {
+		buf.WriteString("  enabled = false\n")
+	}
+	writeEffectiveString(buf, "alias", oc.Alias)
+	writeEffectiveString(buf, "failover_to", oc.FailoverTo)
+	if oc.FailoverTo != "" {
+		fmt.Fprintf(buf, "  failover_threshold = %d\n", oc.FailoverThreshold)
+	}
+	writeEffectiveStartupRetry(buf, oc.StartupRetryInterval, oc.StartupRetryMax)
+	writeEffectiveFilter(buf, oc.Filter)
+
+	var def interface{}
+	if creator, ok := outputs.Outputs[output.Name]; ok {
+		def = creator()
+	}
+	writeEffectiveFields(buf, output.Output, def, minimal)
+	writeEffectiveLines(buf, output.DataFormatOptions)
+
+	if len(oc.NameMapping) > 0 {
+		fmt.Fprintf(buf, "  [outputs.%s.name_mapping]\n", output.Name)
+		for _, mapping := range oc.NameMapping {
+			fmt.Fprintf(buf, "    %q = %q\n", mapping.Pattern, mapping.Name)
+		}
+	}
+	writeEffectiveTagFilters(buf, "outputs."+output.Name, oc.Filter)
+}
+
+func writeEffectiveLines(buf *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		fmt.Fprintf(buf, "  %s\n", line)
+	}
+}
+
+func writeEffectiveString(buf *bytes.Buffer, key, value string) {
+	if value != "" {
+		fmt.Fprintf(buf, "  %s = %q\n", key, value)
+	}
+}
+
+func writeEffectiveStartupRetry(buf *bytes.Buffer, interval time.Duration, max int) {
+	if interval == 0 {
+		return
+	}
+	fmt.Fprintf(buf, "  startup_retry_interval = %q\n", interval.String())
+	fmt.Fprintf(buf, "  startup_retry_max = %d\n", max)
+}
+
+func writeEffectiveTags(buf *bytes.Buffer, indent string, tags map[string]string) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(buf, "%s%s = %q\n", indent, k, tags[k])
+	}
+}
+
+func writeEffectiveFilter(buf *bytes.Buffer, f models.Filter) {
+	add := func(key string, patterns []string) {
+		if len(patterns) > 0 {
+			fmt.Fprintf(buf, "  %s = %s\n", key,
+				tomlValue(reflect.ValueOf(patterns)))
+		}
+	}
+	add("namepass", f.NamePass)
+	add("namedrop", f.NameDrop)
+	add("namematch", f.NameMatch)
+	add("namedropmatch", f.NameDropMatch)
+	add("fieldpass", f.FieldPass)
+	add("fielddrop", f.FieldDrop)
+	add("taginclude", f.TagInclude)
+	add("tagexclude", f.TagExclude)
+}
+
+// writeEffectiveTagFilters writes the tagpass and tagdrop sub tables of a
+// plugin, they follow its options.
+func writeEffectiveTagFilters(buf *bytes.Buffer, table string, f models.Filter) {
+	add := func(key string, filters []models.TagFilter) {
+		if len(filters) == 0 {
+			return
+		}
+		fmt.Fprintf(buf, "  [%s.%s]\n", table, key)
+		for _, tf := range filters {
+			fmt.Fprintf(buf, "    %s = %s\n", tf.Name,
+				tomlValue(reflect.ValueOf(tf.Filter)))
+		}
+	}
+	add("tagpass", f.TagPass)
+	add("tagdrop", f.TagDrop)
+}
+
+// writeEffectiveFields writes the options of a plugin, annotating them with
+// the options of def, the plugin as created before its config is loaded. When
+// minimal is set, the options equal to those of def are left out instead.
+func writeEffectiveFields(buf *bytes.Buffer, plugin, def interface{}, minimal bool) {
+	v := structValue(plugin)
+	if !v.IsValid() {
+		return
+	}
+	d := structValue(def)
+	if d.IsValid() && d.Type() != v.Type() {
+		d = reflect.Value{}
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" || field.Tag.Get("toml") == "-" {
+			continue
+		}
+		if _, ok := debugValue(v.Field(i)); !ok {
+			continue
+		}
+		if minimal && d.IsValid() && sameValue(v.Field(i), d.Field(i)) {
+			continue
+		}
+		fmt.Fprintf(buf, "  %s = %s", tomlKey(field), tomlValue(v.Field(i)))
+		if d.IsValid() && !minimal {
+			writeDefault(buf, v.Field(i), d.Field(i))
+		}
+		buf.WriteString("\n")
+	}
+}
+
+// writeDefault annotates a value with its default, if the value is zero and
+// the default is not.
+func writeDefault(buf *bytes.Buffer, v, def reflect.Value) {
+	if isZeroValue(v) && !isZeroValue(def) {
+		fmt.Fprintf(buf, " # (default: %s)", tomlValue(def))
+	}
+}
+
+// sameValue reports whether v and def are equal, empty slices and maps being
+// equal to nil ones.
+func sameValue(v, def reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), def.Interface()) ||
+		(isZeroValue(v) && isZeroValue(def))
+}
+
+func isZeroValue(v reflect.Value) bool {
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+		return v.Len() == 0
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// structValue returns the struct pointed to by plugin, or an invalid value if
+// there is none.
+func structValue(plugin interface{}) reflect.Value {
+	v := reflect.ValueOf(plugin)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return v
+}
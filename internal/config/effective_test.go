@@ -0,0 +1,71 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_PrintEffectiveConfig(t *testing.T) {
+	os.Setenv("MY_TEST_SERVER", "192.168.1.1")
+	os.Setenv("MY_TEST_FLUSH", "")
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(`
+[global_tags]
+  dc = "us-east-1"
+
+[agent]
+  interval = "5s"
+  flush_interval = "$MY_TEST_FLUSH"
+
+[[inputs.memcached]]
+  servers = ["$MY_TEST_SERVER"]
+  namepass = ["memcached*"]
+  [inputs.memcached.tags]
+    rack = "a1"
+  [inputs.memcached.tagpass]
+    cpu = ["cpu0"]
+
+[[outputs.file]]
+  enabled = false
+  files = ["stdout"]
+`))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.PrintEffectiveConfig(&buf))
+	out := buf.String()
+
+	assert.True(t, strings.HasPrefix(out, effectiveHeader+
+		"[global_tags]\n  dc = \"us-east-1\"\n"))
+	assert.Contains(t, out, "  interval = \"5s\"\n")
+	assert.Contains(t, out,
+		"  flush_interval = \"0s\" # (default: \"10s\")\n")
+	assert.Contains(t, out, "  round_interval = true\n")
+	assert.Contains(t, out, `
+[[inputs.memcached]]
+  interval = "5s"
+  namepass = ["memcached*"]
+  servers = ["192.168.1.1"]
+  unix_sockets = []
+  [inputs.memcached.tags]
+    rack = "a1"
+  [inputs.memcached.tagpass]
+    cpu = ["cpu0"]
+`)
+	assert.Contains(t, out, `
+[[outputs.file]]
+  enabled = false
+  files = ["stdout"]
+`)
+
+	// The output must parse back as TOML
+	tbl, err := parseContents(buf.Bytes())
+	require.NoError(t, err)
+	assert.Contains(t, tbl.Fields, "agent")
+	assert.Contains(t, tbl.Fields, "inputs")
+	assert.Contains(t, tbl.Fields, "outputs")
+}
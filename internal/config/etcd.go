@@ -0,0 +1,271 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+// EtcdConfig holds the settings of the config loaded from etcd.
+type EtcdConfig struct {
+	// Endpoints are the etcd servers, used when LoadConfigFromEtcd is given
+	// none.
+	Endpoints []string `toml:"endpoints"`
+	// Key is the config loaded at startup by LoadRemoteConfigs, and watched
+	// by WatchRemoteConfigs. None is loaded without a key.
+	Key string `toml:"key"`
+	// DialTimeout is how long connecting to etcd, and fetching the config,
+	// may take.
+	DialTimeout internal.Duration `toml:"dial_timeout"`
+	// Username and Password authenticate to etcd, if set.
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	// TLSConfig holds the TLS settings of https endpoints.
+	TLSConfig internal.TLSConfig `toml:"tls"`
+	// PollingInterval is how often WatchEtcdConfig checks the key for a new
+	// value.
+	PollingInterval internal.Duration `toml:"polling_interval"`
+}
+
+// defaultEtcdDialTimeout is the DialTimeout of an EtcdConfig without one, so
+// that an etcd server that stops answering does not block telegraf.
+const defaultEtcdDialTimeout = 5 * time.Second
+
+// etcdClient fetches keys with the JSON gateway of the etcd v3 API, from the
+// first of its endpoints that answers. Plain HTTP requests are used rather
+// than the etcd client, whose gRPC dependencies need a newer Go than this
+// tree builds with.
+type etcdClient struct {
+	config    EtcdConfig
+	endpoints []string
+	client    *http.Client
+}
+
+// etcdValue is the value of a key, and the revision it was last put at.
+type etcdValue struct {
+	value       []byte
+	modRevision string
+}
+
+func newEtcdClient(
+	cfg EtcdConfig,
+	proxy ProxyConfig,
+	endpoints []string,
+) (*etcdClient, error) {
+	timeout := cfg.DialTimeout.Duration
+	if timeout <= 0 {
+		timeout = defaultEtcdDialTimeout
+	}
+	tlsConfig, err := internal.GetTLSConfig(cfg.TLSConfig.SSLCert,
+		cfg.TLSConfig.SSLKey, cfg.TLSConfig.SSLCA,
+		cfg.TLSConfig.InsecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	return &etcdClient{
+		config:    cfg,
+		endpoints: endpoints,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           proxy.ProxyFunc(),
+				TLSClientConfig: tlsConfig,
+			},
+			Timeout: timeout,
+		},
+	}, nil
+}
+
+// get returns the value of key, or an error if it is not set or no endpoint
+// answers.
+func (e *etcdClient) get(key string) (*etcdValue, error) {
+	var errs []string
+	for _, endpoint := range e.endpoints {
+		value, err := e.getFrom(strings.TrimSuffix(endpoint, "/"), key)
+		if err == nil {
+			return value, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return nil, errors.New(strings.Join(errs, "; "))
+}
+
+func (e *etcdClient) getFrom(endpoint, key string) (*etcdValue, error) {
+	var token string
+	if e.config.Username != "" {
+		var auth struct {
+			Token string `json:"token"`
+		}
+		err := e.post(endpoint+"/v3/auth/authenticate", "", map[string]string{
+			"name":     e.config.Username,
+			"password": e.config.Password,
+		}, &auth)
+		if err != nil {
+			return nil, err
+		}
+		token = auth.Token
+	}
+
+	// The gateway encodes keys and values in base64, as encoding/json does
+	// []byte, and revisions, int64, as strings.
+	var resp struct {
+		Kvs []struct {
+			Value       []byte          `json:"value"`
+			ModRevision json.RawMessage `json:"mod_revision"`
+		} `json:"kvs"`
+	}
+	err := e.post(endpoint+"/v3/kv/range", token, map[string][]byte{
+		"key": []byte(key),
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, fmt.Errorf("key %s is not set on %s", key, endpoint)
+	}
+	return &etcdValue{
+		value:       resp.Kvs[0].Value,
+		modRevision: string(resp.Kvs[0].ModRevision),
+	}, nil
+}
+
+// post sends body as JSON to url, and decodes the JSON response into out.
+func (e *etcdClient) post(url, token string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("could not parse the response of %s, %s", url, err)
+	}
+	return nil
+}
+
+// etcdSource describes the config at key of the etcd servers endpoints, for
+// messages.
+func etcdSource(endpoints []string, key string) string {
+	return fmt.Sprintf("etcd://%s%s", strings.Join(endpoints, ","), key)
+}
+
+// LoadConfigFromEtcd loads the TOML config stored at key in etcd, with the
+// JSON gateway of the v3 API, from the servers endpoints, or those of the
+// [agent.etcd] table if endpoints is empty.
+func (c *Config) LoadConfigFromEtcd(endpoints []string, key string) error {
+	etcdConfig := c.Agent.EtcdConfig
+	if len(endpoints) == 0 {
+		endpoints = etcdConfig.Endpoints
+	}
+	source := etcdSource(endpoints, key)
+	if len(endpoints) == 0 {
+		return fmt.Errorf("Error fetching config %s, no etcd endpoints", source)
+	}
+
+	client, err := newEtcdClient(etcdConfig, c.Agent.Proxy, endpoints)
+	if err != nil {
+		return fmt.Errorf("Error connecting to etcd for config %s, %s",
+			source, err)
+	}
+	value, err := client.get(key)
+	if err != nil {
+		return fmt.Errorf("Error fetching config %s, %s", source, err)
+	}
+	if err := c.LoadFromReader(bytes.NewReader(value.value)); err != nil {
+		return fmt.Errorf("Error loading config %s, %s", source, err)
+	}
+	return nil
+}
+
+// WatchEtcdConfig polls key in etcd, on the servers endpoints or those of the
+// [agent.etcd] table, every polling_interval, and when it was put again,
+// loads the new config. Like WatchGitConfig, it sends on the returned channel
+// when the new config loads, a new config that fails to load is logged, and c
+// is not changed. The watch stops when shutdown is closed.
+func (c *Config) WatchEtcdConfig(
+	endpoints []string,
+	key string,
+	shutdown chan struct{},
+) <-chan struct{} {
+	reloaded := make(chan struct{}, 1)
+	etcdConfig := c.Agent.EtcdConfig
+	if len(endpoints) == 0 {
+		endpoints = etcdConfig.Endpoints
+	}
+	source := etcdSource(endpoints, key)
+	interval := etcdConfig.PollingInterval.Duration
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	client, err := newEtcdClient(etcdConfig, c.Agent.Proxy, endpoints)
+	if err != nil {
+		log.Printf("E! Error watching config %s for changes: %s\n", source, err)
+		return reloaded
+	}
+	var current string
+	if value, err := client.get(key); err != nil {
+		log.Printf("E! Error checking config %s for changes: %s\n", source, err)
+	} else {
+		current = value.modRevision
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-shutdown:
+				return
+			case <-ticker.C:
+			}
+
+			value, err := client.get(key)
+			if err != nil {
+				log.Printf("E! Error checking config %s for changes: %s\n",
+					source, err)
+				continue
+			}
+			if value.modRevision == current {
+				continue
+			}
+			current = value.modRevision
+
+			newCfg := NewConfig()
+			newCfg.Agent.EtcdConfig = etcdConfig
+			newCfg.InputFilters = c.InputFilters
+			newCfg.OutputFilters = c.OutputFilters
+			err = newCfg.LoadFromReader(bytes.NewReader(value.value))
+			if err != nil {
+				log.Printf("E! Not reloading config %s: %s\n", source, err)
+				continue
+			}
+			select {
+			case reloaded <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return reloaded
+}
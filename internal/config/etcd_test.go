@@ -0,0 +1,173 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etcdGateway is the JSON gateway of an etcd server holding a single key,
+// requiring the token of the user telegraf if it has a password.
+type etcdGateway struct {
+	sync.Mutex
+	key      string
+	value    []byte
+	revision int
+	password string
+}
+
+func (g *etcdGateway) put(value string) {
+	g.Lock()
+	defer g.Unlock()
+	g.value = []byte(value)
+	g.revision++
+}
+
+func (g *etcdGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.Lock()
+	defer g.Unlock()
+	switch r.URL.Path {
+	case "/v3/auth/authenticate":
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["name"] != "telegraf" || req["password"] != g.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"token": "mytoken"}`)
+	case "/v3/kv/range":
+		if g.password != "" && r.Header.Get("Authorization") != "mytoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req map[string][]byte
+		json.NewDecoder(r.Body).Decode(&req)
+		if string(req["key"]) != g.key || g.value == nil {
+			fmt.Fprint(w, `{"header": {"revision": "1"}}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"kvs": []map[string]interface{}{{
+				"key":          []byte(g.key),
+				"value":        g.value,
+				"mod_revision": fmt.Sprint(g.revision),
+			}},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestConfig_LoadConfigFromEtcd(t *testing.T) {
+	gateway := &etcdGateway{key: "/telegraf/config", password: "s3cret"}
+	gateway.put(`
+[global_tags]
+  dc = "us-east-1"
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+`)
+	ts := httptest.NewServer(gateway)
+	defer ts.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	// the endpoints are tried in order
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(fmt.Sprintf(`
+[agent.etcd]
+  endpoints = ["%s", "%s"]
+  username = "telegraf"
+  password = "s3cret"
+`, down.URL, ts.URL)))
+	require.NoError(t, c.LoadConfigFromEtcd(nil, "/telegraf/config"))
+	assert.Equal(t, []string{"memcached"}, c.InputNames())
+	assert.Equal(t, map[string]string{"dc": "us-east-1"}, c.Tags)
+
+	c = NewConfig()
+	c.Agent.EtcdConfig.Username = "telegraf"
+	c.Agent.EtcdConfig.Password = "s3cret"
+	err := c.LoadConfigFromEtcd([]string{ts.URL}, "/telegraf/other")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "etcd://"+ts.URL+"/telegraf/other")
+	assert.Contains(t, err.Error(), "not set")
+
+	// wrong credentials
+	c.Agent.EtcdConfig.Password = "wrong"
+	assert.Error(t, c.LoadConfigFromEtcd([]string{ts.URL}, "/telegraf/config"))
+
+	err = NewConfig().LoadConfigFromEtcd(nil, "/telegraf/config")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no etcd endpoints")
+}
+
+func TestConfig_WatchEtcdConfig(t *testing.T) {
+	gateway := &etcdGateway{key: "/telegraf/config"}
+	gateway.put("[[inputs.memcached]]\n  servers = [\"localhost\"]\n")
+	ts := httptest.NewServer(gateway)
+	defer ts.Close()
+
+	c := reloadConfig()
+	c.Agent.EtcdConfig.PollingInterval.Duration = 10 * time.Millisecond
+	shutdown := make(chan struct{})
+	defer close(shutdown)
+	reloaded := c.WatchEtcdConfig([]string{ts.URL}, "/telegraf/config",
+		shutdown)
+
+	// an unchanged key, or a config that does not load, is not reported
+	time.Sleep(50 * time.Millisecond)
+	gateway.put("[[inputs.memcached]\n")
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-reloaded:
+		t.Fatal("config was reported without a valid change")
+	default:
+	}
+
+	gateway.put("[[inputs.memcached]]\n  servers = [\"cache\"]\n")
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("new config was not reported")
+	}
+	// the caller reloads telegraf, c is left as is
+	assert.Empty(t, c.OutputNames())
+}
+
+func TestConfig_LoadRemoteConfigsEtcd(t *testing.T) {
+	gateway := &etcdGateway{key: "/telegraf/config"}
+	gateway.put("[[inputs.memcached]]\n  servers = [\"localhost\"]\n")
+	ts := httptest.NewServer(gateway)
+	defer ts.Close()
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(fmt.Sprintf(`
+[agent.etcd]
+  endpoints = ["%s"]
+  key = "/telegraf/config"
+  polling_interval = "10ms"
+`, ts.URL)))
+	// the dial_timeout default is kept with the table
+	assert.Equal(t, defaultEtcdDialTimeout,
+		c.Agent.EtcdConfig.DialTimeout.Duration)
+	require.NoError(t, c.LoadRemoteConfigs())
+	assert.Equal(t, []string{"memcached"}, c.InputNames())
+
+	shutdown := make(chan struct{})
+	defer close(shutdown)
+	changed := c.WatchRemoteConfigs(shutdown)
+	require.NotNil(t, changed)
+	gateway.put("[[inputs.memcached]]\n  servers = [\"cache\"]\n")
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("new config was not reported")
+	}
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+const exportHeader = `# Telegraf configuration of %s, exported for a bug report: the agent
+# options that differ from the defaults, the global tags, and every %s
+# plugin. Options that look like secrets, such as passwords and tokens, are
+# redacted.
+
+`
+
+// ExportForPlugin returns a config with only what is needed to reproduce the
+// behavior of one plugin, to attach to a bug report without the options of
+// the other plugins: the global tags, the agent options that differ from the
+// defaults, and every input and output named pluginName. pluginName is a
+// plugin name such as "cpu", or a section and a name such as "inputs.cpu".
+//
+// The plugins are written as by PrintEffectiveConfig, with environment
+// variables expanded, but options that look like secrets are redacted.
+func (c *Config) ExportForPlugin(pluginName string) ([]byte, error) {
+	section, name := "", pluginName
+	if i := strings.Index(pluginName, "."); i >= 0 {
+		section, name = pluginName[:i], pluginName[i+1:]
+	}
+	if section != "" && section != "inputs" && section != "outputs" {
+		return nil, fmt.Errorf("invalid plugin %q, use name, inputs.name or "+
+			"outputs.name", pluginName)
+	}
+
+	var plugins bytes.Buffer
+	c.mu.RLock()
+	if section != "outputs" {
+		for _, inputs := range [][]*models.RunningInput{c.Inputs, c.DisabledInputs} {
+			for _, input := range inputs {
+				if input.Name == name {
+					c.writeEffectiveInput(&plugins, input, false)
+				}
+			}
+		}
+	}
+	if section != "inputs" {
+		for _, outputs := range [][]*models.RunningOutput{c.Outputs, c.DisabledOutputs} {
+			for _, output := range outputs {
+				if output.Name == name {
+					writeEffectiveOutput(&plugins, output, false)
+				}
+			}
+		}
+	}
+	c.mu.RUnlock()
+	if plugins.Len() == 0 {
+		return nil, fmt.Errorf("plugin %s not found", pluginName)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, exportHeader, pluginName, name)
+	buf.WriteString("[global_tags]\n")
+	writeEffectiveTags(&buf, "  ", c.Tags)
+	buf.WriteString("\n[agent]\n")
+	writeMinimalTable(&buf, reflect.ValueOf(*c.Agent),
+		reflect.ValueOf(*NewConfig().Agent), "agent")
+	buf.Write(plugins.Bytes())
+	return redactSecrets(buf.Bytes()), nil
+}
+
+// redactSecrets replaces the values of the TOML options of config that look
+// like secrets, see secretFields, by "<redacted>".
+func redactSecrets(config []byte) []byte {
+	lines := strings.SplitAfter(string(config), "\n")
+	for i, line := range lines {
+		eq := strings.Index(line, " = ")
+		if eq < 0 || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		key := strings.Trim(strings.TrimSpace(line[:eq]), `"`)
+		value := strings.TrimSpace(line[eq+3:])
+		if value == `""` || !secretFields.Match(key) {
+			continue
+		}
+		lines[i] = line[:eq] + ` = "<redacted>"`
+		if strings.HasSuffix(line, "\n") {
+			lines[i] += "\n"
+		}
+	}
+	return []byte(strings.Join(lines, ""))
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	_ "github.com/influxdata/telegraf/plugins/outputs/influxdb"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ExportForPlugin(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(`
+[global_tags]
+  dc = "us-east-1"
+
+[agent]
+  interval = "5s"
+
+[[inputs.memcached]]
+  servers = ["localhost:11211"]
+
+[[outputs.influxdb]]
+  urls = ["http://localhost:8086"]
+  database = "telegraf"
+  username = "telegraf"
+  password = "hunter2"
+`))
+
+	out, err := c.ExportForPlugin("influxdb")
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `[global_tags]
+  dc = "us-east-1"
+
+[agent]
+  interval = "5s"
+`)
+	assert.Contains(t, string(out), "\n[[outputs.influxdb]]\n")
+	assert.Contains(t, string(out), `  username = "telegraf"`)
+	assert.Contains(t, string(out), `  password = "<redacted>"`)
+	assert.NotContains(t, string(out), "hunter2")
+	assert.NotContains(t, string(out), "memcached")
+
+	out, err = c.ExportForPlugin("inputs.memcached")
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "\n[[inputs.memcached]]\n")
+	assert.NotContains(t, string(out), "influxdb")
+
+	_, err = c.ExportForPlugin("inputs.influxdb")
+	assert.Error(t, err)
+	_, err = c.ExportForPlugin("processors.influxdb")
+	assert.Error(t, err)
+}
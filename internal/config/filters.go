@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+// RebuildFilters compiles the namepass, namedrop, fieldpass, tagpass and
+// other filters of every input and output again, enabled or disabled, so that
+// changes made to their Filter fields after the config was loaded take
+// effect. It returns the first error, naming the plugin whose filter did not
+// compile.
+func (c *Config) RebuildFilters() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rebuildFilters()
+}
+
+// rebuildFilters is RebuildFilters with c.mu held.
+func (c *Config) rebuildFilters() error {
+	for _, inputs := range [][]*models.RunningInput{c.Inputs, c.DisabledInputs} {
+		for _, input := range inputs {
+			if input.Config == nil {
+				continue
+			}
+			if err := input.Config.Filter.Compile(); err != nil {
+				return fmt.Errorf("Error rebuilding filters of inputs.%s, %s",
+					input.Name, err)
+			}
+		}
+	}
+	for _, outputs := range [][]*models.RunningOutput{c.Outputs, c.DisabledOutputs} {
+		for _, output := range outputs {
+			if output.Config == nil {
+				continue
+			}
+			if err := output.Config.Filter.Compile(); err != nil {
+				return fmt.Errorf("Error rebuilding filters of outputs.%s, %s",
+					output.Name, err)
+			}
+		}
+	}
+	return nil
+}
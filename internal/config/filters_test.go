@@ -0,0 +1,46 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/influxdata/telegraf/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_RebuildFilters(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.AddInputFromTOML(`
+[[inputs.memcached]]
+  namepass = ["memcached"]
+`))
+	filter := &c.Inputs[0].Config.Filter
+	assert.False(t, filter.Apply("mem", map[string]interface{}{"v": 1},
+		map[string]string{}))
+
+	filter.NamePass = append(filter.NamePass, "mem*")
+	require.NoError(t, c.RebuildFilters())
+	assert.True(t, filter.Apply("mem", map[string]interface{}{"v": 1},
+		map[string]string{}))
+
+	// an emptied filter lets everything through again
+	filter.NamePass = nil
+	require.NoError(t, c.RebuildFilters())
+	assert.True(t, filter.Apply("cpu", map[string]interface{}{"v": 1},
+		map[string]string{}))
+}
+
+func TestConfig_RebuildFiltersError(t *testing.T) {
+	c := NewConfig()
+	c.Inputs = append(c.Inputs, &models.RunningInput{
+		Name: "exec",
+		Config: &models.InputConfig{
+			Name:   "exec",
+			Filter: models.Filter{NameMatch: []string{"("}},
+		},
+	})
+	err := c.RebuildFilters()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "inputs.exec")
+}
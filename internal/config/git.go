@@ -0,0 +1,259 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+// GitConfig holds the settings of the config loaded from Git.
+type GitConfig struct {
+	// Repository, Branch and Path locate the config file or directory loaded
+	// at startup by LoadRemoteConfigs, and watched by WatchRemoteConfigs.
+	// None is loaded without a repository.
+	Repository string `toml:"repository"`
+	Branch     string `toml:"branch"`
+	Path       string `toml:"path"`
+	// SSHKeyPath is the private key used for ssh repository URLs.
+	SSHKeyPath string `toml:"ssh_key_path"`
+	// Username and Password are used for http and https repository URLs.
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	// PollingInterval is how often WatchGitConfig checks the branch for new
+	// commits.
+	PollingInterval internal.Duration `toml:"polling_interval"`
+}
+
+// runGit runs the git command with args, with env added to its environment,
+// and returns its output. The git command is run rather than linking go-git,
+// which needs a newer Go than this tree builds with.
+var runGit = func(env []string, args ...string) ([]byte, error) {
+	git, err := exec.LookPath("git")
+	if err != nil {
+		return nil, fmt.Errorf("the git command is needed to load configs "+
+			"from Git, %s", err)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(git, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// gitCredentialHelper is a git credential helper answering with the
+// username and password of the environment of the git command, so that they
+// are neither in the repository URL nor on its command line, where ps shows
+// them.
+const gitCredentialHelper = `!f() { test "$1" = get || return 0; ` +
+	`echo "username=$TELEGRAF_GIT_USERNAME"; ` +
+	`echo "password=$TELEGRAF_GIT_PASSWORD"; }; f`
+
+// gitEnv returns the environment of the git command for the credentials of
+// g, and the options of the git command to use them.
+func (g GitConfig) gitEnv() ([]string, []string) {
+	env := []string{"GIT_TERMINAL_PROMPT=0"}
+	var args []string
+	if g.SSHKeyPath != "" {
+		env = append(env, fmt.Sprintf(
+			"GIT_SSH_COMMAND=ssh -i %s -o IdentitiesOnly=yes",
+			shellQuote(g.SSHKeyPath)))
+	}
+	if g.Username != "" {
+		env = append(env, "TELEGRAF_GIT_USERNAME="+g.Username,
+			"TELEGRAF_GIT_PASSWORD="+g.Password)
+		// the empty helper drops the helpers of the git config
+		args = append(args, "-c", "credential.helper=",
+			"-c", "credential.helper="+gitCredentialHelper)
+	}
+	return env, args
+}
+
+// shellQuote quotes s as a single sh word.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// redactURL returns repo without the password of its userinfo, if it has
+// one, for messages.
+func redactURL(repo string) string {
+	u, err := url.Parse(repo)
+	if err != nil || u.User == nil {
+		return repo
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// gitCommand runs git with args and the credentials of g, on repo, and
+// redacts the password of repo from the error.
+func (g GitConfig) gitCommand(repo string, args ...string) ([]byte, error) {
+	env, options := g.gitEnv()
+	out, err := runGit(env, append(options, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("%s", strings.Replace(err.Error(), repo,
+			redactURL(repo), -1))
+	}
+	return out, nil
+}
+
+// gitHead returns the commit at the head of branch in repo.
+func (g GitConfig) gitHead(repo, branch string) (string, error) {
+	if err := checkGitRepository(repo); err != nil {
+		return "", err
+	}
+	out, err := g.gitCommand(repo, "ls-remote", "--", repo,
+		"refs/heads/"+branch)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("branch %s not found", branch)
+	}
+	return fields[0], nil
+}
+
+// checkGitRepository returns an error if repo would be taken for an option
+// of the git command.
+func checkGitRepository(repo string) error {
+	if strings.HasPrefix(repo, "-") {
+		return fmt.Errorf("invalid repository %s", repo)
+	}
+	return nil
+}
+
+// checkGitPath returns an error if path is not a relative path within the
+// clone of the repository.
+func checkGitPath(path string) error {
+	clean := filepath.Clean(path)
+	if filepath.IsAbs(path) || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("path %s is not within the repository", path)
+	}
+	return nil
+}
+
+// LoadConfigFromGit loads the config file or directory at path in branch of
+// the Git repository repo. The branch is cloned into a temporary directory,
+// removed once the config is loaded, with the credentials of the
+// [agent.git_config] table.
+func (c *Config) LoadConfigFromGit(repo, branch, path string) error {
+	source := fmt.Sprintf("%s@%s:%s", redactURL(repo), branch, path)
+	if err := checkGitRepository(repo); err != nil {
+		return fmt.Errorf("Error loading config %s, %s", source, err)
+	}
+	if err := checkGitPath(path); err != nil {
+		return fmt.Errorf("Error loading config %s, %s", source, err)
+	}
+
+	dir, err := ioutil.TempDir("", "telegraf-git-config")
+	if err != nil {
+		return fmt.Errorf("Error cloning config %s, %s", source, err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = c.Agent.GitConfig.gitCommand(repo, "clone", "--quiet", "--depth",
+		"1", "--single-branch", "--branch", branch, "--", repo, dir)
+	if err != nil {
+		return fmt.Errorf("Error cloning config %s, %s", source, err)
+	}
+
+	fpath := filepath.Join(dir, path)
+	info, err := os.Stat(fpath)
+	if err != nil {
+		return fmt.Errorf("Error loading config %s, %s", source, err)
+	}
+	files := []string{fpath}
+	if info.IsDir() {
+		// the files are loaded one by one rather than with LoadDirectory, so
+		// that the clone, removed once loaded, is not rescanned
+		files, err = configFilesIn(fpath)
+		if err != nil {
+			return fmt.Errorf("Error loading config %s, %s", source, err)
+		}
+	}
+	for _, file := range files {
+		if err := c.LoadConfig(file); err != nil {
+			return fmt.Errorf("Error loading config %s, %s", source, err)
+		}
+	}
+	return nil
+}
+
+// WatchGitConfig polls branch of repo every polling_interval of the
+// [agent.git_config] table, and when it has a new commit, loads the config at
+// path from it. It sends on the returned channel when the new config loads,
+// so that the caller validates the whole config and reloads telegraf; a new
+// config that fails to load is logged. c is not changed. The watch stops when
+// shutdown is closed.
+func (c *Config) WatchGitConfig(
+	repo, branch, path string,
+	shutdown chan struct{},
+) <-chan struct{} {
+	reloaded := make(chan struct{}, 1)
+	name := redactURL(repo)
+	gitConfig := c.Agent.GitConfig
+	interval := gitConfig.PollingInterval.Duration
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	current, err := gitConfig.gitHead(repo, branch)
+	if err != nil {
+		log.Printf("E! Error checking config %s@%s for changes: %s\n",
+			name, branch, err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-shutdown:
+				return
+			case <-ticker.C:
+			}
+
+			head, err := gitConfig.gitHead(repo, branch)
+			if err != nil {
+				log.Printf("E! Error checking config %s@%s for changes: %s\n",
+					name, branch, err)
+				continue
+			}
+			if head == current {
+				continue
+			}
+			current = head
+
+			newCfg := NewConfig()
+			newCfg.Agent.GitConfig = gitConfig
+			newCfg.InputFilters = c.InputFilters
+			newCfg.OutputFilters = c.OutputFilters
+			if err := newCfg.LoadConfigFromGit(repo, branch, path); err != nil {
+				log.Printf("E! Not reloading config %s@%s at %s: %s\n",
+					name, branch, head, err)
+				continue
+			}
+			select {
+			case reloaded <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return reloaded
+}
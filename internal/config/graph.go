@@ -0,0 +1,250 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+// graphEdge is a flow of metrics from an input to an output.
+type graphEdge struct {
+	input, output string
+	// label lists the filter patterns letting the metrics through.
+	label string
+}
+
+// PrintDependencyGraph writes the flow of metrics from the inputs to the
+// outputs to w, as an ASCII tree with format "ascii", or as a Graphviz DOT
+// graph with format "dot". The edges are labelled with the output name
+// filters letting the metrics of the input through, "*" if unfiltered.
+//
+// The flows are inferred from the config alone: the measurements of an input
+// are taken to be named after the plugin, ie "cpu*", unless its name_override
+// or namepass options tell otherwise. Glob patterns may overlap without any
+// measurement actually flowing, and namematch expressions are assumed to
+// match.
+func (c *Config) PrintDependencyGraph(w io.Writer, format string) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	inputs := graphNodes("inputs", len(c.Inputs), func(i int) string {
+		return c.Inputs[i].Name
+	})
+	outputs := graphNodes("outputs", len(c.Outputs), func(i int) string {
+		return c.Outputs[i].Name
+	})
+
+	var edges []graphEdge
+	for i, input := range c.Inputs {
+		patterns := inputNamePatterns(input)
+		for j, output := range c.Outputs {
+			if label, ok := graphEdgeLabel(patterns, output.Config.Filter); ok {
+				edges = append(edges, graphEdge{inputs[i], outputs[j], label})
+			}
+		}
+	}
+
+	switch format {
+	case "ascii":
+		return printASCIIGraph(w, inputs, edges)
+	case "dot":
+		return printDOTGraph(w, inputs, outputs, edges)
+	default:
+		return fmt.Errorf("Invalid graph format %q, must be \"ascii\" or \"dot\"",
+			format)
+	}
+}
+
+// graphNodes returns the node names of n plugins, numbering the plugins
+// loaded more than once, ie "inputs.cpu" and "inputs.cpu#2".
+func graphNodes(pluginType string, n int, name func(int) string) []string {
+	nodes := make([]string, n)
+	seen := make(map[string]int)
+	for i := range nodes {
+		nodes[i] = pluginType + "." + name(i)
+		seen[nodes[i]]++
+		if count := seen[nodes[i]]; count > 1 {
+			nodes[i] = fmt.Sprintf("%s#%d", nodes[i], count)
+		}
+	}
+	return nodes
+}
+
+// inputNamePatterns returns glob patterns of the measurement names of input.
+func inputNamePatterns(input *models.RunningInput) []string {
+	ic := input.Config
+	if len(ic.Filter.NamePass) > 0 {
+		return ic.Filter.NamePass
+	}
+	if ic.NameTemplate != "" {
+		return []string{"*"}
+	}
+	name := ic.NameOverride
+	if name == "" {
+		name = input.Name + "*"
+	}
+	return []string{ic.MeasurementPrefix + name + ic.MeasurementSuffix}
+}
+
+// graphEdgeLabel returns the label of the edge from an input producing the
+// measurement name patterns to an output with filter f, and false if f lets
+// none of them through.
+func graphEdgeLabel(patterns []string, f models.Filter) (string, bool) {
+	var labels []string
+
+	if len(f.NamePass) > 0 {
+		var passing []string
+		for _, pass := range f.NamePass {
+			if anyGlobsOverlap(pass, patterns) {
+				passing = append(passing, pass)
+			}
+		}
+		if len(passing) == 0 {
+			return "", false
+		}
+		labels = append(labels, "namepass: "+strings.Join(passing, ", "))
+	}
+
+	if len(f.NameDrop) > 0 {
+		drop, _ := filter.Compile(f.NameDrop)
+		var kept []string
+		for _, pattern := range patterns {
+			if !globCovers(drop, f.NameDrop, pattern) {
+				kept = append(kept, pattern)
+			}
+		}
+		if len(kept) == 0 {
+			return "", false
+		}
+		var dropping []string
+		for _, d := range f.NameDrop {
+			if anyGlobsOverlap(d, kept) {
+				dropping = append(dropping, d)
+			}
+		}
+		if len(dropping) > 0 {
+			labels = append(labels, "namedrop: "+strings.Join(dropping, ", "))
+		}
+	}
+
+	if len(f.NameMatch) > 0 {
+		labels = append(labels, "namematch: "+strings.Join(f.NameMatch, ", "))
+	}
+	if len(f.NameDropMatch) > 0 {
+		labels = append(labels,
+			"namedropmatch: "+strings.Join(f.NameDropMatch, ", "))
+	}
+
+	if len(labels) == 0 {
+		return "*", true
+	}
+	return strings.Join(labels, "; "), true
+}
+
+// globCovers reports whether the compiled drop patterns drop every name
+// matching pattern. Only a literal pattern, or a pattern equal to one of the
+// drop patterns, is known to be covered.
+func globCovers(drop filter.Filter, patterns []string, pattern string) bool {
+	for _, p := range patterns {
+		if p == "*" || p == pattern {
+			return true
+		}
+	}
+	if strings.ContainsAny(pattern, "*?[{") {
+		return false
+	}
+	return drop != nil && drop.Match(pattern)
+}
+
+func anyGlobsOverlap(a string, patterns []string) bool {
+	for _, b := range patterns {
+		if globsOverlap(a, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// globsOverlap reports whether a name may match both glob patterns a and b,
+// with the "*" and "?" wildcards. Other characters are compared literally.
+func globsOverlap(a, b string) bool {
+	// memo[i][j] is 1 if a[i:] and b[j:] overlap, 2 if they do not, 0 if
+	// not known yet.
+	memo := make([][]byte, len(a)+1)
+	for i := range memo {
+		memo[i] = make([]byte, len(b)+1)
+	}
+
+	var overlap func(i, j int) bool
+	overlap = func(i, j int) bool {
+		if memo[i][j] != 0 {
+			return memo[i][j] == 1
+		}
+		var ok bool
+		switch {
+		case i < len(a) && a[i] == '*':
+			ok = overlap(i+1, j) || (j < len(b) && overlap(i, j+1))
+		case j < len(b) && b[j] == '*':
+			ok = overlap(i, j+1) || (i < len(a) && overlap(i+1, j))
+		case i == len(a) || j == len(b):
+			ok = i == len(a) && j == len(b)
+		case a[i] == '?' || b[j] == '?' || a[i] == b[j]:
+			ok = overlap(i+1, j+1)
+		}
+		memo[i][j] = 2
+		if ok {
+			memo[i][j] = 1
+		}
+		return ok
+	}
+	return overlap(0, 0)
+}
+
+func printASCIIGraph(w io.Writer, inputs []string, edges []graphEdge) error {
+	for _, input := range inputs {
+		if _, err := fmt.Fprintln(w, input); err != nil {
+			return err
+		}
+		found := false
+		for _, edge := range edges {
+			if edge.input != input {
+				continue
+			}
+			found = true
+			if _, err := fmt.Fprintf(w, "  `--> %s [%s]\n", edge.output,
+				edge.label); err != nil {
+				return err
+			}
+		}
+		if !found {
+			if _, err := fmt.Fprintln(w, "  `--> (no outputs)"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func printDOTGraph(
+	w io.Writer,
+	inputs []string,
+	outputs []string,
+	edges []graphEdge,
+) error {
+	lines := []string{"digraph telegraf {", "  rankdir=LR;"}
+	for _, input := range inputs {
+		lines = append(lines, fmt.Sprintf("  %q [shape=box];", input))
+	}
+	for _, output := range outputs {
+		lines = append(lines, fmt.Sprintf("  %q [shape=ellipse];", output))
+	}
+	for _, edge := range edges {
+		lines = append(lines, fmt.Sprintf("  %q -> %q [label=%q];",
+			edge.input, edge.output, edge.label))
+	}
+	lines = append(lines, "}")
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const graphConfig = `
+[[inputs.memcached]]
+  servers = ["localhost"]
+
+[[inputs.exec]]
+  commands = ["/bin/true"]
+  name_override = "app_stats"
+
+[[outputs.file]]
+  files = ["stdout"]
+  namepass = ["memcached*", "mysql*"]
+
+[[outputs.file]]
+  files = ["stderr"]
+  namedrop = ["app_stats", "memcached_slab*"]
+`
+
+const graphMemcached = "inputs.memcached\n" +
+	"  `--> outputs.file [namepass: memcached*]\n" +
+	"  `--> outputs.file#2 [namedrop: memcached_slab*]\n"
+
+const graphExec = "inputs.exec\n" +
+	"  `--> (no outputs)\n"
+
+func TestConfig_PrintDependencyGraph(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(graphConfig))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.PrintDependencyGraph(&buf, "ascii"))
+	// the inputs are in no particular order
+	assert.Len(t, buf.String(), len(graphMemcached)+len(graphExec))
+	assert.Contains(t, buf.String(), graphMemcached)
+	assert.Contains(t, buf.String(), graphExec)
+
+	buf.Reset()
+	require.NoError(t, c.PrintDependencyGraph(&buf, "dot"))
+	assert.Contains(t, buf.String(), "digraph telegraf {\n")
+	assert.Contains(t, buf.String(),
+		`"inputs.memcached" -> "outputs.file" [label="namepass: memcached*"];`)
+	assert.NotContains(t, buf.String(), `"inputs.exec" ->`)
+
+	assert.Error(t, c.PrintDependencyGraph(&buf, "svg"))
+}
+
+func TestGlobsOverlap(t *testing.T) {
+	assert.True(t, globsOverlap("cpu*", "cpu"))
+	assert.True(t, globsOverlap("cpu*", "*_usage"))
+	assert.True(t, globsOverlap("c?u", "cp*"))
+	assert.True(t, globsOverlap("*", ""))
+	assert.False(t, globsOverlap("cpu*", "mem*"))
+	assert.False(t, globsOverlap("cpu", "cpu?"))
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/toml/ast"
+)
+
+// volatileKeys are the plugin options left out of the plugin hashes, as they
+// change without changing the plugin config.
+var volatileKeys = map[string]bool{
+	"load_time": true,
+}
+
+// ComputePluginHash returns the hash of the config of a loaded plugin, the
+// index-th input or output named pluginName, counting from 0. The hash covers
+// every option of the plugin table, both the telegraf options such as
+// interval or namepass and the plugin's own options, once environment
+// variables and secrets are expanded. It only changes when the config of the
+// plugin does, so that a reload can tell the plugins that changed apart.
+func (c *Config) ComputePluginHash(
+	pluginType string,
+	pluginName string,
+	index int,
+) (string, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var hashes []string
+	switch pluginType {
+	case "inputs", "input":
+		for _, input := range c.Inputs {
+			if input.Name == pluginName {
+				hashes = append(hashes, input.ConfigHash)
+			}
+		}
+	case "outputs", "output":
+		for _, output := range c.Outputs {
+			if output.Name == pluginName {
+				hashes = append(hashes, output.ConfigHash)
+			}
+		}
+	default:
+		return "", fmt.Errorf("Unknown plugin type %s", pluginType)
+	}
+
+	if index < 0 || index >= len(hashes) {
+		return "", fmt.Errorf("Plugin %s.%s[%d] not found, %d are loaded",
+			pluginType, pluginName, index, len(hashes))
+	}
+	if hashes[index] == "" {
+		return "", fmt.Errorf("Plugin %s.%s[%d] was not loaded from a config "+
+			"table", pluginType, pluginName, index)
+	}
+	return hashes[index], nil
+}
+
+// hashTable returns the hex encoded SHA-256 hash of a canonical form of tbl,
+// in which keys are sorted and values are normalized, so that it does not
+// depend on the layout of the file or on the order of the keys.
+func hashTable(tbl *ast.Table) string {
+	var buf bytes.Buffer
+	writeCanonicalTable(&buf, tbl)
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+func writeCanonicalTable(buf *bytes.Buffer, tbl *ast.Table) {
+	keys := make([]string, 0, len(tbl.Fields))
+	for key := range tbl.Fields {
+		if !volatileKeys[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	buf.WriteString("{")
+	for _, key := range keys {
+		buf.WriteString(strconv.Quote(key))
+		buf.WriteString("=")
+		switch node := tbl.Fields[key].(type) {
+		case *ast.KeyValue:
+			writeCanonicalValue(buf, node.Value)
+		case *ast.Table:
+			writeCanonicalTable(buf, node)
+		case []*ast.Table:
+			buf.WriteString("[")
+			for _, t := range node {
+				writeCanonicalTable(buf, t)
+				buf.WriteString(",")
+			}
+			buf.WriteString("]")
+		}
+		buf.WriteString(";")
+	}
+	buf.WriteString("}")
+}
+
+// writeCanonicalValue writes a value prefixed by its type, so that values of
+// different types never share a form.
+func writeCanonicalValue(buf *bytes.Buffer, value ast.Value) {
+	switch v := value.(type) {
+	case *ast.String:
+		buf.WriteString("s" + strconv.Quote(v.Value))
+	case *ast.Integer:
+		if i, err := v.Int(); err == nil {
+			buf.WriteString("i" + strconv.FormatInt(i, 10))
+			return
+		}
+		buf.WriteString("i" + v.Value)
+	case *ast.Float:
+		if f, err := v.Float(); err == nil {
+			buf.WriteString("f" + strconv.FormatFloat(f, 'g', -1, 64))
+			return
+		}
+		buf.WriteString("f" + v.Value)
+	case *ast.Boolean:
+		buf.WriteString("b" + v.Value)
+	case *ast.Datetime:
+		if t, err := v.Time(); err == nil {
+			buf.WriteString("d" + t.UTC().Format(time.RFC3339Nano))
+			return
+		}
+		buf.WriteString("d" + v.Value)
+	case *ast.Array:
+		buf.WriteString("[")
+		for _, item := range v.Value {
+			writeCanonicalValue(buf, item)
+			buf.WriteString(",")
+		}
+		buf.WriteString("]")
+	case *ast.Table:
+		writeCanonicalTable(buf, v)
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_ComputePluginHash(t *testing.T) {
+	load := func(content string) *Config {
+		c := NewConfig()
+		require.NoError(t, c.LoadConfigString(content))
+		return c
+	}
+
+	c := load(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  interval = "5s"
+  [inputs.memcached.tags]
+    dc = "us-east-1"
+
+[[inputs.memcached]]
+  servers = ["otherhost"]
+`)
+	first, err := c.ComputePluginHash("inputs", "memcached", 0)
+	require.NoError(t, err)
+	assert.Len(t, first, 64)
+	second, err := c.ComputePluginHash("inputs", "memcached", 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	// Key order and layout do not change the hash
+	reordered := load(`
+[[inputs.memcached]]
+  interval = "5s"
+  servers = [ "localhost" ]
+  [inputs.memcached.tags]
+    dc = "us-east-1"
+`)
+	hash, err := reordered.ComputePluginHash("input", "memcached", 0)
+	require.NoError(t, err)
+	assert.Equal(t, first, hash)
+
+	// Telegraf options are part of the hash
+	changed := load(`
+[[inputs.memcached]]
+  servers = ["localhost"]
+  interval = "10s"
+  [inputs.memcached.tags]
+    dc = "us-east-1"
+`)
+	hash, err = changed.ComputePluginHash("inputs", "memcached", 0)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, hash)
+
+	// Neither do volatile keys
+	tbl, err := parseContents([]byte(`servers = ["localhost"]`))
+	require.NoError(t, err)
+	withVolatile, err := parseContents([]byte(`servers = ["localhost"]
+load_time = 2017-01-01T00:00:00Z`))
+	require.NoError(t, err)
+	assert.Equal(t, hashTable(tbl), hashTable(withVolatile))
+
+	_, err = c.ComputePluginHash("inputs", "memcached", 2)
+	assert.Error(t, err)
+	_, err = c.ComputePluginHash("inputs", "cpu", 0)
+	assert.Error(t, err)
+	_, err = c.ComputePluginHash("processors", "memcached", 0)
+	assert.Error(t, err)
+}
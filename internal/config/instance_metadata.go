@@ -0,0 +1,123 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// instanceMetadataTimeout is the timeout of each request to the instance
+// metadata service.
+const instanceMetadataTimeout = 2 * time.Second
+
+// loadInstanceMetadataTags fetches the instance metadata document at
+// InstanceMetadataURL, and adds the values selected by InstanceMetadataMap to
+// the global tags. Tags already set in [global_tags] are kept.
+func (c *Config) loadInstanceMetadataTags() error {
+	a := c.Agent
+	client := a.Proxy.httpClient(instanceMetadataTimeout)
+
+	req, err := http.NewRequest("GET", a.InstanceMetadataURL, nil)
+	if err != nil {
+		return err
+	}
+	if a.InstanceMetadataTokenTTL.Duration > 0 {
+		token, err := instanceMetadataToken(client, a.InstanceMetadataURL,
+			a.InstanceMetadataTokenTTL.Duration)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %s", a.InstanceMetadataURL,
+			resp.Status)
+	}
+
+	var doc interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("could not parse %s as JSON, %s",
+			a.InstanceMetadataURL, err)
+	}
+
+	for path, tag := range a.InstanceMetadataMap {
+		value, ok := jsonPath(doc, path)
+		if !ok {
+			return fmt.Errorf("%s not found in %s", path, a.InstanceMetadataURL)
+		}
+		if _, ok := c.Tags[tag]; !ok {
+			c.Tags[tag] = value
+		}
+	}
+	return nil
+}
+
+// instanceMetadataToken gets an IMDSv2 session token, from the token API on
+// the host of the metadata URL.
+func instanceMetadataToken(
+	client *http.Client,
+	metadataURL string,
+	ttl time.Duration,
+) (string, error) {
+	u, err := url.Parse(metadataURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = "/latest/api/token"
+	u.RawQuery = ""
+
+	req, err := http.NewRequest("PUT", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds",
+		strconv.Itoa(int(ttl.Seconds())))
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned status %s", u, resp.Status)
+	}
+	token, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// jsonPath returns the value at a dot separated path of object keys in a
+// decoded JSON document, formatted as a string.
+func jsonPath(doc interface{}, path string) (string, bool) {
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if doc, ok = obj[key]; !ok {
+			return "", false
+		}
+	}
+
+	switch v := doc.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	return "", false
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_InstanceMetadataTags(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == "PUT" && r.URL.Path == "/latest/api/token":
+				if r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") != "60" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				fmt.Fprint(w, "mytoken")
+			case r.URL.Path == "/latest/dynamic/instance-identity/document":
+				if r.Header.Get("X-aws-ec2-metadata-token") != "mytoken" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				fmt.Fprint(w, `{"instanceId": "i-1234", "region": "us-west-2",
+					"placement": {"availabilityZone": "us-west-2a"}}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	defer ts.Close()
+
+	c := NewConfig()
+	err := c.LoadConfigString(fmt.Sprintf(`
+[global_tags]
+  region = "override"
+
+[agent]
+  instance_metadata_url = "%s/latest/dynamic/instance-identity/document"
+  instance_metadata_token_ttl = "60s"
+  [agent.instance_metadata_map]
+    instanceId = "instance_id"
+    region = "region"
+    "placement.availabilityZone" = "availability_zone"
+`, ts.URL))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"instance_id":       "i-1234",
+		"region":            "override",
+		"availability_zone": "us-west-2a",
+	}, c.Tags)
+
+	c = NewConfig()
+	err = c.LoadConfigString(fmt.Sprintf(`
+[agent]
+  instance_metadata_url = "%s/latest/dynamic/instance-identity/document"
+  [agent.instance_metadata_map]
+    instanceId = "instance_id"
+`, ts.URL))
+	// IMDSv2 token missing
+	assert.Error(t, err)
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-jsonnet"
+)
+
+// maxJsonnetErrorLines is how many lines of a Jsonnet evaluation error are
+// kept in the config error.
+const maxJsonnetErrorLines = 5
+
+// evaluateJsonnet evaluates a Jsonnet file into a JSON document. Imports are
+// found relative to the file.
+func evaluateJsonnet(path string) ([]byte, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	out, err := jsonnet.MakeVM().EvaluateSnippet(path, string(contents))
+	if err != nil {
+		return nil, fmt.Errorf("Error evaluating Jsonnet, %s",
+			jsonnetErrorMessage(err.Error()))
+	}
+	return []byte(out), nil
+}
+
+// jsonnetErrorMessage joins the first non-empty lines of a Jsonnet evaluation
+// error, which holds the offending source and a stack trace, into a single
+// line.
+func jsonnetErrorMessage(msg string) string {
+	var lines []string
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(lines) == maxJsonnetErrorLines {
+			lines = append(lines, "...")
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "; ")
+}
+
+// jsonnetToTOML evaluates a Jsonnet config file, and converts the resulting
+// JSON document into the equivalent TOML config.
+func jsonnetToTOML(path string) ([]byte, error) {
+	out, err := evaluateJsonnet(path)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToTOML(out)
+}
+
+// jsonToTOML converts a JSON config document into TOML. Objects become
+// tables, arrays of objects become arrays of tables, and null values are left
+// out.
+func jsonToTOML(contents []byte) ([]byte, error) {
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Error parsing JSON config, %s", err)
+	}
+	root, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("JSON config must be an object, got %T", doc)
+	}
+
+	var buf bytes.Buffer
+	if err := writeTOMLTable(&buf, nil, root); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeTOMLTable writes the values of a table, followed by its subtables.
+func writeTOMLTable(buf *bytes.Buffer, path []string, table map[string]interface{}) error {
+	keys := make([]string, 0, len(table))
+	for k := range table {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var tables, tableArrays []string
+	for _, k := range keys {
+		switch v := table[k].(type) {
+		case nil:
+		case map[string]interface{}:
+			tables = append(tables, k)
+		case []interface{}:
+			if isTableArray(v) {
+				tableArrays = append(tableArrays, k)
+				continue
+			}
+			value, err := tomlLiteral(v)
+			if err != nil {
+				return fmt.Errorf("Error converting %s, %s",
+					strings.Join(append(path, k), "."), err)
+			}
+			fmt.Fprintf(buf, "%s = %s\n", tomlKeyName(k), value)
+		default:
+			value, err := tomlLiteral(v)
+			if err != nil {
+				return fmt.Errorf("Error converting %s, %s",
+					strings.Join(append(path, k), "."), err)
+			}
+			fmt.Fprintf(buf, "%s = %s\n", tomlKeyName(k), value)
+		}
+	}
+
+	for _, k := range tables {
+		sub := append(append([]string{}, path...), k)
+		fmt.Fprintf(buf, "\n[%s]\n", tomlTableName(sub))
+		if err := writeTOMLTable(buf, sub, table[k].(map[string]interface{})); err != nil {
+			return err
+		}
+	}
+	for _, k := range tableArrays {
+		sub := append(append([]string{}, path...), k)
+		for _, item := range table[k].([]interface{}) {
+			fmt.Fprintf(buf, "\n[[%s]]\n", tomlTableName(sub))
+			if err := writeTOMLTable(buf, sub, item.(map[string]interface{})); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isTableArray reports whether a JSON array is a non-empty array of objects.
+func isTableArray(array []interface{}) bool {
+	if len(array) == 0 {
+		return false
+	}
+	for _, item := range array {
+		if _, ok := item.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// tomlLiteral formats a JSON scalar or array of scalars as a TOML value.
+func tomlLiteral(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case string:
+		// JSON string escapes are valid TOML basic string escapes
+		quoted, err := json.Marshal(t)
+		return string(quoted), err
+	case bool:
+		return strconv.FormatBool(t), nil
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return strconv.FormatInt(i, 10), nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return "", err
+		}
+		s := strconv.FormatFloat(f, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, nil
+	case []interface{}:
+		values := make([]string, 0, len(t))
+		for _, item := range t {
+			if item == nil {
+				continue
+			}
+			value, err := tomlLiteral(item)
+			if err != nil {
+				return "", err
+			}
+			values = append(values, value)
+		}
+		return "[" + strings.Join(values, ", ") + "]", nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
+
+var bareKeyRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// tomlKeyName quotes a key if it cannot be written as a bare key.
+func tomlKeyName(key string) string {
+	if bareKeyRe.MatchString(key) {
+		return key
+	}
+	quoted, _ := json.Marshal(key)
+	return string(quoted)
+}
+
+func tomlTableName(path []string) string {
+	names := make([]string, len(path))
+	for i, key := range path {
+		names[i] = tomlKeyName(key)
+	}
+	return strings.Join(names, ".")
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/inputs/memcached"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_JSONToTOML(t *testing.T) {
+	out, err := jsonToTOML([]byte(`{
+  "global_tags": {"dc": "us-east-1", "rack id": "a\"1"},
+  "agent": {"interval": "10s", "round_interval": true, "jitter": 0.5,
+            "ratio": 1e3, "debug": null},
+  "inputs": {
+    "cpu": [{"percpu": false, "fielddrop": ["time_*"],
+             "tags": {"env": "prod"}}],
+    "mem": {}
+  }
+}`))
+	require.NoError(t, err)
+	assert.Equal(t, `
+[agent]
+interval = "10s"
+jitter = 0.5
+ratio = 1000.0
+round_interval = true
+
+[global_tags]
+dc = "us-east-1"
+"rack id" = "a\"1"
+
+[inputs]
+
+[inputs.mem]
+
+[[inputs.cpu]]
+fielddrop = ["time_*"]
+percpu = false
+
+[inputs.cpu.tags]
+env = "prod"
+`, string(out))
+
+	// the result is valid TOML
+	_, err = parseContents(out)
+	assert.NoError(t, err)
+
+	_, err = jsonToTOML([]byte(`["not", "an", "object"]`))
+	assert.Error(t, err)
+	_, err = jsonToTOML([]byte(`{"inputs": {"cpu": [1, {"a": 1}]}}`))
+	assert.Error(t, err)
+}
+
+func TestConfig_LoadJsonnet(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig("./testdata/jsonnet.jsonnet"))
+	assert.Equal(t, 5*time.Second, c.Agent.Interval.Duration)
+	assert.Equal(t, 500, c.Agent.MetricBatchSize)
+	require.Len(t, c.Inputs, 1)
+	assert.Equal(t, []string{"localhost"},
+		c.Inputs[0].Input.(*memcached.Memcached).Servers)
+	assert.Equal(t, []string{"metricname1", "metricname2"},
+		c.Inputs[0].Config.Filter.NamePass)
+	assert.Equal(t, map[string]string{"dc": "us-east-1"}, c.Inputs[0].Config.Tags)
+}
+
+func TestConfig_JsonnetImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-jsonnet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "servers.libsonnet"),
+		[]byte(`["cache1:11211", "cache2:11211"]`), 0644))
+	path := filepath.Join(dir, "telegraf.jsonnet")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`
+{ inputs: { memcached: [{ servers: import "servers.libsonnet" }] } }
+`), 0644))
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfig(path))
+	require.Len(t, c.Inputs, 1)
+	assert.Equal(t, []string{"cache1:11211", "cache2:11211"},
+		c.Inputs[0].Input.(*memcached.Memcached).Servers)
+}
+
+func TestConfig_JsonnetErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-jsonnet")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "telegraf.jsonnet")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{
+  inputs: {
+    memcached: [{ servers: [servr] }],
+  },
+}
+`), 0644))
+
+	err = NewConfig().LoadConfig(path)
+	require.Error(t, err)
+	assert.Equal(t, "Error parsing "+path+", Error evaluating Jsonnet, "+
+		path+":3:29-34 Unknown variable: servr; "+
+		"memcached: [{ servers: [servr] }],", err.Error())
+}
+
+func TestConfig_JsonnetErrorMessage(t *testing.T) {
+	assert.Equal(t, "", jsonnetErrorMessage("\n  \n"))
+	assert.Equal(t, "a; b; c; d; e; ...",
+		jsonnetErrorMessage("a\nb\n\nc\nd\ne\nf\ng\n"))
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// kubernetesDataDir is the symlink that the kubelet creates in a ConfigMap
+// volume. It points to a timestamped directory holding the current files, and
+// is atomically replaced whenever the ConfigMap is updated.
+const kubernetesDataDir = "..data"
+
+// IsKubernetesConfigMap reports whether mountPath is a Kubernetes ConfigMap
+// volume mount.
+func IsKubernetesConfigMap(mountPath string) bool {
+	info, err := os.Lstat(filepath.Join(mountPath, kubernetesDataDir))
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeSymlink != 0
+}
+
+// LoadKubernetesConfigMap loads all the .conf files of the Kubernetes
+// ConfigMap volume mounted at mountPath.
+func (c *Config) LoadKubernetesConfigMap(mountPath string) error {
+	if !IsKubernetesConfigMap(mountPath) {
+		return fmt.Errorf("%s is not a Kubernetes ConfigMap volume, %s not found",
+			mountPath, kubernetesDataDir)
+	}
+	return c.LoadDirectory(mountPath)
+}
+
+// WatchKubernetesConfigMap polls the ConfigMap volume mounted at mountPath
+// every interval, and sends on the returned channel when the kubelet has
+// replaced the "..data" symlink, ie, when the ConfigMap was updated. The
+// watch stops when shutdown is closed.
+func WatchKubernetesConfigMap(
+	mountPath string,
+	interval time.Duration,
+	shutdown chan struct{},
+) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	dataDir := filepath.Join(mountPath, kubernetesDataDir)
+	current, err := os.Readlink(dataDir)
+	if err != nil {
+		log.Printf("E! Could not watch Kubernetes ConfigMap %s: %s\n",
+			mountPath, err)
+		return changed
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-shutdown:
+				return
+			case <-ticker.C:
+				target, err := os.Readlink(dataDir)
+				if err != nil || target == current {
+					continue
+				}
+				current = target
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changed
+}
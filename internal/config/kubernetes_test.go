@@ -0,0 +1,67 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeConfigMap creates a directory laid out like a Kubernetes ConfigMap
+// volume, with the given .conf file contents.
+func makeConfigMap(t *testing.T, dir, version string, files map[string]string) {
+	versionDir := filepath.Join(dir, version)
+	require.NoError(t, os.Mkdir(versionDir, 0755))
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(versionDir, name),
+			[]byte(content), 0644))
+		os.Symlink(filepath.Join(kubernetesDataDir, name), filepath.Join(dir, name))
+	}
+	os.Remove(filepath.Join(dir, kubernetesDataDir))
+	require.NoError(t, os.Symlink(version, filepath.Join(dir, kubernetesDataDir)))
+}
+
+func TestConfig_LoadKubernetesConfigMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-configmap")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	makeConfigMap(t, dir, "..2016_11_01_10_00_00.000000000", map[string]string{
+		"memcached.conf": "[[inputs.memcached]]\n  servers = [\"localhost\"]\n",
+	})
+
+	assert.True(t, IsKubernetesConfigMap(dir))
+
+	c := NewConfig()
+	require.NoError(t, c.LoadKubernetesConfigMap(dir))
+	// the file is loaded once through its symlink, and not a second time
+	// from the timestamped directory.
+	assert.Equal(t, []string{"memcached"}, c.InputNames())
+
+	assert.False(t, IsKubernetesConfigMap("./testdata"))
+	assert.Error(t, NewConfig().LoadKubernetesConfigMap("./testdata"))
+}
+
+func TestConfig_WatchKubernetesConfigMap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-configmap")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{"empty.conf": ""}
+	makeConfigMap(t, dir, "..first", files)
+
+	shutdown := make(chan struct{})
+	defer close(shutdown)
+	changed := WatchKubernetesConfigMap(dir, 10*time.Millisecond, shutdown)
+
+	makeConfigMap(t, dir, "..second", files)
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("ConfigMap update was not detected")
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+// LintWarning describes a logical contradiction found in the loaded config.
+type LintWarning struct {
+	// PluginType is either "inputs" or "outputs".
+	PluginType string
+	PluginName string
+	// Filters are the two conflicting filter options, ie "namepass" and
+	// "namedrop".
+	Filters [2]string
+	// Pattern is the pass pattern that is also dropped, or the drop pattern
+	// that is also passed.
+	Pattern    string
+	Suggestion string
+}
+
+func (w LintWarning) String() string {
+	return fmt.Sprintf("[%s.%s] %q matches both %s and %s: %s",
+		w.PluginType, w.PluginName, w.Pattern, w.Filters[0], w.Filters[1],
+		w.Suggestion)
+}
+
+// LintConfig looks for filter combinations that can never let anything
+// through, such as a namepass pattern that is also matched by namedrop. It
+// is meant to catch typos in patterns that would silently stop data flowing.
+func (c *Config) LintConfig() []LintWarning {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var warnings []LintWarning
+	for _, input := range c.Inputs {
+		warnings = append(warnings,
+			lintFilter("inputs", input.Name, input.Config.Filter)...)
+	}
+	for _, output := range c.Outputs {
+		warnings = append(warnings,
+			lintFilter("outputs", output.Name, output.Config.Filter)...)
+	}
+	return warnings
+}
+
+func lintFilter(pluginType, name string, f models.Filter) []LintWarning {
+	var warnings []LintWarning
+	add := func(pass, drop string, patterns []string) {
+		for _, pattern := range patterns {
+			warnings = append(warnings, LintWarning{
+				PluginType: pluginType,
+				PluginName: name,
+				Filters:    [2]string{pass, drop},
+				Pattern:    pattern,
+				Suggestion: fmt.Sprintf("remove %q from %s or %s",
+					pattern, pass, drop),
+			})
+		}
+	}
+
+	add("namepass", "namedrop", conflicts(f.NamePass, f.NameDrop))
+	add("fieldpass", "fielddrop", conflicts(f.FieldPass, f.FieldDrop))
+	add("taginclude", "tagexclude", conflicts(f.TagInclude, f.TagExclude))
+	for _, pass := range f.TagPass {
+		for _, drop := range f.TagDrop {
+			if pass.Name != drop.Name {
+				continue
+			}
+			add("tagpass."+pass.Name, "tagdrop."+drop.Name,
+				conflicts(pass.Filter, drop.Filter))
+		}
+	}
+	return warnings
+}
+
+// conflicts returns the patterns of pass matched by drop, and the patterns of
+// drop matched by pass.
+func conflicts(pass, drop []string) []string {
+	passFilter, err := filter.Compile(pass)
+	if err != nil || passFilter == nil {
+		return nil
+	}
+	dropFilter, err := filter.Compile(drop)
+	if err != nil || dropFilter == nil {
+		return nil
+	}
+
+	var patterns []string
+	seen := make(map[string]bool)
+	for _, p := range pass {
+		if dropFilter.Match(p) && !seen[p] {
+			seen[p] = true
+			patterns = append(patterns, p)
+		}
+	}
+	for _, p := range drop {
+		if passFilter.Match(p) && !seen[p] {
+			seen[p] = true
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// VerifyPluginRegistrations checks that every plugin of the loaded config is
+// registered in the inputs and outputs registries, as telegraf builds with a
+// custom set of plugins may miss some. It returns a single error listing all
+// the missing plugins, including the one that failed loading the config.
+func (c *Config) VerifyPluginRegistrations() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	missing := make(map[string]bool)
+	for _, name := range c.unregistered {
+		missing[name] = true
+	}
+	checkInputs := func(running []*models.RunningInput) {
+		for _, input := range running {
+			if _, ok := inputs.Inputs[input.Name]; !ok {
+				missing["inputs."+input.Name] = true
+			}
+		}
+	}
+	checkOutputs := func(running []*models.RunningOutput) {
+		for _, output := range running {
+			if _, ok := outputs.Outputs[output.Name]; !ok {
+				missing["outputs."+output.Name] = true
+			}
+		}
+	}
+	checkInputs(c.Inputs)
+	checkInputs(c.DisabledInputs)
+	checkOutputs(c.Outputs)
+	checkOutputs(c.DisabledOutputs)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(missing))
+	for name := range missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("Plugins not registered in this build: %s",
+		strings.Join(names, ", "))
+}
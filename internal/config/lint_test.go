@@ -0,0 +1,64 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLintConfig(t *testing.T) {
+	c := NewConfig()
+	err := c.AddInputFromTOML(`
+[[inputs.memcached]]
+  namepass = ["cpu*"]
+  namedrop = ["cpu_usage"]
+  fieldpass = ["usage"]
+  fielddrop = ["idle"]
+  [inputs.memcached.tagpass]
+    cpu = ["cpu0"]
+  [inputs.memcached.tagdrop]
+    cpu = ["cpu*"]
+`)
+	assert.NoError(t, err)
+
+	warnings := c.LintConfig()
+	assert.Len(t, warnings, 2)
+	assert.Equal(t, LintWarning{
+		PluginType: "inputs",
+		PluginName: "memcached",
+		Filters:    [2]string{"namepass", "namedrop"},
+		Pattern:    "cpu_usage",
+		Suggestion: `remove "cpu_usage" from namepass or namedrop`,
+	}, warnings[0])
+	assert.Equal(t, [2]string{"tagpass.cpu", "tagdrop.cpu"}, warnings[1].Filters)
+	assert.Equal(t, "cpu0", warnings[1].Pattern)
+	assert.Equal(t,
+		`[inputs.memcached] "cpu0" matches both tagpass.cpu and tagdrop.cpu: `+
+			`remove "cpu0" from tagpass.cpu or tagdrop.cpu`,
+		warnings[1].String())
+}
+
+func TestLintConfigNoWarnings(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfig("./testdata/single_plugin.toml")
+	assert.NoError(t, err)
+	assert.Empty(t, c.LintConfig())
+}
+
+func TestVerifyPluginRegistrations(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigString(`
+[[inputs.memcached]]
+[[inputs.not_a_plugin]]
+`)
+	assert.Error(t, err)
+
+	err = c.VerifyPluginRegistrations()
+	assert.EqualError(t, err, "Plugins not registered in this build: "+
+		"inputs.not_a_plugin")
+
+	c = NewConfig()
+	err = c.LoadConfig("./testdata/single_plugin.toml")
+	assert.NoError(t, err)
+	assert.NoError(t, c.VerifyPluginRegistrations())
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+const (
+	// Default average size in memory of a metric.
+	DEFAULT_AVG_METRIC_SIZE_BYTES = 200
+
+	// memoryAgentOverhead is the estimated memory used by the agent itself,
+	// with the Go runtime, whatever the plugins.
+	memoryAgentOverhead = 16 * 1024 * 1024
+	// memoryPluginOverhead is the estimated memory used by each plugin, for
+	// its goroutines, clients and buffers other than the metric buffers.
+	memoryPluginOverhead = 512 * 1024
+)
+
+// MemoryEstimate is the estimated maximum memory used by telegraf with a
+// config, in bytes.
+type MemoryEstimate struct {
+	// BufferBytes is the memory used by full metric buffers.
+	BufferBytes int64
+	// OverheadBytes is the memory used by the agent and the plugins.
+	OverheadBytes int64
+	TotalBytes    int64
+}
+
+// EstimateMemoryUsage estimates the maximum memory telegraf uses with the
+// config, to provision memory constrained devices. Each output holds up to
+// its metric_buffer_limit metrics, plus a batch of metric_batch_size metrics
+// being written, and each input up to its own metric_buffer_limit metrics,
+// all of avg_metric_size_bytes. Each plugin adds a fixed overhead.
+func (c *Config) EstimateMemoryUsage() MemoryEstimate {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	metricSize := int64(c.Agent.AvgMetricSizeBytes)
+	if metricSize <= 0 {
+		metricSize = DEFAULT_AVG_METRIC_SIZE_BYTES
+	}
+
+	var metrics int64
+	for _, output := range c.Outputs {
+		bufferLimit := output.MetricBufferLimit
+		if bufferLimit == 0 {
+			bufferLimit = models.DEFAULT_METRIC_BUFFER_LIMIT
+		}
+		batchSize := output.MetricBatchSize
+		if batchSize == 0 {
+			batchSize = models.DEFAULT_METRIC_BATCH_SIZE
+		}
+		metrics += int64(bufferLimit + batchSize)
+	}
+	for _, input := range c.Inputs {
+		metrics += int64(input.Config.MetricBufferLimit)
+	}
+
+	estimate := MemoryEstimate{
+		BufferBytes: metrics * metricSize,
+		OverheadBytes: memoryAgentOverhead +
+			int64(len(c.Inputs)+len(c.Outputs))*memoryPluginOverhead,
+	}
+	estimate.TotalBytes = estimate.BufferBytes + estimate.OverheadBytes
+	return estimate
+}
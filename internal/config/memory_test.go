@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_EstimateMemoryUsage(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(`
+[agent]
+  metric_batch_size = 100
+  metric_buffer_limit = 1000
+  avg_metric_size_bytes = 500
+
+[[inputs.memcached]]
+  servers = ["localhost"]
+  metric_buffer_limit = 50
+
+[[outputs.file]]
+  files = ["stdout"]
+`))
+
+	estimate := c.EstimateMemoryUsage()
+	assert.Equal(t, int64((1000+100+50)*500), estimate.BufferBytes)
+	assert.Equal(t, int64(memoryAgentOverhead+2*memoryPluginOverhead),
+		estimate.OverheadBytes)
+	assert.Equal(t, estimate.BufferBytes+estimate.OverheadBytes,
+		estimate.TotalBytes)
+
+	assert.Equal(t, DEFAULT_AVG_METRIC_SIZE_BYTES,
+		NewConfig().Agent.AvgMetricSizeBytes)
+}
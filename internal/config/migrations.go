@@ -0,0 +1,239 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/influxdata/toml/ast"
+)
+
+// LatestConfigFormatVersion is the newest config_format_version, the target
+// of the -migrate-config flag.
+const LatestConfigFormatVersion = 2
+
+// configSource is a config file loaded into a Config, kept to load it again
+// migrated by MigrateConfig.
+type configSource struct {
+	path     string
+	contents []byte
+	// overrides are those of LoadConfigWithOverrides.
+	overrides map[string]interface{}
+}
+
+// migrationRule rewrites a config file for a config_format_version. apply
+// changes the root table of the file, and reports whether it did.
+type migrationRule struct {
+	version     int
+	description string
+	apply       func(tbl *ast.Table) (bool, error)
+}
+
+// migrations are the rules upgrading a config to each config_format_version,
+// applied in order.
+var migrations = []migrationRule{
+	{
+		version:     2,
+		description: "the [plugins] section is renamed [inputs]",
+		apply:       migratePluginsSection,
+	},
+	{
+		version:     2,
+		description: "the pass and drop filters are renamed fieldpass and fielddrop",
+		apply:       migrateFilterAliases,
+	},
+	{
+		version:     2,
+		description: "config_format_version is set to 2",
+		apply: func(tbl *ast.Table) (bool, error) {
+			return setFormatVersion(tbl, 2)
+		},
+	},
+}
+
+// MigrateConfig returns a new config, loaded from the config files of c
+// rewritten by the migration rules up to targetVersion, the
+// config_format_version of the new config. c is left unchanged. Only the
+// files loaded by LoadConfig, LoadConfigWithOverrides, LoadDirectory and
+// LoadFromReader are migrated, plugins added otherwise are not part of the new
+// config. Options missing from the files get their defaults, as on any load.
+func (c *Config) MigrateConfig(targetVersion int) (*Config, error) {
+	version := c.Agent.ConfigFormatVersion
+	if targetVersion < version || targetVersion > LatestConfigFormatVersion {
+		return nil, fmt.Errorf("Cannot migrate config_format_version %d to "+
+			"%d, the target version must be between %d and %d", version,
+			targetVersion, version, LatestConfigFormatVersion)
+	}
+	if len(c.sources) == 0 {
+		return nil, fmt.Errorf("Cannot migrate config, no config file loaded")
+	}
+
+	newCfg := NewConfig()
+	newCfg.Version = c.Version
+	newCfg.InputFilters = c.InputFilters
+	newCfg.OutputFilters = c.OutputFilters
+	for _, src := range c.sources {
+		tbl, err := parseContents(src.contents)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing %s, %s", src.path, err)
+		}
+		keys := make([]string, 0, len(src.overrides))
+		for key := range src.overrides {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := applyOverride(tbl, key, src.overrides[key]); err != nil {
+				return nil, fmt.Errorf("Error overriding %s in %s, %s", key,
+					src.path, err)
+			}
+		}
+
+		// The files of a migrated config are kept as they were, and migrated
+		// again from their own version when it is migrated in turn.
+		fileVersion, err := fileFormatVersion(tbl)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing %s, %s", src.path, err)
+		}
+		for _, rule := range migrations {
+			if rule.version <= fileVersion || rule.version > targetVersion {
+				continue
+			}
+			changed, err := rule.apply(tbl)
+			if err != nil {
+				return nil, fmt.Errorf("Error migrating %s, %s", src.path, err)
+			}
+			if changed {
+				log.Printf("I! Migrated %s: %s\n", src.path, rule.description)
+			}
+		}
+
+		if err := newCfg.loadTable(src.path, tbl); err != nil {
+			return nil, fmt.Errorf("Error loading migrated %s, %s", src.path,
+				err)
+		}
+		newCfg.sources = append(newCfg.sources, src)
+	}
+	return newCfg, nil
+}
+
+// fileFormatVersion returns the config_format_version set by the [agent] table
+// of tbl, 1 when it is not set.
+func fileFormatVersion(tbl *ast.Table) (int, error) {
+	agent, ok := tbl.Fields["agent"].(*ast.Table)
+	if !ok {
+		return 1, nil
+	}
+	kv, ok := agent.Fields["config_format_version"].(*ast.KeyValue)
+	if !ok {
+		return 1, nil
+	}
+	i, ok := kv.Value.(*ast.Integer)
+	if !ok {
+		return 0, fmt.Errorf("config_format_version must be an integer")
+	}
+	return strconv.Atoi(i.Value)
+}
+
+// migratePluginsSection moves the inputs of the legacy [plugins] section to
+// the [inputs] section.
+func migratePluginsSection(tbl *ast.Table) (bool, error) {
+	node, ok := tbl.Fields["plugins"]
+	if !ok {
+		return false, nil
+	}
+	plugins, ok := node.(*ast.Table)
+	if !ok {
+		return false, fmt.Errorf("invalid [plugins] section")
+	}
+
+	inputs, ok := tbl.Fields["inputs"].(*ast.Table)
+	if !ok {
+		if _, exists := tbl.Fields["inputs"]; exists {
+			return false, fmt.Errorf("invalid [inputs] section")
+		}
+		inputs = &ast.Table{
+			Name:   "inputs",
+			Fields: make(map[string]interface{}),
+			Type:   ast.TableTypeNormal,
+			Line:   plugins.Line,
+		}
+		tbl.Fields["inputs"] = inputs
+	}
+	for name, plugin := range plugins.Fields {
+		tables := append(asTables(inputs.Fields[name]), asTables(plugin)...)
+		if len(tables) == 1 {
+			inputs.Fields[name] = tables[0]
+		} else {
+			inputs.Fields[name] = tables
+		}
+	}
+	delete(tbl.Fields, "plugins")
+	return true, nil
+}
+
+// asTables returns the plugin tables of a section field, a table or an array
+// of tables.
+func asTables(node interface{}) []*ast.Table {
+	switch t := node.(type) {
+	case *ast.Table:
+		return []*ast.Table{t}
+	case []*ast.Table:
+		return t
+	}
+	return nil
+}
+
+// migrateFilterAliases renames the pass and drop filters of the plugins to
+// fieldpass and fielddrop.
+func migrateFilterAliases(tbl *ast.Table) (bool, error) {
+	changed := false
+	for _, section := range []string{"inputs", "outputs"} {
+		sectionTbl, ok := tbl.Fields[section].(*ast.Table)
+		if !ok {
+			continue
+		}
+		for _, plugin := range pluginTables(sectionTbl) {
+			for _, alias := range [][2]string{
+				{"pass", "fieldpass"},
+				{"drop", "fielddrop"},
+			} {
+				node, ok := plugin.Fields[alias[0]]
+				if !ok {
+					continue
+				}
+				if _, ok := plugin.Fields[alias[1]]; ok {
+					return false, fmt.Errorf("%s.%s sets both %s and %s",
+						section, plugin.Name, alias[0], alias[1])
+				}
+				kv, ok := node.(*ast.KeyValue)
+				if !ok {
+					return false, fmt.Errorf("%s.%s: %s must be an array",
+						section, plugin.Name, alias[0])
+				}
+				kv.Key = alias[1]
+				plugin.Fields[alias[1]] = kv
+				delete(plugin.Fields, alias[0])
+				changed = true
+			}
+		}
+	}
+	return changed, nil
+}
+
+// setFormatVersion sets config_format_version in the [agent] table, added if
+// missing.
+func setFormatVersion(tbl *ast.Table, version int) (bool, error) {
+	if _, ok := tbl.Fields["agent"]; !ok {
+		tbl.Fields["agent"] = &ast.Table{
+			Name:   "agent",
+			Fields: make(map[string]interface{}),
+			Type:   ast.TableTypeNormal,
+		}
+	}
+	if err := applyOverride(tbl, "agent.config_format_version", version); err != nil {
+		return false, err
+	}
+	return true, nil
+}
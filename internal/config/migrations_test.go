@@ -0,0 +1,64 @@
+package config
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_MigrateConfig(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(`
+[[plugins.memcached]]
+  servers = ["localhost"]
+  drop = ["uptime"]
+
+[[inputs.exec]]
+  commands = ["/bin/true"]
+
+[[outputs.file]]
+  files = ["stdout"]
+  pass = ["value"]
+`))
+
+	migrated, err := c.MigrateConfig(LatestConfigFormatVersion)
+	require.NoError(t, err)
+	assert.Equal(t, 2, migrated.Agent.ConfigFormatVersion)
+	names := migrated.InputNames()
+	sort.Strings(names)
+	assert.Equal(t, []string{"exec", "memcached"}, names)
+	for _, input := range migrated.Inputs {
+		if input.Name == "memcached" {
+			assert.Equal(t, []string{"uptime"}, input.Config.Filter.FieldDrop)
+		}
+	}
+	require.Len(t, migrated.Outputs, 1)
+	assert.Equal(t, []string{"value"},
+		migrated.Outputs[0].Config.Filter.FieldPass)
+
+	// the receiver is left unchanged
+	assert.Equal(t, 1, c.Agent.ConfigFormatVersion)
+	assert.Len(t, c.Inputs, 2)
+
+	// a migrated config migrates again
+	again, err := migrated.MigrateConfig(LatestConfigFormatVersion)
+	require.NoError(t, err)
+	assert.Len(t, again.Inputs, 2)
+}
+
+func TestConfig_MigrateConfigInvalidVersion(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(`
+[agent]
+  config_format_version = 2
+`))
+	_, err := c.MigrateConfig(1)
+	assert.Error(t, err)
+	_, err = c.MigrateConfig(LatestConfigFormatVersion + 1)
+	assert.Error(t, err)
+
+	_, err = NewConfig().MigrateConfig(LatestConfigFormatVersion)
+	assert.Error(t, err)
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+// GenerateMinimalConfig returns the loaded config as TOML, with only what
+// differs from the defaults: the agent options that are not at their default,
+// and for each plugin, its options that differ from those of the plugin as
+// created, along with its parser or serializer options. Loading it gives the
+// same agent and plugins, to show what a config actually customizes.
+//
+// As with PrintEffectiveConfig, environment variables and secrets are
+// expanded, and plugin options that are tables, other than the telegraf
+// ones such as tags, are left out.
+func (c *Config) GenerateMinimalConfig() string {
+	var buf bytes.Buffer
+	if len(c.Tags) > 0 {
+		buf.WriteString("[global_tags]\n")
+		writeEffectiveTags(&buf, "  ", c.Tags)
+	}
+
+	var agent bytes.Buffer
+	writeMinimalTable(&agent, reflect.ValueOf(*c.Agent),
+		reflect.ValueOf(*NewConfig().Agent), "agent")
+	if agent.Len() > 0 {
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("[agent]\n")
+		buf.Write(agent.Bytes())
+	}
+
+	c.writeEffectivePlugins(&buf, true)
+	return buf.String()
+}
+
+// writeMinimalTable writes the options of v, a struct of the agent config,
+// that differ from those of def, followed by the sub tables that differ.
+func writeMinimalTable(buf *bytes.Buffer, v, def reflect.Value, table string) {
+	var subTables []int
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.PkgPath != "" || field.Tag.Get("toml") == "-" {
+			continue
+		}
+		if sameValue(v.Field(i), def.Field(i)) {
+			continue
+		}
+		if field.Type.Kind() == reflect.Map ||
+			(field.Type.Kind() == reflect.Struct &&
+				field.Type != reflect.TypeOf(internal.Duration{})) {
+			subTables = append(subTables, i)
+			continue
+		}
+		fmt.Fprintf(buf, "  %s = %s\n", tomlKey(field), tomlValue(v.Field(i)))
+	}
+
+	for _, i := range subTables {
+		key := tomlKey(v.Type().Field(i))
+		fmt.Fprintf(buf, "  [%s.%s]\n", table, key)
+		if v.Field(i).Kind() == reflect.Map {
+			var keys []string
+			for _, k := range v.Field(i).MapKeys() {
+				keys = append(keys, k.String())
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(buf, "    %q = %s\n", k,
+					tomlValue(v.Field(i).MapIndex(reflect.ValueOf(k))))
+			}
+			continue
+		}
+		var sub bytes.Buffer
+		writeMinimalTable(&sub, v.Field(i), def.Field(i), table+"."+key)
+		for _, line := range strings.SplitAfter(sub.String(), "\n") {
+			if line != "" {
+				buf.WriteString("  " + line)
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_GenerateMinimalConfig(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(`
+[global_tags]
+  dc = "us-east-1"
+
+[agent]
+  interval = "5s"
+  flush_interval = "10s"
+  round_interval = true
+  [agent.per_plugin_log_level]
+    "inputs.memcached" = "debug"
+
+[[inputs.memcached]]
+  servers = ["localhost:11211"]
+  unix_sockets = []
+  [inputs.memcached.tags]
+    rack = "a1"
+
+[[inputs.exec]]
+  commands = ["/bin/true"]
+  interval = "1m"
+  data_format = "json"
+  tag_keys = ["host"]
+`))
+
+	out := c.GenerateMinimalConfig()
+	assert.True(t, strings.HasPrefix(out, `[global_tags]
+  dc = "us-east-1"
+
+[agent]
+  interval = "5s"
+  [agent.per_plugin_log_level]
+    "inputs.memcached" = "debug"
+
+[[inputs.`), out)
+	assert.Contains(t, out, `
+[[inputs.memcached]]
+  servers = ["localhost:11211"]
+  [inputs.memcached.tags]
+    rack = "a1"
+`)
+	assert.Contains(t, out, `
+[[inputs.exec]]
+  interval = "1m0s"
+  commands = ["/bin/true"]
+  data_format = "json"
+  tag_keys = ["host"]
+`)
+
+	// the minimal config loads back to the same config, the inputs of a
+	// file being loaded in any order
+	loaded := NewConfig()
+	require.NoError(t, loaded.LoadConfigString(out))
+	blocks := func(config string) []string {
+		b := strings.Split(strings.TrimSpace(config), "\n\n")
+		sort.Strings(b)
+		return b
+	}
+	assert.Equal(t, blocks(out), blocks(loaded.GenerateMinimalConfig()))
+}
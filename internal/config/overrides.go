@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/toml/ast"
+)
+
+// LoadConfigWithOverrides loads the config file at path like LoadConfig, after
+// replacing values of the file with those of overrides.
+//
+// The keys of overrides are dot separated paths, such as "agent.interval" or
+// "outputs.file.files". An array of tables is indexed with "[n]", and so is a
+// plugin section to select its plugins in file order, ie
+// "inputs.exec[1].timeout" is the second exec input and "inputs[0].tags.env"
+// is the env tag of the first input. Every table of a path must exist in the
+// file, only its last key may be new.
+//
+// Values are strings, booleans, integers, floats, time.Time and
+// time.Duration, which is set as a duration string.
+func (c *Config) LoadConfigWithOverrides(
+	path string,
+	overrides map[string]interface{},
+) error {
+	var err error
+	if path == "" {
+		if path, err = getDefaultConfigPath(); err != nil {
+			return err
+		}
+	}
+	contents, err := readConfigFile(path)
+	if err != nil {
+		return fmt.Errorf("Error parsing %s, %s", path, err)
+	}
+	tbl, err := parseContents(contents)
+	if err != nil {
+		return fmt.Errorf("Error parsing %s, %s", path, err)
+	}
+
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := applyOverride(tbl, key, overrides[key]); err != nil {
+			return fmt.Errorf("Error overriding %s in %s, %s", key, path, err)
+		}
+	}
+	if err = c.loadTable(path, tbl); err != nil {
+		return err
+	}
+	c.sources = append(c.sources, configSource{path: path,
+		contents: contents, overrides: overrides})
+	return nil
+}
+
+// applyOverride sets the value at the dot separated path key of tbl.
+func applyOverride(tbl *ast.Table, key string, value interface{}) error {
+	val, err := overrideValue(value)
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(key, ".")
+	for i, part := range parts[:len(parts)-1] {
+		name, index, err := splitIndex(part)
+		if err != nil {
+			return err
+		}
+		path := strings.Join(parts[:i+1], ".")
+
+		switch node := tbl.Fields[name].(type) {
+		case *ast.Table:
+			if index < 0 {
+				tbl = node
+				continue
+			}
+			tables := pluginTables(node)
+			if index >= len(tables) {
+				return fmt.Errorf("%s not found, there are %d tables in %s",
+					path, len(tables), name)
+			}
+			tbl = tables[index]
+		case []*ast.Table:
+			if index < 0 {
+				if len(node) != 1 {
+					return fmt.Errorf("%s has %d tables, select one with "+
+						"%s[n]", path, len(node), name)
+				}
+				index = 0
+			}
+			if index >= len(node) {
+				return fmt.Errorf("%s not found, there are %d tables in %s",
+					path, len(node), name)
+			}
+			tbl = node[index]
+		case nil:
+			return fmt.Errorf("%s not found", path)
+		default:
+			return fmt.Errorf("%s is not a table", path)
+		}
+	}
+
+	last := parts[len(parts)-1]
+	if last == "" || strings.Contains(last, "[") {
+		return fmt.Errorf("invalid key %q", last)
+	}
+	if node, ok := tbl.Fields[last]; ok {
+		if _, ok := node.(*ast.KeyValue); !ok {
+			return fmt.Errorf("%s is a table", key)
+		}
+	}
+	tbl.Fields[last] = &ast.KeyValue{Key: last, Value: val, Line: tbl.Line}
+	return nil
+}
+
+// splitIndex splits a path element of the form "name[n]" into its name and
+// index. The index is -1 when there is none.
+func splitIndex(part string) (string, int, error) {
+	open := strings.Index(part, "[")
+	if open < 0 {
+		if part == "" {
+			return "", -1, fmt.Errorf("empty path element")
+		}
+		return part, -1, nil
+	}
+	if !strings.HasSuffix(part, "]") {
+		return "", -1, fmt.Errorf("invalid path element %q", part)
+	}
+	index, err := strconv.Atoi(part[open+1 : len(part)-1])
+	if err != nil || index < 0 || open == 0 {
+		return "", -1, fmt.Errorf("invalid path element %q", part)
+	}
+	return part[:open], index, nil
+}
+
+// pluginTables returns the tables held by a section such as "inputs", in
+// the order they appear in the file.
+func pluginTables(section *ast.Table) []*ast.Table {
+	var tables tablesByLine
+	for _, node := range section.Fields {
+		switch t := node.(type) {
+		case *ast.Table:
+			tables = append(tables, t)
+		case []*ast.Table:
+			tables = append(tables, t...)
+		}
+	}
+	sort.Sort(tables)
+	return tables
+}
+
+type tablesByLine []*ast.Table
+
+func (t tablesByLine) Len() int           { return len(t) }
+func (t tablesByLine) Less(i, j int) bool { return t[i].Line < t[j].Line }
+func (t tablesByLine) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+
+// overrideValue returns the TOML value of an override. Data is set along
+// with Value, as types implementing UnmarshalTOML are given the source.
+func overrideValue(value interface{}) (ast.Value, error) {
+	switch v := value.(type) {
+	case string:
+		return &ast.String{Value: v, Data: []rune(strconv.Quote(v))}, nil
+	case time.Duration:
+		s := v.String()
+		return &ast.String{Value: s, Data: []rune(strconv.Quote(s))}, nil
+	case bool:
+		s := strconv.FormatBool(v)
+		return &ast.Boolean{Value: s, Data: []rune(s)}, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(v)
+		return &ast.Integer{Value: s, Data: []rune(s)}, nil
+	case float32:
+		s := strconv.FormatFloat(float64(v), 'f', -1, 32)
+		return &ast.Float{Value: s, Data: []rune(s)}, nil
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		return &ast.Float{Value: s, Data: []rune(s)}, nil
+	case time.Time:
+		s := v.Format(time.RFC3339Nano)
+		return &ast.Datetime{Value: s, Data: []rune(s)}, nil
+	}
+	return nil, fmt.Errorf("unsupported override value type %T", value)
+}
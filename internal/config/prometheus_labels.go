@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+)
+
+const (
+	// prometheusLabelsTimeout is the timeout of the scrape of
+	// LoadGlobalTagsFromPrometheusLabels.
+	prometheusLabelsTimeout = 5 * time.Second
+
+	// defaultPrometheusLabelsMetric is the metric whose labels are read when
+	// prometheus_labels_metric is not set.
+	defaultPrometheusLabelsMetric = "telegraf_info"
+)
+
+// LoadGlobalTagsFromPrometheusLabels scrapes a Prometheus /metrics endpoint
+// in the text exposition format, and adds the labels of its
+// prometheus_labels_metric metric, telegraf_info by default, to the global
+// tags. If the metric has several series, their labels are merged, the first
+// series winning. Tags already set in [global_tags] are kept.
+func (c *Config) LoadGlobalTagsFromPrometheusLabels(url string) error {
+	name := c.Agent.PrometheusLabelsMetric
+	if name == "" {
+		name = defaultPrometheusLabelsMetric
+	}
+
+	client := c.Agent.Proxy.httpClient(prometheusLabelsTimeout)
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %s", url, resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not parse %s, %s", url, err)
+	}
+	family, ok := families[name]
+	if !ok || len(family.Metric) == 0 {
+		return fmt.Errorf("metric %s not found in %s", name, url)
+	}
+
+	for _, m := range family.Metric {
+		for _, label := range m.Label {
+			if _, ok := c.Tags[label.GetName()]; !ok {
+				c.Tags[label.GetName()] = label.GetValue()
+			}
+		}
+	}
+	return nil
+}
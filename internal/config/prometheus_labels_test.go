@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const prometheusLabelsMetrics = `# HELP telegraf_info Deployment of telegraf.
+# TYPE telegraf_info gauge
+telegraf_info{cluster="prod",region="us-west-2"} 1
+# HELP build_info Build of the service.
+# TYPE build_info gauge
+build_info{version="1.2.3"} 1
+`
+
+func TestConfig_PrometheusLabelsTags(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/metrics" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			fmt.Fprint(w, prometheusLabelsMetrics)
+		}))
+	defer ts.Close()
+
+	c := NewConfig()
+	err := c.LoadConfigString(fmt.Sprintf(`
+[global_tags]
+  region = "override"
+
+[agent]
+  prometheus_labels_url = "%s/metrics"
+`, ts.URL))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"cluster": "prod",
+		"region":  "override",
+	}, c.Tags)
+
+	c = NewConfig()
+	c.Agent.PrometheusLabelsMetric = "build_info"
+	err = c.LoadGlobalTagsFromPrometheusLabels(ts.URL + "/metrics")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"version": "1.2.3"}, c.Tags)
+
+	c = NewConfig()
+	c.Agent.PrometheusLabelsMetric = "missing_info"
+	err = c.LoadGlobalTagsFromPrometheusLabels(ts.URL + "/metrics")
+	assert.Error(t, err)
+
+	c = NewConfig()
+	err = c.LoadGlobalTagsFromPrometheusLabels(ts.URL + "/other")
+	assert.Error(t, err)
+}
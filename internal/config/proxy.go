@@ -0,0 +1,168 @@
+package config
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyConfig holds HTTP proxy settings. When set, they are exported as the
+// standard proxy environment variables so that every HTTP client in every
+// plugin uses them.
+type ProxyConfig struct {
+	HTTPProxy  string `toml:"http_proxy"`
+	HTTPSProxy string `toml:"https_proxy"`
+	NoProxy    string `toml:"no_proxy"`
+}
+
+// proxyEnv is the value of a proxy environment variable before Apply set it.
+type proxyEnv struct {
+	value string
+	set   bool
+}
+
+var (
+	proxyEnvLock sync.Mutex
+	// proxyEnvApplied holds the previous values of the variables set by
+	// Apply, restored once a config no longer sets them.
+	proxyEnvApplied = make(map[string]proxyEnv)
+)
+
+// Apply sets the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+// for the proxy settings that are not empty. The variables that a previous
+// Apply set, and p leaves empty, are restored to their previous value.
+//
+// net/http reads the proxy environment variables once, on the first proxied
+// request, so Apply must be called before plugins make requests. Commands run
+// by plugins always get the variables set last.
+func (p ProxyConfig) Apply() error {
+	proxyEnvLock.Lock()
+	defer proxyEnvLock.Unlock()
+	for env, value := range map[string]string{
+		"HTTP_PROXY":  p.HTTPProxy,
+		"HTTPS_PROXY": p.HTTPSProxy,
+		"NO_PROXY":    p.NoProxy,
+	} {
+		previous, applied := proxyEnvApplied[env]
+		if value == "" {
+			if !applied {
+				continue
+			}
+			var err error
+			if previous.set {
+				err = os.Setenv(env, previous.value)
+			} else {
+				err = os.Unsetenv(env)
+			}
+			if err != nil {
+				return err
+			}
+			delete(proxyEnvApplied, env)
+			continue
+		}
+		if !applied {
+			v, ok := os.LookupEnv(env)
+			proxyEnvApplied[env] = proxyEnv{value: v, set: ok}
+		}
+		if err := os.Setenv(env, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProxyFunc returns the proxy function of the HTTP clients loading the
+// config, such as the instance metadata and S3 clients. It uses the settings
+// of p when any is set, rather than the environment variables of Apply,
+// which are read once by net/http and may be read before Apply is called.
+// Otherwise it uses the environment.
+func (p ProxyConfig) ProxyFunc() func(*http.Request) (*url.URL, error) {
+	if p.HTTPProxy == "" && p.HTTPSProxy == "" && p.NoProxy == "" {
+		return http.ProxyFromEnvironment
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		proxy := p.HTTPProxy
+		if req.URL.Scheme == "https" {
+			proxy = p.HTTPSProxy
+		}
+		if proxy == "" || !p.useProxy(canonicalAddr(req.URL)) {
+			return nil, nil
+		}
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || !strings.HasPrefix(proxyURL.Scheme, "http") {
+			// proxies are often given without a scheme, ie
+			// "proxy.example.com:3128"
+			if u, err := url.Parse("http://" + proxy); err == nil {
+				return u, nil
+			}
+		}
+		return proxyURL, err
+	}
+}
+
+// httpClient returns an HTTP client with the given timeout, going through
+// the proxy of p, see ProxyFunc.
+func (p ProxyConfig) httpClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{Proxy: p.ProxyFunc()},
+		Timeout:   timeout,
+	}
+}
+
+// useProxy reports whether requests to addr, a host:port, go through the
+// proxy, with the same rules as the NO_PROXY environment variable: loopback
+// addresses are not proxied, nor the hosts and domains listed in no_proxy.
+func (p ProxyConfig) useProxy(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return false
+	}
+	if p.NoProxy == "*" {
+		return false
+	}
+
+	host = strings.ToLower(host)
+	for _, entry := range strings.Split(p.NoProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		if host == entry {
+			return false
+		}
+		if entry[0] == '.' && (strings.HasSuffix(host, entry) ||
+			host == entry[1:]) {
+			return false
+		}
+		if entry[0] != '.' && strings.HasSuffix(host, "."+entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalAddr returns the host:port of u, with the default port of its
+// scheme if it has none.
+func canonicalAddr(u *url.URL) string {
+	if _, _, err := net.SplitHostPort(u.Host); err == nil {
+		return u.Host
+	}
+	port := "80"
+	if u.Scheme == "https" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Host, port)
+}
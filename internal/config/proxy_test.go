@@ -0,0 +1,49 @@
+package config
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyConfig_ProxyFunc(t *testing.T) {
+	proxy := ProxyConfig{
+		HTTPProxy:  "proxy.example.com:3128",
+		HTTPSProxy: "https://secure.example.com:3129",
+		NoProxy:    "internal.example.com, .corp.example.com",
+	}.ProxyFunc()
+
+	for rawurl, expected := range map[string]string{
+		"http://influxdb.example.com:8086/write": "http://proxy.example.com:3128",
+		"https://influxdb.example.com/write":     "https://secure.example.com:3129",
+		"http://localhost:8086/write":            "",
+		"http://127.0.0.1:8086/write":            "",
+		"http://internal.example.com/write":      "",
+		"http://db.internal.example.com/write":   "",
+		"http://corp.example.com/write":          "",
+		"http://db.corp.example.com/write":       "",
+		"http://notinternal.example.com/write":   "http://proxy.example.com:3128",
+	} {
+		req, err := http.NewRequest("GET", rawurl, nil)
+		require.NoError(t, err)
+		u, err := proxy(req)
+		require.NoError(t, err)
+		if expected == "" {
+			assert.Nil(t, u, rawurl)
+			continue
+		}
+		if assert.NotNil(t, u, rawurl) {
+			assert.Equal(t, expected, u.String(), rawurl)
+		}
+	}
+
+	// only the configured schemes are proxied
+	proxy = ProxyConfig{HTTPProxy: "http://proxy.example.com"}.ProxyFunc()
+	req, err := http.NewRequest("GET", "https://influxdb.example.com", nil)
+	require.NoError(t, err)
+	u, err := proxy(req)
+	require.NoError(t, err)
+	assert.Nil(t, u)
+}
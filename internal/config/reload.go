@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+var (
+	// reloadHealthTimeout is how long ReloadSafely waits for the new plugins
+	// to report healthy.
+	reloadHealthTimeout = 30 * time.Second
+	// reloadHealthPoll is how often the health of the new plugins is checked.
+	reloadHealthPoll = 100 * time.Millisecond
+)
+
+// ReloadSafely switches c to the plugins and settings of newCfg, or leaves c
+// untouched if newCfg is invalid or its plugins cannot be started.
+//
+// newCfg is validated first, then the switch has two phases. First the new
+// outputs are started and connected, in parallel, and the new plugins
+// implementing telegraf.HealthChecker are waited for until they report
+// healthy, up to a timeout. If any of them fails, the new outputs are stopped
+// again and an error is returned, with the old config and its connected
+// outputs still in place. Otherwise the plugins and settings of c are
+// replaced by those of newCfg, and the old outputs and service inputs are
+// stopped. The plugins disabled by DisablePlugin stay disabled in newCfg,
+// and are not started, while newCfg has a plugin with the same id.
+//
+// Either way the enabled outputs of c are connected when it returns, so the
+// agent running c next must not Connect them again. Service inputs need the
+// accumulator of the agent, so their services are not started here, but by
+// the agent running c. ReloadSafely must not be called while an agent runs
+// c: the agent is stopped first, and a new one is run with c once it
+// returns.
+func (c *Config) ReloadSafely(newCfg *Config) error {
+	if err := newCfg.Validate(); err != nil {
+		return fmt.Errorf("Not reloading, the new config is invalid, %s", err)
+	}
+
+	// plugins are not disabled or enabled while the config is switched, and
+	// those disabled stay disabled in the new config
+	c.toggleMu.Lock()
+	defer c.toggleMu.Unlock()
+	c.mu.RLock()
+	var disabled []string
+	for id := range c.disabled {
+		disabled = append(disabled, id)
+	}
+	c.mu.RUnlock()
+	newCfg.keepDisabled(disabled)
+
+	if err := startNewPlugins(newCfg); err != nil {
+		return fmt.Errorf("Not reloading, the new config failed to start, %s",
+			err)
+	}
+
+	for _, o := range newCfg.Outputs {
+		o.Quiet = newCfg.Agent.Quiet
+	}
+
+	c.mu.Lock()
+	oldInputs, oldOutputs := c.Inputs, c.Outputs
+	c.replaceState(newCfg)
+	c.mu.Unlock()
+
+	for _, input := range oldInputs {
+		input.StopService()
+	}
+	for _, o := range oldOutputs {
+		// outputs disabled at runtime are already stopped, the others are
+		// stopped once a write in progress returns
+		output := o
+		output.Pause(func() {
+			stopOutputs([]*models.RunningOutput{output})
+		})
+	}
+	return nil
+}
+
+// replaceState replaces the settings and plugins of c by those of newCfg,
+// everything but the locks of c. c.mu must be held.
+func (c *Config) replaceState(newCfg *Config) {
+	c.Tags = newCfg.Tags
+	c.InputFilters = newCfg.InputFilters
+	c.OutputFilters = newCfg.OutputFilters
+	c.Version = newCfg.Version
+	c.Agent = newCfg.Agent
+	c.Inputs = newCfg.Inputs
+	c.Outputs = newCfg.Outputs
+	c.DisabledInputs = newCfg.DisabledInputs
+	c.DisabledOutputs = newCfg.DisabledOutputs
+	c.secretStores = newCfg.secretStores
+	c.defaultsApplied = newCfg.defaultsApplied
+	c.instanceMetadataLoaded = newCfg.instanceMetadataLoaded
+	c.prometheusLabelsLoaded = newCfg.prometheusLabelsLoaded
+	c.tagsPreprocessed = newCfg.tagsPreprocessed
+	c.directories = newCfg.directories
+	c.directoryFiles = newCfg.directoryFiles
+	c.disabled = newCfg.disabled
+	c.sources = newCfg.sources
+	c.unregistered = newCfg.unregistered
+}
+
+// startNewPlugins starts the outputs of c and waits for its plugins to be
+// healthy, except for the plugins disabled, see keepDisabled. On error the
+// outputs that were started are stopped.
+func startNewPlugins(c *Config) error {
+	var (
+		wg      sync.WaitGroup
+		lock    sync.Mutex
+		started []*models.RunningOutput
+		errs    []error
+	)
+	for _, output := range c.Outputs {
+		if output.Paused() {
+			continue
+		}
+		wg.Add(1)
+		go func(o *models.RunningOutput) {
+			defer wg.Done()
+			err := startReloadedOutput(o)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			started = append(started, o)
+		}(output)
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		var checks []healthCheck
+		for _, input := range c.Inputs {
+			if hc, ok := input.Input.(telegraf.HealthChecker); ok &&
+				!input.Paused() {
+				checks = append(checks, healthCheck{"input " + input.Name, hc})
+			}
+		}
+		for _, output := range c.Outputs {
+			if hc, ok := output.Output.(telegraf.HealthChecker); ok &&
+				!output.Paused() {
+				checks = append(checks, healthCheck{"output " + output.Name, hc})
+			}
+		}
+		for _, check := range waitHealthy(checks) {
+			errs = append(errs, fmt.Errorf("%s did not become healthy within "+
+				"%s", check.name, reloadHealthTimeout))
+		}
+	}
+
+	if len(errs) > 0 {
+		stopOutputs(started)
+		return errs[0]
+	}
+	return nil
+}
+
+// startReloadedOutput starts the service of a service output, and connects
+// the output.
+func startReloadedOutput(o *models.RunningOutput) error {
+	if so, ok := o.Output.(telegraf.ServiceOutput); ok {
+		if err := so.Start(); err != nil {
+			return fmt.Errorf("output %s failed to start, %s", o.Name, err)
+		}
+	}
+	if err := o.Output.Connect(); err != nil {
+		if so, ok := o.Output.(telegraf.ServiceOutput); ok {
+			so.Stop()
+		}
+		return fmt.Errorf("output %s failed to connect, %s", o.Name, err)
+	}
+	return nil
+}
+
+// healthCheck is a plugin waited for by ReloadSafely.
+type healthCheck struct {
+	name   string
+	plugin telegraf.HealthChecker
+}
+
+// waitHealthy waits up to reloadHealthTimeout for the plugins of checks to
+// report healthy, and returns those that did not.
+func waitHealthy(checks []healthCheck) []healthCheck {
+	pending := checks
+	deadline := time.Now().Add(reloadHealthTimeout)
+	for {
+		var unhealthy []healthCheck
+		for _, check := range pending {
+			if !check.plugin.IsHealthy() {
+				unhealthy = append(unhealthy, check)
+			}
+		}
+		pending = unhealthy
+		if len(pending) == 0 || !time.Now().Before(deadline) {
+			return pending
+		}
+		time.Sleep(reloadHealthPoll)
+	}
+}
+
+func stopOutputs(outputs []*models.RunningOutput) {
+	for _, o := range outputs {
+		if err := o.Output.Close(); err != nil {
+			log.Printf("E! Error closing output %s: %s\n", o.Name, err)
+		}
+		if so, ok := o.Output.(telegraf.ServiceOutput); ok {
+			so.Stop()
+		}
+	}
+}
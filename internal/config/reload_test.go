@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/models"
+	"github.com/influxdata/telegraf/plugins/inputs/memcached"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// reloadOutput is an output recording its connection state.
+type reloadOutput struct {
+	connectErr error
+	healthy    bool
+	connected  bool
+	closed     bool
+}
+
+func (o *reloadOutput) Connect() error {
+	if o.connectErr != nil {
+		return o.connectErr
+	}
+	o.connected = true
+	return nil
+}
+func (o *reloadOutput) Close() error                          { o.closed = true; return nil }
+func (o *reloadOutput) Description() string                   { return "" }
+func (o *reloadOutput) SampleConfig() string                  { return "" }
+func (o *reloadOutput) Write(metrics []telegraf.Metric) error { return nil }
+
+// healthOutput is a reloadOutput reporting its health.
+type healthOutput struct {
+	reloadOutput
+}
+
+func (o *healthOutput) IsHealthy() bool { return o.healthy }
+
+func reloadConfig(outputs ...telegraf.Output) *Config {
+	c := NewConfig()
+	for _, o := range outputs {
+		c.Outputs = append(c.Outputs, models.NewRunningOutput("test", o,
+			&models.OutputConfig{Name: "test"}, 0, 0))
+	}
+	return c
+}
+
+// validReloadConfig is a reloadConfig with an input, so that it validates.
+func validReloadConfig(outputs ...telegraf.Output) *Config {
+	c := reloadConfig(outputs...)
+	c.Inputs = append(c.Inputs, &models.RunningInput{
+		Name:   "memcached",
+		Input:  &memcached.Memcached{},
+		Config: &models.InputConfig{Name: "memcached"},
+	})
+	return c
+}
+
+func TestConfig_ReloadSafely(t *testing.T) {
+	oldOutput := &reloadOutput{}
+	c := validReloadConfig(oldOutput)
+	c.tagsPreprocessed = true
+
+	newOutput := &healthOutput{reloadOutput{healthy: true}}
+	newCfg := validReloadConfig(newOutput)
+	newCfg.Tags["dc"] = "us-east-1"
+	newCfg.Agent.Quiet = true
+
+	assert.NoError(t, c.ReloadSafely(newCfg))
+	assert.True(t, newOutput.connected)
+	assert.False(t, newOutput.closed)
+	assert.True(t, oldOutput.closed)
+	assert.Equal(t, newCfg.Outputs, c.Outputs)
+	assert.Equal(t, "us-east-1", c.Tags["dc"])
+	assert.True(t, c.Outputs[0].Quiet)
+	// the tags of the new config are not preprocessed yet
+	assert.False(t, c.tagsPreprocessed)
+}
+
+func TestConfig_ReloadSafelyRollback(t *testing.T) {
+	defer func(timeout time.Duration) {
+		reloadHealthTimeout = timeout
+	}(reloadHealthTimeout)
+	reloadHealthTimeout = 50 * time.Millisecond
+
+	oldOutput := &reloadOutput{}
+	c := validReloadConfig(oldOutput)
+	outputs := c.Outputs
+
+	// a new output failing to connect
+	started := &reloadOutput{}
+	failing := &reloadOutput{connectErr: errors.New("connection refused")}
+	err := c.ReloadSafely(validReloadConfig(started, failing))
+	assert.Error(t, err)
+	assert.True(t, started.closed)
+	assert.False(t, oldOutput.closed)
+	assert.Equal(t, outputs, c.Outputs)
+
+	// a new output never becoming healthy
+	unhealthy := &healthOutput{}
+	err = c.ReloadSafely(validReloadConfig(unhealthy))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "output test did not become healthy")
+	}
+	assert.True(t, unhealthy.closed)
+	assert.False(t, oldOutput.closed)
+	assert.Equal(t, outputs, c.Outputs)
+
+	// an invalid new config, without outputs, is not started
+	err = c.ReloadSafely(validReloadConfig())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid")
+	}
+	assert.False(t, oldOutput.closed)
+	assert.Equal(t, outputs, c.Outputs)
+}
+
+func TestConfig_ReloadSafelyKeepsDisabled(t *testing.T) {
+	oldOutput := &reloadOutput{}
+	c := validReloadConfig(oldOutput)
+	assert.NoError(t, c.DisablePlugin("outputs.test"))
+	assert.NoError(t, c.DisablePlugin("inputs.memcached"))
+
+	newOutput := &reloadOutput{}
+	newCfg := validReloadConfig(newOutput)
+	assert.NoError(t, c.ReloadSafely(newCfg))
+	assert.Equal(t, []string{"inputs.memcached", "outputs.test"},
+		c.DisabledPlugins())
+	// the disabled output is not started until it is enabled
+	assert.False(t, newOutput.connected)
+	assert.True(t, c.Outputs[0].Paused())
+	assert.True(t, c.Inputs[0].Paused())
+
+	assert.NoError(t, c.EnablePlugin("outputs.test"))
+	assert.True(t, newOutput.connected)
+}
+
+// reloadLocks are the fields of Config that ReloadSafely does not replace.
+var reloadLocks = map[string]bool{"mu": true, "toggleMu": true}
+
+// configField returns the field i of the Config v, exported or not.
+func configField(v reflect.Value, i int) reflect.Value {
+	f := v.Field(i)
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+}
+
+// nonZero returns a value of typ that differs from its zero value.
+func nonZero(t *testing.T, typ reflect.Type) reflect.Value {
+	v := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.String:
+		v.SetString("set")
+	case reflect.Int, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Ptr:
+		v.Set(reflect.New(typ.Elem()))
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(typ, 1, 1))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(typ))
+		v.SetMapIndex(reflect.Zero(typ.Key()), reflect.Zero(typ.Elem()))
+	default:
+		t.Fatalf("no test value for a %s", typ)
+	}
+	return v
+}
+
+// Every field added to Config must be carried over by a reload, see
+// replaceState.
+func TestConfig_ReplaceStateCarriesAllFields(t *testing.T) {
+	c := NewConfig()
+	newCfg := NewConfig()
+	nv := reflect.ValueOf(newCfg).Elem()
+	for i := 0; i < nv.NumField(); i++ {
+		if reloadLocks[nv.Type().Field(i).Name] {
+			continue
+		}
+		f := configField(nv, i)
+		f.Set(nonZero(t, f.Type()))
+	}
+
+	c.mu.Lock()
+	c.replaceState(newCfg)
+	c.mu.Unlock()
+
+	cv := reflect.ValueOf(c).Elem()
+	for i := 0; i < cv.NumField(); i++ {
+		name := cv.Type().Field(i).Name
+		if reloadLocks[name] {
+			continue
+		}
+		assert.True(t, reflect.DeepEqual(configField(nv, i).Interface(),
+			configField(cv, i).Interface()),
+			"field %s of Config is not carried over by a reload", name)
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+// LoadRemoteConfigs loads the configs stored remotely that the config loaded
+// so far points to: the S3 object of the bucket and key of [agent.s3], the
+// path in the Git repository of [agent.git_config], and the key of
+// [agent.etcd]. It is called once the local config files are loaded, and the
+// remote configs are added to them.
+func (c *Config) LoadRemoteConfigs() error {
+	s3Config := c.Agent.S3Config
+	gitConfig := c.Agent.GitConfig
+	etcdConfig := c.Agent.EtcdConfig
+	if s3Config.Bucket != "" {
+		err := c.LoadConfigFromS3(s3Config.Bucket, s3Config.Key,
+			s3Config.Region)
+		if err != nil {
+			return err
+		}
+	}
+	if gitConfig.Repository != "" {
+		err := c.LoadConfigFromGit(gitConfig.Repository, gitConfig.Branch,
+			gitConfig.Path)
+		if err != nil {
+			return err
+		}
+	}
+	if etcdConfig.Key != "" {
+		err := c.LoadConfigFromEtcd(etcdConfig.Endpoints, etcdConfig.Key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchRemoteConfigs watches the remote configs of LoadRemoteConfigs that
+// can change while telegraf runs: the branch of [agent.git_config] and the
+// key of [agent.etcd]. It sends
+// on the returned channel when one of them has a new config that loads, and
+// returns nil when none is watched. The watch stops when shutdown is closed.
+func (c *Config) WatchRemoteConfigs(shutdown chan struct{}) <-chan struct{} {
+	var watched []<-chan struct{}
+	gitConfig := c.Agent.GitConfig
+	if gitConfig.Repository != "" {
+		watched = append(watched, c.WatchGitConfig(gitConfig.Repository,
+			gitConfig.Branch, gitConfig.Path, shutdown))
+	}
+	etcdConfig := c.Agent.EtcdConfig
+	if etcdConfig.Key != "" {
+		watched = append(watched, c.WatchEtcdConfig(etcdConfig.Endpoints,
+			etcdConfig.Key, shutdown))
+	}
+	if len(watched) == 0 {
+		return nil
+	}
+
+	changed := make(chan struct{}, 1)
+	for _, w := range watched {
+		go func(w <-chan struct{}) {
+			for {
+				select {
+				case <-w:
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				case <-shutdown:
+					return
+				}
+			}
+		}(w)
+	}
+	return changed
+}
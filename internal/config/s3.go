@@ -0,0 +1,145 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"gopkg.in/yaml.v2"
+
+	internalaws "github.com/influxdata/telegraf/internal/config/aws"
+)
+
+// S3Config holds the settings of the config loaded from S3.
+type S3Config struct {
+	// Bucket, Key and Region locate the config loaded at startup by
+	// LoadRemoteConfigs, none is loaded without a bucket.
+	Bucket string `toml:"bucket"`
+	Key    string `toml:"key"`
+	Region string `toml:"region"`
+
+	// CachePath is a local copy of the last config fetched from S3, loaded
+	// instead when S3 cannot be reached.
+	CachePath string `toml:"s3_cache_path"`
+}
+
+// fetchS3Object returns the contents and Content-Type of an S3 object,
+// fetched with client. The credentials are looked up by the standard AWS
+// credential chain: environment variables, shared credentials file, then
+// instance role.
+var fetchS3Object = func(
+	client *http.Client,
+	bucket, key, region string,
+) (io.ReadCloser, string, error) {
+	credentialConfig := &internalaws.CredentialConfig{Region: region}
+	svc := s3.New(credentialConfig.Credentials(),
+		&aws.Config{HTTPClient: client})
+	out, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return out.Body, aws.StringValue(out.ContentType), nil
+}
+
+// LoadConfigFromS3 loads the config stored in the S3 object key of bucket.
+// Objects with a YAML Content-Type, such as "application/x-yaml", are parsed
+// as YAML, any other as TOML.
+//
+// When s3_cache_path is set in [agent.s3], every config fetched is saved
+// there, and is loaded instead if the object cannot be fetched.
+func (c *Config) LoadConfigFromS3(bucket, key, region string) error {
+	source := fmt.Sprintf("s3://%s/%s", bucket, key)
+	cachePath := c.Agent.S3Config.CachePath
+
+	body, contentType, err := fetchS3Object(c.Agent.Proxy.httpClient(0),
+		bucket, key, region)
+	if err != nil {
+		if cachePath == "" {
+			return fmt.Errorf("Error fetching config %s, %s", source, err)
+		}
+		log.Printf("W! Error fetching config %s, loading cached copy %s: %s\n",
+			source, cachePath, err)
+		return c.LoadConfig(cachePath)
+	}
+	defer body.Close()
+
+	var r io.Reader = body
+	if isYAMLContentType(contentType) {
+		contents, err := ioutil.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("Error reading config %s, %s", source, err)
+		}
+		tomlContents, err := yamlToTOML(contents)
+		if err != nil {
+			return fmt.Errorf("Error parsing config %s, %s", source, err)
+		}
+		r = bytes.NewReader(tomlContents)
+	}
+
+	if cachePath == "" {
+		if err := c.LoadFromReader(r); err != nil {
+			return fmt.Errorf("Error loading config %s, %s", source, err)
+		}
+		return nil
+	}
+
+	// Keep the config read, as TOML, to save it once it loaded.
+	var contents bytes.Buffer
+	if err := c.LoadFromReader(io.TeeReader(r, &contents)); err != nil {
+		return fmt.Errorf("Error loading config %s, %s", source, err)
+	}
+	if err := ioutil.WriteFile(cachePath, contents.Bytes(), 0600); err != nil {
+		log.Printf("W! Could not cache config %s to %s: %s\n",
+			source, cachePath, err)
+	}
+	return nil
+}
+
+func isYAMLContentType(contentType string) bool {
+	return strings.Contains(strings.ToLower(contentType), "yaml")
+}
+
+// yamlToTOML converts a YAML config document into TOML, with the same rules
+// as jsonToTOML.
+func yamlToTOML(contents []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(contents, &doc); err != nil {
+		return nil, fmt.Errorf("Error parsing YAML config, %s", err)
+	}
+	out, err := json.Marshal(yamlToJSONValue(doc))
+	if err != nil {
+		return nil, err
+	}
+	return jsonToTOML(out)
+}
+
+// yamlToJSONValue converts the maps decoded from YAML, which may have keys of
+// any type, into maps with string keys that can be marshalled to JSON.
+func yamlToJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			m[fmt.Sprint(k)] = yamlToJSONValue(value)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(v))
+		for i, value := range v {
+			a[i] = yamlToJSONValue(value)
+		}
+		return a
+	default:
+		return v
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3Object makes LoadConfigFromS3 fetch content, or fail with err.
+func fakeS3Object(content, contentType string, err error) func() {
+	orig := fetchS3Object
+	fetchS3Object = func(
+		client *http.Client,
+		bucket, key, region string,
+	) (io.ReadCloser, string, error) {
+		if err != nil {
+			return nil, "", err
+		}
+		return ioutil.NopCloser(strings.NewReader(content)), contentType, nil
+	}
+	return func() { fetchS3Object = orig }
+}
+
+func TestConfig_LoadConfigFromS3(t *testing.T) {
+	defer fakeS3Object(`
+agent:
+  interval: 20s
+global_tags:
+  dc: us-east-1
+inputs:
+  memcached:
+    - servers: ["cache1:11211"]
+`, "application/x-yaml", nil)()
+
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigFromS3("configs", "telegraf.yaml", "us-east-1"))
+	assert.Equal(t, []string{"memcached"}, c.InputNames())
+	assert.Equal(t, map[string]string{"dc": "us-east-1"}, c.Tags)
+	assert.Equal(t, "20s", c.Agent.Interval.Duration.String())
+
+	defer fakeS3Object("[[inputs.memcached]]\n", "text/plain", nil)()
+	c = NewConfig()
+	require.NoError(t, c.LoadConfigFromS3("configs", "telegraf.conf", "us-east-1"))
+	assert.Equal(t, []string{"memcached"}, c.InputNames())
+}
+
+func TestConfig_LoadConfigFromS3Cache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-s3")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	cachePath := filepath.Join(dir, "s3-config.conf")
+
+	newConfig := func() *Config {
+		c := NewConfig()
+		require.NoError(t, c.LoadConfigString(
+			"[agent.s3]\n  s3_cache_path = \""+cachePath+"\"\n"))
+		return c
+	}
+
+	restore := fakeS3Object("inputs:\n  memcached:\n    - {}\n",
+		"application/yaml", nil)
+	require.NoError(t, newConfig().LoadConfigFromS3("configs", "telegraf.yaml", ""))
+	restore()
+	cached, err := ioutil.ReadFile(cachePath)
+	require.NoError(t, err)
+	assert.Contains(t, string(cached), "[[inputs.memcached]]")
+
+	defer fakeS3Object("", "", errors.New("no route to host"))()
+	c := newConfig()
+	require.NoError(t, c.LoadConfigFromS3("configs", "telegraf.yaml", ""))
+	assert.Equal(t, []string{"memcached"}, c.InputNames())
+
+	// without a cached copy, the fetch error is returned
+	assert.Error(t, NewConfig().LoadConfigFromS3("configs", "telegraf.yaml", ""))
+}
+
+func TestConfig_LoadRemoteConfigsS3(t *testing.T) {
+	var fetched []string
+	orig := fetchS3Object
+	fetchS3Object = func(
+		client *http.Client,
+		bucket, key, region string,
+	) (io.ReadCloser, string, error) {
+		fetched = append(fetched, bucket, key, region)
+		return ioutil.NopCloser(strings.NewReader("[[inputs.memcached]]\n")),
+			"text/plain", nil
+	}
+	defer func() { fetchS3Object = orig }()
+
+	// no bucket, nothing is fetched
+	c := NewConfig()
+	require.NoError(t, c.LoadRemoteConfigs())
+	assert.Empty(t, fetched)
+
+	require.NoError(t, c.LoadConfigString(`
+[agent.s3]
+  bucket = "configs"
+  key = "telegraf.conf"
+  region = "eu-west-1"
+`))
+	require.NoError(t, c.LoadRemoteConfigs())
+	assert.Equal(t, []string{"configs", "telegraf.conf", "eu-west-1"}, fetched)
+	assert.Equal(t, []string{"memcached"}, c.InputNames())
+}
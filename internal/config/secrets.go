@@ -0,0 +1,312 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/influxdata/config"
+	"github.com/influxdata/toml/ast"
+
+	internalaws "github.com/influxdata/telegraf/internal/config/aws"
+)
+
+// secretPrefix starts a string value referencing a secret, in the form
+// "secret:store_name:key_path".
+const secretPrefix = "secret:"
+
+// SecretStore is a backend from which secrets referenced in the config are
+// read.
+type SecretStore interface {
+	Get(key string) (string, error)
+}
+
+// secretStoreConfig is a [[secret_store]] table.
+type secretStoreConfig struct {
+	Name string
+	Type string
+
+	// Path is the directory holding one file per secret, for "file" stores.
+	Path string
+
+	// Address and Token are used by "vault" stores. Token defaults to the
+	// VAULT_TOKEN environment variable.
+	Address string
+	Token   string
+
+	// Region is the AWS region of "aws-ssm" stores.
+	Region string
+
+	// SecretOnError is "fail" (the default) to make unresolvable secrets a
+	// config error, or "use_empty" to replace them with an empty string.
+	SecretOnError string
+}
+
+type secretStore struct {
+	SecretStore
+	useEmpty bool
+}
+
+// addSecretStore registers the secret store defined by a [[secret_store]]
+// table.
+func (c *Config) addSecretStore(table *ast.Table) error {
+	sc := &secretStoreConfig{}
+	if err := config.UnmarshalTable(table, sc); err != nil {
+		return err
+	}
+	if sc.Name == "" {
+		return fmt.Errorf("secret_store is missing a name")
+	}
+	if _, ok := c.secretStores[sc.Name]; ok {
+		return fmt.Errorf("Duplicate secret_store %s", sc.Name)
+	}
+
+	var useEmpty bool
+	switch sc.SecretOnError {
+	case "", "fail":
+	case "use_empty":
+		useEmpty = true
+	default:
+		return fmt.Errorf("Invalid secret_on_error %q for secret_store %s",
+			sc.SecretOnError, sc.Name)
+	}
+
+	var store SecretStore
+	switch sc.Type {
+	case "env":
+		store = envSecretStore{}
+	case "file":
+		if sc.Path == "" {
+			return fmt.Errorf("secret_store %s is missing a path", sc.Name)
+		}
+		store = fileSecretStore{path: sc.Path}
+	case "vault":
+		if sc.Address == "" {
+			return fmt.Errorf("secret_store %s is missing an address", sc.Name)
+		}
+		token := sc.Token
+		if token == "" {
+			token = os.Getenv("VAULT_TOKEN")
+		}
+		store = &vaultSecretStore{
+			address: strings.TrimRight(sc.Address, "/"),
+			token:   token,
+			client:  &http.Client{Timeout: 10 * time.Second},
+		}
+	case "aws-ssm":
+		if sc.Region == "" {
+			return fmt.Errorf("secret_store %s is missing a region", sc.Name)
+		}
+		credentialConfig := &internalaws.CredentialConfig{Region: sc.Region}
+		store = &awsSSMSecretStore{
+			client: ssm.New(credentialConfig.Credentials(), &aws.Config{
+				HTTPClient: &http.Client{Timeout: 10 * time.Second},
+			}),
+		}
+	default:
+		return fmt.Errorf("Unknown secret_store type %q for secret_store %s",
+			sc.Type, sc.Name)
+	}
+
+	c.secretStores[sc.Name] = &secretStore{
+		SecretStore: store,
+		useEmpty:    useEmpty,
+	}
+	return nil
+}
+
+// resolveSecrets replaces every string value of the form
+// "secret:store_name:key_path" in tbl with the secret it references.
+func (c *Config) resolveSecrets(tbl *ast.Table) error {
+	for name, val := range tbl.Fields {
+		if name == "secret_store" {
+			continue
+		}
+		if err := c.resolveSecretsIn(val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) resolveSecretsIn(val interface{}) error {
+	switch v := val.(type) {
+	case *ast.Table:
+		for _, field := range v.Fields {
+			if err := c.resolveSecretsIn(field); err != nil {
+				return err
+			}
+		}
+	case []*ast.Table:
+		for _, t := range v {
+			if err := c.resolveSecretsIn(t); err != nil {
+				return err
+			}
+		}
+	case *ast.KeyValue:
+		return c.resolveSecretsIn(v.Value)
+	case *ast.Array:
+		for _, elem := range v.Value {
+			if err := c.resolveSecretsIn(elem); err != nil {
+				return err
+			}
+		}
+	case *ast.String:
+		if !strings.HasPrefix(v.Value, secretPrefix) {
+			return nil
+		}
+		secret, err := c.resolveSecret(v.Value)
+		if err != nil {
+			return err
+		}
+		v.Value = secret
+	}
+	return nil
+}
+
+func (c *Config) resolveSecret(ref string) (string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(ref, secretPrefix), ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("Invalid secret reference %q, expected "+
+			"secret:store_name:key_path", ref)
+	}
+	store, ok := c.secretStores[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("Undefined secret_store %s in %q", parts[0], ref)
+	}
+
+	secret, err := store.Get(parts[1])
+	if err != nil {
+		if store.useEmpty {
+			log.Printf("W! Could not resolve secret %q, using an empty value: "+
+				"%s\n", ref, err)
+			return "", nil
+		}
+		return "", fmt.Errorf("Could not resolve secret %q, %s", ref, err)
+	}
+	return secret, nil
+}
+
+// envSecretStore reads secrets from environment variables.
+type envSecretStore struct{}
+
+func (envSecretStore) Get(key string) (string, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", key)
+	}
+	return value, nil
+}
+
+// fileSecretStore reads secrets from files in a directory, one file per
+// secret, such as Docker or Kubernetes secret mounts. Trailing newlines are
+// trimmed. Symlinks are followed, as Kubernetes mounts secrets through them,
+// but only to files within the directory.
+type fileSecretStore struct {
+	path string
+}
+
+func (s fileSecretStore) Get(key string) (string, error) {
+	root, err := filepath.EvalSymlinks(s.path)
+	if err != nil {
+		return "", err
+	}
+	file, err := filepath.EvalSymlinks(
+		filepath.Join(root, filepath.Clean("/"+key)))
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(file, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("secret %s links outside of %s", key, s.path)
+	}
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
+
+// vaultSecretStore reads secrets from a HashiCorp Vault KV secrets engine.
+// Keys have the form "path#field", the field defaulting to "value".
+type vaultSecretStore struct {
+	address string
+	token   string
+	client  *http.Client
+}
+
+func (s *vaultSecretStore) Get(key string) (string, error) {
+	path, field := key, "value"
+	if i := strings.LastIndex(key, "#"); i >= 0 {
+		path, field = key[:i], key[i+1:]
+	}
+
+	req, err := http.NewRequest("GET",
+		s.address+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", s.token)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned status %s for %s",
+			resp.Status, path)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	data := body.Data
+	// KV version 2 nests the secret in another data object
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %s not found in vault secret %s",
+			field, path)
+	}
+	if str, ok := value.(string); ok {
+		return str, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// awsSSMSecretStore reads secrets from the AWS Systems Manager Parameter
+// Store, decrypting SecureString parameters. The key is the name of the
+// parameter. The credentials are looked up by the standard AWS credential
+// chain: environment variables, shared credentials file, then instance role.
+type awsSSMSecretStore struct {
+	client ssmiface.SSMAPI
+}
+
+func (s *awsSSMSecretStore) Get(key string) (string, error) {
+	out, err := s.client.GetParameters(&ssm.GetParametersInput{
+		Names:          []*string{aws.String(key)},
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, p := range out.Parameters {
+		if aws.StringValue(p.Name) == key {
+			return aws.StringValue(p.Value), nil
+		}
+	}
+	return "", fmt.Errorf("parameter %s not found in aws ssm", key)
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/influxdata/telegraf/plugins/inputs/memcached"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_SecretStores(t *testing.T) {
+	os.Setenv("TELEGRAF_TEST_SECRET", "envsecret")
+	defer os.Unsetenv("TELEGRAF_TEST_SECRET")
+
+	c := NewConfig()
+	err := c.LoadConfig("./testdata/secret_store.toml")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"filepassword", ""},
+		c.Inputs[0].Input.(*memcached.Memcached).Servers)
+	assert.Equal(t, "envsecret", c.Inputs[0].Config.Tags["token"])
+}
+
+func TestConfig_SecretStoreErrors(t *testing.T) {
+	c := NewConfig()
+	err := c.AddInputFromTOML("[[inputs.memcached]]\n" +
+		"  servers = [\"secret:nonexistent:key\"]\n")
+	assert.Error(t, err)
+
+	c = NewConfig()
+	assert.NoError(t, c.LoadConfig("./testdata/secret_store.toml"))
+	err = c.AddInputFromTOML("[[inputs.memcached]]\n" +
+		"  servers = [\"secret:files:nonexistent\"]\n")
+	assert.Error(t, err)
+	// keys may not leave the file store directory
+	err = c.AddInputFromTOML("[[inputs.memcached]]\n" +
+		"  servers = [\"secret:files:../secret_store.toml\"]\n")
+	assert.Error(t, err)
+	assert.Len(t, c.Inputs, 1)
+}
+
+func TestVaultSecretStore(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Vault-Token") != "mytoken" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			switch r.URL.Path {
+			case "/v1/kv/data/memcached":
+				fmt.Fprintln(w, `{"data": {"data": {"password": "kv2"}}}`)
+			case "/v1/secret/memcached":
+				fmt.Fprintln(w, `{"data": {"value": "kv1"}}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	defer ts.Close()
+
+	store := &vaultSecretStore{
+		address: ts.URL,
+		token:   "mytoken",
+		client:  http.DefaultClient,
+	}
+	v, err := store.Get("kv/data/memcached#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "kv2", v)
+	v, err = store.Get("secret/memcached")
+	assert.NoError(t, err)
+	assert.Equal(t, "kv1", v)
+	_, err = store.Get("secret/memcached#missing")
+	assert.Error(t, err)
+	_, err = store.Get("secret/nonexistent")
+	assert.Error(t, err)
+}
+
+func TestFileSecretStoreSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	outside, err := ioutil.TempDir("", "outside")
+	require.NoError(t, err)
+	defer os.RemoveAll(outside)
+
+	// Kubernetes links every secret to a file in a hidden directory
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "..data"), 0755))
+	require.NoError(t, ioutil.WriteFile(
+		filepath.Join(dir, "..data", "password"), []byte("inside\n"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "password"),
+		filepath.Join(dir, "password")))
+	require.NoError(t, ioutil.WriteFile(
+		filepath.Join(outside, "password"), []byte("outside\n"), 0644))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "password"),
+		filepath.Join(dir, "escape")))
+
+	store := fileSecretStore{path: dir}
+	v, err := store.Get("password")
+	assert.NoError(t, err)
+	assert.Equal(t, "inside", v)
+	_, err = store.Get("escape")
+	assert.Error(t, err)
+}
+
+type mockSSM struct {
+	ssmiface.SSMAPI
+	parameters map[string]string
+}
+
+func (m *mockSSM) GetParameters(
+	in *ssm.GetParametersInput,
+) (*ssm.GetParametersOutput, error) {
+	out := &ssm.GetParametersOutput{}
+	for _, name := range in.Names {
+		if !aws.BoolValue(in.WithDecryption) {
+			return nil, fmt.Errorf("not decrypted")
+		}
+		value, ok := m.parameters[aws.StringValue(name)]
+		if !ok {
+			out.InvalidParameters = append(out.InvalidParameters, name)
+			continue
+		}
+		out.Parameters = append(out.Parameters, &ssm.Parameter{
+			Name:  name,
+			Value: aws.String(value),
+		})
+	}
+	return out, nil
+}
+
+func TestAWSSSMSecretStore(t *testing.T) {
+	store := &awsSSMSecretStore{client: &mockSSM{
+		parameters: map[string]string{"/telegraf/password": "ssm"},
+	}}
+	v, err := store.Get("/telegraf/password")
+	assert.NoError(t, err)
+	assert.Equal(t, "ssm", v)
+	_, err = store.Get("/telegraf/nonexistent")
+	assert.Error(t, err)
+
+	c := NewConfig()
+	err = c.LoadConfigString("[[secret_store]]\n" +
+		"  name = \"ssm\"\n  type = \"aws-ssm\"\n")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing a region")
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TagRule is a transformation of the value of a global tag, a
+// [[agent.tag_preprocessing]] table.
+type TagRule struct {
+	// Key is the tag transformed, "*" for every tag.
+	Key string `toml:"key"`
+	// Action is "lower" or "upper" to change the case of the value, "trim"
+	// to remove the leading and trailing characters of Args[0], whitespace
+	// without Args, and "replace" to replace every Args[0] by Args[1].
+	Action string   `toml:"action"`
+	Args   []string `toml:"args"`
+}
+
+// check returns an error if the action or the arguments of r are invalid.
+func (r TagRule) check() error {
+	if r.Key == "" {
+		return fmt.Errorf("tag rule %q has no key", r.Action)
+	}
+	switch r.Action {
+	case "lower", "upper":
+		if len(r.Args) != 0 {
+			return fmt.Errorf("tag rule %q of %s takes no args", r.Action,
+				r.Key)
+		}
+	case "trim":
+		if len(r.Args) > 1 {
+			return fmt.Errorf("tag rule \"trim\" of %s takes at most one "+
+				"arg, the characters to trim", r.Key)
+		}
+	case "replace":
+		if len(r.Args) != 2 || r.Args[0] == "" {
+			return fmt.Errorf("tag rule \"replace\" of %s takes two args, "+
+				"the string to replace and its replacement", r.Key)
+		}
+	default:
+		return fmt.Errorf("Invalid tag rule action %q of %s, must be "+
+			"\"lower\", \"upper\", \"trim\" or \"replace\"", r.Action, r.Key)
+	}
+	return nil
+}
+
+// apply returns value transformed by r.
+func (r TagRule) apply(value string) string {
+	switch r.Action {
+	case "lower":
+		return strings.ToLower(value)
+	case "upper":
+		return strings.ToUpper(value)
+	case "trim":
+		if len(r.Args) == 0 {
+			return strings.TrimSpace(value)
+		}
+		return strings.Trim(value, r.Args[0])
+	case "replace":
+		return strings.Replace(value, r.Args[0], r.Args[1], -1)
+	}
+	return value
+}
+
+// checkTagRules returns the error of the first invalid rule of rules.
+func checkTagRules(rules []TagRule) error {
+	for _, rule := range rules {
+		if err := rule.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PreprocessGlobalTags applies the rules of [[agent.tag_preprocessing]] to
+// the global tags, unless they already were: an agent created again for the
+// same config, when a reload is rolled back, leaves them as they are.
+func (c *Config) PreprocessGlobalTags() error {
+	if c.tagsPreprocessed {
+		return nil
+	}
+	if err := c.PreprocessTags(c.Agent.TagPreprocessing); err != nil {
+		return err
+	}
+	c.tagsPreprocessed = true
+	return nil
+}
+
+// PreprocessTags applies rules, in order, to the values of the global tags.
+// The rules are all checked first, so that on error no tag is changed. The
+// rules of [[agent.tag_preprocessing]] are applied once, by the agent, as
+// they need not give the same result when applied again.
+func (c *Config) PreprocessTags(rules []TagRule) error {
+	if err := checkTagRules(rules); err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		for key, value := range c.Tags {
+			if rule.Key == "*" || rule.Key == key {
+				c.Tags[key] = rule.apply(value)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_PreprocessTags(t *testing.T) {
+	c := NewConfig()
+	c.Tags = map[string]string{
+		"dc":   "  US East/1 ",
+		"rack": "_R12_",
+	}
+	require.NoError(t, c.PreprocessTags([]TagRule{
+		{Key: "*", Action: "trim"},
+		{Key: "dc", Action: "lower"},
+		{Key: "dc", Action: "replace", Args: []string{" ", "-"}},
+		{Key: "dc", Action: "replace", Args: []string{"/", ""}},
+		{Key: "rack", Action: "trim", Args: []string{"_"}},
+		{Key: "rack", Action: "upper"},
+	}))
+	assert.Equal(t, map[string]string{"dc": "us-east1", "rack": "R12"},
+		c.Tags)
+
+	// no tag is changed when a rule is invalid
+	for _, rule := range []TagRule{
+		{Key: "dc", Action: "title"},
+		{Key: "dc", Action: "replace", Args: []string{"-"}},
+		{Key: "dc", Action: "lower", Args: []string{"x"}},
+		{Action: "lower"},
+	} {
+		err := c.PreprocessTags([]TagRule{{Key: "dc", Action: "upper"}, rule})
+		assert.Error(t, err)
+		assert.Equal(t, "us-east1", c.Tags["dc"])
+	}
+}
+
+func TestConfig_PreprocessGlobalTags(t *testing.T) {
+	c := NewConfig()
+	c.Tags = map[string]string{"dc": "us-east-1"}
+	c.Agent.TagPreprocessing = []TagRule{
+		{Key: "dc", Action: "replace", Args: []string{"us", "us-x"}},
+	}
+	require.NoError(t, c.PreprocessGlobalTags())
+	// an agent created again for the config does not apply the rules twice
+	require.NoError(t, c.PreprocessGlobalTags())
+	assert.Equal(t, "us-x-east-1", c.Tags["dc"])
+}
+
+func TestConfig_TagPreprocessingLoad(t *testing.T) {
+	c := NewConfig()
+	require.NoError(t, c.LoadConfigString(`
+[global_tags]
+  dc = "US-EAST-1 "
+
+[agent]
+  [[agent.tag_preprocessing]]
+    key = "*"
+    action = "trim"
+  [[agent.tag_preprocessing]]
+    key = "dc"
+    action = "lower"
+`))
+	// applied by the agent, once the config is loaded
+	assert.Equal(t, "US-EAST-1 ", c.Tags["dc"])
+	assert.Equal(t, []TagRule{
+		{Key: "*", Action: "trim"},
+		{Key: "dc", Action: "lower"},
+	}, c.Agent.TagPreprocessing)
+
+	err := NewConfig().LoadConfigString(`
+[agent]
+  [[agent.tag_preprocessing]]
+    key = "dc"
+    action = "capitalize"
+`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "capitalize")
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+// DisablePlugin disables a running plugin until EnablePlugin is called,
+// without reloading the config. The plugin is named by alias, the alias of
+// an output, or its section and name such as "inputs.cpu", with an index in
+// config order when there are several such as "inputs.exec[1]".
+//
+// A disabled input is not gathered from, and the service of a service input
+// is stopped. A disabled output is closed once a write in progress returns,
+// and the metrics sent to it are dropped.
+func (c *Config) DisablePlugin(alias string) error {
+	c.toggleMu.Lock()
+	defer c.toggleMu.Unlock()
+	id, input, output, err := c.findToggledPlugin(alias, true)
+	if err != nil {
+		return err
+	}
+
+	// plugins are stopped without holding c.mu, so that the other plugins
+	// are gathered from and written to meanwhile
+	if input != nil {
+		input.Pause()
+	} else {
+		output.Pause(func() {
+			stopOutputs([]*models.RunningOutput{output})
+		})
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.disabled == nil {
+		c.disabled = make(map[string]bool)
+	}
+	c.disabled[id] = true
+	log.Printf("I! Disabled plugin %s\n", id)
+	return c.rebuildFilters()
+}
+
+// EnablePlugin enables a plugin disabled by DisablePlugin again. An output is
+// started and connected again, and an input is gathered from at its next
+// interval. Service inputs need the accumulator of the agent, so their
+// services are started again by the agent, before their next gather.
+//
+// The plugin is resumed with the config it was built with, rather than built
+// again: plugins have no Init method to run again in this version, and the
+// agent keeps running the same RunningInput or RunningOutput.
+func (c *Config) EnablePlugin(alias string) error {
+	c.toggleMu.Lock()
+	defer c.toggleMu.Unlock()
+	id, input, output, err := c.findToggledPlugin(alias, false)
+	if err != nil {
+		return err
+	}
+
+	if input != nil {
+		input.Resume()
+	} else {
+		err := output.Resume(func() error {
+			return startReloadedOutput(output)
+		})
+		if err != nil {
+			return fmt.Errorf("Error enabling plugin %s, %s", id, err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.disabled, id)
+	log.Printf("I! Enabled plugin %s\n", id)
+	return c.rebuildFilters()
+}
+
+// keepDisabled disables the plugins of c with the given ids, those disabled
+// in the config that c replaces, see ReloadSafely. c is not started yet, so
+// its outputs are not closed, and the services of its inputs are started
+// once they are enabled again.
+func (c *Config) keepDisabled(ids []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		_, input, output, err := c.findPlugin(id)
+		if err != nil {
+			log.Printf("I! Plugin %s disabled before the reload is not in "+
+				"the new config: %s\n", id, err)
+			continue
+		}
+		if input != nil {
+			input.PauseUnstarted()
+		} else {
+			output.Pause(func() {})
+		}
+		if c.disabled == nil {
+			c.disabled = make(map[string]bool)
+		}
+		c.disabled[id] = true
+	}
+}
+
+// findToggledPlugin returns the plugin named by alias, see findPlugin, with
+// an error if it is already disabled, for disable, or is not disabled.
+func (c *Config) findToggledPlugin(
+	alias string,
+	disable bool,
+) (string, *models.RunningInput, *models.RunningOutput, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	id, input, output, err := c.findPlugin(alias)
+	switch {
+	case err != nil:
+		return "", nil, nil, err
+	case disable && c.disabled[id]:
+		return "", nil, nil, fmt.Errorf("plugin %s is already disabled", id)
+	case !disable && !c.disabled[id]:
+		return "", nil, nil, fmt.Errorf("plugin %s is not disabled", id)
+	}
+	return id, input, output, nil
+}
+
+// DisabledPlugins returns the plugins disabled by DisablePlugin, sorted, as
+// "inputs.name", "inputs.name[n]" or the alias of an output.
+func (c *Config) DisabledPlugins() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var ids []string
+	for id := range c.disabled {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// findPlugin returns the input or output named by alias, see DisablePlugin,
+// and its id in c.disabled. c.mu must be held.
+func (c *Config) findPlugin(
+	alias string,
+) (string, *models.RunningInput, *models.RunningOutput, error) {
+	for _, output := range c.Outputs {
+		if output.Config.Alias != "" && output.Config.Alias == alias {
+			return alias, nil, output, nil
+		}
+	}
+
+	section, name := "", alias
+	if i := strings.Index(alias, "."); i >= 0 {
+		section, name = alias[:i], alias[i+1:]
+	}
+	name, index, err := splitIndex(name)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("invalid plugin %q, %s", alias, err)
+	}
+
+	var inputs []*models.RunningInput
+	var outputs []*models.RunningOutput
+	switch section {
+	case "inputs":
+		for _, input := range c.Inputs {
+			if input.Name == name {
+				inputs = append(inputs, input)
+			}
+		}
+	case "outputs":
+		for _, output := range c.Outputs {
+			if output.Name == name {
+				outputs = append(outputs, output)
+			}
+		}
+	default:
+		return "", nil, nil, fmt.Errorf("invalid plugin %q, use an output "+
+			"alias, inputs.name or outputs.name", alias)
+	}
+
+	count := len(inputs) + len(outputs)
+	switch {
+	case count == 0 || index >= count:
+		return "", nil, nil, fmt.Errorf("plugin %s not found", alias)
+	case index < 0 && count > 1:
+		return "", nil, nil, fmt.Errorf("there are %d %s plugins, select "+
+			"one with %s[n]", count, alias, alias)
+	case index < 0:
+		index = 0
+	}
+
+	id := section + "." + name
+	if count > 1 {
+		id = fmt.Sprintf("%s[%d]", id, index)
+	}
+	if inputs != nil {
+		return id, inputs[index], nil, nil
+	}
+	if outputs[index].Config.Alias != "" {
+		id = outputs[index].Config.Alias
+	}
+	return id, nil, outputs[index], nil
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// toggleService is a service input counting its starts and stops.
+type toggleService struct {
+	stops int
+}
+
+func (i *toggleService) SampleConfig() string                  { return "" }
+func (i *toggleService) Description() string                   { return "" }
+func (i *toggleService) Gather(acc telegraf.Accumulator) error { return nil }
+func (i *toggleService) Start(acc telegraf.Accumulator) error  { return nil }
+func (i *toggleService) Stop()                                 { i.stops++ }
+
+func TestConfig_DisablePlugin(t *testing.T) {
+	first, second := &toggleService{}, &toggleService{}
+	firstInput := &models.RunningInput{Name: "exec", Input: first}
+	secondInput := &models.RunningInput{Name: "exec", Input: second}
+	output := &reloadOutput{}
+	runningOutput := models.NewRunningOutput("test", output,
+		&models.OutputConfig{Name: "test", Alias: "primary"}, 0, 0)
+
+	c := NewConfig()
+	c.Inputs = []*models.RunningInput{firstInput, secondInput}
+	c.Outputs = []*models.RunningOutput{runningOutput}
+
+	assert.Error(t, c.DisablePlugin("inputs.exec"))
+	assert.Error(t, c.DisablePlugin("inputs.exec[2]"))
+	assert.Error(t, c.DisablePlugin("exec"))
+
+	assert.NoError(t, c.DisablePlugin("inputs.exec[1]"))
+	assert.True(t, secondInput.Paused())
+	assert.False(t, firstInput.Paused())
+	assert.Equal(t, 1, second.stops)
+	assert.Equal(t, 0, first.stops)
+	assert.Error(t, c.DisablePlugin("inputs.exec[1]"))
+
+	assert.NoError(t, c.DisablePlugin("outputs.test"))
+	assert.True(t, output.closed)
+	assert.True(t, runningOutput.Paused())
+	assert.Equal(t, []string{"inputs.exec[1]", "primary"}, c.DisabledPlugins())
+
+	// the alias and the name of an output are the same plugin
+	assert.Error(t, c.DisablePlugin("primary"))
+	assert.NoError(t, c.EnablePlugin("primary"))
+	assert.True(t, output.connected)
+	assert.False(t, runningOutput.Paused())
+
+	assert.NoError(t, c.EnablePlugin("inputs.exec[1]"))
+	assert.False(t, secondInput.Paused())
+	assert.Error(t, c.EnablePlugin("inputs.exec[1]"))
+	assert.Empty(t, c.DisabledPlugins())
+
+	// the service of the input is stopped only once
+	secondInput.StopService()
+	assert.Equal(t, 1, second.stops)
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/models"
+)
+
+// unusedFiltersTimeout is how long ListUnusedFilters waits for the gather of
+// an input without a collection_timeout.
+const unusedFiltersTimeout = 10 * time.Second
+
+// FilterWarning describes an output filter pattern that matches none of the
+// measurements of the loaded inputs.
+type FilterWarning struct {
+	PluginName string
+	// Filter is the filter option of the pattern, "namepass" or "namedrop".
+	Filter  string
+	Pattern string
+}
+
+func (w FilterWarning) String() string {
+	return fmt.Sprintf("[outputs.%s] %s pattern %q matches no measurement "+
+		"of the loaded inputs", w.PluginName, w.Filter, w.Pattern)
+}
+
+// ListUnusedFilters returns a warning for every namepass and namedrop pattern
+// of the outputs that matches none of the measurements of the inputs, often a
+// typo that silently starves an output.
+//
+// The measurements are found by a test collection round: every input is
+// gathered once, as with -test. Service inputs cannot be gathered without
+// being started, and neither can inputs failing to gather, so they are
+// assumed to produce measurements named after the plugin, such as "statsd".
+// The warnings are hints: an input may produce other measurements on later
+// collections.
+func (c *Config) ListUnusedFilters() []FilterWarning {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	names := c.sampleMeasurements()
+
+	var warnings []FilterWarning
+	for _, output := range c.Outputs {
+		check := func(option string, patterns []string) {
+			for _, pattern := range patterns {
+				if !matchesAny(pattern, names) {
+					warnings = append(warnings, FilterWarning{
+						PluginName: output.Name,
+						Filter:     option,
+						Pattern:    pattern,
+					})
+				}
+			}
+		}
+		check("namepass", output.Config.Filter.NamePass)
+		check("namedrop", output.Config.Filter.NameDrop)
+	}
+	return warnings
+}
+
+func matchesAny(pattern string, names []string) bool {
+	f, err := filter.Compile([]string{pattern})
+	if err != nil || f == nil {
+		// invalid patterns fail when the config is loaded
+		return true
+	}
+	for _, name := range names {
+		if f.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleMeasurements returns the sorted measurement names of one collection
+// of every input.
+func (c *Config) sampleMeasurements() []string {
+	seen := make(map[string]bool)
+	for _, input := range c.Inputs {
+		collector := &measurementCollector{config: input.Config}
+		if _, ok := input.Input.(telegraf.ServiceInput); !ok {
+			collector.gather(input)
+		}
+		names := collector.Names()
+		if len(names) == 0 {
+			names = []string{collector.name(input.Name, nil, nil)}
+		}
+		for _, name := range names {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// measurementCollector is an accumulator recording the measurement names of
+// an input, as renamed by its name options.
+type measurementCollector struct {
+	config *models.InputConfig
+
+	sync.Mutex
+	names []string
+}
+
+// gather gathers input once, giving up after its collection timeout.
+func (m *measurementCollector) gather(input *models.RunningInput) {
+	timeout := input.Config.CollectionTimeout
+	if timeout <= 0 {
+		timeout = unusedFiltersTimeout
+	}
+	// done is buffered, so that an abandoned gather can still return.
+	done := make(chan error, 1)
+	go func() {
+		done <- input.Input.Gather(m)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+// Names returns the measurement names added so far.
+func (m *measurementCollector) Names() []string {
+	m.Lock()
+	defer m.Unlock()
+	return append([]string(nil), m.names...)
+}
+
+func (m *measurementCollector) name(
+	measurement string,
+	tags map[string]string,
+	fields map[string]interface{},
+) string {
+	if m.config.NameTemplate != "" {
+		name, err := m.config.MetricName(measurement, tags, fields)
+		if err != nil {
+			return measurement
+		}
+		return name
+	}
+	if m.config.NameOverride != "" {
+		measurement = m.config.NameOverride
+	}
+	return m.config.MeasurementPrefix + measurement + m.config.MeasurementSuffix
+}
+
+func (m *measurementCollector) AddFields(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	name := m.name(measurement, tags, fields)
+	m.Lock()
+	defer m.Unlock()
+	m.names = append(m.names, name)
+}
+
+func (m *measurementCollector) AddGauge(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	m.AddFields(measurement, fields, tags, t...)
+}
+
+func (m *measurementCollector) AddCounter(
+	measurement string,
+	fields map[string]interface{},
+	tags map[string]string,
+	t ...time.Time,
+) {
+	m.AddFields(measurement, fields, tags, t...)
+}
+
+func (m *measurementCollector) AddError(err error)              {}
+func (m *measurementCollector) Debug() bool                     { return false }
+func (m *measurementCollector) SetDebug(enabled bool)           {}
+func (m *measurementCollector) SetPrecision(p, i time.Duration) {}
+func (m *measurementCollector) DisablePrecision()               {}
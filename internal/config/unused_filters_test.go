@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mysqlInput is an input adding the measurements of the mysql input.
+type mysqlInput struct{}
+
+func (i *mysqlInput) SampleConfig() string { return "" }
+func (i *mysqlInput) Description() string  { return "" }
+func (i *mysqlInput) Gather(acc telegraf.Accumulator) error {
+	fields := map[string]interface{}{"value": 1}
+	acc.AddFields("mysql", fields, nil)
+	acc.AddGauge("mysql_variables", fields, nil)
+	return nil
+}
+
+func TestListUnusedFilters(t *testing.T) {
+	c := NewConfig()
+	err := c.LoadConfigString(`
+[[outputs.file]]
+  namepass = ["mysql_*", "mysq_*"]
+  namedrop = ["db_mysql"]
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	c.Inputs = append(c.Inputs, &models.RunningInput{
+		Name:   "mysql",
+		Input:  &mysqlInput{},
+		Config: &models.InputConfig{Name: "mysql"},
+	})
+
+	warnings := c.ListUnusedFilters()
+	assert.Equal(t, []FilterWarning{
+		{PluginName: "file", Filter: "namepass", Pattern: "mysq_*"},
+		{PluginName: "file", Filter: "namedrop", Pattern: "db_mysql"},
+	}, warnings)
+	assert.Equal(t, `[outputs.file] namepass pattern "mysq_*" matches no `+
+		`measurement of the loaded inputs`, warnings[0].String())
+
+	// name options of the inputs apply
+	c.Inputs[0].Config.MeasurementPrefix = "db_"
+	warnings = c.ListUnusedFilters()
+	assert.Len(t, warnings, 2)
+	assert.Equal(t, "mysql_*", warnings[0].Pattern)
+	assert.Equal(t, "mysq_*", warnings[1].Pattern)
+}
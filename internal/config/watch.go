@@ -0,0 +1,102 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileState is what a config file watch compares to detect a change.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// configFileStates returns the state of each of paths. Directories are
+// expanded to the .conf files they hold, so that added and removed files are
+// noticed too. Missing paths are left out.
+func configFileStates(paths []string) map[string]fileState {
+	states := make(map[string]fileState)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			states[path] = fileState{info.ModTime(), info.Size()}
+			continue
+		}
+		files, err := ioutil.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), ".conf") {
+				continue
+			}
+			name := filepath.Join(path, file.Name())
+			if info, err := os.Stat(name); err == nil {
+				states[name] = fileState{info.ModTime(), info.Size()}
+			}
+		}
+	}
+	return states
+}
+
+func sameFileStates(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		if other, ok := b[path]; !ok || other != state {
+			return false
+		}
+	}
+	return true
+}
+
+// WatchConfigFiles polls the config files and directories in paths every
+// interval, and sends on the returned channel once they have changed and then
+// stayed unchanged for debounce. This lets a reload wait until tools that write
+// files non-atomically are done. The watch stops when shutdown is closed.
+func WatchConfigFiles(
+	paths []string,
+	interval time.Duration,
+	debounce time.Duration,
+	shutdown chan struct{},
+) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	current := configFileStates(paths)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var lastChange time.Time
+		pending := false
+		for {
+			select {
+			case <-shutdown:
+				return
+			case now := <-ticker.C:
+				states := configFileStates(paths)
+				if !sameFileStates(states, current) {
+					current = states
+					lastChange = now
+					pending = true
+					continue
+				}
+				if !pending || now.Sub(lastChange) < debounce {
+					continue
+				}
+				pending = false
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changed
+}
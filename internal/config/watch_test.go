@@ -0,0 +1,64 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_WatchConfigFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "telegraf-watch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "telegraf.conf")
+	require.NoError(t, ioutil.WriteFile(path, []byte(""), 0644))
+	confDir := filepath.Join(dir, "telegraf.d")
+	require.NoError(t, os.Mkdir(confDir, 0755))
+
+	shutdown := make(chan struct{})
+	defer close(shutdown)
+	changed := WatchConfigFiles([]string{path, confDir},
+		10*time.Millisecond, 100*time.Millisecond, shutdown)
+
+	select {
+	case <-changed:
+		t.Fatal("change reported before any file was written")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// a new file in the config directory is a change
+	require.NoError(t, ioutil.WriteFile(filepath.Join(confDir, "cpu.conf"),
+		[]byte("[[inputs.cpu]]\n"), 0644))
+	// the change is only reported after the debounce period
+	select {
+	case <-changed:
+		t.Fatal("change reported before the debounce period")
+	case <-time.After(50 * time.Millisecond):
+	}
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("change was not reported")
+	}
+
+	// files that are not .conf files are ignored
+	require.NoError(t, ioutil.WriteFile(filepath.Join(confDir, "notes.txt"),
+		[]byte("notes"), 0644))
+	select {
+	case <-changed:
+		t.Fatal("change reported for a non .conf file")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("[agent]\n"), 0644))
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("change was not reported")
+	}
+}
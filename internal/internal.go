@@ -102,6 +102,23 @@ func RandomString(n int) string {
 	return string(bytes)
 }
 
+// TLSConfig holds the TLS settings shared by plugins, as set in the
+// [agent.tls] table.
+type TLSConfig struct {
+	SSLCA              string `toml:"ssl_ca"`
+	SSLCert            string `toml:"ssl_cert"`
+	SSLKey             string `toml:"ssl_key"`
+	InsecureSkipVerify bool   `toml:"insecure_skip_verify"`
+}
+
+// DefaultTLSSetter is a plugin accepting the [agent.tls] settings as the
+// defaults of its own TLS options. SetDefaultTLS is called before the options
+// of the plugin are loaded, so that each option set for the plugin overrides
+// the default.
+type DefaultTLSSetter interface {
+	SetDefaultTLS(cfg TLSConfig)
+}
+
 // GetTLSConfig gets a tls.Config object from the given certs, key, and CA files.
 // you must give the full path to the files.
 // If all files are blank and InsecureSkipVerify=false, returns a nil pointer.
@@ -145,6 +162,28 @@ func GetTLSConfig(
 	return t, nil
 }
 
+// FormatTimestamp formats t as format: "unix_ns", "unix_ms" or "unix_s" for
+// an int64 count of nanoseconds, milliseconds or seconds since the epoch, and
+// "rfc3339" or "rfc3339nano" for a string in loc, UTC if nil. Any other
+// format is "unix_ns".
+func FormatTimestamp(t time.Time, format string, loc *time.Location) interface{} {
+	if loc == nil {
+		loc = time.UTC
+	}
+	switch format {
+	case "unix_ms":
+		return t.UnixNano() / int64(time.Millisecond)
+	case "unix_s":
+		return t.Unix()
+	case "rfc3339":
+		return t.In(loc).Format(time.RFC3339)
+	case "rfc3339nano":
+		return t.In(loc).Format(time.RFC3339Nano)
+	default:
+		return t.UnixNano()
+	}
+}
+
 // SnakeCase converts the given string to snake case following the Golang format:
 // acronyms are converted to lower-case and preceded by an underscore.
 func SnakeCase(in string) string {
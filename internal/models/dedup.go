@@ -0,0 +1,118 @@
+package models
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// DEFAULT_DEDUP_CACHE_SIZE is the number of series remembered by the
+// deduplication of an input without a dedup_cache_size.
+const DEFAULT_DEDUP_CACHE_SIZE = 1000
+
+// DedupConfig is the [inputs.x.deduplication] table of an input. A metric
+// whose Fields are identical to those of the last metric sent for its series,
+// its measurement and tags, is suppressed if it is less than Interval newer.
+type DedupConfig struct {
+	Interval time.Duration
+	// Fields are the fields compared, empty means all of them.
+	Fields []string
+	// CacheSize is the number of series remembered, the least recently seen
+	// series are forgotten first.
+	CacheSize int
+}
+
+// dedupCache is an LRU cache of the last metric sent for each series.
+type dedupCache struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type dedupEntry struct {
+	series string
+	values string
+	sent   time.Time
+}
+
+func newDedupCache(size int) *dedupCache {
+	if size <= 0 {
+		size = DEFAULT_DEDUP_CACHE_SIZE
+	}
+	return &dedupCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// suppress reports whether m duplicates the last metric sent for its series,
+// and records m as sent if it does not.
+func (c *dedupCache) suppress(conf *DedupConfig, m telegraf.Metric) bool {
+	series := seriesKey(m)
+	values := fieldsKey(m.Fields(), conf.Fields)
+
+	if elem, ok := c.entries[series]; ok {
+		c.order.MoveToFront(elem)
+		entry := elem.Value.(*dedupEntry)
+		if entry.values == values && m.Time().Sub(entry.sent) < conf.Interval {
+			return true
+		}
+		entry.values = values
+		entry.sent = m.Time()
+		return false
+	}
+
+	c.entries[series] = c.order.PushFront(&dedupEntry{
+		series: series,
+		values: values,
+		sent:   m.Time(),
+	})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*dedupEntry).series)
+	}
+	return false
+}
+
+// seriesKey returns the measurement and sorted tags of m.
+func seriesKey(m telegraf.Metric) string {
+	tags := m.Tags()
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{m.Name()}
+	for _, k := range keys {
+		parts = append(parts, k+"="+tags[k])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// fieldsKey returns the sorted values and types of the given fields, or of
+// all fields if names is empty.
+func fieldsKey(fields map[string]interface{}, names []string) string {
+	if len(names) == 0 {
+		for k := range fields {
+			names = append(names, k)
+		}
+	}
+	keys := make([]string, len(names))
+	copy(keys, names)
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v, ok := fields[k]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%T:%v", k, v, v))
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
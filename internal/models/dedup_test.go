@@ -0,0 +1,69 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dedupMetric(t *testing.T, host string, value, other int64, ts time.Time) telegraf.Metric {
+	m, err := telegraf.NewMetric("sensor", map[string]string{"host": host},
+		map[string]interface{}{"value": value, "other": other}, ts)
+	require.NoError(t, err)
+	return m
+}
+
+func TestRunningInputDeduplicate(t *testing.T) {
+	stats := make(chan telegraf.Metric, 10)
+	input := &RunningInput{
+		Name: "sensor",
+		Config: &InputConfig{
+			Dedup: &DedupConfig{
+				Interval: time.Minute,
+				Fields:   []string{"value"},
+			},
+		},
+		InternalStats: stats,
+	}
+	now := time.Now()
+
+	assert.False(t, input.Deduplicate(dedupMetric(t, "a", 1, 1, now)))
+	// same value, only an ignored field changed
+	assert.True(t, input.Deduplicate(
+		dedupMetric(t, "a", 1, 2, now.Add(10*time.Second))))
+	// another series
+	assert.False(t, input.Deduplicate(
+		dedupMetric(t, "b", 1, 1, now.Add(10*time.Second))))
+	// a changed value
+	assert.False(t, input.Deduplicate(
+		dedupMetric(t, "a", 2, 1, now.Add(20*time.Second))))
+	// the same value once the interval has passed
+	assert.False(t, input.Deduplicate(
+		dedupMetric(t, "a", 2, 1, now.Add(90*time.Second))))
+
+	require.Len(t, stats, 1)
+	stat := <-stats
+	assert.Equal(t, "telegraf_dedup", stat.Name())
+	assert.Equal(t, int64(1), stat.Fields()["suppressed_total"])
+
+	// inputs without deduplication never suppress metrics
+	input = &RunningInput{Name: "sensor", Config: &InputConfig{}}
+	assert.False(t, input.Deduplicate(dedupMetric(t, "a", 1, 1, now)))
+	assert.False(t, input.Deduplicate(dedupMetric(t, "a", 1, 1, now)))
+}
+
+func TestDedupCacheEviction(t *testing.T) {
+	conf := &DedupConfig{Interval: time.Minute}
+	cache := newDedupCache(1)
+	now := time.Now()
+
+	assert.False(t, cache.suppress(conf, dedupMetric(t, "a", 1, 1, now)))
+	assert.False(t, cache.suppress(conf, dedupMetric(t, "b", 1, 1, now)))
+	// a was evicted by b
+	assert.False(t, cache.suppress(conf, dedupMetric(t, "a", 1, 1, now)))
+	assert.True(t, cache.suppress(conf, dedupMetric(t, "a", 1, 1, now)))
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"github.com/influxdata/telegraf/filter"
+)
+
+// FieldRename renames the fields whose name matches the glob Pattern to Name.
+type FieldRename struct {
+	Pattern string
+	Name    string
+
+	filter filter.Filter
+}
+
+// NewFieldRename returns the rename of the fields matching pattern to name.
+func NewFieldRename(pattern, name string) (FieldRename, error) {
+	f, err := filter.Compile([]string{pattern})
+	if err != nil {
+		return FieldRename{}, err
+	}
+	return FieldRename{Pattern: pattern, Name: name, filter: f}, nil
+}
+
+// RenameFields renames the fields matching the FieldRename patterns of the
+// input, in place. Each field is renamed by the first rename matching it.
+func (c *InputConfig) RenameFields(fields map[string]interface{}) {
+	if len(c.FieldRename) == 0 {
+		return
+	}
+	renamed := make(map[string]interface{})
+	for k, v := range fields {
+		for _, rename := range c.FieldRename {
+			if rename.filter.Match(k) {
+				delete(fields, k)
+				renamed[rename.Name] = v
+				break
+			}
+		}
+	}
+	for k, v := range renamed {
+		fields[k] = v
+	}
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"regexp"
 
 	"github.com/influxdata/telegraf/filter"
 )
@@ -20,6 +21,16 @@ type Filter struct {
 	NamePass []string
 	namePass filter.Filter
 
+	// NameMatch and NameDropMatch are regular expressions, for names that
+	// globs cannot express. A name passes NameMatch if any of its expressions
+	// match, and is dropped by NameDropMatch if any of its expressions match.
+	// They apply on top of NamePass and NameDrop, a name must pass all of
+	// them.
+	NameMatch     []string
+	nameMatch     []*regexp.Regexp
+	NameDropMatch []string
+	nameDropMatch []*regexp.Regexp
+
 	FieldDrop []string
 	fieldDrop filter.Filter
 	FieldPass []string
@@ -40,12 +51,16 @@ type Filter struct {
 func (f *Filter) Compile() error {
 	if len(f.NameDrop) == 0 &&
 		len(f.NamePass) == 0 &&
+		len(f.NameMatch) == 0 &&
+		len(f.NameDropMatch) == 0 &&
 		len(f.FieldDrop) == 0 &&
 		len(f.FieldPass) == 0 &&
 		len(f.TagInclude) == 0 &&
 		len(f.TagExclude) == 0 &&
 		len(f.TagPass) == 0 &&
 		len(f.TagDrop) == 0 {
+		// the lists may have been emptied since the last compile
+		f.isActive = false
 		return nil
 	}
 
@@ -59,6 +74,14 @@ func (f *Filter) Compile() error {
 	if err != nil {
 		return fmt.Errorf("Error compiling 'namepass', %s", err)
 	}
+	f.nameMatch, err = compileRegexps(f.NameMatch)
+	if err != nil {
+		return fmt.Errorf("Error compiling 'namematch', %s", err)
+	}
+	f.nameDropMatch, err = compileRegexps(f.NameDropMatch)
+	if err != nil {
+		return fmt.Errorf("Error compiling 'namedropmatch', %s", err)
+	}
 
 	f.fieldDrop, err = filter.Compile(f.FieldDrop)
 	if err != nil {
@@ -136,9 +159,42 @@ func (f *Filter) IsActive() bool {
 	return f.isActive
 }
 
+func compileRegexps(patterns []string) ([]*regexp.Regexp, error) {
+	var regexps []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		regexps = append(regexps, re)
+	}
+	return regexps, nil
+}
+
 // shouldNamePass returns true if the metric should pass, false if should drop
-// based on the drop/pass filter parameters
+// based on the drop/pass and namematch/namedropmatch filter parameters
 func (f *Filter) shouldNamePass(key string) bool {
+	if len(f.nameMatch) > 0 && !matchAny(f.nameMatch, key) {
+		return false
+	}
+	if matchAny(f.nameDropMatch, key) {
+		return false
+	}
+	return f.shouldNameGlobPass(key)
+}
+
+func matchAny(regexps []*regexp.Regexp, key string) bool {
+	for _, re := range regexps {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldNameGlobPass returns true if the metric should pass, false if should
+// drop based on the drop/pass filter parameters
+func (f *Filter) shouldNameGlobPass(key string) bool {
 	if f.namePass != nil {
 		if f.namePass.Match(key) {
 			return true
@@ -147,6 +147,41 @@ func TestFilter_NameDrop(t *testing.T) {
 	}
 }
 
+func TestFilter_NameMatch(t *testing.T) {
+	f := Filter{
+		NamePass:      []string{"volume_*"},
+		NameMatch:     []string{"^volume_[0-9a-f]{8}$", "^volume_total$"},
+		NameDropMatch: []string{"^volume_0+$"},
+	}
+	require.NoError(t, f.Compile())
+
+	passes := []string{
+		"volume_1a2b3c4d",
+		"volume_total",
+	}
+
+	drops := []string{
+		"volume_00000000",
+		"volume_xyz",
+		"disk_1a2b3c4d",
+	}
+
+	for _, measurement := range passes {
+		if !f.shouldNamePass(measurement) {
+			t.Errorf("Expected measurement %s to pass", measurement)
+		}
+	}
+
+	for _, measurement := range drops {
+		if f.shouldNamePass(measurement) {
+			t.Errorf("Expected measurement %s to drop", measurement)
+		}
+	}
+
+	f = Filter{NameMatch: []string{"volume_("}}
+	require.Error(t, f.Compile())
+}
+
 func TestFilter_FieldPass(t *testing.T) {
 	f := Filter{
 		FieldPass: []string{"foo*", "cpu_usage_idle"},
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultGatherLatencyBuckets and DefaultWriteLatencyBuckets are the bucket
+// upper bounds, in milliseconds, of the gather and write latency histograms
+// when [agent.histogram] does not set them.
+var (
+	DefaultGatherLatencyBuckets = []float64{
+		1, 5, 10, 50, 100, 500, 1000, 5000, 10000}
+	DefaultWriteLatencyBuckets = []float64{
+		5, 10, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+)
+
+// LatencyHistogram counts durations in buckets by their upper bound in
+// milliseconds, like a Prometheus histogram: the count of a bucket includes
+// the durations of the buckets below it.
+type LatencyHistogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []int64
+	count  int64
+	sumMs  float64
+}
+
+// NewLatencyHistogram returns a histogram with the bucket upper bounds
+// bounds, in milliseconds, which must be positive and increasing.
+func NewLatencyHistogram(bounds []float64) (*LatencyHistogram, error) {
+	if err := ValidateLatencyBuckets(bounds); err != nil {
+		return nil, err
+	}
+	return &LatencyHistogram{
+		bounds: bounds,
+		counts: make([]int64, len(bounds)),
+	}, nil
+}
+
+// ValidateLatencyBuckets checks that the bucket upper bounds bounds are
+// positive and increasing.
+func ValidateLatencyBuckets(bounds []float64) error {
+	for i, bound := range bounds {
+		if bound <= 0 {
+			return fmt.Errorf("latency bucket %g is not positive", bound)
+		}
+		if i > 0 && bound <= bounds[i-1] {
+			return fmt.Errorf("latency buckets are not increasing, %g "+
+				"follows %g", bound, bounds[i-1])
+		}
+	}
+	return nil
+}
+
+// Observe adds the duration d to the histogram.
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	ms := float64(d) / float64(time.Millisecond)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.bounds {
+		if ms <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sumMs += ms
+}
+
+// Fields returns the histogram as metric fields: "le_<bound>" for the count
+// of each bucket, "count" for the count of all durations, and "sum_ms" for
+// their sum.
+func (h *LatencyHistogram) Fields() map[string]interface{} {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fields := make(map[string]interface{}, len(h.bounds)+2)
+	for i, bound := range h.bounds {
+		fields["le_"+strconv.FormatFloat(bound, 'f', -1, 64)] = h.counts[i]
+	}
+	fields["count"] = h.count
+	fields["sum_ms"] = h.sumMs
+	return fields
+}
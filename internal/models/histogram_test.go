@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	h, err := NewLatencyHistogram([]float64{0.5, 10, 100})
+	require.NoError(t, err)
+
+	h.Observe(200 * time.Microsecond)
+	h.Observe(10 * time.Millisecond)
+	h.Observe(50 * time.Millisecond)
+	h.Observe(time.Second)
+
+	fields := h.Fields()
+	assert.InDelta(t, 1060.2, fields["sum_ms"], 1e-9)
+	delete(fields, "sum_ms")
+	assert.Equal(t, map[string]interface{}{
+		"le_0.5": int64(1),
+		"le_10":  int64(2),
+		"le_100": int64(3),
+		"count":  int64(4),
+	}, fields)
+}
+
+func TestLatencyHistogram_InvalidBuckets(t *testing.T) {
+	_, err := NewLatencyHistogram([]float64{10, 5})
+	assert.Error(t, err)
+	_, err = NewLatencyHistogram([]float64{0, 5})
+	assert.Error(t, err)
+	_, err = NewLatencyHistogram(nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"log"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+)
+
+const (
+	// Label policy actions, for the tags whose key is not allowed.
+	LABEL_POLICY_DROP   = "drop"
+	LABEL_POLICY_RENAME = "rename"
+
+	// LABEL_POLICY_PREFIX prefixes the keys of the renamed tags.
+	LABEL_POLICY_PREFIX = "unknown_"
+)
+
+// LabelPolicy enforces a tag taxonomy on the metrics sent to the outputs:
+// tag keys not matching the whitelist, if any, or matching the blacklist are
+// dropped, or renamed with LABEL_POLICY_PREFIX, depending on Action. Each
+// such key is logged once.
+//
+// A LabelPolicy is not safe for concurrent use.
+type LabelPolicy struct {
+	// Action is LABEL_POLICY_DROP (the default) or LABEL_POLICY_RENAME.
+	Action string
+
+	whitelist filter.Filter
+	blacklist filter.Filter
+	logged    map[string]bool
+}
+
+// NewLabelPolicy returns a LabelPolicy allowing the tag keys matching the
+// whitelist glob patterns, if any, and not matching the blacklist ones. It
+// returns nil if both lists are empty.
+func NewLabelPolicy(whitelist, blacklist []string, action string) (*LabelPolicy, error) {
+	if len(whitelist) == 0 && len(blacklist) == 0 {
+		return nil, nil
+	}
+	p := &LabelPolicy{Action: action, logged: make(map[string]bool)}
+	var err error
+	if p.whitelist, err = filter.Compile(whitelist); err != nil {
+		return nil, err
+	}
+	if p.blacklist, err = filter.Compile(blacklist); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Apply returns m with the tags that are not allowed dropped or renamed. m is
+// returned as is if all of its tags are allowed.
+func (p *LabelPolicy) Apply(m telegraf.Metric) telegraf.Metric {
+	if p == nil {
+		return m
+	}
+
+	var unknown []string
+	for k := range m.Tags() {
+		if !p.allowed(k) {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) == 0 {
+		return m
+	}
+
+	action := "dropped"
+	if p.Action == LABEL_POLICY_RENAME {
+		action = "renamed"
+	}
+	tags := make(map[string]string)
+	for k, v := range m.Tags() {
+		tags[k] = v
+	}
+	for _, k := range unknown {
+		if !p.logged[k] {
+			p.logged[k] = true
+			log.Printf("W! Tag key %q of %s is not allowed by the metric "+
+				"label policy, it is %s\n", k, m.Name(), action)
+		}
+		v := tags[k]
+		delete(tags, k)
+		if p.Action == LABEL_POLICY_RENAME {
+			tags[LABEL_POLICY_PREFIX+k] = v
+		}
+	}
+
+	var out telegraf.Metric
+	var err error
+	switch m.Type() {
+	case telegraf.Gauge:
+		out, err = telegraf.NewGaugeMetric(m.Name(), tags, m.Fields(), m.Time())
+	case telegraf.Counter:
+		out, err = telegraf.NewCounterMetric(m.Name(), tags, m.Fields(), m.Time())
+	default:
+		out, err = telegraf.NewMetric(m.Name(), tags, m.Fields(), m.Time())
+	}
+	if err != nil {
+		log.Printf("E! Could not apply the label policy to %s, dropping it: "+
+			"%s\n", m.Name(), err)
+		return nil
+	}
+	return out
+}
+
+func (p *LabelPolicy) allowed(key string) bool {
+	if p.whitelist != nil && !p.whitelist.Match(key) {
+		return false
+	}
+	return p.blacklist == nil || !p.blacklist.Match(key)
+}
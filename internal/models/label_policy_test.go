@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/influxdata/telegraf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLabelPolicyDisabled(t *testing.T) {
+	p, err := NewLabelPolicy(nil, nil, LABEL_POLICY_DROP)
+	require.NoError(t, err)
+	assert.Nil(t, p)
+	m := tagMetric(t, map[string]string{"user": "a"})
+	assert.Equal(t, m, p.Apply(m))
+}
+
+func TestLabelPolicyDrop(t *testing.T) {
+	p, err := NewLabelPolicy([]string{"host", "dc*"}, []string{"dc_secret"},
+		LABEL_POLICY_DROP)
+	require.NoError(t, err)
+
+	m := tagMetric(t, map[string]string{"host": "h", "dc": "east"})
+	assert.Equal(t, m, p.Apply(m))
+
+	for i := 0; i < 2; i++ {
+		out := p.Apply(tagMetric(t, map[string]string{
+			"host": "h", "user": "a", "dc_secret": "s",
+		}))
+		require.NotNil(t, out)
+		assert.Equal(t, map[string]string{"host": "h"}, out.Tags())
+		assert.Equal(t, telegraf.Gauge, out.Type())
+	}
+	// each unknown key is logged once
+	assert.Equal(t, map[string]bool{"user": true, "dc_secret": true}, p.logged)
+}
+
+func TestLabelPolicyRename(t *testing.T) {
+	p, err := NewLabelPolicy(nil, []string{"user"}, LABEL_POLICY_RENAME)
+	require.NoError(t, err)
+
+	out := p.Apply(tagMetric(t, map[string]string{"host": "h", "user": "a"}))
+	require.NotNil(t, out)
+	assert.Equal(t, map[string]string{"host": "h", "unknown_user": "a"},
+		out.Tags())
+}
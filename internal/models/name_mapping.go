@@ -0,0 +1,61 @@
+package models
+
+import (
+	"log"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+)
+
+// NameMapping renames the measurements whose name matches the glob Pattern to
+// Name, for a single output.
+type NameMapping struct {
+	Pattern string
+	Name    string
+
+	filter filter.Filter
+}
+
+// NewNameMapping returns the mapping of the measurements matching pattern to
+// name.
+func NewNameMapping(pattern, name string) (NameMapping, error) {
+	f, err := filter.Compile([]string{pattern})
+	if err != nil {
+		return NameMapping{}, err
+	}
+	return NameMapping{Pattern: pattern, Name: name, filter: f}, nil
+}
+
+// MapName returns the metric renamed by the first NameMapping of the output
+// matching its name, or the metric itself if none does.
+func (c *OutputConfig) MapName(m telegraf.Metric) telegraf.Metric {
+	for _, mapping := range c.NameMapping {
+		if !mapping.filter.Match(m.Name()) {
+			continue
+		}
+		if mapping.Name == m.Name() {
+			return m
+		}
+
+		var out telegraf.Metric
+		var err error
+		switch m.Type() {
+		case telegraf.Gauge:
+			out, err = telegraf.NewGaugeMetric(mapping.Name, m.Tags(),
+				m.Fields(), m.Time())
+		case telegraf.Counter:
+			out, err = telegraf.NewCounterMetric(mapping.Name, m.Tags(),
+				m.Fields(), m.Time())
+		default:
+			out, err = telegraf.NewMetric(mapping.Name, m.Tags(), m.Fields(),
+				m.Time())
+		}
+		if err != nil {
+			log.Printf("E! Could not rename %s to %s for output %s: %s\n",
+				m.Name(), mapping.Name, c.Name, err)
+			return m
+		}
+		return out
+	}
+	return m
+}
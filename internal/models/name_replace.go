@@ -0,0 +1,33 @@
+package models
+
+import (
+	"regexp"
+)
+
+// NameReplace replaces the measurement names matching the regular expression
+// From with To, in which $1, $2, ... refer to the capture groups of From.
+type NameReplace struct {
+	From string
+	To   string
+
+	re *regexp.Regexp
+}
+
+// NewNameReplace returns the replacement of the names matching from by to.
+func NewNameReplace(from, to string) (NameReplace, error) {
+	re, err := regexp.Compile(from)
+	if err != nil {
+		return NameReplace{}, err
+	}
+	return NameReplace{From: from, To: to, re: re}, nil
+}
+
+// ReplaceName returns name, replaced by the first of rules matching it.
+func ReplaceName(rules []NameReplace, name string) string {
+	for _, rule := range rules {
+		if rule.re.MatchString(name) {
+			return rule.re.ReplaceAllString(name, rule.To)
+		}
+	}
+	return name
+}
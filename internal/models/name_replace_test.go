@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceName(t *testing.T) {
+	var rules []NameReplace
+	for _, r := range [][2]string{
+		{"^docker_container_(.*)$", "container_$1"},
+		{"^docker_(.*)$", "engine_$1"},
+	} {
+		rule, err := NewNameReplace(r[0], r[1])
+		require.NoError(t, err)
+		rules = append(rules, rule)
+	}
+
+	// first matching rule wins
+	assert.Equal(t, "container_cpu", ReplaceName(rules, "docker_container_cpu"))
+	assert.Equal(t, "engine_info", ReplaceName(rules, "docker_info"))
+	assert.Equal(t, "cpu", ReplaceName(rules, "cpu"))
+	assert.Equal(t, "cpu", ReplaceName(nil, "cpu"))
+}
+
+func TestNewNameReplaceInvalid(t *testing.T) {
+	_, err := NewNameReplace("docker_(", "container")
+	assert.Error(t, err)
+}
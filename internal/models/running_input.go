@@ -1,6 +1,11 @@
 package models
 
 import (
+	"bytes"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -10,6 +15,231 @@ type RunningInput struct {
 	Name   string
 	Input  telegraf.Input
 	Config *InputConfig
+
+	// ConfigHash is the hash of the config table of the input, see
+	// config.ComputePluginHash.
+	ConfigHash string
+
+	// Source is the config file the input was loaded from.
+	Source string
+
+	// DataFormatOptions are the parser options of the input, such as
+	// data_format, as "key = value" TOML lines.
+	DataFormatOptions []string
+
+	// InternalStats, if set, receives telegraf's own metrics about this input.
+	InternalStats chan telegraf.Metric
+	// GatherLatency, if set, counts the durations of the gathers, reported
+	// to InternalStats with each gather.
+	GatherLatency *LatencyHistogram
+
+	bufferOnce sync.Once
+	bufferLock sync.Mutex
+	buffer     chan telegraf.Metric
+	dropped    int64
+
+	dedupLock  sync.Mutex
+	dedup      *dedupCache
+	suppressed int64
+
+	// paused is set while the input is disabled at runtime, and
+	// serviceStopped once the service of a service input is stopped.
+	pauseLock      sync.Mutex
+	paused         bool
+	serviceStopped bool
+
+	// gathering is 1 while a gather of the input runs, see SetGathering.
+	gathering int32
+}
+
+// SetGathering records whether a gather of the input is running. A gather
+// abandoned at its collection timeout keeps running until it returns.
+func (r *RunningInput) SetGathering(gathering bool) {
+	var v int32
+	if gathering {
+		v = 1
+	}
+	atomic.StoreInt32(&r.gathering, v)
+}
+
+// Gathering reports whether a gather of the input is running.
+func (r *RunningInput) Gathering() bool {
+	return atomic.LoadInt32(&r.gathering) == 1
+}
+
+func (r *RunningInput) initBuffer() {
+	r.bufferOnce.Do(func() {
+		r.buffer = make(chan telegraf.Metric, r.Config.MetricBufferLimit)
+	})
+}
+
+// BufferMetric adds a metric to the buffer of an input with a
+// MetricBufferLimit, dropping the oldest buffered metric when the buffer is
+// full.
+func (r *RunningInput) BufferMetric(m telegraf.Metric) {
+	r.initBuffer()
+	r.bufferLock.Lock()
+	defer r.bufferLock.Unlock()
+	for {
+		select {
+		case r.buffer <- m:
+			return
+		default:
+		}
+		select {
+		case <-r.buffer:
+			dropped := atomic.AddInt64(&r.dropped, 1)
+			sendStat(r.InternalStats, "internal_input_buffer",
+				map[string]string{"input": r.Name},
+				map[string]interface{}{"dropped_total": dropped})
+		default:
+		}
+	}
+}
+
+// Dropped returns the number of metrics dropped from the buffer of the input
+// because it was full.
+func (r *RunningInput) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// Deduplicate reports whether m should be suppressed, as it repeats the last
+// metric sent for its series within Config.Dedup.Interval. It always returns
+// false for inputs without a deduplication table.
+func (r *RunningInput) Deduplicate(m telegraf.Metric) bool {
+	if r.Config.Dedup == nil {
+		return false
+	}
+	r.dedupLock.Lock()
+	defer r.dedupLock.Unlock()
+	if r.dedup == nil {
+		r.dedup = newDedupCache(r.Config.Dedup.CacheSize)
+	}
+	if !r.dedup.suppress(r.Config.Dedup, m) {
+		return false
+	}
+	r.suppressed++
+	sendStat(r.InternalStats, "telegraf_dedup",
+		map[string]string{"input": r.Name},
+		map[string]interface{}{"suppressed_total": r.suppressed})
+	return true
+}
+
+// DispatchMetrics moves the metrics of the input's buffer to metricC, until
+// shutdown is closed.
+func (r *RunningInput) DispatchMetrics(
+	shutdown chan struct{},
+	metricC chan telegraf.Metric,
+) {
+	r.initBuffer()
+	for {
+		select {
+		case <-shutdown:
+			return
+		case m := <-r.buffer:
+			select {
+			case metricC <- m:
+			case <-shutdown:
+				return
+			}
+		}
+	}
+}
+
+// RecordGather reports the duration and error count of a single gather to
+// InternalStats, if it is set, along with the gather latency histogram.
+func (r *RunningInput) RecordGather(elapsed time.Duration, errors uint64) {
+	sendStat(r.InternalStats, "internal_gather",
+		map[string]string{"input": r.Name},
+		map[string]interface{}{
+			"gather_time_ns": elapsed.Nanoseconds(),
+			"errors":         int64(errors),
+		})
+	if r.GatherLatency != nil {
+		r.GatherLatency.Observe(elapsed)
+		sendStat(r.InternalStats, "internal_gather_latency",
+			map[string]string{"input": r.Name}, r.GatherLatency.Fields())
+	}
+}
+
+// Pause disables the input at runtime: it is not gathered from until Resume
+// is called, and the service of a service input is stopped.
+func (r *RunningInput) Pause() {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	r.paused = true
+	if p, ok := r.Input.(telegraf.ServiceInput); ok && !r.serviceStopped {
+		p.Stop()
+		r.serviceStopped = true
+	}
+}
+
+// PauseUnstarted disables an input that was not started yet, such as an
+// input of a reloaded config, see Pause. Its service is started by
+// RestartService once it is resumed.
+func (r *RunningInput) PauseUnstarted() {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	r.paused = true
+	r.serviceStopped = true
+}
+
+// Resume enables the input again after Pause. The service of a service input
+// is started again by RestartService.
+func (r *RunningInput) Resume() {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	r.paused = false
+}
+
+// Paused reports whether the input is disabled at runtime.
+func (r *RunningInput) Paused() bool {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	return r.paused
+}
+
+// RestartService starts the service of a resumed service input with start,
+// if Pause stopped it.
+func (r *RunningInput) RestartService(start func() error) error {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	if r.paused || !r.serviceStopped {
+		return nil
+	}
+	if err := start(); err != nil {
+		return err
+	}
+	r.serviceStopped = false
+	return nil
+}
+
+// StartService starts the service of a service input with start. A service
+// stopped by StopService, when the agent running it stopped, can be started
+// again by the next agent. The service of a paused input is started by
+// RestartService once it is resumed.
+func (r *RunningInput) StartService(start func() error) error {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	if r.paused {
+		return nil
+	}
+	if err := start(); err != nil {
+		return err
+	}
+	r.serviceStopped = false
+	return nil
+}
+
+// StopService stops the service of a service input, unless it is already
+// stopped by Pause.
+func (r *RunningInput) StopService() {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	if p, ok := r.Input.(telegraf.ServiceInput); ok && !r.serviceStopped {
+		p.Stop()
+		r.serviceStopped = true
+	}
 }
 
 // InputConfig containing a name, interval, and filter
@@ -21,4 +251,118 @@ type InputConfig struct {
 	Tags              map[string]string
 	Filter            Filter
 	Interval          time.Duration
+
+	// TagsFromEnv are the tags_from_env entries of the input, their values
+	// are added to Tags when the config is loaded.
+	TagsFromEnv []string
+
+	// FieldRename renames the fields of the metrics gathered, before they
+	// are filtered.
+	FieldRename []FieldRename
+
+	// CollectionTimeout, when nonzero, is how long a gather may take before
+	// it is abandoned.
+	CollectionTimeout time.Duration
+
+	// Disabled is set when the input is configured with "enabled = false".
+	Disabled bool
+
+	// SamplingRate is the probability, greater than 0 and at most 1, for
+	// each metric of this input to be kept. 0, when unset, and 1 both disable
+	// sampling.
+	SamplingRate float64
+	// SamplingSeed seeds the sampling random source, for reproducible
+	// sampling. 0 means a time-based seed.
+	SamplingSeed int64
+
+	// MetricBufferLimit, when nonzero, is the size of a buffer between the
+	// input and the metric channel shared by all inputs. The oldest metrics
+	// are dropped when it is full.
+	MetricBufferLimit int
+
+	// NameTemplate is a text/template evaluated for every metric to build its
+	// measurement name. It takes precedence over NameOverride,
+	// MeasurementPrefix and MeasurementSuffix.
+	NameTemplate string
+
+	// Dedup, if set, suppresses the metrics repeating the last metric of
+	// their series, see RunningInput.Deduplicate.
+	Dedup *DedupConfig
+
+	// StartupRetryInterval, when nonzero, is how often the service of a
+	// service input that failed to start is retried, whatever the agent
+	// startup_error_behavior. StartupRetryMax limits the number of retries,
+	// 0 means no limit.
+	StartupRetryInterval time.Duration
+	StartupRetryMax      int
+
+	samplerLock  sync.Mutex
+	sampler      *rand.Rand
+	nameTemplate *template.Template
+}
+
+// nameTemplateContext is the data given to the name_template of an input.
+type nameTemplateContext struct {
+	PluginName  string
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+}
+
+// CompileNameTemplate parses NameTemplate, it must be called before
+// MetricName when NameTemplate is set. A tag or field missing from a metric
+// is an error of MetricName, rather than rendered as "<no value>".
+func (c *InputConfig) CompileNameTemplate() error {
+	if c.NameTemplate == "" {
+		c.nameTemplate = nil
+		return nil
+	}
+	tmpl, err := template.New(c.Name).Option("missingkey=error").
+		Parse(c.NameTemplate)
+	if err != nil {
+		return err
+	}
+	c.nameTemplate = tmpl
+	return nil
+}
+
+// MetricName returns the measurement name built from the name template, or
+// an empty string if there is no compiled name template.
+func (c *InputConfig) MetricName(
+	measurement string,
+	tags map[string]string,
+	fields map[string]interface{},
+) (string, error) {
+	if c.nameTemplate == nil {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	err := c.nameTemplate.Execute(&buf, nameTemplateContext{
+		PluginName:  c.Name,
+		Measurement: measurement,
+		Tags:        tags,
+		Fields:      fields,
+	})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Sample reports whether a metric should be kept according to SamplingRate.
+func (c *InputConfig) Sample() bool {
+	if c.SamplingRate <= 0 || c.SamplingRate >= 1 {
+		return true
+	}
+
+	c.samplerLock.Lock()
+	defer c.samplerLock.Unlock()
+	if c.sampler == nil {
+		seed := c.SamplingSeed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		c.sampler = rand.New(rand.NewSource(seed))
+	}
+	return c.sampler.Float64() < c.SamplingRate
 }
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -14,6 +16,10 @@ const (
 
 	// Default number of metrics kept. It should be a multiple of batch size.
 	DEFAULT_METRIC_BUFFER_LIMIT = 10000
+
+	// Metric overflow strategies, for when the metric buffer is full.
+	OVERFLOW_DROP_OLDEST = "drop_oldest"
+	OVERFLOW_DROP_NEWEST = "drop_newest"
 )
 
 // RunningOutput contains the output configuration
@@ -25,8 +31,51 @@ type RunningOutput struct {
 	MetricBufferLimit int
 	MetricBatchSize   int
 
+	// InternalStats, if set, receives telegraf's own metrics about this output.
+	InternalStats chan telegraf.Metric
+	// WriteLatency, if set, counts the durations of the batch writes,
+	// reported to InternalStats with each write.
+	WriteLatency *LatencyHistogram
+
+	// ConfigHash is the hash of the config table of the output, see
+	// config.ComputePluginHash.
+	ConfigHash string
+
+	// Source is the config file the output was loaded from.
+	Source string
+
+	// DataFormatOptions are the serializer options of the output, such as
+	// data_format, as "key = value" TOML lines.
+	DataFormatOptions []string
+
 	metrics     *buffer.Buffer
 	failMetrics *buffer.Buffer
+
+	writeErrors int64
+
+	// failover receives the metrics that fail to be written once
+	// Config.FailoverThreshold writes in a row failed.
+	failover            *RunningOutput
+	consecutiveFailures int
+	failedOver          bool
+
+	// standby is set on the outputs other outputs fail over to, they only
+	// receive the metrics handed over to them, see SetFailover.
+	standby bool
+
+	// lock serializes the use of the buffers, which a failing over output
+	// adds metrics to.
+	lock sync.Mutex
+
+	// connect, if set, is retried every connectRetry before writing, until
+	// it succeeds.
+	connect        func() error
+	connectRetry   time.Duration
+	lastConnect    time.Time
+	connectRetries int
+
+	// paused is set while the output is disabled at runtime.
+	paused bool
 }
 
 func NewRunningOutput(
@@ -54,9 +103,148 @@ func NewRunningOutput(
 	return ro
 }
 
+// SetOverflowStrategy sets which metrics are dropped when the metric buffer is
+// full, either OVERFLOW_DROP_OLDEST (the default) or OVERFLOW_DROP_NEWEST.
+func (ro *RunningOutput) SetOverflowStrategy(strategy string) {
+	ro.failMetrics.SetDropNewest(strategy == OVERFLOW_DROP_NEWEST)
+}
+
+// SetFailover sets the output receiving the metrics of this output while it
+// fails to write, see OutputConfig.FailoverTo. The failover output is put on
+// standby.
+func (ro *RunningOutput) SetFailover(failover *RunningOutput) {
+	ro.lock.Lock()
+	ro.failover = failover
+	ro.lock.Unlock()
+	if failover != nil {
+		failover.SetStandby(true)
+	}
+}
+
+// SetStandby sets whether the output is on standby. A standby output is not
+// sent the metrics of the inputs, only those of the outputs failing over to
+// it, but it is written to at every flush like other outputs.
+func (ro *RunningOutput) SetStandby(standby bool) {
+	ro.lock.Lock()
+	defer ro.lock.Unlock()
+	ro.standby = standby
+}
+
+// Standby reports whether the output is on standby, see SetStandby.
+func (ro *RunningOutput) Standby() bool {
+	ro.lock.Lock()
+	defer ro.lock.Unlock()
+	return ro.standby
+}
+
+// FailedOver reports whether the metrics of the output are currently sent to
+// its failover output.
+func (ro *RunningOutput) FailedOver() bool {
+	ro.lock.Lock()
+	defer ro.lock.Unlock()
+	return ro.failedOver
+}
+
+// keepFailed keeps a batch that failed to be written to retry it later, or
+// gives it to the failover output once too many writes failed in a row.
+func (ro *RunningOutput) keepFailed(batch []telegraf.Metric) {
+	if ro.failover == nil || ro.consecutiveFailures < ro.Config.FailoverThreshold {
+		ro.failMetrics.Add(batch...)
+		return
+	}
+
+	if !ro.failedOver {
+		ro.failedOver = true
+		log.Printf("W! Output [%s] failed to write %d times in a row, failing "+
+			"over to output [%s]\n", ro.Name, ro.consecutiveFailures,
+			ro.failover.Name)
+		batch = append(ro.failMetrics.Batch(ro.failMetrics.Len()), batch...)
+	}
+	for _, metric := range batch {
+		ro.failover.AddMetric(metric)
+	}
+}
+
+// Pause disables the output at runtime, and stops it with stop, which closes
+// it. stop is called once a write in progress returns, and is not called if
+// the output is already paused, in which case Pause returns false. Metrics
+// added to a disabled output are dropped, and it is not written to.
+func (ro *RunningOutput) Pause(stop func()) bool {
+	ro.lock.Lock()
+	defer ro.lock.Unlock()
+	if ro.paused {
+		return false
+	}
+	ro.paused = true
+	stop()
+	return true
+}
+
+// Resume enables an output disabled by Pause again, once start, which starts
+// and connects it, succeeds. The output is not written to while start runs.
+func (ro *RunningOutput) Resume(start func() error) error {
+	ro.lock.Lock()
+	defer ro.lock.Unlock()
+	if !ro.paused {
+		return nil
+	}
+	if err := start(); err != nil {
+		return err
+	}
+	ro.paused = false
+	return nil
+}
+
+// Paused reports whether the output is disabled at runtime.
+func (ro *RunningOutput) Paused() bool {
+	ro.lock.Lock()
+	defer ro.lock.Unlock()
+	return ro.paused
+}
+
+// SetConnectRetry marks the output as not started. connect is called before
+// writing, at most once every interval, until it succeeds or has been retried
+// Config.StartupRetryMax times. Writes fail, and metrics stay buffered, until
+// then.
+func (ro *RunningOutput) SetConnectRetry(interval time.Duration, connect func() error) {
+	ro.connect = connect
+	ro.connectRetry = interval
+	ro.lastConnect = time.Now()
+}
+
+// ensureConnected retries to connect the output if it is not started.
+func (ro *RunningOutput) ensureConnected() error {
+	if ro.connect == nil {
+		return nil
+	}
+	max := ro.Config.StartupRetryMax
+	if time.Since(ro.lastConnect) < ro.connectRetry ||
+		(max > 0 && ro.connectRetries >= max) {
+		return fmt.Errorf("output %s is not connected", ro.Name)
+	}
+	ro.lastConnect = time.Now()
+	ro.connectRetries++
+	if err := ro.connect(); err != nil {
+		if max > 0 && ro.connectRetries >= max {
+			log.Printf("E! Output [%s] failed to start after %d retries, "+
+				"giving up\n", ro.Name, max)
+		}
+		return fmt.Errorf("could not connect output %s: %s", ro.Name, err)
+	}
+	log.Printf("I! Output [%s] connected\n", ro.Name)
+	ro.connect = nil
+	return nil
+}
+
 // AddMetric adds a metric to the output. This function can also write cached
 // points if FlushBufferWhenFull is true.
 func (ro *RunningOutput) AddMetric(metric telegraf.Metric) {
+	ro.lock.Lock()
+	defer ro.lock.Unlock()
+	if ro.paused {
+		return
+	}
+
 	// Filter any tagexclude/taginclude parameters before adding metric
 	if ro.Config.Filter.IsActive() {
 		// In order to filter out tags, we need to create a new metric, since
@@ -71,19 +259,26 @@ func (ro *RunningOutput) AddMetric(metric telegraf.Metric) {
 		// error is not possible if creating from another metric, so ignore.
 		metric, _ = telegraf.NewMetric(name, tags, fields, t)
 	}
+	metric = ro.Config.MapName(metric)
 
 	ro.metrics.Add(metric)
 	if ro.metrics.Len() == ro.MetricBatchSize {
 		batch := ro.metrics.Batch(ro.MetricBatchSize)
 		err := ro.write(batch)
 		if err != nil {
-			ro.failMetrics.Add(batch...)
+			ro.keepFailed(batch)
 		}
 	}
 }
 
 // Write writes all cached points to this output.
 func (ro *RunningOutput) Write() error {
+	ro.lock.Lock()
+	defer ro.lock.Unlock()
+	if ro.paused {
+		return nil
+	}
+
 	if !ro.Quiet {
 		log.Printf("I! Output [%s] buffer fullness: %d / %d metrics. "+
 			"Total gathered metrics: %d. Total dropped metrics: %d.",
@@ -115,7 +310,7 @@ func (ro *RunningOutput) Write() error {
 				err = ro.write(batch)
 			}
 			if err != nil {
-				ro.failMetrics.Add(batch...)
+				ro.keepFailed(batch)
 			}
 		}
 	}
@@ -127,7 +322,7 @@ func (ro *RunningOutput) Write() error {
 		err = ro.write(batch)
 	}
 	if err != nil {
-		ro.failMetrics.Add(batch...)
+		ro.keepFailed(batch)
 		return err
 	}
 	return nil
@@ -137,20 +332,82 @@ func (ro *RunningOutput) write(metrics []telegraf.Metric) error {
 	if metrics == nil || len(metrics) == 0 {
 		return nil
 	}
+	if err := ro.ensureConnected(); err != nil {
+		ro.writeErrors++
+		ro.consecutiveFailures++
+		ro.recordWrite(len(metrics), 0, err)
+		return err
+	}
 	start := time.Now()
 	err := ro.Output.Write(metrics)
 	elapsed := time.Since(start)
+	if ro.WriteLatency != nil {
+		ro.WriteLatency.Observe(elapsed)
+	}
 	if err == nil {
 		if !ro.Quiet {
 			log.Printf("I! Output [%s] wrote batch of %d metrics in %s\n",
 				ro.Name, len(metrics), elapsed)
 		}
+		if ro.failedOver {
+			log.Printf("I! Output [%s] recovered, no longer failing over to "+
+				"output [%s]\n", ro.Name, ro.failover.Name)
+		}
+		ro.consecutiveFailures = 0
+		ro.failedOver = false
+	} else {
+		ro.writeErrors++
+		ro.consecutiveFailures++
 	}
+	ro.recordWrite(len(metrics), elapsed, err)
 	return err
 }
 
+// recordWrite reports the outcome of a single batch write to InternalStats,
+// if it is set, along with the write latency histogram.
+func (ro *RunningOutput) recordWrite(n int, elapsed time.Duration, err error) {
+	written := int64(n)
+	if err != nil {
+		written = 0
+	}
+	sendStat(ro.InternalStats, "internal_write",
+		map[string]string{"output": ro.Name},
+		map[string]interface{}{
+			"buffer_size":     int64(ro.failMetrics.Len() + ro.metrics.Len()),
+			"buffer_limit":    int64(ro.MetricBufferLimit),
+			"metrics_written": written,
+			"metrics_dropped": int64(ro.metrics.Drops() + ro.failMetrics.Drops()),
+			"write_errors":    ro.writeErrors,
+			"write_time_ns":   elapsed.Nanoseconds(),
+		})
+	if ro.WriteLatency != nil {
+		sendStat(ro.InternalStats, "internal_write_latency",
+			map[string]string{"output": ro.Name}, ro.WriteLatency.Fields())
+	}
+}
+
 // OutputConfig containing name and filter
 type OutputConfig struct {
 	Name   string
 	Filter Filter
+
+	// Disabled is set when the output is configured with "enabled = false".
+	Disabled bool
+
+	// Alias names the output, so that other outputs can refer to it.
+	Alias string
+	// FailoverTo is the alias of the output that receives the metrics of
+	// this output after FailoverThreshold consecutive write failures.
+	FailoverTo        string
+	FailoverThreshold int
+
+	// StartupRetryInterval, when nonzero, is how often an output that failed
+	// to start is retried, whatever the agent startup_error_behavior.
+	// StartupRetryMax limits the number of retries, 0 means no limit.
+	StartupRetryInterval time.Duration
+	StartupRetryMax      int
+
+	// NameMapping renames the measurements written to this output, with the
+	// first mapping matching the measurement name.
+	NameMapping []NameMapping
 }
@@ -216,6 +216,29 @@ func TestRunningOutput_TagIncludeMatch(t *testing.T) {
 	assert.Len(t, m.Metrics()[0].Tags(), 1)
 }
 
+func TestRunningOutput_NameMapping(t *testing.T) {
+	mapping, err := NewNameMapping("metric[12]", "renamed")
+	require.NoError(t, err)
+	conf := &OutputConfig{
+		Filter: Filter{
+			NamePass: []string{"metric1", "metric3"},
+		},
+		NameMapping: []NameMapping{mapping},
+	}
+	assert.NoError(t, conf.Filter.Compile())
+
+	m := &mockOutput{}
+	ro := NewRunningOutput("test", m, conf, 1000, 10000)
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	assert.NoError(t, ro.Write())
+	require.Len(t, m.Metrics(), 2)
+	assert.Equal(t, "renamed", m.Metrics()[0].Name())
+	assert.Equal(t, "metric3", m.Metrics()[1].Name())
+	assert.Equal(t, first5[0].Fields(), m.Metrics()[0].Fields())
+}
+
 // Test that we can write metrics with simple default setup.
 func TestRunningOutputDefault(t *testing.T) {
 	conf := &OutputConfig{
@@ -469,6 +492,162 @@ func TestRunningOutputWriteFailOrder3(t *testing.T) {
 	assert.Equal(t, expected, m.Metrics())
 }
 
+// Verify that write statistics are reported on InternalStats, including
+// failed writes.
+func TestRunningOutputInternalStats(t *testing.T) {
+	conf := &OutputConfig{
+		Filter: Filter{},
+	}
+
+	m := &mockOutput{}
+	m.failWrite = true
+	ro := NewRunningOutput("test", m, conf, 1000, 10000)
+	ro.InternalStats = make(chan telegraf.Metric, 10)
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	require.Error(t, ro.Write())
+	require.Len(t, ro.InternalStats, 1)
+	stat := <-ro.InternalStats
+	assert.Equal(t, "internal_write", stat.Name())
+	assert.Equal(t, map[string]string{"output": "test"}, stat.Tags())
+	assert.Equal(t, int64(0), stat.Fields()["metrics_written"])
+	assert.Equal(t, int64(1), stat.Fields()["write_errors"])
+
+	m.failWrite = false
+	require.NoError(t, ro.Write())
+	require.Len(t, ro.InternalStats, 1)
+	stat = <-ro.InternalStats
+	assert.Equal(t, int64(5), stat.Fields()["metrics_written"])
+	assert.Equal(t, int64(1), stat.Fields()["write_errors"])
+}
+
+func TestRunningOutputConnectRetry(t *testing.T) {
+	conf := &OutputConfig{
+		Filter: Filter{},
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput("test", m, conf, 1000, 10000)
+	connectErr := fmt.Errorf("connection refused")
+	connects := 0
+	ro.SetConnectRetry(0, func() error {
+		connects++
+		return connectErr
+	})
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	// writes fail until the output connects, and the metrics stay buffered
+	require.Error(t, ro.Write())
+	assert.Equal(t, 1, connects)
+	assert.Len(t, m.Metrics(), 0)
+
+	connectErr = nil
+	require.NoError(t, ro.Write())
+	assert.Equal(t, 2, connects)
+	assert.Len(t, m.Metrics(), 5)
+
+	// once connected, connect is not called again
+	for _, metric := range next5 {
+		ro.AddMetric(metric)
+	}
+	require.NoError(t, ro.Write())
+	assert.Equal(t, 2, connects)
+	assert.Len(t, m.Metrics(), 10)
+}
+
+func TestRunningOutputConnectRetryMax(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:          Filter{},
+		StartupRetryMax: 2,
+	}
+
+	m := &mockOutput{}
+	ro := NewRunningOutput("test", m, conf, 1000, 10000)
+	connects := 0
+	ro.SetConnectRetry(0, func() error {
+		connects++
+		return fmt.Errorf("connection refused")
+	})
+
+	for _, metric := range first5 {
+		ro.AddMetric(metric)
+	}
+	for i := 0; i < 4; i++ {
+		require.Error(t, ro.Write())
+	}
+	// the output gave up after 2 retries
+	assert.Equal(t, 2, connects)
+	assert.Len(t, m.Metrics(), 0)
+}
+
+func TestRunningOutputFailover(t *testing.T) {
+	conf := &OutputConfig{
+		Filter:            Filter{},
+		FailoverThreshold: 2,
+	}
+	m := &mockOutput{failWrite: true}
+	ro := NewRunningOutput("primary", m, conf, 1000, 10000)
+
+	fm := &mockOutput{}
+	failover := NewRunningOutput("secondary", fm, &OutputConfig{}, 1000, 10000)
+	ro.SetFailover(failover)
+	assert.True(t, failover.Standby())
+	assert.False(t, ro.Standby())
+
+	// the first failure is kept for the primary output
+	ro.AddMetric(first5[0])
+	require.Error(t, ro.Write())
+	assert.False(t, ro.FailedOver())
+	require.NoError(t, failover.Write())
+	assert.Len(t, fm.Metrics(), 0)
+
+	// the second failure in a row fails over, along with the kept metrics
+	ro.AddMetric(first5[1])
+	require.Error(t, ro.Write())
+	assert.True(t, ro.FailedOver())
+	require.NoError(t, failover.Write())
+	assert.Len(t, fm.Metrics(), 2)
+
+	// a successful write to the primary output reverts the failover
+	m.failWrite = false
+	ro.AddMetric(first5[2])
+	require.NoError(t, ro.Write())
+	assert.False(t, ro.FailedOver())
+	assert.Len(t, m.Metrics(), 1)
+	require.NoError(t, failover.Write())
+	assert.Len(t, fm.Metrics(), 2)
+}
+
+func TestRunningOutputPause(t *testing.T) {
+	m := &mockOutput{}
+	ro := NewRunningOutput("test", m, &OutputConfig{}, 1000, 10000)
+
+	stopped := 0
+	assert.True(t, ro.Pause(func() { stopped++ }))
+	assert.False(t, ro.Pause(func() { stopped++ }))
+	assert.Equal(t, 1, stopped)
+	assert.True(t, ro.Paused())
+
+	// metrics added to a paused output are dropped
+	ro.AddMetric(first5[0])
+	require.NoError(t, ro.Write())
+	assert.Len(t, m.Metrics(), 0)
+
+	// the output stays paused when it fails to start
+	require.Error(t, ro.Resume(func() error { return fmt.Errorf("no") }))
+	assert.True(t, ro.Paused())
+
+	require.NoError(t, ro.Resume(func() error { return nil }))
+	assert.False(t, ro.Paused())
+	ro.AddMetric(first5[1])
+	require.NoError(t, ro.Write())
+	assert.Len(t, m.Metrics(), 1)
+}
+
 type mockOutput struct {
 	sync.Mutex
 
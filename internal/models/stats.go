@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// sendStat creates an internal metric and sends it on the given channel.
+// The send never blocks: if the channel is full the metric is discarded, as
+// internal instrumentation must not slow down the plugins it is measuring.
+func sendStat(
+	c chan telegraf.Metric,
+	measurement string,
+	tags map[string]string,
+	fields map[string]interface{},
+) {
+	if c == nil {
+		return
+	}
+	m, err := telegraf.NewMetric(measurement, tags, fields, time.Now())
+	if err != nil {
+		return
+	}
+	select {
+	case c <- m:
+	default:
+	}
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"log"
+
+	"github.com/influxdata/telegraf"
+)
+
+const (
+	// Tag cardinality limit actions, for when a tag exceeds a TagLimiter
+	// limit.
+	TAG_LIMIT_REPLACE = "replace"
+	TAG_LIMIT_DROP    = "drop"
+
+	// TAG_LIMIT_EXCEEDED replaces the tag values over the limits.
+	TAG_LIMIT_EXCEEDED = "_exceeded"
+)
+
+// TagLimiter limits the tag cardinality of the metrics sent to the outputs:
+// the number of tag keys, and the number of values of each key. The first
+// keys and values seen are kept, a tag over a limit has its value replaced
+// with TAG_LIMIT_EXCEEDED, or its metric dropped, depending on Action.
+//
+// A TagLimiter is not safe for concurrent use.
+type TagLimiter struct {
+	// MaxValuesPerKey and MaxKeys are the limits, 0 disables a limit.
+	MaxValuesPerKey int
+	MaxKeys         int
+	// Action is TAG_LIMIT_REPLACE (the default) or TAG_LIMIT_DROP.
+	Action string
+
+	// InternalStats, if set, receives the count of exceeded tags, by key.
+	InternalStats chan telegraf.Metric
+
+	values   map[string]map[string]bool
+	exceeded map[string]int64
+}
+
+// NewTagLimiter returns a TagLimiter, or nil if both limits are disabled.
+func NewTagLimiter(maxValuesPerKey, maxKeys int, action string) *TagLimiter {
+	if maxValuesPerKey <= 0 && maxKeys <= 0 {
+		return nil
+	}
+	return &TagLimiter{
+		MaxValuesPerKey: maxValuesPerKey,
+		MaxKeys:         maxKeys,
+		Action:          action,
+		values:          make(map[string]map[string]bool),
+		exceeded:        make(map[string]int64),
+	}
+}
+
+// Apply returns m with the tags over the limits replaced, or nil if m is
+// dropped. m is returned as is if none of its tags are over the limits.
+func (t *TagLimiter) Apply(m telegraf.Metric) telegraf.Metric {
+	if t == nil {
+		return m
+	}
+
+	var over []string
+	for k, v := range m.Tags() {
+		if !t.admit(k, v) {
+			over = append(over, k)
+		}
+	}
+	if len(over) == 0 {
+		return m
+	}
+	for _, k := range over {
+		t.exceeded[k]++
+		sendStat(t.InternalStats, "telegraf_tag_cardinality",
+			map[string]string{"key": k},
+			map[string]interface{}{"exceeded_total": t.exceeded[k]})
+	}
+
+	if t.Action == TAG_LIMIT_DROP {
+		return nil
+	}
+	tags := make(map[string]string)
+	for k, v := range m.Tags() {
+		tags[k] = v
+	}
+	for _, k := range over {
+		tags[k] = TAG_LIMIT_EXCEEDED
+	}
+
+	var out telegraf.Metric
+	var err error
+	switch m.Type() {
+	case telegraf.Gauge:
+		out, err = telegraf.NewGaugeMetric(m.Name(), tags, m.Fields(), m.Time())
+	case telegraf.Counter:
+		out, err = telegraf.NewCounterMetric(m.Name(), tags, m.Fields(), m.Time())
+	default:
+		out, err = telegraf.NewMetric(m.Name(), tags, m.Fields(), m.Time())
+	}
+	if err != nil {
+		log.Printf("E! Could not limit the tags of %s, dropping it: %s\n",
+			m.Name(), err)
+		return nil
+	}
+	return out
+}
+
+// admit records the value v of tag key k, and returns false if k or v are
+// over a limit.
+func (t *TagLimiter) admit(k, v string) bool {
+	values, ok := t.values[k]
+	if !ok {
+		if t.MaxKeys > 0 && len(t.values) >= t.MaxKeys {
+			return false
+		}
+		values = make(map[string]bool)
+		t.values[k] = values
+	}
+	if values[v] {
+		return true
+	}
+	if t.MaxValuesPerKey > 0 && len(values) >= t.MaxValuesPerKey {
+		return false
+	}
+	values[v] = true
+	return true
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tagMetric(t *testing.T, tags map[string]string) telegraf.Metric {
+	m, err := telegraf.NewGaugeMetric("requests", tags,
+		map[string]interface{}{"value": int64(1)}, time.Now())
+	require.NoError(t, err)
+	return m
+}
+
+func TestTagLimiterDisabled(t *testing.T) {
+	tl := NewTagLimiter(0, 0, TAG_LIMIT_REPLACE)
+	assert.Nil(t, tl)
+	m := tagMetric(t, map[string]string{"user": "a"})
+	assert.Equal(t, m, tl.Apply(m))
+}
+
+func TestTagLimiterReplace(t *testing.T) {
+	tl := NewTagLimiter(2, 0, TAG_LIMIT_REPLACE)
+	stats := make(chan telegraf.Metric, 10)
+	tl.InternalStats = stats
+
+	for _, user := range []string{"a", "b", "a"} {
+		m := tagMetric(t, map[string]string{"user": user, "host": "h"})
+		assert.Equal(t, m, tl.Apply(m))
+	}
+
+	out := tl.Apply(tagMetric(t, map[string]string{"user": "c", "host": "h"}))
+	require.NotNil(t, out)
+	assert.Equal(t, map[string]string{"user": "_exceeded", "host": "h"},
+		out.Tags())
+	assert.Equal(t, telegraf.Gauge, out.Type())
+
+	stat := <-stats
+	assert.Equal(t, "telegraf_tag_cardinality", stat.Name())
+	assert.Equal(t, map[string]string{"key": "user"}, stat.Tags())
+	assert.Equal(t, map[string]interface{}{"exceeded_total": int64(1)},
+		stat.Fields())
+}
+
+func TestTagLimiterDropKeys(t *testing.T) {
+	tl := NewTagLimiter(0, 1, TAG_LIMIT_DROP)
+
+	m := tagMetric(t, map[string]string{"host": "a"})
+	assert.Equal(t, m, tl.Apply(m))
+	m = tagMetric(t, map[string]string{"host": "b"})
+	assert.Equal(t, m, tl.Apply(m))
+
+	assert.Nil(t, tl.Apply(tagMetric(t,
+		map[string]string{"host": "a", "trace_id": "1"})))
+}
@@ -0,0 +1,423 @@
+// Package msgpack encodes and decodes the subset of MessagePack used by the
+// msgpack data format: nil, booleans, integers, floats, strings, binary,
+// arrays, maps and the timestamp extension type.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// timestampExt is the extension type of MessagePack timestamps.
+const timestampExt = -1
+
+// Marshal returns the MessagePack encoding of v. Maps are encoded with their
+// keys sorted, so that the encoding of a value is always the same.
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch t := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if t {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case int:
+		encodeInt(buf, int64(t))
+	case int8:
+		encodeInt(buf, int64(t))
+	case int16:
+		encodeInt(buf, int64(t))
+	case int32:
+		encodeInt(buf, int64(t))
+	case int64:
+		encodeInt(buf, t)
+	case uint:
+		encodeUint(buf, uint64(t))
+	case uint8:
+		encodeUint(buf, uint64(t))
+	case uint16:
+		encodeUint(buf, uint64(t))
+	case uint32:
+		encodeUint(buf, uint64(t))
+	case uint64:
+		encodeUint(buf, t)
+	case float32:
+		buf.WriteByte(0xca)
+		writeBig(buf, math.Float32bits(t))
+	case float64:
+		buf.WriteByte(0xcb)
+		writeBig(buf, math.Float64bits(t))
+	case string:
+		encodeHeader(buf, len(t), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		buf.WriteString(t)
+	case []byte:
+		encodeHeader(buf, len(t), 0, -1, 0xc4, 0xc5, 0xc6)
+		buf.Write(t)
+	case time.Time:
+		encodeTime(buf, t)
+	case []interface{}:
+		encodeHeader(buf, len(t), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range t {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]string:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[k] = v
+		}
+		return encode(buf, m)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		encodeHeader(buf, len(t), 0x80, 15, 0, 0xde, 0xdf)
+		for _, k := range keys {
+			encode(buf, k)
+			if err := encode(buf, t[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot encode type %T", v)
+	}
+	return nil
+}
+
+// encodeHeader writes the type and length of a string, binary, array or
+// map. fix is the fixed size type used up to fixMax items, and code8, code16
+// and code32 the types with an 8, 16 and 32 bit length. A zero code is not
+// available for the type.
+func encodeHeader(buf *bytes.Buffer, n int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case n <= fixMax:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint8 && code8 != 0:
+		buf.WriteByte(code8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		writeBig(buf, uint16(n))
+	default:
+		buf.WriteByte(code32)
+		writeBig(buf, uint32(n))
+	}
+}
+
+func encodeInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0:
+		encodeUint(buf, uint64(i))
+	case i >= -32:
+		buf.WriteByte(byte(i))
+	case i >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(i))
+	case i >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		writeBig(buf, int16(i))
+	case i >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		writeBig(buf, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		writeBig(buf, i)
+	}
+}
+
+func encodeUint(buf *bytes.Buffer, u uint64) {
+	switch {
+	case u <= 0x7f:
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(u))
+	case u <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		writeBig(buf, uint16(u))
+	case u <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		writeBig(buf, uint32(u))
+	default:
+		buf.WriteByte(0xcf)
+		writeBig(buf, u)
+	}
+}
+
+// encodeTime writes t as a timestamp extension, in the smallest of the 32, 64
+// and 96 bit formats able to hold it.
+func encodeTime(buf *bytes.Buffer, t time.Time) {
+	sec := t.Unix()
+	nsec := uint32(t.Nanosecond())
+	switch {
+	case sec>>34 == 0 && nsec == 0:
+		buf.Write([]byte{0xd6, 0xff})
+		writeBig(buf, uint32(sec))
+	case sec>>34 == 0:
+		buf.Write([]byte{0xd7, 0xff})
+		writeBig(buf, uint64(nsec)<<34|uint64(sec))
+	default:
+		buf.Write([]byte{0xc7, 12, 0xff})
+		writeBig(buf, nsec)
+		writeBig(buf, sec)
+	}
+}
+
+func writeBig(buf *bytes.Buffer, v interface{}) {
+	binary.Write(buf, binary.BigEndian, v)
+}
+
+// Decoder reads MessagePack values from a byte slice. Maps are decoded to
+// map[string]interface{}, arrays to []interface{}, integers to int64, or
+// uint64 when they do not fit, floats to float64, binary to []byte and
+// timestamps to time.Time.
+type Decoder struct {
+	buf []byte
+	pos int
+}
+
+func NewDecoder(buf []byte) *Decoder {
+	return &Decoder{buf: buf}
+}
+
+// More reports whether there are values left to decode.
+func (d *Decoder) More() bool {
+	return d.pos < len(d.buf)
+}
+
+var errShort = errors.New("msgpack: unexpected end of data")
+
+// maxPrealloc caps the number of array items or map entries allocated ahead
+// of decoding them, as the sizes come from the data being decoded.
+const maxPrealloc = 1024
+
+// Decode returns the next value, or io.EOF once all values were decoded.
+func (d *Decoder) Decode() (interface{}, error) {
+	if !d.More() {
+		return nil, io.EOF
+	}
+	code := d.buf[d.pos]
+	d.pos++
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xf0 == 0x80:
+		return d.decodeMap(int(code & 0x0f))
+	case code&0xf0 == 0x90:
+		return d.decodeArray(int(code & 0x0f))
+	case code&0xe0 == 0xa0:
+		return d.decodeString(int(code & 0x1f))
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.readLength(code - 0xc4)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.readLength(code - 0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(n)
+	case 0xca:
+		b, err := d.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 0xcb:
+		b, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.readUint(1 << (code - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (code - 0xd0)
+		u, err := d.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		// sign extend from the size of the value
+		shift := uint(64 - 8*size)
+		return int64(u<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (code - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.readLength(code - 0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(n)
+	case 0xdc, 0xdd:
+		n, err := d.readLength(code - 0xdc + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n)
+	case 0xde, 0xdf:
+		n, err := d.readLength(code - 0xde + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n)
+	}
+	return nil, fmt.Errorf("msgpack: invalid type 0x%02x at offset %d",
+		code, d.pos-1)
+}
+
+func (d *Decoder) read(n int) ([]byte, error) {
+	if n < 0 || len(d.buf)-d.pos < n {
+		return nil, errShort
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *Decoder) readUint(size int) (uint64, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+// readLength reads a length of 8, 16 or 32 bits, for sizeCode 0, 1 or 2.
+func (d *Decoder) readLength(sizeCode byte) (int, error) {
+	u, err := d.readUint(1 << sizeCode)
+	return int(u), err
+}
+
+func (d *Decoder) decodeString(n int) (interface{}, error) {
+	b, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *Decoder) decodeArray(n int) (interface{}, error) {
+	// every item takes at least one byte
+	if n < 0 || n > len(d.buf)-d.pos {
+		return nil, errShort
+	}
+	array := make([]interface{}, 0, preallocSize(n))
+	for i := 0; i < n; i++ {
+		item, err := d.Decode()
+		if err == io.EOF {
+			return nil, errShort
+		}
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, item)
+	}
+	return array, nil
+}
+
+func (d *Decoder) decodeMap(n int) (interface{}, error) {
+	// every entry takes at least one byte for its key and one for its value
+	if n < 0 || n > (len(d.buf)-d.pos)/2 {
+		return nil, errShort
+	}
+	m := make(map[string]interface{}, preallocSize(n))
+	for i := 0; i < n; i++ {
+		key, err := d.Decode()
+		if err == nil {
+			var value interface{}
+			value, err = d.Decode()
+			if str, ok := key.(string); ok {
+				m[str] = value
+			} else {
+				m[fmt.Sprint(key)] = value
+			}
+		}
+		if err == io.EOF {
+			return nil, errShort
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func preallocSize(n int) int {
+	if n > maxPrealloc {
+		return maxPrealloc
+	}
+	return n
+}
+
+func (d *Decoder) decodeExt(n int) (interface{}, error) {
+	b, err := d.read(n + 1)
+	if err != nil {
+		return nil, err
+	}
+	if int8(b[0]) != timestampExt {
+		return nil, fmt.Errorf("msgpack: unsupported extension type %d",
+			int8(b[0]))
+	}
+
+	data := b[1:]
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		u := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(u&(1<<34-1)), int64(u>>34)).UTC(), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data)
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return time.Unix(sec, int64(nsec)).UTC(), nil
+	}
+	return nil, fmt.Errorf("msgpack: invalid timestamp length %d", n)
+}
@@ -0,0 +1,96 @@
+package msgpack
+
+import (
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshal(t *testing.T) {
+	var tests = []struct {
+		value    interface{}
+		expected []byte
+	}{
+		{nil, []byte{0xc0}},
+		{true, []byte{0xc3}},
+		{int64(5), []byte{0x05}},
+		{int64(-5), []byte{0xfb}},
+		{int64(200), []byte{0xcc, 0xc8}},
+		{int64(-200), []byte{0xd1, 0xff, 0x38}},
+		{uint64(math.MaxUint64), []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff,
+			0xff, 0xff, 0xff}},
+		{1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{[]interface{}{int64(1), "a"}, []byte{0x92, 0x01, 0xa1, 'a'}},
+		{map[string]interface{}{"b": int64(2), "a": int64(1)},
+			[]byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x02}},
+		{time.Unix(1, 0), []byte{0xd6, 0xff, 0, 0, 0, 1}},
+	}
+
+	for _, test := range tests {
+		b, err := Marshal(test.value)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, b, "%v", test.value)
+	}
+
+	_, err := Marshal(struct{}{})
+	assert.Error(t, err)
+}
+
+func TestRoundTrip(t *testing.T) {
+	values := []interface{}{
+		nil,
+		false,
+		int64(-1 << 40),
+		int64(math.MaxInt64),
+		uint64(math.MaxUint64),
+		3.25,
+		string(make([]byte, 300)),
+		[]byte{1, 2, 3},
+		time.Unix(1500000000, 123).UTC(),
+		time.Unix(-1, 5).UTC(),
+		map[string]interface{}{
+			"list": []interface{}{int64(1), int64(2)},
+			"map":  map[string]interface{}{"x": "y"},
+		},
+	}
+
+	for _, value := range values {
+		b, err := Marshal(value)
+		require.NoError(t, err)
+		d := NewDecoder(b)
+		decoded, err := d.Decode()
+		require.NoError(t, err)
+		assert.Equal(t, value, decoded)
+		_, err = d.Decode()
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	// truncated string
+	_, err := NewDecoder([]byte{0xa3, 'a'}).Decode()
+	assert.Error(t, err)
+
+	// truncated map
+	_, err = NewDecoder([]byte{0x81, 0xa1, 'a'}).Decode()
+	assert.Error(t, err)
+
+	// unused type
+	_, err = NewDecoder([]byte{0xc1}).Decode()
+	assert.Error(t, err)
+
+	// array and map sizes larger than the data left
+	_, err = NewDecoder([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}).Decode()
+	assert.Equal(t, errShort, err)
+	_, err = NewDecoder([]byte{0xdf, 0xff, 0xff, 0xff, 0xff}).Decode()
+	assert.Equal(t, errShort, err)
+	_, err = NewDecoder([]byte{0x92, 0x01}).Decode()
+	assert.Equal(t, errShort, err)
+	_, err = NewDecoder([]byte{0x81, 0x01}).Decode()
+	assert.Equal(t, errShort, err)
+}
@@ -1,13 +1,23 @@
 package logger
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
 
 	"github.com/influxdata/wlog"
 )
 
+var (
+	// output is where SetupLogging directs the log, before the level of
+	// wlog is applied.
+	output     io.Writer = os.Stderr
+	outputLock sync.Mutex
+)
+
 // newTelegrafWriter returns a logging-wrapped writer.
 func newTelegrafWriter(w io.Writer) io.Writer {
 	return &telegrafLog{
@@ -54,5 +64,49 @@ func SetupLogging(debug, quiet bool, logfile string) {
 		oFile = os.Stdout
 	}
 
+	outputLock.Lock()
+	output = oFile
+	outputLock.Unlock()
 	log.SetOutput(newTelegrafWriter(oFile))
 }
+
+// NewPluginLogger returns a logger writing to the output set by SetupLogging,
+// for a plugin with its own log level: "debug", "info", "warn" or "error".
+// An empty level follows the global log level.
+func NewPluginLogger(level string) (*log.Logger, error) {
+	var l wlog.Level
+	if level != "" {
+		var ok bool
+		if l, ok = wlog.StringToLevel[strings.ToUpper(level)]; !ok {
+			return nil, fmt.Errorf("Invalid log level %q, must be \"debug\", "+
+				"\"info\", \"warn\" or \"error\"", level)
+		}
+	}
+	return log.New(&levelWriter{level: l}, "", log.LstdFlags), nil
+}
+
+// levelWriter drops the messages below its level, or below the global level
+// if it has none, and writes the others to output.
+type levelWriter struct {
+	level wlog.Level
+}
+
+func (w *levelWriter) Write(p []byte) (int, error) {
+	level := w.level
+	if level == 0 {
+		level = wlog.LogLevel()
+	}
+	// like wlog, the message level is the letter before the first "!"
+	for i, c := range p {
+		if c == wlog.Delimiter && i > 0 {
+			if l, ok := wlog.Levels[p[i-1]]; ok && l < level {
+				return len(p), nil
+			}
+			break
+		}
+	}
+
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	return output.Write(p)
+}
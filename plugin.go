@@ -0,0 +1,42 @@
+package telegraf
+
+import (
+	"context"
+	"log"
+)
+
+// PluginVersioner may be implemented by inputs and outputs to report the
+// telegraf version they were built for, so that plugins built for an
+// incompatible version can be detected when the config is loaded.
+type PluginVersioner interface {
+	// PluginVersion returns the telegraf version the plugin was built for,
+	// ie "1.2.0".
+	PluginVersion() string
+}
+
+// LoggerSetter may be implemented by inputs and outputs that log through a
+// logger of their own, so that their log level can be set with the
+// per_plugin_log_level agent option.
+type LoggerSetter interface {
+	// SetLogger sets the logger of the plugin. Its messages must start with a
+	// level prefix, ie "D! ", like those of the standard logger.
+	SetLogger(logger *log.Logger)
+}
+
+// HealthChecker may be implemented by inputs and outputs that take some time
+// to become ready after they are started, such as outputs connecting in the
+// background. A config reload waits for them to report healthy before
+// switching to the new plugins.
+type HealthChecker interface {
+	// IsHealthy reports whether the plugin is ready.
+	IsHealthy() bool
+}
+
+// ConnectivityTester may be implemented by outputs connecting to a remote
+// service, to check that the service is reachable without starting the
+// output, see the -verify-connectivity flag.
+type ConnectivityTester interface {
+	// TestConnect returns an error if the remote service cannot be reached
+	// before ctx is done.
+	TestConnect(ctx context.Context) error
+}
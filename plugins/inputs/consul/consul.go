@@ -50,6 +50,14 @@ func (c *Consul) Description() string {
 	return "Gather health check statuses from services registered in Consul"
 }
 
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (c *Consul) SetDefaultTLS(cfg internal.TLSConfig) {
+	c.SSLCA = cfg.SSLCA
+	c.SSLCert = cfg.SSLCert
+	c.SSLKey = cfg.SSLKey
+	c.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (c *Consul) SampleConfig() string {
 	return sampleConfig
 }
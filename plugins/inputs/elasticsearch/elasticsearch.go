@@ -102,6 +102,14 @@ func NewElasticsearch() *Elasticsearch {
 }
 
 // SampleConfig returns sample configuration for this plugin.
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (e *Elasticsearch) SetDefaultTLS(cfg internal.TLSConfig) {
+	e.SSLCA = cfg.SSLCA
+	e.SSLCert = cfg.SSLCert
+	e.SSLKey = cfg.SSLKey
+	e.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (e *Elasticsearch) SampleConfig() string {
 	return sampleConfig
 }
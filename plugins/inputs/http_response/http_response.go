@@ -63,6 +63,14 @@ var sampleConfig = `
 `
 
 // SampleConfig returns the plugin SampleConfig
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (h *HTTPResponse) SetDefaultTLS(cfg internal.TLSConfig) {
+	h.SSLCA = cfg.SSLCA
+	h.SSLCert = cfg.SSLCert
+	h.SSLKey = cfg.SSLKey
+	h.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (h *HTTPResponse) SampleConfig() string {
 	return sampleConfig
 }
@@ -111,6 +111,14 @@ var sampleConfig = `
   # insecure_skip_verify = false
 `
 
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (h *HttpJson) SetDefaultTLS(cfg internal.TLSConfig) {
+	h.SSLCA = cfg.SSLCA
+	h.SSLCert = cfg.SSLCert
+	h.SSLKey = cfg.SSLKey
+	h.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (h *HttpJson) SampleConfig() string {
 	return sampleConfig
 }
@@ -88,6 +88,14 @@ var sampleConfig = `
   data_format = "influx"
 `
 
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (m *MQTTConsumer) SetDefaultTLS(cfg internal.TLSConfig) {
+	m.SSLCA = cfg.SSLCA
+	m.SSLCert = cfg.SSLCert
+	m.SSLKey = cfg.SSLKey
+	m.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (m *MQTTConsumer) SampleConfig() string {
 	return sampleConfig
 }
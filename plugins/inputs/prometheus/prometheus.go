@@ -46,6 +46,14 @@ var sampleConfig = `
   # insecure_skip_verify = false
 `
 
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (p *Prometheus) SetDefaultTLS(cfg internal.TLSConfig) {
+	p.SSLCA = cfg.SSLCA
+	p.SSLCert = cfg.SSLCert
+	p.SSLKey = cfg.SSLKey
+	p.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (p *Prometheus) SampleConfig() string {
 	return sampleConfig
 }
@@ -152,6 +152,14 @@ var sampleConfig = `
 `
 
 // SampleConfig ...
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (r *RabbitMQ) SetDefaultTLS(cfg internal.TLSConfig) {
+	r.SSLCA = cfg.SSLCA
+	r.SSLCert = cfg.SSLCert
+	r.SSLKey = cfg.SSLKey
+	r.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (r *RabbitMQ) SampleConfig() string {
 	return sampleConfig
 }
@@ -168,6 +168,14 @@ func (q *AMQP) Close() error {
 	return q.channel.Close()
 }
 
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (q *AMQP) SetDefaultTLS(cfg internal.TLSConfig) {
+	q.SSLCA = cfg.SSLCA
+	q.SSLCert = cfg.SSLCert
+	q.SSLKey = cfg.SSLKey
+	q.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (q *AMQP) SampleConfig() string {
 	return sampleConfig
 }
@@ -1,6 +1,7 @@
 package influxdb
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
@@ -151,6 +152,47 @@ func createDatabase(c client.Client, database string) error {
 	return err
 }
 
+// TestConnect pings every HTTP url, see the -verify-connectivity flag. UDP
+// urls are connectionless and are not checked.
+func (i *InfluxDB) TestConnect(ctx context.Context) error {
+	urls := append([]string{}, i.URLs...)
+	if i.URL != "" {
+		urls = append(urls, i.URL)
+	}
+	tlsCfg, err := internal.GetTLSConfig(
+		i.SSLCert, i.SSLKey, i.SSLCA, i.InsecureSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	timeout := i.Timeout.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = deadline.Sub(time.Now())
+	}
+	for _, u := range urls {
+		if strings.HasPrefix(u, "udp") {
+			continue
+		}
+		c, err := client.NewHTTPClient(client.HTTPConfig{
+			Addr:      u,
+			Username:  i.Username,
+			Password:  i.Password,
+			UserAgent: i.UserAgent,
+			Timeout:   i.Timeout.Duration,
+			TLSConfig: tlsCfg,
+		})
+		if err != nil {
+			return err
+		}
+		_, _, err = c.Ping(timeout)
+		c.Close()
+		if err != nil {
+			return fmt.Errorf("could not ping %s: %s", u, err)
+		}
+	}
+	return nil
+}
+
 func (i *InfluxDB) Close() error {
 	var errS string
 	for j, _ := range i.conns {
@@ -164,6 +206,14 @@ func (i *InfluxDB) Close() error {
 	return nil
 }
 
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (i *InfluxDB) SetDefaultTLS(cfg internal.TLSConfig) {
+	i.SSLCA = cfg.SSLCA
+	i.SSLCert = cfg.SSLCert
+	i.SSLKey = cfg.SSLKey
+	i.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (i *InfluxDB) SampleConfig() string {
 	return sampleConfig
 }
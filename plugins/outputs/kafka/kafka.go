@@ -140,6 +140,14 @@ func (k *Kafka) Close() error {
 	return k.producer.Close()
 }
 
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (k *Kafka) SetDefaultTLS(cfg internal.TLSConfig) {
+	k.SSLCA = cfg.SSLCA
+	k.SSLCert = cfg.SSLCert
+	k.SSLKey = cfg.SSLKey
+	k.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (k *Kafka) SampleConfig() string {
 	return sampleConfig
 }
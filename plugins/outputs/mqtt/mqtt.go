@@ -97,6 +97,14 @@ func (m *MQTT) Close() error {
 	return nil
 }
 
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (m *MQTT) SetDefaultTLS(cfg internal.TLSConfig) {
+	m.SSLCA = cfg.SSLCA
+	m.SSLCert = cfg.SSLCert
+	m.SSLKey = cfg.SSLKey
+	m.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (m *MQTT) SampleConfig() string {
 	return sampleConfig
 }
@@ -92,6 +92,14 @@ func (n *NATS) Close() error {
 	return nil
 }
 
+// SetDefaultTLS sets the TLS options to the [agent.tls] settings.
+func (n *NATS) SetDefaultTLS(cfg internal.TLSConfig) {
+	n.SSLCA = cfg.SSLCA
+	n.SSLCert = cfg.SSLCert
+	n.SSLKey = cfg.SSLKey
+	n.InsecureSkipVerify = cfg.InsecureSkipVerify
+}
+
 func (n *NATS) SampleConfig() string {
 	return sampleConfig
 }
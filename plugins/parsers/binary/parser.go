@@ -0,0 +1,209 @@
+package binary
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Field is one field of a binary layout.
+type Field struct {
+	Name string
+	Type string
+	// Offset and Length are in bits, Offset counting from the most
+	// significant bit of the first byte.
+	Offset int
+	Length int
+}
+
+// typeBits is the size in bits of each supported field type.
+var typeBits = map[string]int{
+	"int8": 8, "int16": 16, "int32": 32, "int64": 64,
+	"uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64,
+	"float32": 32, "float64": 64,
+	"bool": 1,
+}
+
+// ParseLayout parses layout entries of the form
+// "name:type:offset_bits:length_bits". The length may be left out, in which
+// case it is the size of the type.
+func ParseLayout(layout []string) ([]Field, error) {
+	fields := make([]Field, 0, len(layout))
+	for _, entry := range layout {
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 && len(parts) != 4 {
+			return nil, fmt.Errorf("Invalid binary_layout entry %q, expected "+
+				"name:type:offset_bits:length_bits", entry)
+		}
+		f := Field{Name: parts[0], Type: parts[1]}
+		if f.Name == "" {
+			return nil, fmt.Errorf("Invalid binary_layout entry %q, missing "+
+				"field name", entry)
+		}
+		size, ok := typeBits[f.Type]
+		if !ok {
+			return nil, fmt.Errorf("Invalid binary_layout entry %q, unknown "+
+				"type %s", entry, f.Type)
+		}
+
+		var err error
+		if f.Offset, err = strconv.Atoi(parts[2]); err != nil || f.Offset < 0 {
+			return nil, fmt.Errorf("Invalid binary_layout entry %q, invalid "+
+				"offset %s", entry, parts[2])
+		}
+		f.Length = size
+		if len(parts) == 4 {
+			if f.Length, err = strconv.Atoi(parts[3]); err != nil {
+				return nil, fmt.Errorf("Invalid binary_layout entry %q, "+
+					"invalid length %s", entry, parts[3])
+			}
+		}
+
+		switch {
+		case f.Type == "float32" || f.Type == "float64":
+			if f.Length != size {
+				return nil, fmt.Errorf("Invalid binary_layout entry %q, %s "+
+					"fields must be %d bits long", entry, f.Type, size)
+			}
+		case f.Type == "bool":
+			if f.Length < 1 || f.Length > 64 {
+				return nil, fmt.Errorf("Invalid binary_layout entry %q, "+
+					"length must be between 1 and 64 bits", entry)
+			}
+		case f.Length < 1 || f.Length > size:
+			return nil, fmt.Errorf("Invalid binary_layout entry %q, length "+
+				"must be between 1 and %d bits", entry, size)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// BinaryParser parses binary payloads into a metric, extracting each field
+// of the layout at its bit offset.
+type BinaryParser struct {
+	MetricName  string
+	Fields      []Field
+	ByteOrder   binary.ByteOrder
+	DefaultTags map[string]string
+}
+
+// NewParser returns a parser for the given layout, with "big" (the default)
+// or "little" endianness.
+func NewParser(
+	metricName string,
+	layout []string,
+	endianness string,
+	defaultTags map[string]string,
+) (*BinaryParser, error) {
+	var order binary.ByteOrder
+	switch endianness {
+	case "", "big":
+		order = binary.BigEndian
+	case "little":
+		order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("Invalid binary_endianness %q, must be \"big\" "+
+			"or \"little\"", endianness)
+	}
+	if len(layout) == 0 {
+		return nil, fmt.Errorf("binary data format needs a binary_layout")
+	}
+	fields, err := ParseLayout(layout)
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryParser{
+		MetricName:  metricName,
+		Fields:      fields,
+		ByteOrder:   order,
+		DefaultTags: defaultTags,
+	}, nil
+}
+
+func (p *BinaryParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	fields := make(map[string]interface{}, len(p.Fields))
+	for _, f := range p.Fields {
+		if f.Offset+f.Length > len(buf)*8 {
+			return nil, fmt.Errorf("binary field %s at bits %d-%d is beyond "+
+				"the %d bytes payload", f.Name, f.Offset, f.Offset+f.Length-1,
+				len(buf))
+		}
+		fields[f.Name] = p.value(f, p.bits(buf, f))
+	}
+
+	metric, err := telegraf.NewMetric(p.MetricName, p.DefaultTags,
+		fields, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return []telegraf.Metric{metric}, nil
+}
+
+// bits returns the raw bits of a field. Fields of whole bytes are decoded
+// with the byte order of the parser, other bit fields are read most
+// significant bit first.
+func (p *BinaryParser) bits(buf []byte, f Field) uint64 {
+	if f.Offset%8 == 0 {
+		b := buf[f.Offset/8:]
+		switch f.Length {
+		case 8:
+			return uint64(b[0])
+		case 16:
+			return uint64(p.ByteOrder.Uint16(b))
+		case 32:
+			return uint64(p.ByteOrder.Uint32(b))
+		case 64:
+			return p.ByteOrder.Uint64(b)
+		}
+	}
+
+	var v uint64
+	for i := f.Offset; i < f.Offset+f.Length; i++ {
+		bit := (buf[i/8] >> uint(7-i%8)) & 1
+		v = v<<1 | uint64(bit)
+	}
+	return v
+}
+
+func (p *BinaryParser) value(f Field, bits uint64) interface{} {
+	switch f.Type {
+	case "float32":
+		return float64(math.Float32frombits(uint32(bits)))
+	case "float64":
+		return math.Float64frombits(bits)
+	case "bool":
+		return bits != 0
+	case "uint8", "uint16", "uint32", "uint64":
+		// InfluxDB does not support writing uint64
+		if bits > math.MaxInt64 {
+			return int64(math.MaxInt64)
+		}
+		return int64(bits)
+	}
+	// sign extend the signed types
+	shift := uint(64 - f.Length)
+	return int64(bits<<shift) >> shift
+}
+
+func (p *BinaryParser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("Can not parse the line: %s, for data format: binary", line)
+	}
+
+	return metrics[0], nil
+}
+
+func (p *BinaryParser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
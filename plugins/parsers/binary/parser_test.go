@@ -0,0 +1,87 @@
+package binary
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBigEndian(t *testing.T) {
+	parser, err := NewParser("binary_test", []string{
+		"temperature:int16:0:16",
+		"pressure:uint32:16:32",
+		"ratio:float32:48",
+		"alarm:bool:80:1",
+		"mode:uint8:81:3",
+		"offset:int8:84:4",
+	}, "big", nil)
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse([]byte{
+		0xff, 0x38, // -200
+		0x00, 0x01, 0x86, 0xa0, // 100000
+		0x3f, 0xc0, 0x00, 0x00, // 1.5
+		0xae, // 1 010 1110: true, 2, -2
+	})
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "binary_test", metrics[0].Name())
+	assert.Equal(t, map[string]interface{}{
+		"temperature": int64(-200),
+		"pressure":    int64(100000),
+		"ratio":       float64(1.5),
+		"alarm":       true,
+		"mode":        int64(2),
+		"offset":      int64(-2),
+	}, metrics[0].Fields())
+}
+
+func TestParseLittleEndian(t *testing.T) {
+	parser, err := NewParser("binary_test", []string{
+		"count:uint16:0:16",
+		"value:float64:16:64",
+	}, "little", map[string]string{"bus": "can0"})
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse([]byte{
+		0x34, 0x12,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x04, 0x40, // 2.5
+	})
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, map[string]interface{}{
+		"count": int64(0x1234),
+		"value": float64(2.5),
+	}, metrics[0].Fields())
+	assert.Equal(t, map[string]string{"bus": "can0"}, metrics[0].Tags())
+}
+
+func TestParseShortPayload(t *testing.T) {
+	parser, err := NewParser("binary_test", []string{"value:uint32:8:32"},
+		"", nil)
+	require.NoError(t, err)
+
+	_, err = parser.Parse([]byte{0x01, 0x02, 0x03, 0x04})
+	assert.Error(t, err)
+}
+
+func TestInvalidLayout(t *testing.T) {
+	for _, layout := range []string{
+		"value",
+		":uint8:0:8",
+		"value:uint128:0:128",
+		"value:uint8:-1:8",
+		"value:uint8:0:9",
+		"value:uint8:0:0",
+		"value:float32:0:16",
+	} {
+		_, err := NewParser("binary_test", []string{layout}, "big", nil)
+		assert.Error(t, err, layout)
+	}
+
+	_, err := NewParser("binary_test", []string{"value:uint8:0:8"}, "middle", nil)
+	assert.Error(t, err)
+	_, err = NewParser("binary_test", nil, "big", nil)
+	assert.Error(t, err)
+}
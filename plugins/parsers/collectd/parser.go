@@ -0,0 +1,393 @@
+package collectd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Part types of the collectd binary protocol.
+const (
+	partHost           = 0x0000
+	partTime           = 0x0001
+	partPlugin         = 0x0002
+	partPluginInstance = 0x0003
+	partType           = 0x0004
+	partTypeInstance   = 0x0005
+	partValues         = 0x0006
+	partTimeHR         = 0x0008
+	partSignature      = 0x0200
+	partEncryption     = 0x0210
+)
+
+// Value types of a values part.
+const (
+	valueCounter  = 0
+	valueGauge    = 1
+	valueDerive   = 2
+	valueAbsolute = 3
+)
+
+// Security levels, the protection a value list must have to be accepted.
+const (
+	levelNone = iota
+	levelSign
+	levelEncrypt
+)
+
+// valueList holds the identifier and time of the value list being parsed.
+// Each part of a packet updates one of them, for all the values parts that
+// follow.
+type valueList struct {
+	host           string
+	time           time.Time
+	plugin         string
+	pluginInstance string
+	typ            string
+	typeInstance   string
+}
+
+// CollectdParser parses packets of the collectd binary protocol, as sent by
+// the collectd network plugin, into metrics, one metric per value list.
+type CollectdParser struct {
+	DefaultTags map[string]string
+
+	// TypesDB names the fields after the data sources of the collectd types.
+	TypesDB TypesDB
+
+	level     int
+	passwords map[string]string
+}
+
+// NewParser returns a parser accepting the value lists with at least the
+// security level "none" (the default), "sign" or "encrypt". The passwords of
+// signed and encrypted packets are read from authFile, with one
+// "username: password" per line. The data sources of the types are read
+// from the typesDB files.
+func NewParser(
+	securityLevel string,
+	authFile string,
+	typesDB []string,
+	defaultTags map[string]string,
+) (*CollectdParser, error) {
+	p := &CollectdParser{DefaultTags: defaultTags}
+	switch securityLevel {
+	case "", "none":
+		p.level = levelNone
+	case "sign":
+		p.level = levelSign
+	case "encrypt":
+		p.level = levelEncrypt
+	default:
+		return nil, fmt.Errorf("Invalid collectd_security_level %q, must be "+
+			"\"none\", \"sign\" or \"encrypt\"", securityLevel)
+	}
+
+	if authFile != "" {
+		var err error
+		if p.passwords, err = loadAuthFile(authFile); err != nil {
+			return nil, err
+		}
+	} else if p.level != levelNone {
+		return nil, fmt.Errorf("collectd_security_level %q needs a "+
+			"collectd_auth_file", securityLevel)
+	}
+
+	if len(typesDB) > 0 {
+		var err error
+		if p.TypesDB, err = LoadTypesDB(typesDB); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func loadAuthFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading collectd_auth_file %s, %s",
+			path, err)
+	}
+	defer file.Close()
+
+	passwords := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid line in collectd_auth_file %s, "+
+				"expected \"username: password\"", path)
+		}
+		passwords[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return passwords, scanner.Err()
+}
+
+func (p *CollectdParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	metrics := make([]telegraf.Metric, 0)
+	if err := p.parse(buf, levelNone, &valueList{}, &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// parse parses the parts of buf, protected with the given security level,
+// appending a metric to metrics for each values part.
+func (p *CollectdParser) parse(
+	buf []byte,
+	level int,
+	vl *valueList,
+	metrics *[]telegraf.Metric,
+) error {
+	for len(buf) > 0 {
+		if len(buf) < 4 {
+			return fmt.Errorf("truncated collectd part header")
+		}
+		typ := binary.BigEndian.Uint16(buf[0:2])
+		length := int(binary.BigEndian.Uint16(buf[2:4]))
+		if length < 4 || length > len(buf) {
+			return fmt.Errorf("invalid collectd part length %d", length)
+		}
+		part := buf[4:length]
+		rest := buf[length:]
+
+		switch typ {
+		case partHost:
+			vl.host = parseString(part)
+		case partPlugin:
+			vl.plugin = parseString(part)
+		case partPluginInstance:
+			vl.pluginInstance = parseString(part)
+		case partType:
+			vl.typ = parseString(part)
+		case partTypeInstance:
+			vl.typeInstance = parseString(part)
+		case partTime:
+			if len(part) != 8 {
+				return fmt.Errorf("invalid collectd time part length %d", length)
+			}
+			vl.time = time.Unix(int64(binary.BigEndian.Uint64(part)), 0)
+		case partTimeHR:
+			if len(part) != 8 {
+				return fmt.Errorf("invalid collectd time part length %d", length)
+			}
+			// in units of 2^-30 seconds
+			hr := binary.BigEndian.Uint64(part)
+			vl.time = time.Unix(int64(hr>>30), int64((hr&(1<<30-1))*1e9>>30))
+		case partValues:
+			if level < p.level {
+				return fmt.Errorf("collectd values of %s are not signed or "+
+					"encrypted as required by the security level", vl.plugin)
+			}
+			metric, err := p.metric(vl, part)
+			if err != nil {
+				return err
+			}
+			if metric != nil {
+				*metrics = append(*metrics, metric)
+			}
+		case partSignature:
+			if p.level == levelNone {
+				break
+			}
+			if err := p.verify(part, rest); err != nil {
+				return err
+			}
+			if level < levelSign {
+				level = levelSign
+			}
+		case partEncryption:
+			plain, err := p.decrypt(part)
+			if err != nil {
+				return err
+			}
+			if err := p.parse(plain, levelEncrypt, vl, metrics); err != nil {
+				return err
+			}
+		}
+		// other parts, such as intervals and notifications, are ignored
+		buf = rest
+	}
+	return nil
+}
+
+func parseString(part []byte) string {
+	return string(bytes.TrimRight(part, "\x00"))
+}
+
+// verify checks the HMAC-SHA256 signature part, covering the user name and
+// the signed parts that follow it.
+func (p *CollectdParser) verify(part, signed []byte) error {
+	if len(part) < sha256.Size {
+		return fmt.Errorf("invalid collectd signature part length %d",
+			len(part)+4)
+	}
+	user := part[sha256.Size:]
+	password, ok := p.passwords[string(user)]
+	if !ok {
+		return fmt.Errorf("unknown collectd user %q", user)
+	}
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write(user)
+	mac.Write(signed)
+	if !hmac.Equal(mac.Sum(nil), part[:sha256.Size]) {
+		return fmt.Errorf("invalid collectd signature from user %q", user)
+	}
+	return nil
+}
+
+// decrypt returns the parts encrypted in the encryption part, with AES-256 in
+// OFB mode and a key derived from the password of the user. The plain parts
+// are preceded by their SHA-1 checksum.
+func (p *CollectdParser) decrypt(part []byte) ([]byte, error) {
+	if len(part) < 2 {
+		return nil, fmt.Errorf("invalid collectd encryption part length %d",
+			len(part)+4)
+	}
+	userLen := int(binary.BigEndian.Uint16(part[0:2]))
+	if len(part) < 2+userLen+aes.BlockSize+sha1.Size {
+		return nil, fmt.Errorf("invalid collectd encryption part length %d",
+			len(part)+4)
+	}
+	user := string(part[2 : 2+userLen])
+	password, ok := p.passwords[user]
+	if !ok {
+		return nil, fmt.Errorf("unknown collectd user %q", user)
+	}
+	iv := part[2+userLen : 2+userLen+aes.BlockSize]
+	encrypted := part[2+userLen+aes.BlockSize:]
+
+	key := sha256.Sum256([]byte(password))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(encrypted))
+	cipher.NewOFB(block, iv).XORKeyStream(plain, encrypted)
+
+	checksum := sha1.Sum(plain[sha1.Size:])
+	if !bytes.Equal(checksum[:], plain[:sha1.Size]) {
+		return nil, fmt.Errorf("invalid collectd encrypted data from user "+
+			"%q, wrong password", user)
+	}
+	return plain[sha1.Size:], nil
+}
+
+// metric builds the metric of the values part, named after the plugin and
+// tagged with the rest of the value list identifier. The fields are named
+// after the data sources of the type, or "value" for a single value of a type
+// missing from the types.db, and "value0", "value1", ... for several.
+func (p *CollectdParser) metric(vl *valueList, part []byte) (telegraf.Metric, error) {
+	if len(part) < 2 {
+		return nil, fmt.Errorf("invalid collectd values part length %d",
+			len(part)+4)
+	}
+	n := int(binary.BigEndian.Uint16(part[0:2]))
+	if len(part) != 2+9*n {
+		return nil, fmt.Errorf("invalid collectd values part length %d for "+
+			"%d values", len(part)+4, n)
+	}
+	if vl.plugin == "" {
+		return nil, fmt.Errorf("collectd values without a plugin name")
+	}
+
+	names, ok := p.TypesDB[vl.typ]
+	if ok && len(names) != n {
+		return nil, fmt.Errorf("collectd type %s has %d data sources, got %d "+
+			"values", vl.typ, len(names), n)
+	}
+	if !ok {
+		names = []string{"value"}
+		if n > 1 {
+			names = make([]string, n)
+			for i := range names {
+				names[i] = "value" + strconv.Itoa(i)
+			}
+		}
+	}
+
+	types := part[2 : 2+n]
+	values := part[2+n:]
+	fields := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		raw := values[8*i : 8*i+8]
+		switch types[i] {
+		case valueGauge:
+			// gauges are the only values in little endian
+			v := math.Float64frombits(binary.LittleEndian.Uint64(raw))
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				continue
+			}
+			fields[names[i]] = v
+		case valueDerive:
+			fields[names[i]] = int64(binary.BigEndian.Uint64(raw))
+		case valueCounter, valueAbsolute:
+			// InfluxDB does not support writing uint64
+			v := binary.BigEndian.Uint64(raw)
+			if v > math.MaxInt64 {
+				v = math.MaxInt64
+			}
+			fields[names[i]] = int64(v)
+		default:
+			return nil, fmt.Errorf("invalid collectd value type %d", types[i])
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string, len(p.DefaultTags)+4)
+	for k, v := range p.DefaultTags {
+		tags[k] = v
+	}
+	for k, v := range map[string]string{
+		"host":          vl.host,
+		"instance":      vl.pluginInstance,
+		"type":          vl.typ,
+		"type_instance": vl.typeInstance,
+	} {
+		if v != "" {
+			tags[k] = v
+		}
+	}
+
+	t := vl.time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return telegraf.NewMetric(vl.plugin, tags, fields, t.UTC())
+}
+
+func (p *CollectdParser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("Can not parse the line: %s, for data format: collectd", line)
+	}
+
+	return metrics[0], nil
+}
+
+func (p *CollectdParser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
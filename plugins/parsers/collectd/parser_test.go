@@ -0,0 +1,230 @@
+package collectd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stringPart(typ uint16, s string) []byte {
+	return part(typ, append([]byte(s), 0))
+}
+
+func timePart(t time.Time) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(t.Unix())<<30)
+	return part(partTimeHR, b)
+}
+
+func valuesPart(types []byte, values []uint64) []byte {
+	b := make([]byte, 2+9*len(types))
+	binary.BigEndian.PutUint16(b, uint16(len(types)))
+	copy(b[2:], types)
+	for i, v := range values {
+		if types[i] == valueGauge {
+			binary.LittleEndian.PutUint64(b[2+len(types)+8*i:], v)
+		} else {
+			binary.BigEndian.PutUint64(b[2+len(types)+8*i:], v)
+		}
+	}
+	return part(partValues, b)
+}
+
+func part(typ uint16, data []byte) []byte {
+	b := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint16(b[0:2], typ)
+	binary.BigEndian.PutUint16(b[2:4], uint16(4+len(data)))
+	return append(b, data...)
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func sign(user, password string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(password))
+	mac.Write([]byte(user))
+	mac.Write(payload)
+	return append(part(partSignature, append(mac.Sum(nil), user...)),
+		payload...)
+}
+
+func encrypt(user, password string, payload []byte) []byte {
+	checksum := sha1.Sum(payload)
+	plain := append(checksum[:], payload...)
+	iv := make([]byte, aes.BlockSize)
+	key := sha256.Sum256([]byte(password))
+	block, _ := aes.NewCipher(key[:])
+	encrypted := make([]byte, len(plain))
+	cipher.NewOFB(block, iv).XORKeyStream(encrypted, plain)
+
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, uint16(len(user)))
+	b = append(b, user...)
+	b = append(b, iv...)
+	return part(partEncryption, append(b, encrypted...))
+}
+
+func tempFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "collectd")
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = file.WriteString(content)
+	require.NoError(t, err)
+	return file.Name()
+}
+
+var now = time.Unix(1500000000, 0)
+
+func interfacePacket() []byte {
+	return concat(
+		stringPart(partHost, "server01"),
+		timePart(now),
+		stringPart(partPlugin, "interface"),
+		stringPart(partPluginInstance, "eth0"),
+		stringPart(partType, "if_octets"),
+		valuesPart([]byte{valueDerive, valueDerive}, []uint64{100, 200}),
+		stringPart(partPlugin, "load"),
+		stringPart(partPluginInstance, ""),
+		stringPart(partType, "load"),
+		valuesPart([]byte{valueGauge, valueGauge, valueGauge}, []uint64{
+			math.Float64bits(0.5),
+			math.Float64bits(1.5),
+			math.Float64bits(math.NaN()),
+		}),
+	)
+}
+
+func TestParse(t *testing.T) {
+	parser, err := NewParser("", "", nil, map[string]string{"dc": "eu"})
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse(interfacePacket())
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+
+	assert.Equal(t, "interface", metrics[0].Name())
+	assert.Equal(t, map[string]string{
+		"dc":       "eu",
+		"host":     "server01",
+		"instance": "eth0",
+		"type":     "if_octets",
+	}, metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{
+		"value0": int64(100),
+		"value1": int64(200),
+	}, metrics[0].Fields())
+	assert.Equal(t, now.UnixNano(), metrics[0].UnixNano())
+
+	assert.Equal(t, "load", metrics[1].Name())
+	assert.Equal(t, map[string]string{
+		"dc":   "eu",
+		"host": "server01",
+		"type": "load",
+	}, metrics[1].Tags())
+	assert.Equal(t, map[string]interface{}{
+		"value0": 0.5,
+		"value1": 1.5,
+	}, metrics[1].Fields())
+}
+
+func TestParseTypesDB(t *testing.T) {
+	typesDB := tempFile(t, `# comment
+if_octets  rx:DERIVE:0:U, tx:DERIVE:0:U
+load       shortterm:GAUGE:0:5000, midterm:GAUGE:0:5000, longterm:GAUGE:0:5000
+`)
+	defer os.Remove(typesDB)
+
+	parser, err := NewParser("none", "", []string{typesDB}, nil)
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse(interfacePacket())
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, map[string]interface{}{
+		"rx": int64(100),
+		"tx": int64(200),
+	}, metrics[0].Fields())
+	assert.Equal(t, map[string]interface{}{
+		"shortterm": 0.5,
+		"midterm":   1.5,
+	}, metrics[1].Fields())
+}
+
+func TestParseSecurityLevels(t *testing.T) {
+	authFile := tempFile(t, "alice: secret\n")
+	defer os.Remove(authFile)
+
+	signed := sign("alice", "secret", interfacePacket())
+	encrypted := encrypt("alice", "secret", interfacePacket())
+
+	tests := []struct {
+		level  string
+		packet []byte
+		ok     bool
+	}{
+		{"none", interfacePacket(), true},
+		{"none", encrypted, true},
+		{"sign", interfacePacket(), false},
+		{"sign", signed, true},
+		{"sign", encrypted, true},
+		{"sign", sign("alice", "wrong", interfacePacket()), false},
+		{"sign", sign("bob", "secret", interfacePacket()), false},
+		{"encrypt", signed, false},
+		{"encrypt", encrypted, true},
+		{"encrypt", encrypt("alice", "wrong", interfacePacket()), false},
+	}
+	for i, tt := range tests {
+		parser, err := NewParser(tt.level, authFile, nil, nil)
+		require.NoError(t, err)
+
+		metrics, err := parser.Parse(tt.packet)
+		if !tt.ok {
+			assert.Error(t, err, "test %d", i)
+			continue
+		}
+		require.NoError(t, err, "test %d", i)
+		assert.Len(t, metrics, 2, "test %d", i)
+	}
+}
+
+func TestNewParserErrors(t *testing.T) {
+	_, err := NewParser("paranoid", "", nil, nil)
+	assert.Error(t, err)
+
+	_, err = NewParser("sign", "", nil, nil)
+	assert.Error(t, err)
+
+	typesDB := tempFile(t, "if_octets rx:DERIVE\n")
+	defer os.Remove(typesDB)
+	_, err = NewParser("", "", []string{typesDB}, nil)
+	assert.Error(t, err)
+}
+
+func TestParseInvalid(t *testing.T) {
+	parser, err := NewParser("", "", nil, nil)
+	require.NoError(t, err)
+
+	// truncated part
+	_, err = parser.Parse(interfacePacket()[:10])
+	assert.Error(t, err)
+
+	// values without a plugin
+	_, err = parser.Parse(valuesPart([]byte{valueGauge}, []uint64{0}))
+	assert.Error(t, err)
+}
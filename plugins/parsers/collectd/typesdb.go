@@ -0,0 +1,61 @@
+package collectd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TypesDB maps collectd type names, ie "if_octets", to the names of their
+// data sources, ie "rx" and "tx", in the order of the values.
+type TypesDB map[string][]string
+
+// LoadTypesDB reads collectd types.db files. A type defined in several files
+// takes the definition of the last one.
+func LoadTypesDB(paths []string) (TypesDB, error) {
+	db := make(TypesDB)
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading collectd types.db %s, %s",
+				path, err)
+		}
+		err = db.read(file, path)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// read parses lines of the form
+// "name ds_name:ds_type:min:max, ds_name:ds_type:min:max".
+func (db TypesDB) read(file *os.File, path string) error {
+	scanner := bufio.NewScanner(file)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			return fmt.Errorf("Invalid collectd type at %s:%d, missing data "+
+				"sources", path, lineno)
+		}
+		var sources []string
+		for _, ds := range strings.Split(strings.Join(parts[1:], ""), ",") {
+			spec := strings.Split(ds, ":")
+			if len(spec) != 4 || spec[0] == "" {
+				return fmt.Errorf("Invalid collectd data source %q at %s:%d, "+
+					"expected name:type:min:max", ds, path, lineno)
+			}
+			sources = append(sources, spec[0])
+		}
+		db[parts[0]] = sources
+	}
+	return scanner.Err()
+}
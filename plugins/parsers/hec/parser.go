@@ -0,0 +1,158 @@
+package hec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// metadataTags are the HEC metadata keys that become tags.
+var metadataTags = []string{"host", "source", "sourcetype", "index"}
+
+// HECParser parses Splunk HTTP Event Collector payloads, one or more JSON
+// events one after the other, into metrics, one metric per event.
+//
+// The raw event of an event payload is the "message" field, as a string,
+// or as JSON when it is an object. A metric payload, with "event": "metric",
+// has its fields in the "fields" object: a single metric with "metric_name"
+// and "_value" is named after metric_name up to its last dot, with the rest
+// as the field name, and the other string fields, the dimensions, as tags.
+// Without metric_name, every entry of "fields" is a field. The "fields" of
+// an event payload are fields too. The host, source, sourcetype and index of
+// the event become tags.
+type HECParser struct {
+	MetricName  string
+	DefaultTags map[string]string
+}
+
+// hecEvent is the JSON object of a single HEC event.
+type hecEvent struct {
+	Time       *float64               `json:"time"`
+	Host       string                 `json:"host"`
+	Source     string                 `json:"source"`
+	SourceType string                 `json:"sourcetype"`
+	Index      string                 `json:"index"`
+	Event      json.RawMessage        `json:"event"`
+	Fields     map[string]interface{} `json:"fields"`
+}
+
+func (p *HECParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	metrics := make([]telegraf.Metric, 0)
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	for {
+		var event hecEvent
+		err := decoder.Decode(&event)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse out as HEC event, %s", err)
+		}
+		metric, err := p.parseEvent(&event)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, nil
+}
+
+func (p *HECParser) parseEvent(event *hecEvent) (telegraf.Metric, error) {
+	tags := make(map[string]string)
+	for k, v := range p.DefaultTags {
+		tags[k] = v
+	}
+	for i, value := range []string{event.Host, event.Source,
+		event.SourceType, event.Index} {
+		if value != "" {
+			tags[metadataTags[i]] = value
+		}
+	}
+
+	t := time.Now()
+	if event.Time != nil {
+		sec, frac := math.Modf(*event.Time)
+		t = time.Unix(int64(sec), int64(frac*1e9))
+	}
+
+	var raw interface{}
+	if len(event.Event) > 0 {
+		if err := json.Unmarshal(event.Event, &raw); err != nil {
+			return nil, fmt.Errorf("invalid HEC event, %s", err)
+		}
+	}
+
+	name := p.MetricName
+	fields := make(map[string]interface{})
+	if raw == "metric" {
+		metricName, hasName := event.Fields["metric_name"].(string)
+		value, hasValue := event.Fields["_value"]
+		if hasName && hasValue {
+			measurement, field := metricName, "value"
+			if i := strings.LastIndex(metricName, "."); i > 0 {
+				measurement, field = metricName[:i], metricName[i+1:]
+			}
+			fields[field] = fieldValue(value)
+			for k, v := range event.Fields {
+				if str, ok := v.(string); ok && k != "metric_name" {
+					tags[k] = str
+				}
+			}
+			return telegraf.NewMetric(measurement, tags, fields, t)
+		}
+	} else {
+		switch v := raw.(type) {
+		case nil:
+		case string:
+			fields["message"] = v
+		default:
+			fields["message"] = string(event.Event)
+		}
+	}
+
+	for k, v := range event.Fields {
+		fields[k] = fieldValue(v)
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("HEC event has no event and no fields")
+	}
+	return telegraf.NewMetric(name, tags, fields, t)
+}
+
+// fieldValue converts the JSON numbers of a field to int64 or float64.
+func fieldValue(v interface{}) interface{} {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
+
+func (p *HECParser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("Can not parse the line: %s, for data format: "+
+			"hec", line)
+	}
+	return metrics[0], nil
+}
+
+func (p *HECParser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
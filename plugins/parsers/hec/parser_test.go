@@ -0,0 +1,71 @@
+package hec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Event(t *testing.T) {
+	p := &HECParser{MetricName: "hec", DefaultTags: map[string]string{
+		"listener": "a",
+	}}
+	metrics, err := p.Parse([]byte(`
+{"time": 1426279439.5, "host": "web-01", "source": "access.log",
+ "sourcetype": "access_combined", "index": "main",
+ "event": "GET /index.html 200"}
+{"event": {"user": "alice", "action": "login"}, "fields": {"region": "eu"}}
+`))
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+
+	assert.Equal(t, "hec", metrics[0].Name())
+	assert.Equal(t, map[string]string{
+		"listener":   "a",
+		"host":       "web-01",
+		"source":     "access.log",
+		"sourcetype": "access_combined",
+		"index":      "main",
+	}, metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{"message": "GET /index.html 200"},
+		metrics[0].Fields())
+	assert.Equal(t, time.Unix(1426279439, 5e8).UnixNano(),
+		metrics[0].UnixNano())
+
+	assert.Equal(t, map[string]interface{}{
+		"message": `{"user": "alice", "action": "login"}`,
+		"region":  "eu",
+	}, metrics[1].Fields())
+}
+
+func TestParse_Metric(t *testing.T) {
+	p := &HECParser{MetricName: "hec"}
+	metrics, err := p.Parse([]byte(`{"time": 1426279439, "host": "web-01",
+ "event": "metric", "fields": {"metric_name": "cpu.usage_idle",
+ "_value": 99.5, "cpu": "cpu0"}}
+{"event": "metric", "fields": {"requests": 10, "errors": 1}}`))
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+
+	assert.Equal(t, "cpu", metrics[0].Name())
+	assert.Equal(t, map[string]string{"host": "web-01", "cpu": "cpu0"},
+		metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{"usage_idle": float64(99.5)},
+		metrics[0].Fields())
+
+	assert.Equal(t, "hec", metrics[1].Name())
+	assert.Equal(t, map[string]interface{}{
+		"requests": int64(10),
+		"errors":   int64(1),
+	}, metrics[1].Fields())
+}
+
+func TestParse_Invalid(t *testing.T) {
+	p := &HECParser{MetricName: "hec"}
+	_, err := p.Parse([]byte(`{"event": `))
+	assert.Error(t, err)
+	_, err = p.Parse([]byte(`{"host": "web-01"}`))
+	assert.Error(t, err)
+}
@@ -3,8 +3,11 @@ package json
 import (
 	"encoding/json"
 	"fmt"
+	"log"
+	"math"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -14,17 +17,122 @@ type JSONParser struct {
 	MetricName  string
 	TagKeys     []string
 	DefaultTags map[string]string
+
+	// JSONQuery is the dot separated path of the object, or the array of
+	// objects, to parse into metrics. JSONQueryMissing decides what happens
+	// when the path is not found: "error" (the default), "skip" to return no
+	// metrics, or "use_root" to parse the whole document.
+	JSONQuery        string
+	JSONQueryMissing string
+
+	// JSONTimeKey is the key holding the metric timestamp, the current time
+	// is used if empty. The timestamp is parsed using JSONTimeFormat, a Go
+	// reference time layout or one of "unix", "unix_ms", "unix_us" and
+	// "unix_ns" for numeric epochs, in the JSONTimezone location.
+	JSONTimeKey    string
+	JSONTimeFormat string
+	JSONTimezone   string
+
+	// JSONStrictTypes skips, with an error logged, the fields whose JSON
+	// value is not of their expected type instead of coercing or ignoring
+	// them. The expected type is DataType, one of "integer", "float",
+	// "string" and "boolean", or if it is empty the JSON type of the field the
+	// first time it was parsed.
+	JSONStrictTypes bool
+	DataType        string
+
+	// JSONPathPrefix is stripped from the start of the flattened field keys,
+	// so that "data_metrics_" turns data_metrics_cpu_usage into cpu_usage.
+	JSONPathPrefix string
+
+	typesLock  sync.Mutex
+	fieldTypes map[string]string
 }
 
 func (p *JSONParser) Parse(buf []byte) ([]telegraf.Metric, error) {
 	metrics := make([]telegraf.Metric, 0)
 
-	var jsonOut map[string]interface{}
-	err := json.Unmarshal(buf, &jsonOut)
+	if p.JSONQuery == "" {
+		var jsonOut map[string]interface{}
+		err := json.Unmarshal(buf, &jsonOut)
+		if err != nil {
+			err = fmt.Errorf("unable to parse out as JSON, %s", err)
+			return nil, err
+		}
+		metric, err := p.parseObject(jsonOut)
+		if err != nil {
+			return nil, err
+		}
+		return append(metrics, metric), nil
+	}
+
+	var doc interface{}
+	err := json.Unmarshal(buf, &doc)
 	if err != nil {
 		err = fmt.Errorf("unable to parse out as JSON, %s", err)
 		return nil, err
 	}
+	result, ok := query(doc, p.JSONQuery)
+	if !ok {
+		switch p.JSONQueryMissing {
+		case "skip":
+			return metrics, nil
+		case "use_root":
+			result = doc
+		default:
+			return nil, fmt.Errorf("JSON query %s could not be found",
+				p.JSONQuery)
+		}
+	}
+
+	var objects []interface{}
+	switch v := result.(type) {
+	case []interface{}:
+		objects = v
+	default:
+		objects = []interface{}{v}
+	}
+	for _, object := range objects {
+		jsonOut, ok := object.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("JSON query %s does not select an object "+
+				"or an array of objects", p.JSONQuery)
+		}
+		metric, err := p.parseObject(jsonOut)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, nil
+}
+
+// query returns the value at the dot separated path of doc. Path elements
+// index arrays when they are numbers.
+func query(doc interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if doc, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			doc = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// parseObject builds a metric from a JSON object.
+func (p *JSONParser) parseObject(jsonOut map[string]interface{}) (telegraf.Metric, error) {
+	var err error
 
 	tags := make(map[string]string)
 	for k, v := range p.DefaultTags {
@@ -43,18 +151,226 @@ func (p *JSONParser) Parse(buf []byte) ([]telegraf.Metric, error) {
 		delete(jsonOut, tag)
 	}
 
+	timestamp := time.Now().UTC()
+	if p.JSONTimeKey != "" {
+		value, ok := jsonOut[p.JSONTimeKey]
+		if !ok {
+			return nil, fmt.Errorf("JSON time key %s could not be found",
+				p.JSONTimeKey)
+		}
+		timestamp, err = p.parseTime(value)
+		if err != nil {
+			return nil, err
+		}
+		delete(jsonOut, p.JSONTimeKey)
+	}
+
+	if p.JSONStrictTypes {
+		fields := make(map[string]interface{})
+		p.flattenStrict(fields, "", jsonOut)
+		return telegraf.NewMetric(p.MetricName, tags,
+			p.stripPathPrefix(fields), timestamp)
+	}
+
 	f := JSONFlattener{}
 	err = f.FlattenJSON("", jsonOut)
 	if err != nil {
 		return nil, err
 	}
 
-	metric, err := telegraf.NewMetric(p.MetricName, tags, f.Fields, time.Now().UTC())
+	return telegraf.NewMetric(p.MetricName, tags, p.stripPathPrefix(f.Fields),
+		timestamp)
+}
+
+// stripPathPrefix removes JSONPathPrefix from the start of the field keys. A
+// key equal to the prefix is kept as is, as it would be left empty.
+func (p *JSONParser) stripPathPrefix(
+	fields map[string]interface{},
+) map[string]interface{} {
+	if p.JSONPathPrefix == "" {
+		return fields
+	}
+	strip := func(k string) bool {
+		return strings.HasPrefix(k, p.JSONPathPrefix) && k != p.JSONPathPrefix
+	}
+	stripped := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if !strip(k) {
+			stripped[k] = v
+		}
+	}
+	// stripped keys win over the keys they collide with
+	for k, v := range fields {
+		if strip(k) {
+			stripped[strings.TrimPrefix(k, p.JSONPathPrefix)] = v
+		}
+	}
+	return stripped
+}
+
+// flattenStrict flattens v into fields like FlattenJSON, checking the type of
+// every value against the type expected for its field.
+func (p *JSONParser) flattenStrict(
+	fields map[string]interface{},
+	fieldname string,
+	v interface{},
+) {
+	join := func(k string) string {
+		if fieldname == "" {
+			return k
+		}
+		return fieldname + "_" + k
+	}
+
+	var jsonType string
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			p.flattenStrict(fields, join(k), item)
+		}
+		return
+	case []interface{}:
+		for i, item := range t {
+			p.flattenStrict(fields, join(strconv.Itoa(i)), item)
+		}
+		return
+	case float64:
+		jsonType = "number"
+	case string:
+		jsonType = "string"
+	case bool:
+		jsonType = "boolean"
+	default:
+		return
+	}
+
+	if p.DataType == "" {
+		if expected := p.learnType(fieldname, jsonType); expected != jsonType {
+			log.Printf("E! JSON field %s is a %s, expected a %s, skipping it",
+				fieldname, jsonType, expected)
+			return
+		}
+		// like FlattenJSON, only numbers are kept
+		if jsonType == "number" {
+			fields[fieldname] = v
+		}
+		return
+	}
+
+	switch dataType(p.DataType) {
+	case "integer":
+		if f, ok := v.(float64); ok && f == math.Trunc(f) &&
+			math.Abs(f) < math.MaxInt64 {
+			fields[fieldname] = int64(f)
+			return
+		}
+	case "float":
+		if jsonType == "number" {
+			fields[fieldname] = v
+			return
+		}
+	case "string", "boolean":
+		if jsonType == dataType(p.DataType) {
+			fields[fieldname] = v
+			return
+		}
+	}
+	log.Printf("E! JSON field %s value %v is not a %s, skipping it",
+		fieldname, v, dataType(p.DataType))
+}
+
+// learnType returns the type expected for a field, recording jsonType for
+// the fields seen for the first time.
+func (p *JSONParser) learnType(fieldname, jsonType string) string {
+	p.typesLock.Lock()
+	defer p.typesLock.Unlock()
+	if p.fieldTypes == nil {
+		p.fieldTypes = make(map[string]string)
+	}
+	if expected, ok := p.fieldTypes[fieldname]; ok {
+		return expected
+	}
+	p.fieldTypes[fieldname] = jsonType
+	return jsonType
+}
+
+// dataType returns the name of a data_type, accepting the aliases of the
+// value parser. It returns "" for unknown types.
+func dataType(name string) string {
+	switch name {
+	case "int", "integer":
+		return "integer"
+	case "float", "long":
+		return "float"
+	case "str", "string":
+		return "string"
+	case "bool", "boolean":
+		return "boolean"
+	}
+	return ""
+}
+
+// ValidDataType reports whether name is a data_type of JSONStrictTypes.
+func ValidDataType(name string) bool {
+	return dataType(name) != ""
+}
+
+// parseTime parses the value of the JSONTimeKey key into a timestamp.
+func (p *JSONParser) parseTime(value interface{}) (time.Time, error) {
+	var unit time.Duration
+	switch p.JSONTimeFormat {
+	case "unix":
+		unit = time.Second
+	case "unix_ms":
+		unit = time.Millisecond
+	case "unix_us":
+		unit = time.Microsecond
+	case "unix_ns":
+		unit = time.Nanosecond
+	}
+
+	if unit != 0 {
+		var epoch float64
+		switch v := value.(type) {
+		case float64:
+			epoch = v
+		case string:
+			var err error
+			epoch, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("JSON time %q is not a number", v)
+			}
+		default:
+			return time.Time{}, fmt.Errorf("JSON time %v is not a number", v)
+		}
+		// split off the fraction to keep nanosecond precision
+		whole := math.Floor(epoch)
+		nsec := int64(whole)*int64(unit) + int64((epoch-whole)*float64(unit))
+		return time.Unix(0, nsec).UTC(), nil
+	}
 
+	str, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("JSON time %v is not a string", value)
+	}
+	if p.JSONTimeFormat == "" {
+		return time.Time{}, fmt.Errorf("json_time_format must be set with " +
+			"json_time_key")
+	}
+	loc := time.UTC
+	if p.JSONTimezone != "" {
+		var err error
+		loc, err = time.LoadLocation(p.JSONTimezone)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("Invalid json_timezone %s, %s",
+				p.JSONTimezone, err)
+		}
+	}
+	t, err := time.ParseInLocation(p.JSONTimeFormat, str, loc)
 	if err != nil {
-		return nil, err
+		return time.Time{}, fmt.Errorf("Error parsing JSON time %q, %s", str, err)
 	}
-	return append(metrics, metric), nil
+	return t.UTC(), nil
 }
 
 func (p *JSONParser) ParseLine(line string) (telegraf.Metric, error) {
@@ -2,6 +2,7 @@ package json
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -282,3 +283,164 @@ func TestParseValidJSONDefaultTagsOverride(t *testing.T) {
 		"mytag": "foobar",
 	}, metrics[0].Tags())
 }
+
+func TestParseJSONTime(t *testing.T) {
+	parser := JSONParser{
+		MetricName:     "json_test",
+		JSONTimeKey:    "time",
+		JSONTimeFormat: "2006-01-02 15:04:05",
+		JSONTimezone:   "America/New_York",
+	}
+
+	metrics, err := parser.Parse([]byte(`{"a": 5, "time": "2017-01-02 10:00:00"}`))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, map[string]interface{}{
+		"a": float64(5),
+	}, metrics[0].Fields())
+	assert.Equal(t, time.Date(2017, 1, 2, 15, 0, 0, 0, time.UTC),
+		metrics[0].Time().UTC())
+
+	_, err = parser.Parse([]byte(`{"a": 5}`))
+	assert.Error(t, err)
+
+	_, err = parser.Parse([]byte(`{"a": 5, "time": "yesterday"}`))
+	assert.Error(t, err)
+}
+
+func TestParseJSONUnixTime(t *testing.T) {
+	parser := JSONParser{
+		MetricName:     "json_test",
+		JSONTimeKey:    "time",
+		JSONTimeFormat: "unix_ms",
+	}
+
+	metrics, err := parser.Parse([]byte(`{"a": 5, "time": 1483369200500}`))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, time.Unix(1483369200, 500000000).UTC(), metrics[0].Time().UTC())
+}
+
+const nestedJSON = `
+{
+    "status": "ok",
+    "data": {
+        "metrics": [
+            {"name": "a", "value": 1},
+            {"name": "b", "value": 2}
+        ],
+        "summary": {"total": 3}
+    }
+}
+`
+
+func TestParseJSONQuery(t *testing.T) {
+	parser := JSONParser{
+		MetricName: "json_test",
+		TagKeys:    []string{"name"},
+		JSONQuery:  "data.metrics",
+	}
+
+	// each element of an array is a metric
+	metrics, err := parser.Parse([]byte(nestedJSON))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 2)
+	assert.Equal(t, map[string]interface{}{"value": float64(1)}, metrics[0].Fields())
+	assert.Equal(t, map[string]string{"name": "a"}, metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{"value": float64(2)}, metrics[1].Fields())
+	assert.Equal(t, map[string]string{"name": "b"}, metrics[1].Tags())
+
+	parser.JSONQuery = "data.metrics.1"
+	metrics, err = parser.Parse([]byte(nestedJSON))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, map[string]string{"name": "b"}, metrics[0].Tags())
+
+	parser.JSONQuery = "data.summary"
+	metrics, err = parser.Parse([]byte(nestedJSON))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, map[string]interface{}{"total": float64(3)}, metrics[0].Fields())
+
+	parser.JSONQuery = "status"
+	_, err = parser.Parse([]byte(nestedJSON))
+	assert.Error(t, err)
+}
+
+func TestParseJSONQueryMissing(t *testing.T) {
+	parser := JSONParser{
+		MetricName: "json_test",
+		JSONQuery:  "data.missing",
+	}
+	_, err := parser.Parse([]byte(nestedJSON))
+	assert.Error(t, err)
+
+	parser.JSONQueryMissing = "skip"
+	metrics, err := parser.Parse([]byte(nestedJSON))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 0)
+
+	parser.JSONQueryMissing = "use_root"
+	metrics, err = parser.Parse([]byte(validJSON))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, map[string]interface{}{
+		"a":   float64(5),
+		"b_c": float64(6),
+	}, metrics[0].Fields())
+}
+
+func TestParseJSONStrictTypes(t *testing.T) {
+	parser := JSONParser{
+		MetricName:      "json_test",
+		JSONStrictTypes: true,
+	}
+	metrics, err := parser.Parse([]byte(`{"a": 5, "b": {"c": 6}, "d": "x"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"a":   float64(5),
+		"b_c": float64(6),
+	}, metrics[0].Fields())
+
+	// the types of the first metric are expected from then on
+	metrics, err = parser.Parse([]byte(`{"a": "7", "b": {"c": 8}, "d": 9}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"b_c": float64(8)},
+		metrics[0].Fields())
+}
+
+func TestParseJSONStrictTypesDataType(t *testing.T) {
+	parser := JSONParser{
+		MetricName:      "json_test",
+		JSONStrictTypes: true,
+		DataType:        "integer",
+	}
+	metrics, err := parser.Parse([]byte(`{"a": 5, "b": 1.5, "c": "6"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"a": int64(5)}, metrics[0].Fields())
+
+	parser.DataType = "string"
+	metrics, err = parser.Parse([]byte(`{"a": 5, "c": "6"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"c": "6"}, metrics[0].Fields())
+}
+
+func TestParseJSONPathPrefix(t *testing.T) {
+	parser := JSONParser{
+		MetricName:     "json_test",
+		JSONPathPrefix: "data_metrics_",
+	}
+	metrics, err := parser.Parse([]byte(
+		`{"data": {"metrics": {"cpu": {"usage": 0.4}}}, "status": 1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"cpu_usage": float64(0.4),
+		"status":    float64(1),
+	}, metrics[0].Fields())
+
+	parser.JSONStrictTypes = true
+	metrics, err = parser.Parse([]byte(`{"data": {"metrics": {"mem": 2}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"mem": float64(2)},
+		metrics[0].Fields())
+}
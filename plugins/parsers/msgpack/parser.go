@@ -0,0 +1,179 @@
+package msgpack
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/msgpack"
+)
+
+// MsgpackParser parses MessagePack encoded maps into metrics, one metric per
+// map. The buffer may hold several maps one after the other.
+type MsgpackParser struct {
+	MetricName  string
+	DefaultTags map[string]string
+
+	// TagKeys are the keys whose values become tags instead of fields. Keys
+	// of nested maps are joined with "_", ie "host_name".
+	TagKeys []string
+
+	// Format is empty to build each metric from the keys of a map, or
+	// "telegraf" or "influx" to parse maps written by the msgpack serializer
+	// in that format.
+	Format string
+}
+
+func (p *MsgpackParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	metrics := make([]telegraf.Metric, 0)
+	decoder := msgpack.NewDecoder(buf)
+	for {
+		v, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse out as msgpack, %s", err)
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unable to parse out as msgpack, expected "+
+				"a map, got %T", v)
+		}
+
+		var metric telegraf.Metric
+		if p.Format == "" {
+			metric, err = p.parseMap(m)
+		} else {
+			metric, err = p.parseMetric(m)
+		}
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, nil
+}
+
+// parseMap builds a metric from the flattened keys of a map.
+func (p *MsgpackParser) parseMap(m map[string]interface{}) (telegraf.Metric, error) {
+	fields := make(map[string]interface{})
+	flatten(fields, "", m)
+
+	tags := make(map[string]string)
+	for k, v := range p.DefaultTags {
+		tags[k] = v
+	}
+	for _, key := range p.TagKeys {
+		if v, ok := fields[key]; ok {
+			tags[key] = tagValue(v)
+			delete(fields, key)
+		}
+	}
+	return telegraf.NewMetric(p.MetricName, tags, fields, time.Now().UTC())
+}
+
+// parseMetric builds a metric from a map holding the name, tags, fields and
+// time of a metric, as written by the msgpack serializer.
+func (p *MsgpackParser) parseMetric(m map[string]interface{}) (telegraf.Metric, error) {
+	nameKey := "name"
+	if p.Format == "influx" {
+		nameKey = "measurement"
+	}
+	name, _ := m[nameKey].(string)
+	if name == "" {
+		name = p.MetricName
+	}
+
+	tags := make(map[string]string)
+	for k, v := range p.DefaultTags {
+		tags[k] = v
+	}
+	if mtags, ok := m["tags"].(map[string]interface{}); ok {
+		for k, v := range mtags {
+			tags[k] = tagValue(v)
+		}
+	}
+
+	fields := make(map[string]interface{})
+	if mfields, ok := m["fields"].(map[string]interface{}); ok {
+		flatten(fields, "", mfields)
+	}
+	for _, key := range p.TagKeys {
+		if v, ok := fields[key]; ok {
+			tags[key] = tagValue(v)
+			delete(fields, key)
+		}
+	}
+
+	timestamp := time.Now().UTC()
+	switch t := m["time"].(type) {
+	case time.Time:
+		timestamp = t
+	case int64:
+		timestamp = time.Unix(0, t).UTC()
+	case nil:
+	default:
+		return nil, fmt.Errorf("msgpack metric time %v is not a timestamp", t)
+	}
+	return telegraf.NewMetric(name, tags, fields, timestamp)
+}
+
+// flatten adds the values of v to fields, joining the keys of nested maps
+// and the indexes of arrays to their parent key with "_".
+func flatten(fields map[string]interface{}, key string, v interface{}) {
+	join := func(k string) string {
+		if key == "" {
+			return k
+		}
+		return key + "_" + k
+	}
+
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, item := range t {
+			flatten(fields, join(k), item)
+		}
+	case []interface{}:
+		for i, item := range t {
+			flatten(fields, join(strconv.Itoa(i)), item)
+		}
+	case uint64:
+		// only values too large for an int64 are decoded as uint64
+		fields[key] = int64(math.MaxInt64)
+	case []byte:
+		fields[key] = string(t)
+	case int64, float64, bool, string:
+		fields[key] = t
+	}
+}
+
+func tagValue(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func (p *MsgpackParser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("Can not parse the line: %s, for data format: msgpack ", line)
+	}
+
+	return metrics[0], nil
+}
+
+func (p *MsgpackParser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
@@ -0,0 +1,107 @@
+package msgpack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/internal/msgpack"
+)
+
+func marshal(t *testing.T, values ...interface{}) []byte {
+	var buf []byte
+	for _, v := range values {
+		b, err := msgpack.Marshal(v)
+		require.NoError(t, err)
+		buf = append(buf, b...)
+	}
+	return buf
+}
+
+func TestParseMaps(t *testing.T) {
+	parser := MsgpackParser{
+		MetricName:  "msgpack_test",
+		TagKeys:     []string{"host", "device_id"},
+		DefaultTags: map[string]string{"source": "test"},
+	}
+
+	metrics, err := parser.Parse(marshal(t,
+		map[string]interface{}{
+			"host":  "server01",
+			"value": 1.5,
+			"device": map[string]interface{}{
+				"id":    int64(7),
+				"temps": []interface{}{int64(20), int64(21)},
+			},
+		},
+		map[string]interface{}{
+			"host":   "server02",
+			"status": "ok",
+			"up":     true,
+		},
+	))
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+
+	assert.Equal(t, "msgpack_test", metrics[0].Name())
+	assert.Equal(t, map[string]string{
+		"source":    "test",
+		"host":      "server01",
+		"device_id": "7",
+	}, metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{
+		"value":          1.5,
+		"device_temps_0": int64(20),
+		"device_temps_1": int64(21),
+	}, metrics[0].Fields())
+
+	assert.Equal(t, map[string]interface{}{
+		"status": "ok",
+		"up":     true,
+	}, metrics[1].Fields())
+}
+
+func TestParseMetric(t *testing.T) {
+	now := time.Unix(1500000000, 5).UTC()
+
+	parser := MsgpackParser{Format: "telegraf"}
+	metrics, err := parser.Parse(marshal(t, map[string]interface{}{
+		"name":   "cpu",
+		"tags":   map[string]interface{}{"cpu": "cpu0"},
+		"fields": map[string]interface{}{"idle": 91.5},
+		"time":   now,
+	}))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "cpu", metrics[0].Name())
+	assert.Equal(t, map[string]string{"cpu": "cpu0"}, metrics[0].Tags())
+	assert.Equal(t, map[string]interface{}{"idle": 91.5}, metrics[0].Fields())
+	assert.Equal(t, now, metrics[0].Time())
+
+	parser = MsgpackParser{Format: "influx"}
+	metrics, err = parser.Parse(marshal(t, map[string]interface{}{
+		"measurement": "cpu",
+		"fields":      map[string]interface{}{"idle": 91.5},
+		"time":        now.UnixNano(),
+	}))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "cpu", metrics[0].Name())
+	assert.Equal(t, now, metrics[0].Time().UTC())
+}
+
+func TestParseInvalid(t *testing.T) {
+	parser := MsgpackParser{MetricName: "msgpack_test"}
+
+	_, err := parser.Parse(marshal(t, "not a map"))
+	assert.Error(t, err)
+
+	_, err = parser.Parse([]byte{0x81, 0xa1})
+	assert.Error(t, err)
+
+	// an array size far larger than the data must not be allocated
+	_, err = parser.Parse([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	assert.Error(t, err)
+}
@@ -0,0 +1,249 @@
+package prometheus
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+
+	"github.com/matttproud/golang_protobuf_extensions/pbutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Metric versions, the exposition formats parsed.
+const (
+	// MetricVersionText is the text exposition format.
+	MetricVersionText = 1
+	// MetricVersionProtobuf is the delimited protocol buffer format.
+	MetricVersionProtobuf = 2
+)
+
+// metricTypes are the names of the Prometheus metric types, as used by
+// prometheus_metric_types.
+var metricTypes = map[dto.MetricType]string{
+	dto.MetricType_COUNTER:   "counter",
+	dto.MetricType_GAUGE:     "gauge",
+	dto.MetricType_SUMMARY:   "summary",
+	dto.MetricType_UNTYPED:   "untyped",
+	dto.MetricType_HISTOGRAM: "histogram",
+}
+
+// PrometheusParser parses the Prometheus exposition formats. Each sample is a
+// metric named after its metric family, with its labels as tags. Counters
+// have a "counter" field, gauges a "gauge" field and untyped metrics a
+// "value" field. Summaries have a field per quantile, and histograms a field
+// per bucket upper bound, along with "count" and "sum" fields.
+type PrometheusParser struct {
+	// MetricVersion is MetricVersionText (the default) or
+	// MetricVersionProtobuf.
+	MetricVersion int
+	// IgnoreTimestamp timestamps the metrics with the time they are parsed,
+	// rather than the timestamp of their sample.
+	IgnoreTimestamp bool
+	// MetricTypes are the types of the metric families parsed, all of them
+	// if empty.
+	MetricTypes []string
+	// IncludeHelp adds the help text of the metric family as a
+	// "description" field.
+	IncludeHelp bool
+	DefaultTags map[string]string
+}
+
+// NewParser returns a parser of the exposition format metricVersion, 0 for
+// the default, checking metricTypes.
+func NewParser(
+	metricVersion int,
+	ignoreTimestamp bool,
+	metricTypes []string,
+	includeHelp bool,
+	defaultTags map[string]string,
+) (*PrometheusParser, error) {
+	switch metricVersion {
+	case 0:
+		metricVersion = MetricVersionText
+	case MetricVersionText, MetricVersionProtobuf:
+	default:
+		return nil, fmt.Errorf("Invalid prometheus_metric_version %d, must "+
+			"be 1 (text) or 2 (protobuf)", metricVersion)
+	}
+	for _, typ := range metricTypes {
+		if !validMetricType(typ) {
+			return nil, fmt.Errorf("Invalid prometheus_metric_types %q, must "+
+				"be \"counter\", \"gauge\", \"histogram\", \"summary\" or "+
+				"\"untyped\"", typ)
+		}
+	}
+	return &PrometheusParser{
+		MetricVersion:   metricVersion,
+		IgnoreTimestamp: ignoreTimestamp,
+		MetricTypes:     metricTypes,
+		IncludeHelp:     includeHelp,
+		DefaultTags:     defaultTags,
+	}, nil
+}
+
+func validMetricType(typ string) bool {
+	for _, name := range metricTypes {
+		if name == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PrometheusParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	families, err := p.metricFamilies(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var metrics []telegraf.Metric
+	for _, mf := range families {
+		if !p.parsesType(mf.GetType()) {
+			continue
+		}
+		for _, m := range mf.Metric {
+			fields := makeFields(mf.GetType(), m)
+			if len(fields) == 0 {
+				continue
+			}
+			if p.IncludeHelp && mf.GetHelp() != "" {
+				fields["description"] = mf.GetHelp()
+			}
+
+			tags := make(map[string]string)
+			for k, v := range p.DefaultTags {
+				tags[k] = v
+			}
+			for _, lp := range m.Label {
+				tags[lp.GetName()] = lp.GetValue()
+			}
+
+			t := now
+			if !p.IgnoreTimestamp && m.TimestampMs != nil && *m.TimestampMs > 0 {
+				t = time.Unix(0, *m.TimestampMs*int64(time.Millisecond))
+			}
+
+			var metric telegraf.Metric
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				metric, err = telegraf.NewCounterMetric(mf.GetName(), tags,
+					fields, t)
+			case dto.MetricType_GAUGE:
+				metric, err = telegraf.NewGaugeMetric(mf.GetName(), tags,
+					fields, t)
+			default:
+				metric, err = telegraf.NewMetric(mf.GetName(), tags, fields, t)
+			}
+			if err != nil {
+				return nil, err
+			}
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics, nil
+}
+
+// metricFamilies decodes the metric families of buf, in the order they
+// appear for the protobuf format.
+func (p *PrometheusParser) metricFamilies(buf []byte) ([]*dto.MetricFamily, error) {
+	var families []*dto.MetricFamily
+	if p.MetricVersion == MetricVersionProtobuf {
+		reader := bytes.NewReader(buf)
+		for {
+			mf := &dto.MetricFamily{}
+			if _, err := pbutil.ReadDelimited(reader, mf); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return nil, fmt.Errorf("reading metric family protocol "+
+					"buffer failed: %s", err)
+			}
+			families = append(families, mf)
+		}
+		return families, nil
+	}
+
+	var parser expfmt.TextParser
+	// parse even if the buffer begins with a newline
+	buf = bytes.TrimPrefix(buf, []byte("\n"))
+	byName, err := parser.TextToMetricFamilies(bufio.NewReader(
+		bytes.NewReader(buf)))
+	if err != nil {
+		return nil, fmt.Errorf("reading text format failed: %s", err)
+	}
+	for _, mf := range byName {
+		families = append(families, mf)
+	}
+	return families, nil
+}
+
+func (p *PrometheusParser) parsesType(typ dto.MetricType) bool {
+	if len(p.MetricTypes) == 0 {
+		return true
+	}
+	for _, name := range p.MetricTypes {
+		if name == metricTypes[typ] {
+			return true
+		}
+	}
+	return false
+}
+
+// makeFields returns the fields of a sample of a metric family of type typ.
+// NaN values are left out.
+func makeFields(typ dto.MetricType, m *dto.Metric) map[string]interface{} {
+	fields := make(map[string]interface{})
+	switch typ {
+	case dto.MetricType_SUMMARY:
+		for _, q := range m.GetSummary().Quantile {
+			if !math.IsNaN(q.GetValue()) {
+				fields[fmt.Sprint(q.GetQuantile())] = q.GetValue()
+			}
+		}
+		fields["count"] = float64(m.GetSummary().GetSampleCount())
+		fields["sum"] = m.GetSummary().GetSampleSum()
+	case dto.MetricType_HISTOGRAM:
+		for _, b := range m.GetHistogram().Bucket {
+			fields[fmt.Sprint(b.GetUpperBound())] = float64(b.GetCumulativeCount())
+		}
+		fields["count"] = float64(m.GetHistogram().GetSampleCount())
+		fields["sum"] = m.GetHistogram().GetSampleSum()
+	case dto.MetricType_COUNTER:
+		if v := m.GetCounter().GetValue(); !math.IsNaN(v) {
+			fields["counter"] = v
+		}
+	case dto.MetricType_GAUGE:
+		if v := m.GetGauge().GetValue(); !math.IsNaN(v) {
+			fields["gauge"] = v
+		}
+	default:
+		if v := m.GetUntyped().GetValue(); !math.IsNaN(v) {
+			fields["value"] = v
+		}
+	}
+	return fields
+}
+
+func (p *PrometheusParser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(strings.TrimSpace(line) + "\n"))
+	if err != nil {
+		return nil, err
+	}
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("Can not parse the line: %s, for data format: "+
+			"prometheus", line)
+	}
+	return metrics[0], nil
+}
+
+func (p *PrometheusParser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
@@ -0,0 +1,121 @@
+package prometheus
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/matttproud/golang_protobuf_extensions/pbutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exposition = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{method="post",code="200"} 1027 1395066363000
+# HELP go_goroutines Number of goroutines that currently exist.
+# TYPE go_goroutines gauge
+go_goroutines 15
+# HELP rpc_duration_seconds A summary of the RPC duration in seconds.
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 4773
+rpc_duration_seconds{quantile="0.9"} 9001
+rpc_duration_seconds_sum 1.7560473e+07
+rpc_duration_seconds_count 2693
+`
+
+func byName(metrics []telegraf.Metric) map[string]telegraf.Metric {
+	named := make(map[string]telegraf.Metric)
+	for _, m := range metrics {
+		named[m.Name()] = m
+	}
+	return named
+}
+
+func TestParse(t *testing.T) {
+	p, err := NewParser(0, false, nil, false,
+		map[string]string{"source": "exec"})
+	require.NoError(t, err)
+
+	metrics, err := p.Parse([]byte(exposition))
+	require.NoError(t, err)
+	require.Len(t, metrics, 3)
+	named := byName(metrics)
+
+	requests := named["http_requests_total"]
+	assert.Equal(t, telegraf.Counter, requests.Type())
+	assert.Equal(t, map[string]string{
+		"method": "post", "code": "200", "source": "exec",
+	}, requests.Tags())
+	assert.Equal(t, map[string]interface{}{"counter": float64(1027)},
+		requests.Fields())
+	assert.Equal(t, time.Unix(1395066363, 0).UnixNano(), requests.UnixNano())
+
+	goroutines := named["go_goroutines"]
+	assert.Equal(t, telegraf.Gauge, goroutines.Type())
+	assert.Equal(t, map[string]interface{}{"gauge": float64(15)},
+		goroutines.Fields())
+
+	assert.Equal(t, map[string]interface{}{
+		"0.5":   float64(4773),
+		"0.9":   float64(9001),
+		"sum":   float64(1.7560473e+07),
+		"count": float64(2693),
+	}, named["rpc_duration_seconds"].Fields())
+}
+
+func TestParse_Options(t *testing.T) {
+	p, err := NewParser(MetricVersionText, true, []string{"counter"}, true,
+		nil)
+	require.NoError(t, err)
+
+	before := time.Now()
+	metrics, err := p.Parse([]byte(exposition))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "http_requests_total", metrics[0].Name())
+	assert.Equal(t, "The total number of HTTP requests.",
+		metrics[0].Fields()["description"])
+	assert.False(t, metrics[0].Time().Before(before))
+}
+
+func TestParse_Protobuf(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := pbutil.WriteDelimited(&buf, &dto.MetricFamily{
+		Name: proto.String("go_goroutines"),
+		Type: dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{
+			Gauge: &dto.Gauge{Value: proto.Float64(15)},
+		}},
+	})
+	require.NoError(t, err)
+
+	p, err := NewParser(MetricVersionProtobuf, false, nil, false, nil)
+	require.NoError(t, err)
+	metrics, err := p.Parse(buf.Bytes())
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, map[string]interface{}{"gauge": float64(15)},
+		metrics[0].Fields())
+}
+
+func TestParseLine(t *testing.T) {
+	p, err := NewParser(0, false, nil, false, nil)
+	require.NoError(t, err)
+
+	m, err := p.ParseLine(`http_requests_total{method="get"} 3`)
+	require.NoError(t, err)
+	assert.Equal(t, "http_requests_total", m.Name())
+	assert.Equal(t, map[string]interface{}{"value": float64(3)}, m.Fields())
+}
+
+func TestNewParser_Invalid(t *testing.T) {
+	_, err := NewParser(3, false, nil, false, nil)
+	assert.Error(t, err)
+	_, err = NewParser(0, false, []string{"counters"}, false, nil)
+	assert.Error(t, err)
+}
@@ -2,14 +2,22 @@ package parsers
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/influxdata/telegraf"
 
+	"github.com/influxdata/telegraf/plugins/parsers/binary"
+	"github.com/influxdata/telegraf/plugins/parsers/collectd"
 	"github.com/influxdata/telegraf/plugins/parsers/graphite"
+	"github.com/influxdata/telegraf/plugins/parsers/hec"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
 	"github.com/influxdata/telegraf/plugins/parsers/json"
+	"github.com/influxdata/telegraf/plugins/parsers/msgpack"
 	"github.com/influxdata/telegraf/plugins/parsers/nagios"
+	"github.com/influxdata/telegraf/plugins/parsers/prometheus"
 	"github.com/influxdata/telegraf/plugins/parsers/value"
+	"github.com/influxdata/telegraf/plugins/parsers/xpath"
 )
 
 // ParserInput is an interface for input plugins that are able to parse
@@ -40,7 +48,8 @@ type Parser interface {
 // Config is a struct that covers the data types needed for all parser types,
 // and can be used to instantiate _any_ of the parsers.
 type Config struct {
-	// Dataformat can be one of: json, influx, graphite, value, nagios
+	// Dataformat can be one of: json, influx, graphite, value, nagios,
+	// xpath_json, xpath_xml, binary, msgpack, collectd, prometheus, hec
 	DataFormat string
 
 	// Separator only applied to Graphite data.
@@ -50,14 +59,61 @@ type Config struct {
 
 	// TagKeys only apply to JSON data
 	TagKeys []string
+	// JSONQuery only applies to JSON data, it is the dot separated path of
+	// the object or array of objects to parse. JSONQueryMissing is "error"
+	// (the default), "skip" or "use_root", for when the path is not found.
+	JSONQuery        string
+	JSONQueryMissing string
+	// JSONTimeKey, JSONTimeFormat and JSONTimezone only apply to JSON data,
+	// they select and parse the key holding the metric timestamp.
+	JSONTimeKey    string
+	JSONTimeFormat string
+	JSONTimezone   string
+	// JSONStrictTypes only applies to JSON data, it skips the fields whose
+	// value is not of the type set by DataType, or of their first value.
+	JSONStrictTypes bool
+	// JSONPathPrefix only applies to JSON data, it is stripped from the start
+	// of the flattened field keys.
+	JSONPathPrefix string
 	// MetricName applies to JSON & value. This will be the name of the measurement.
 	MetricName string
 
-	// DataType only applies to value, this will be the type to parse value to
+	// DataType only applies to value, this will be the type to parse value to.
+	// It also applies to JSON data with JSONStrictTypes.
 	DataType string
 
 	// DefaultTags are the default tags that will be added to all parsed metrics.
 	DefaultTags map[string]string
+
+	// XPathConfig only applies to xpath_json and xpath_xml, it describes how
+	// to build metrics from the parsed document.
+	XPathConfig []xpath.Config
+
+	// BinaryLayout and BinaryEndianness only apply to binary data.
+	// BinaryLayout describes each field as "name:type:offset_bits:length_bits".
+	BinaryLayout     []string
+	BinaryEndianness string
+
+	// MsgpackTagKeys and MsgpackFormat only apply to msgpack data.
+	// MsgpackFormat is empty to parse plain maps, or "telegraf" or "influx"
+	// to parse metrics written by the msgpack serializer.
+	MsgpackTagKeys []string
+	MsgpackFormat  string
+
+	// CollectdSecurityLevel, CollectdAuthFile and CollectdTypesDB only apply
+	// to collectd data. The security level is "none", "sign" or "encrypt".
+	CollectdSecurityLevel string
+	CollectdAuthFile      string
+	CollectdTypesDB       []string
+
+	// PrometheusMetricVersion, PrometheusIgnoreTimestamp,
+	// PrometheusMetricTypes and PrometheusIncludeHelp only apply to
+	// prometheus data. The metric version is 1 for the text format, 2 for
+	// the protobuf format.
+	PrometheusMetricVersion   int
+	PrometheusIgnoreTimestamp bool
+	PrometheusMetricTypes     []string
+	PrometheusIncludeHelp     bool
 }
 
 // NewParser returns a Parser interface based on the given config.
@@ -66,8 +122,7 @@ func NewParser(config *Config) (Parser, error) {
 	var parser Parser
 	switch config.DataFormat {
 	case "json":
-		parser, err = NewJSONParser(config.MetricName,
-			config.TagKeys, config.DefaultTags)
+		parser, err = newJSONParser(config)
 	case "value":
 		parser, err = NewValueParser(config.MetricName,
 			config.DataType, config.DefaultTags)
@@ -78,6 +133,24 @@ func NewParser(config *Config) (Parser, error) {
 	case "graphite":
 		parser, err = NewGraphiteParser(config.Separator,
 			config.Templates, config.DefaultTags)
+	case "xpath_json", "xpath_xml":
+		parser, err = NewXPathParser(strings.TrimPrefix(config.DataFormat, "xpath_"),
+			config.MetricName, config.XPathConfig, config.DefaultTags)
+	case "binary":
+		parser, err = NewBinaryParser(config.MetricName, config.BinaryLayout,
+			config.BinaryEndianness, config.DefaultTags)
+	case "msgpack":
+		parser, err = NewMsgpackParser(config.MetricName, config.MsgpackTagKeys,
+			config.MsgpackFormat, config.DefaultTags)
+	case "collectd":
+		parser, err = NewCollectdParser(config.CollectdSecurityLevel,
+			config.CollectdAuthFile, config.CollectdTypesDB, config.DefaultTags)
+	case "prometheus":
+		parser, err = NewPrometheusParser(config.PrometheusMetricVersion,
+			config.PrometheusIgnoreTimestamp, config.PrometheusMetricTypes,
+			config.PrometheusIncludeHelp, config.DefaultTags)
+	case "hec":
+		parser, err = NewHECParser(config.MetricName, config.DefaultTags)
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
@@ -97,6 +170,66 @@ func NewJSONParser(
 	return parser, nil
 }
 
+// NewJSONTimeParser returns a JSON parser taking the metric timestamp from
+// the timeKey key.
+func NewJSONTimeParser(
+	metricName string,
+	tagKeys []string,
+	timeKey string,
+	timeFormat string,
+	timezone string,
+	defaultTags map[string]string,
+) (Parser, error) {
+	return newJSONParser(&Config{
+		MetricName:     metricName,
+		TagKeys:        tagKeys,
+		JSONTimeKey:    timeKey,
+		JSONTimeFormat: timeFormat,
+		JSONTimezone:   timezone,
+		DefaultTags:    defaultTags,
+	})
+}
+
+func newJSONParser(config *Config) (Parser, error) {
+	if config.JSONTimeKey != "" && config.JSONTimeFormat == "" {
+		return nil, fmt.Errorf("json_time_format must be set with json_time_key")
+	}
+	if config.JSONTimezone != "" {
+		if _, err := time.LoadLocation(config.JSONTimezone); err != nil {
+			return nil, fmt.Errorf("Invalid json_timezone %s, %s",
+				config.JSONTimezone, err)
+		}
+	}
+	switch config.JSONQueryMissing {
+	case "", "error", "skip", "use_root":
+	default:
+		return nil, fmt.Errorf("Invalid json_query_missing %q, must be "+
+			"\"error\", \"skip\" or \"use_root\"", config.JSONQueryMissing)
+	}
+	if config.JSONStrictTypes && config.DataType != "" &&
+		!json.ValidDataType(config.DataType) {
+		return nil, fmt.Errorf("Invalid data_type %q for json_strict_types, "+
+			"must be \"integer\", \"float\", \"string\" or \"boolean\"",
+			config.DataType)
+	}
+	parser := &json.JSONParser{
+		MetricName:       config.MetricName,
+		TagKeys:          config.TagKeys,
+		DefaultTags:      config.DefaultTags,
+		JSONQuery:        config.JSONQuery,
+		JSONQueryMissing: config.JSONQueryMissing,
+		JSONTimeKey:      config.JSONTimeKey,
+		JSONTimeFormat:   config.JSONTimeFormat,
+		JSONTimezone:     config.JSONTimezone,
+		JSONStrictTypes:  config.JSONStrictTypes,
+		JSONPathPrefix:   config.JSONPathPrefix,
+	}
+	if config.JSONStrictTypes {
+		parser.DataType = config.DataType
+	}
+	return parser, nil
+}
+
 func NewNagiosParser() (Parser, error) {
 	return &nagios.NagiosParser{}, nil
 }
@@ -124,3 +257,71 @@ func NewValueParser(
 		DefaultTags: defaultTags,
 	}, nil
 }
+
+func NewXPathParser(
+	format string,
+	metricName string,
+	configs []xpath.Config,
+	defaultTags map[string]string,
+) (Parser, error) {
+	return xpath.NewParser(format, metricName, configs, defaultTags)
+}
+
+func NewBinaryParser(
+	metricName string,
+	layout []string,
+	endianness string,
+	defaultTags map[string]string,
+) (Parser, error) {
+	return binary.NewParser(metricName, layout, endianness, defaultTags)
+}
+
+func NewMsgpackParser(
+	metricName string,
+	tagKeys []string,
+	format string,
+	defaultTags map[string]string,
+) (Parser, error) {
+	switch format {
+	case "", "telegraf", "influx":
+	default:
+		return nil, fmt.Errorf("Invalid msgpack_format %q, must be "+
+			"\"telegraf\" or \"influx\"", format)
+	}
+	return &msgpack.MsgpackParser{
+		MetricName:  metricName,
+		TagKeys:     tagKeys,
+		Format:      format,
+		DefaultTags: defaultTags,
+	}, nil
+}
+
+func NewCollectdParser(
+	securityLevel string,
+	authFile string,
+	typesDB []string,
+	defaultTags map[string]string,
+) (Parser, error) {
+	return collectd.NewParser(securityLevel, authFile, typesDB, defaultTags)
+}
+
+func NewPrometheusParser(
+	metricVersion int,
+	ignoreTimestamp bool,
+	metricTypes []string,
+	includeHelp bool,
+	defaultTags map[string]string,
+) (Parser, error) {
+	return prometheus.NewParser(metricVersion, ignoreTimestamp, metricTypes,
+		includeHelp, defaultTags)
+}
+
+func NewHECParser(
+	metricName string,
+	defaultTags map[string]string,
+) (Parser, error) {
+	return &hec.HECParser{
+		MetricName:  metricName,
+		DefaultTags: defaultTags,
+	}, nil
+}
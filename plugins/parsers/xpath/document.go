@@ -0,0 +1,158 @@
+package xpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// node is an element, attribute or text node of a parsed XML or JSON
+// document. The document itself is a node with an empty name and no parent.
+type node struct {
+	name     string
+	text     string
+	isAttr   bool
+	isText   bool
+	parent   *node
+	attrs    []*node
+	children []*node
+}
+
+func (n *node) addChild(name string) *node {
+	child := &node{name: name, parent: n}
+	n.children = append(n.children, child)
+	return child
+}
+
+// value returns the string value of the node, which for elements is the
+// concatenated text of all its descendants.
+func (n *node) value() string {
+	if n.isAttr || n.isText || len(n.children) == 0 {
+		return n.text
+	}
+	var buf bytes.Buffer
+	buf.WriteString(n.text)
+	for _, child := range n.children {
+		buf.WriteString(child.value())
+	}
+	return buf.String()
+}
+
+// parseXML parses an XML document into a node tree.
+func parseXML(buf []byte) (*node, error) {
+	doc := &node{}
+	current := doc
+	decoder := xml.NewDecoder(bytes.NewReader(buf))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse out as XML, %s", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			current = current.addChild(t.Name.Local)
+			for _, attr := range t.Attr {
+				current.attrs = append(current.attrs, &node{
+					name:   attr.Name.Local,
+					text:   attr.Value,
+					isAttr: true,
+					parent: current,
+				})
+			}
+		case xml.EndElement:
+			current = current.parent
+		case xml.CharData:
+			if current != doc {
+				current.text += string(t)
+			}
+		}
+	}
+	if len(doc.children) == 0 {
+		return nil, fmt.Errorf("unable to parse out as XML, no root element")
+	}
+	return doc, nil
+}
+
+// parseJSON parses a JSON document into a node tree. Object keys become
+// child elements, and the items of an array become sibling elements named
+// after the key holding the array. Items of a top-level array have an empty
+// name, and can be selected with "/*".
+func parseJSON(buf []byte) (*node, error) {
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, fmt.Errorf("unable to parse out as JSON, %s", err)
+	}
+
+	doc := &node{}
+	if obj, ok := v.(map[string]interface{}); ok {
+		addJSONObject(doc, obj)
+	} else {
+		addJSON(doc, "", v)
+	}
+	return doc, nil
+}
+
+func addJSONObject(n *node, obj map[string]interface{}) {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		addJSON(n, k, obj[k])
+	}
+}
+
+func addJSON(parent *node, name string, v interface{}) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		addJSONObject(parent.addChild(name), t)
+	case []interface{}:
+		for _, item := range t {
+			if _, ok := item.([]interface{}); ok {
+				// nested arrays get an element of their own
+				addJSON(parent.addChild(name), "", item)
+				continue
+			}
+			addJSON(parent, name, item)
+		}
+	case json.Number:
+		parent.addChild(name).text = t.String()
+	case string:
+		parent.addChild(name).text = t
+	case bool:
+		parent.addChild(name).text = strconv.FormatBool(t)
+	case nil:
+		parent.addChild(name)
+	}
+}
+
+// convert turns a selected string value into an int64, float64 or bool
+// field value when possible.
+func convert(s string) interface{} {
+	s = strings.TrimSpace(s)
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	return s
+}
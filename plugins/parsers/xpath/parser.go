@@ -0,0 +1,155 @@
+package xpath
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Config is one xpath_config table, describing how to build metrics from the
+// parsed document.
+type Config struct {
+	// MetricName is the name of the metrics, the parser's metric name is used
+	// if empty.
+	MetricName string `toml:"metric_name"`
+	// MetricSelection selects the nodes from which one metric each is built,
+	// defaults to the document root.
+	MetricSelection string `toml:"metric_selection"`
+	// Fields and Tags map field and tag names to XPath queries, relative to
+	// each selected metric node.
+	Fields map[string]string
+	Tags   map[string]string
+}
+
+type compiledConfig struct {
+	Config
+	selection *query
+	fields    map[string]*query
+	tags      map[string]*query
+}
+
+// XPathParser parses XML or JSON documents into metrics using XPath queries.
+type XPathParser struct {
+	MetricName  string
+	DefaultTags map[string]string
+
+	parse   func(buf []byte) (*node, error)
+	configs []compiledConfig
+}
+
+// NewParser returns a parser for the "xml" or "json" format, and compiles the
+// XPath queries of the configs.
+func NewParser(
+	format string,
+	metricName string,
+	configs []Config,
+	defaultTags map[string]string,
+) (*XPathParser, error) {
+	p := &XPathParser{
+		MetricName:  metricName,
+		DefaultTags: defaultTags,
+	}
+	switch format {
+	case "xml":
+		p.parse = parseXML
+	case "json":
+		p.parse = parseJSON
+	default:
+		return nil, fmt.Errorf("Invalid xpath document format: %s", format)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("xpath data formats need at least one xpath_config")
+	}
+
+	for _, config := range configs {
+		cc := compiledConfig{
+			Config: config,
+			fields: make(map[string]*query),
+			tags:   make(map[string]*query),
+		}
+		selection := config.MetricSelection
+		if selection == "" {
+			selection = "/"
+		}
+		var err error
+		if cc.selection, err = compile(selection); err != nil {
+			return nil, err
+		}
+		for name, expr := range config.Fields {
+			if cc.fields[name], err = compile(expr); err != nil {
+				return nil, err
+			}
+		}
+		for name, expr := range config.Tags {
+			if cc.tags[name], err = compile(expr); err != nil {
+				return nil, err
+			}
+		}
+		p.configs = append(p.configs, cc)
+	}
+	return p, nil
+}
+
+func (p *XPathParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	doc, err := p.parse(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]telegraf.Metric, 0)
+	now := time.Now().UTC()
+	for _, config := range p.configs {
+		name := config.MetricName
+		if name == "" {
+			name = p.MetricName
+		}
+
+		for _, selected := range config.selection.selectNodes(doc) {
+			tags := make(map[string]string)
+			for k, v := range p.DefaultTags {
+				tags[k] = v
+			}
+			for tag, q := range config.tags {
+				if nodes := q.selectNodes(selected); len(nodes) > 0 {
+					tags[tag] = strings.TrimSpace(nodes[0].value())
+				}
+			}
+
+			fields := make(map[string]interface{})
+			for field, q := range config.fields {
+				if nodes := q.selectNodes(selected); len(nodes) > 0 {
+					fields[field] = convert(nodes[0].value())
+				}
+			}
+			if len(fields) == 0 {
+				continue
+			}
+
+			metric, err := telegraf.NewMetric(name, tags, fields, now)
+			if err != nil {
+				return nil, err
+			}
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics, nil
+}
+
+func (p *XPathParser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line + "\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("Can not parse the line: %s, for data format: xpath ", line)
+	}
+
+	return metrics[0], nil
+}
+
+func (p *XPathParser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
@@ -0,0 +1,162 @@
+package xpath
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validXML = `<?xml version="1.0"?>
+<status>
+  <host name="server01" region="us-west">
+    <disk device="sda"><used>55</used><free>45.5</free></disk>
+    <disk device="sdb"><used>10</used><free>90</free></disk>
+    <healthy>true</healthy>
+  </host>
+</status>
+`
+
+const validJSON = `{
+  "host": "server01",
+  "disks": [
+    {"device": "sda", "used": 55, "free": 45.5, "mounted": true},
+    {"device": "sdb", "used": 10, "free": 90, "mounted": false}
+  ],
+  "version": "1.2.3"
+}`
+
+func TestParseXML(t *testing.T) {
+	p, err := NewParser("xml", "exec", []Config{
+		{
+			MetricName:      "disk",
+			MetricSelection: "//disk",
+			Fields: map[string]string{
+				"used": "used",
+				"free": "free/text()",
+			},
+			Tags: map[string]string{
+				"device": "@device",
+				"host":   "../@name",
+			},
+		},
+		{
+			MetricSelection: "/status/host",
+			Fields: map[string]string{
+				"healthy":  "healthy",
+				"sda_used": "disk[@device='sda']/used",
+				"last":     "disk[last()]/used",
+			},
+		},
+	}, map[string]string{"source": "test"})
+	require.NoError(t, err)
+
+	metrics, err := p.Parse([]byte(validXML))
+	require.NoError(t, err)
+	require.Len(t, metrics, 3)
+
+	assert.Equal(t, "disk", metrics[0].Name())
+	assert.Equal(t, map[string]interface{}{
+		"used": int64(55),
+		"free": float64(45.5),
+	}, metrics[0].Fields())
+	assert.Equal(t, map[string]string{
+		"device": "sda",
+		"host":   "server01",
+		"source": "test",
+	}, metrics[0].Tags())
+	assert.Equal(t, "sdb", metrics[1].Tags()["device"])
+
+	assert.Equal(t, "exec", metrics[2].Name())
+	assert.Equal(t, map[string]interface{}{
+		"healthy":  true,
+		"sda_used": int64(55),
+		"last":     int64(10),
+	}, metrics[2].Fields())
+}
+
+func TestParseJSON(t *testing.T) {
+	p, err := NewParser("json", "exec", []Config{
+		{
+			MetricName:      "disk",
+			MetricSelection: "/disks[used>0]",
+			Fields: map[string]string{
+				"used":    "used",
+				"mounted": "mounted",
+			},
+			Tags: map[string]string{
+				"device":  "device",
+				"host":    "/host",
+				"version": "../version",
+			},
+		},
+		{
+			MetricName:      "disk",
+			MetricSelection: "/disks[2]",
+			Fields:          map[string]string{"free": "free"},
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	metrics, err := p.Parse([]byte(validJSON))
+	require.NoError(t, err)
+	require.Len(t, metrics, 3)
+
+	assert.Equal(t, map[string]interface{}{
+		"used":    int64(55),
+		"mounted": true,
+	}, metrics[0].Fields())
+	assert.Equal(t, map[string]string{
+		"device":  "sda",
+		"host":    "server01",
+		"version": "1.2.3",
+	}, metrics[0].Tags())
+	assert.Equal(t, false, metrics[1].Fields()["mounted"])
+	assert.Equal(t, map[string]interface{}{"free": int64(90)},
+		metrics[2].Fields())
+}
+
+func TestParseNoMatch(t *testing.T) {
+	p, err := NewParser("json", "exec", []Config{
+		{Fields: map[string]string{"missing": "/nothing/here"}},
+	}, nil)
+	require.NoError(t, err)
+
+	metrics, err := p.Parse([]byte(validJSON))
+	require.NoError(t, err)
+	assert.Empty(t, metrics)
+
+	_, err = p.ParseLine(validJSON)
+	assert.Error(t, err)
+}
+
+func TestParseInvalid(t *testing.T) {
+	p, err := NewParser("xml", "exec", []Config{
+		{Fields: map[string]string{"value": "/a"}},
+	}, nil)
+	require.NoError(t, err)
+	_, err = p.Parse([]byte("<a>1</b>"))
+	assert.Error(t, err)
+
+	p, err = NewParser("json", "exec", []Config{
+		{Fields: map[string]string{"value": "/a"}},
+	}, nil)
+	require.NoError(t, err)
+	_, err = p.Parse([]byte("{"))
+	assert.Error(t, err)
+}
+
+func TestNewParserErrors(t *testing.T) {
+	fields := map[string]string{"value": "/a"}
+	_, err := NewParser("yaml", "exec", []Config{{Fields: fields}}, nil)
+	assert.Error(t, err)
+	_, err = NewParser("json", "exec", nil, nil)
+	assert.Error(t, err)
+
+	for _, expr := range []string{"", "/a[", "/a[0]", "/a[b='c]", "/a/", "/a]"} {
+		_, err = NewParser("json", "exec", []Config{
+			{Fields: map[string]string{"value": expr}},
+		}, nil)
+		assert.Error(t, err, expr)
+	}
+}
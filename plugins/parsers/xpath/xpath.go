@@ -0,0 +1,374 @@
+package xpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// query is a compiled XPath location path. Only a subset of XPath 1.0 is
+// supported:
+//   - absolute ("/a/b") and relative ("a/b") paths, and "//" for descendants
+//   - "." and "..", element names, "*", "@attr", "@*" and "text()" steps
+//   - predicates with a position ("[1]", "[last()]"), an existence test
+//     ("[b]", "[@id]") or a comparison to a literal ("[@id='1']", "[b!=2]",
+//     "[b>=2]")
+type query struct {
+	absolute bool
+	steps    []step
+}
+
+type step struct {
+	// descendant is set when the step follows "//"
+	descendant bool
+	kind       stepKind
+	name       string
+	predicates []predicate
+}
+
+type stepKind int
+
+const (
+	stepChild stepKind = iota
+	stepAttr
+	stepText
+	stepSelf
+	stepParent
+)
+
+type predicate struct {
+	position int
+	last     bool
+	path     *query
+	op       string
+	literal  string
+}
+
+// compile parses an XPath expression into a query.
+func compile(expr string) (*query, error) {
+	c := &compiler{expr: strings.TrimSpace(expr)}
+	q, err := c.path()
+	if err != nil {
+		return nil, fmt.Errorf("invalid XPath %q, %s", expr, err)
+	}
+	if c.pos != len(c.expr) {
+		return nil, fmt.Errorf("invalid XPath %q, unexpected %q at %d",
+			expr, c.expr[c.pos:], c.pos)
+	}
+	return q, nil
+}
+
+type compiler struct {
+	expr string
+	pos  int
+}
+
+func (c *compiler) peek(s string) bool {
+	return strings.HasPrefix(c.expr[c.pos:], s)
+}
+
+func (c *compiler) skipSpace() {
+	for c.pos < len(c.expr) && c.expr[c.pos] == ' ' {
+		c.pos++
+	}
+}
+
+func (c *compiler) path() (*query, error) {
+	q := &query{}
+	descendant := false
+	switch {
+	case c.peek("//"):
+		q.absolute, descendant = true, true
+		c.pos += 2
+	case c.peek("/"):
+		q.absolute = true
+		c.pos++
+		if c.pos == len(c.expr) {
+			// "/" alone selects the document
+			return q, nil
+		}
+	}
+
+	for {
+		s, err := c.step()
+		if err != nil {
+			return nil, err
+		}
+		s.descendant = descendant
+		q.steps = append(q.steps, s)
+
+		switch {
+		case c.peek("//"):
+			descendant = true
+			c.pos += 2
+		case c.peek("/"):
+			descendant = false
+			c.pos++
+		default:
+			return q, nil
+		}
+	}
+}
+
+func (c *compiler) step() (step, error) {
+	var s step
+	switch {
+	case c.peek(".."):
+		s.kind = stepParent
+		c.pos += 2
+	case c.peek("."):
+		s.kind = stepSelf
+		c.pos++
+	case c.peek("text()"):
+		s.kind = stepText
+		c.pos += len("text()")
+	case c.peek("@"):
+		c.pos++
+		s.kind = stepAttr
+		s.name = c.name()
+		if s.name == "" {
+			return s, fmt.Errorf("missing attribute name at %d", c.pos)
+		}
+	default:
+		s.kind = stepChild
+		s.name = c.name()
+		if s.name == "" {
+			return s, fmt.Errorf("missing element name at %d", c.pos)
+		}
+	}
+
+	for c.peek("[") {
+		c.pos++
+		p, err := c.predicate()
+		if err != nil {
+			return s, err
+		}
+		s.predicates = append(s.predicates, p)
+	}
+	return s, nil
+}
+
+func (c *compiler) name() string {
+	if c.peek("*") {
+		c.pos++
+		return "*"
+	}
+	start := c.pos
+	for c.pos < len(c.expr) {
+		ch := c.expr[c.pos]
+		if strings.IndexByte("/[]@=!<> ()", ch) >= 0 {
+			break
+		}
+		c.pos++
+	}
+	return c.expr[start:c.pos]
+}
+
+func (c *compiler) predicate() (predicate, error) {
+	var p predicate
+	c.skipSpace()
+
+	start := c.pos
+	for c.pos < len(c.expr) && c.expr[c.pos] >= '0' && c.expr[c.pos] <= '9' {
+		c.pos++
+	}
+	switch {
+	case c.pos > start:
+		p.position, _ = strconv.Atoi(c.expr[start:c.pos])
+		if p.position < 1 {
+			return p, fmt.Errorf("invalid position %d", p.position)
+		}
+	case c.peek("last()"):
+		p.last = true
+		c.pos += len("last()")
+	default:
+		path, err := c.path()
+		if err != nil {
+			return p, err
+		}
+		p.path = path
+
+		c.skipSpace()
+		for _, op := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+			if c.peek(op) {
+				p.op = op
+				c.pos += len(op)
+				break
+			}
+		}
+		if p.op != "" {
+			c.skipSpace()
+			literal, err := c.literal()
+			if err != nil {
+				return p, err
+			}
+			p.literal = literal
+		}
+	}
+
+	c.skipSpace()
+	if !c.peek("]") {
+		return p, fmt.Errorf("missing ] at %d", c.pos)
+	}
+	c.pos++
+	return p, nil
+}
+
+func (c *compiler) literal() (string, error) {
+	if c.peek("'") || c.peek(`"`) {
+		quote := c.expr[c.pos : c.pos+1]
+		end := strings.Index(c.expr[c.pos+1:], quote)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string at %d", c.pos)
+		}
+		literal := c.expr[c.pos+1 : c.pos+1+end]
+		c.pos += end + 2
+		return literal, nil
+	}
+
+	start := c.pos
+	for c.pos < len(c.expr) && strings.IndexByte("+-.0123456789", c.expr[c.pos]) >= 0 {
+		c.pos++
+	}
+	if c.pos == start {
+		return "", fmt.Errorf("expected a string or number at %d", c.pos)
+	}
+	return c.expr[start:c.pos], nil
+}
+
+// selectNodes returns the nodes selected by q from the context node, in
+// document order.
+func (q *query) selectNodes(context *node) []*node {
+	nodes := []*node{context}
+	if q.absolute {
+		for nodes[0].parent != nil {
+			nodes[0] = nodes[0].parent
+		}
+	}
+	for _, s := range q.steps {
+		nodes = s.apply(nodes)
+	}
+	return nodes
+}
+
+func (s step) apply(context []*node) []*node {
+	var result []*node
+	seen := make(map[*node]bool)
+	for _, n := range context {
+		bases := []*node{n}
+		if s.descendant {
+			bases = descendantsOrSelf(n)
+		}
+		for _, base := range bases {
+			for _, candidate := range s.filter(s.candidates(base)) {
+				if !seen[candidate] {
+					seen[candidate] = true
+					result = append(result, candidate)
+				}
+			}
+		}
+	}
+	return result
+}
+
+func (s step) candidates(n *node) []*node {
+	var nodes []*node
+	switch s.kind {
+	case stepSelf:
+		nodes = append(nodes, n)
+	case stepParent:
+		if n.parent != nil {
+			nodes = append(nodes, n.parent)
+		}
+	case stepText:
+		if strings.TrimSpace(n.text) != "" && !n.isAttr && !n.isText {
+			nodes = append(nodes, &node{text: n.text, isText: true, parent: n})
+		}
+	case stepAttr:
+		for _, attr := range n.attrs {
+			if s.name == "*" || attr.name == s.name {
+				nodes = append(nodes, attr)
+			}
+		}
+	case stepChild:
+		for _, child := range n.children {
+			if s.name == "*" || child.name == s.name {
+				nodes = append(nodes, child)
+			}
+		}
+	}
+	return nodes
+}
+
+func (s step) filter(nodes []*node) []*node {
+	for _, p := range s.predicates {
+		var kept []*node
+		for i, n := range nodes {
+			if p.match(n, i+1, len(nodes)) {
+				kept = append(kept, n)
+			}
+		}
+		nodes = kept
+	}
+	return nodes
+}
+
+func (p predicate) match(n *node, position, size int) bool {
+	switch {
+	case p.position != 0:
+		return position == p.position
+	case p.last:
+		return position == size
+	}
+
+	selected := p.path.selectNodes(n)
+	if p.op == "" {
+		return len(selected) > 0
+	}
+	for _, s := range selected {
+		if compare(s.value(), p.op, p.literal) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares a node value to a literal, numerically if both are
+// numbers. Only "=" and "!=" apply to strings.
+func compare(value, op, literal string) bool {
+	v, err1 := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	l, err2 := strconv.ParseFloat(literal, 64)
+	if err1 != nil || err2 != nil {
+		switch op {
+		case "=":
+			return value == literal
+		case "!=":
+			return value != literal
+		}
+		return false
+	}
+
+	switch op {
+	case "=":
+		return v == l
+	case "!=":
+		return v != l
+	case "<":
+		return v < l
+	case "<=":
+		return v <= l
+	case ">":
+		return v > l
+	case ">=":
+		return v >= l
+	}
+	return false
+}
+
+func descendantsOrSelf(n *node) []*node {
+	nodes := []*node{n}
+	for _, child := range n.children {
+		nodes = append(nodes, descendantsOrSelf(child)...)
+	}
+	return nodes
+}
@@ -0,0 +1,84 @@
+package carbon2
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+var sanitizedChars = strings.NewReplacer(" ", "_", "=", "_")
+
+// Carbon2Serializer writes metrics in the Carbon 2.0 plaintext format, one
+// line per field:
+//
+//	metric=<name> field=<field> <tag>=<value>...  <value> <unix seconds>
+//
+// The metric name and field name are intrinsic tags along with the metric
+// tags. String fields are skipped, booleans are written as 1 or 0.
+type Carbon2Serializer struct {
+}
+
+func (s *Carbon2Serializer) Serialize(metric telegraf.Metric) ([]string, error) {
+	out := []string{}
+
+	tags := metric.Tags()
+	tagKeys := make([]string, 0, len(tags))
+	for k := range tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+	var tagParts []string
+	for _, k := range tagKeys {
+		tagParts = append(tagParts,
+			sanitizedChars.Replace(k)+"="+sanitizedChars.Replace(tags[k]))
+	}
+
+	fields := metric.Fields()
+	fieldNames := make([]string, 0, len(fields))
+	for name := range fields {
+		fieldNames = append(fieldNames, name)
+	}
+	sort.Strings(fieldNames)
+
+	// the format needs a timestamp, metrics without one get the write time
+	timestamp := metric.UnixNano() / 1000000000
+	if metric.Time().IsZero() {
+		timestamp = time.Now().Unix()
+	}
+	for _, name := range fieldNames {
+		value, ok := formatValue(fields[name])
+		if !ok {
+			continue
+		}
+		intrinsic := []string{
+			"metric=" + sanitizedChars.Replace(metric.Name()),
+			"field=" + sanitizedChars.Replace(name),
+		}
+		out = append(out, fmt.Sprintf("%s  %s %d",
+			strings.Join(append(intrinsic, tagParts...), " "),
+			value, timestamp))
+	}
+	return out, nil
+}
+
+// formatValue formats a numeric or boolean field value.
+func formatValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		if v {
+			return "1", true
+		}
+		return "0", true
+	}
+	return "", false
+}
@@ -0,0 +1,70 @@
+package carbon2
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/influxdata/telegraf"
+)
+
+func TestSerializeMetric(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	tags := map[string]string{
+		"host": "server01",
+		"cpu":  "cpu 0",
+	}
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+		"usage_user": int64(8),
+		"online":     true,
+		"state":      "ok",
+	}
+	m, err := telegraf.NewMetric("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := Carbon2Serializer{}
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"metric=cpu field=online cpu=cpu_0 host=server01  1 1500000000",
+		"metric=cpu field=usage_idle cpu=cpu_0 host=server01  91.5 1500000000",
+		"metric=cpu field=usage_user cpu=cpu_0 host=server01  8 1500000000",
+	}, mS)
+}
+
+func TestSerializeMetricNoTags(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	m, err := telegraf.NewMetric("mem",
+		map[string]string{},
+		map[string]interface{}{"used": int64(1024)},
+		now)
+	assert.NoError(t, err)
+
+	s := Carbon2Serializer{}
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"metric=mem field=used  1024 1500000000"}, mS)
+}
+
+func TestSerializeMetricNoTimestamp(t *testing.T) {
+	m, err := telegraf.NewMetric("mem",
+		map[string]string{},
+		map[string]interface{}{"used": int64(1024)},
+		time.Time{})
+	assert.NoError(t, err)
+
+	before := time.Now().Unix()
+	s := Carbon2Serializer{}
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Len(t, mS, 1)
+
+	// metrics without a timestamp get the write time
+	var timestamp int64
+	_, err = fmt.Sscanf(mS[0], "metric=mem field=used  1024 %d", &timestamp)
+	assert.NoError(t, err)
+	assert.True(t, timestamp >= before && timestamp <= time.Now().Unix())
+}
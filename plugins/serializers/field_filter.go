@@ -0,0 +1,74 @@
+package serializers
+
+import (
+	"fmt"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+)
+
+// fieldFilterSerializer strips fields from metrics before handing them to
+// the wrapped serializer. Unlike the fieldpass and fielddrop filters, it only
+// applies to what this serializer outputs.
+type fieldFilterSerializer struct {
+	Serializer
+
+	include filter.Filter
+	exclude filter.Filter
+}
+
+func newFieldFilterSerializer(
+	s Serializer,
+	include []string,
+	exclude []string,
+) (Serializer, error) {
+	inc, err := filter.Compile(include)
+	if err != nil {
+		return nil, fmt.Errorf("Error compiling 'field_include', %s", err)
+	}
+	exc, err := filter.Compile(exclude)
+	if err != nil {
+		return nil, fmt.Errorf("Error compiling 'field_exclude', %s", err)
+	}
+	return &fieldFilterSerializer{
+		Serializer: s,
+		include:    inc,
+		exclude:    exc,
+	}, nil
+}
+
+// Serialize serializes the metric with only the included fields, or returns
+// nothing if no field is left.
+func (s *fieldFilterSerializer) Serialize(m telegraf.Metric) ([]string, error) {
+	fields := make(map[string]interface{})
+	for k, v := range m.Fields() {
+		if s.include != nil && !s.include.Match(k) {
+			continue
+		}
+		if s.exclude != nil && s.exclude.Match(k) {
+			continue
+		}
+		fields[k] = v
+	}
+	if len(fields) == 0 {
+		return []string{}, nil
+	}
+	if len(fields) == len(m.Fields()) {
+		return s.Serializer.Serialize(m)
+	}
+
+	var filtered telegraf.Metric
+	var err error
+	switch m.Type() {
+	case telegraf.Gauge:
+		filtered, err = telegraf.NewGaugeMetric(m.Name(), m.Tags(), fields, m.Time())
+	case telegraf.Counter:
+		filtered, err = telegraf.NewCounterMetric(m.Name(), m.Tags(), fields, m.Time())
+	default:
+		filtered, err = telegraf.NewMetric(m.Name(), m.Tags(), fields, m.Time())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.Serializer.Serialize(filtered)
+}
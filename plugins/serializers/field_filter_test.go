@@ -0,0 +1,46 @@
+package serializers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/influxdata/telegraf"
+)
+
+func TestFieldFilterSerializer(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{
+		"cpu": "cpu0",
+	}
+	fields := map[string]interface{}{
+		"usage_idle":   float64(91.5),
+		"usage_user":   float64(5),
+		"usage_system": float64(3.5),
+	}
+	m, err := telegraf.NewMetric("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s, err := NewSerializer(&Config{
+		DataFormat:   "json",
+		FieldInclude: []string{"usage_*"},
+		FieldExclude: []string{"usage_user"},
+	})
+	assert.NoError(t, err)
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	expS := []string{fmt.Sprintf("{\"fields\":{\"usage_idle\":91.5,\"usage_system\":3.5},\"name\":\"cpu\",\"tags\":{\"cpu\":\"cpu0\"},\"timestamp\":%d}", now.Unix())}
+	assert.Equal(t, expS, mS)
+
+	// metrics left with no fields are not serialized
+	s, err = NewSerializer(&Config{
+		DataFormat:   "json",
+		FieldInclude: []string{"time_*"},
+	})
+	assert.NoError(t, err)
+	mS, err = s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Empty(t, mS)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/influxdata/telegraf"
 )
@@ -23,8 +24,12 @@ type GraphiteSerializer struct {
 func (s *GraphiteSerializer) Serialize(metric telegraf.Metric) ([]string, error) {
 	out := []string{}
 
-	// Convert UnixNano to Unix timestamps
+	// Convert UnixNano to Unix timestamps, the plaintext protocol needs one
+	// so metrics without a timestamp get the write time
 	timestamp := metric.UnixNano() / 1000000000
+	if metric.Time().IsZero() {
+		timestamp = time.Now().Unix()
+	}
 
 	bucket := SerializeBucketName(metric.Name(), metric.Tags(), s.Template, s.Prefix)
 	if bucket == "" {
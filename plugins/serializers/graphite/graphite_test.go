@@ -135,6 +135,26 @@ func TestSerializeValueField(t *testing.T) {
 }
 
 // test that a field named "value" gets ignored in middle of template.
+func TestSerializeNoTimestamp(t *testing.T) {
+	fields := map[string]interface{}{
+		"value": float64(91.5),
+	}
+	m, err := telegraf.NewMetric("cpu", defaultTags, fields, time.Time{})
+	assert.NoError(t, err)
+
+	before := time.Now().Unix()
+	s := GraphiteSerializer{}
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Len(t, mS, 1)
+
+	// metrics without a timestamp get the write time
+	var timestamp int64
+	_, err = fmt.Sscanf(mS[0], "localhost.cpu0.us-west-2.cpu 91.5 %d", &timestamp)
+	assert.NoError(t, err)
+	assert.True(t, timestamp >= before && timestamp <= time.Now().Unix())
+}
+
 func TestSerializeValueField2(t *testing.T) {
 	now := time.Now()
 	tags := map[string]string{
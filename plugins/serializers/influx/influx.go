@@ -1,12 +1,35 @@
 package influx
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 )
 
 type InfluxSerializer struct {
+	// TimestampFormat is the precision of the timestamps, "unix_ns",
+	// "unix_ms" or "unix_s", see internal.FormatTimestamp, nanoseconds if
+	// empty. TimestampLocation is the time zone of the other formats of
+	// internal.FormatTimestamp, which are not valid line protocol.
+	TimestampFormat   string
+	TimestampLocation *time.Location
 }
 
 func (s *InfluxSerializer) Serialize(metric telegraf.Metric) ([]string, error) {
-	return []string{metric.String()}, nil
+	// metrics without a timestamp are written without one, so that the
+	// database sets it
+	if s.TimestampFormat == "" || s.TimestampFormat == "unix_ns" ||
+		metric.Time().IsZero() {
+		return []string{metric.String()}, nil
+	}
+	// the line of the metric without its timestamp
+	m, err := telegraf.NewMetric(metric.Name(), metric.Tags(), metric.Fields(),
+		time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return []string{fmt.Sprintf("%s %v", m.String(), internal.FormatTimestamp(
+		metric.Time(), s.TimestampFormat, s.TimestampLocation))}, nil
 }
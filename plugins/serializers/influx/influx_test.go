@@ -66,3 +66,42 @@ func TestSerializeMetricString(t *testing.T) {
 	expS := []string{fmt.Sprintf("cpu,cpu=cpu0 usage_idle=\"foobar\" %d", now.UnixNano())}
 	assert.Equal(t, expS, mS)
 }
+
+func TestSerializeMetricTimestampFormat(t *testing.T) {
+	now := time.Unix(1480000000, 123456789)
+	m, err := telegraf.NewMetric("cpu", map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": float64(91.5)}, now)
+	assert.NoError(t, err)
+
+	for format, ts := range map[string]string{
+		"unix_ms": "1480000000123",
+		"unix_s":  "1480000000",
+	} {
+		s := InfluxSerializer{TimestampFormat: format}
+		mS, err := s.Serialize(m)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"cpu,cpu=cpu0 usage_idle=91.5 " + ts}, mS)
+	}
+}
+
+func TestSerializeMetricNoTimestamp(t *testing.T) {
+	m, err := telegraf.NewMetric("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": float64(1)}, time.Time{})
+	assert.NoError(t, err)
+
+	for _, format := range []string{"", "unix_ms", "unix_s"} {
+		s := InfluxSerializer{TimestampFormat: format}
+		mS, err := s.Serialize(m)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"cpu,host=a value=1"}, mS)
+	}
+
+	// a string field ending with a space keeps its value
+	m, err = telegraf.NewMetric("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"status": "up "}, time.Unix(1480000000, 0))
+	assert.NoError(t, err)
+	s := InfluxSerializer{TimestampFormat: "unix_s"}
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`cpu,host=a status="up " 1480000000`}, mS)
+}
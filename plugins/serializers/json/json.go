@@ -2,11 +2,18 @@ package json
 
 import (
 	ejson "encoding/json"
+	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 )
 
 type JsonSerializer struct {
+	// TimestampFormat is the format of the timestamps, see
+	// internal.FormatTimestamp, seconds if empty. TimestampLocation is the
+	// time zone of the rfc3339 formats.
+	TimestampFormat   string
+	TimestampLocation *time.Location
 }
 
 func (s *JsonSerializer) Serialize(metric telegraf.Metric) ([]string, error) {
@@ -16,7 +23,15 @@ func (s *JsonSerializer) Serialize(metric telegraf.Metric) ([]string, error) {
 	m["tags"] = metric.Tags()
 	m["fields"] = metric.Fields()
 	m["name"] = metric.Name()
-	m["timestamp"] = metric.UnixNano() / 1000000000
+	// metrics without a timestamp are written without one
+	if !metric.Time().IsZero() {
+		if s.TimestampFormat == "" {
+			m["timestamp"] = metric.UnixNano() / 1000000000
+		} else {
+			m["timestamp"] = internal.FormatTimestamp(metric.Time(),
+				s.TimestampFormat, s.TimestampLocation)
+		}
+	}
 	serialized, err := ejson.Marshal(m)
 	if err != nil {
 		return []string{}, err
@@ -85,3 +85,34 @@ func TestSerializeMultiFields(t *testing.T) {
 	expS := []string{fmt.Sprintf("{\"fields\":{\"usage_idle\":90,\"usage_total\":8559615},\"name\":\"cpu\",\"tags\":{\"cpu\":\"cpu0\"},\"timestamp\":%d}", now.Unix())}
 	assert.Equal(t, expS, mS)
 }
+
+func TestSerializeMetricTimestampFormat(t *testing.T) {
+	now := time.Unix(1480000000, 123456789)
+	m, err := telegraf.NewMetric("cpu", map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": int64(90)}, now)
+	assert.NoError(t, err)
+
+	s := JsonSerializer{TimestampFormat: "rfc3339"}
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"fields":{"usage_idle":90},"name":"cpu",` +
+		`"tags":{"cpu":"cpu0"},"timestamp":"2016-11-24T15:06:40Z"}`}, mS)
+
+	s = JsonSerializer{TimestampFormat: "unix_ms"}
+	mS, err = s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"fields":{"usage_idle":90},"name":"cpu",` +
+		`"tags":{"cpu":"cpu0"},"timestamp":1480000000123}`}, mS)
+}
+
+func TestSerializeMetricNoTimestamp(t *testing.T) {
+	m, err := telegraf.NewMetric("cpu", map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": int64(90)}, time.Time{})
+	assert.NoError(t, err)
+
+	s := JsonSerializer{}
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"fields":{"usage_idle":90},"name":"cpu",` +
+		`"tags":{"cpu":"cpu0"}}`}, mS)
+}
@@ -0,0 +1,41 @@
+package msgpack
+
+import (
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/msgpack"
+)
+
+// MsgpackSerializer encodes each metric as a MessagePack map.
+type MsgpackSerializer struct {
+	// Format is "telegraf" (the default) to write the "name", "tags",
+	// "fields" and "time" keys, time being a MessagePack timestamp, or
+	// "influx" to write the "measurement", "tags", "fields" and "time" keys
+	// of an InfluxDB point, time being an integer of nanoseconds.
+	Format string
+}
+
+func (s *MsgpackSerializer) Serialize(metric telegraf.Metric) ([]string, error) {
+	m := map[string]interface{}{
+		"tags":   metric.Tags(),
+		"fields": metric.Fields(),
+	}
+	// metrics without a timestamp are written without a time key
+	hasTime := !metric.Time().IsZero()
+	if s.Format == "influx" {
+		m["measurement"] = metric.Name()
+		if hasTime {
+			m["time"] = metric.UnixNano()
+		}
+	} else {
+		m["name"] = metric.Name()
+		if hasTime {
+			m["time"] = metric.Time()
+		}
+	}
+
+	serialized, err := msgpack.Marshal(m)
+	if err != nil {
+		return []string{}, err
+	}
+	return []string{string(serialized)}, nil
+}
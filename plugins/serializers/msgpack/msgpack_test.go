@@ -0,0 +1,79 @@
+package msgpack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/msgpack"
+)
+
+func decode(t *testing.T, s string) map[string]interface{} {
+	v, err := msgpack.NewDecoder([]byte(s)).Decode()
+	require.NoError(t, err)
+	m, ok := v.(map[string]interface{})
+	require.True(t, ok)
+	return m
+}
+
+func TestSerializeMetric(t *testing.T) {
+	now := time.Unix(1500000000, 5).UTC()
+	m, err := telegraf.NewMetric("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": float64(91.5), "count": int64(3)},
+		now)
+	require.NoError(t, err)
+
+	s := MsgpackSerializer{}
+	mS, err := s.Serialize(m)
+	require.NoError(t, err)
+	require.Len(t, mS, 1)
+	assert.Equal(t, map[string]interface{}{
+		"name": "cpu",
+		"tags": map[string]interface{}{"cpu": "cpu0"},
+		"fields": map[string]interface{}{
+			"usage_idle": float64(91.5),
+			"count":      int64(3),
+		},
+		"time": now,
+	}, decode(t, mS[0]))
+}
+
+func TestSerializeMetricInflux(t *testing.T) {
+	now := time.Unix(1500000000, 5).UTC()
+	m, err := telegraf.NewMetric("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"idle": true},
+		now)
+	require.NoError(t, err)
+
+	s := MsgpackSerializer{Format: "influx"}
+	mS, err := s.Serialize(m)
+	require.NoError(t, err)
+	require.Len(t, mS, 1)
+	assert.Equal(t, map[string]interface{}{
+		"measurement": "cpu",
+		"tags":        map[string]interface{}{"cpu": "cpu0"},
+		"fields":      map[string]interface{}{"idle": true},
+		"time":        now.UnixNano(),
+	}, decode(t, mS[0]))
+}
+
+func TestSerializeMetricNoTimestamp(t *testing.T) {
+	m, err := telegraf.NewMetric("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"idle": true},
+		time.Time{})
+	require.NoError(t, err)
+
+	for _, format := range []string{"", "influx"} {
+		s := MsgpackSerializer{Format: format}
+		mS, err := s.Serialize(m)
+		require.NoError(t, err)
+		require.Len(t, mS, 1)
+		assert.NotContains(t, decode(t, mS[0]), "time")
+	}
+}
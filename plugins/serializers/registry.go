@@ -1,11 +1,17 @@
 package serializers
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/influxdata/telegraf"
 
+	"github.com/influxdata/telegraf/plugins/serializers/carbon2"
 	"github.com/influxdata/telegraf/plugins/serializers/graphite"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/plugins/serializers/json"
+	"github.com/influxdata/telegraf/plugins/serializers/msgpack"
+	"github.com/influxdata/telegraf/plugins/serializers/splunkhec"
 )
 
 // SerializerOutput is an interface for output plugins that are able to
@@ -25,7 +31,8 @@ type Serializer interface {
 // Config is a struct that covers the data types needed for all serializer types,
 // and can be used to instantiate _any_ of the serializers.
 type Config struct {
-	// Dataformat can be one of: influx, graphite
+	// Dataformat can be one of: influx, graphite, json, msgpack, carbon2,
+	// splunk_hec
 	DataFormat string
 
 	// Prefix to add to all measurements, only supports Graphite
@@ -34,6 +41,29 @@ type Config struct {
 	// Template for converting telegraf metrics into Graphite
 	// only supports Graphite
 	Template string
+
+	// FieldInclude and FieldExclude strip fields from metrics before they are
+	// serialized, supports all data formats
+	FieldInclude []string
+	FieldExclude []string
+
+	// MsgpackFormat is "telegraf" (the default) or "influx", only supports
+	// msgpack
+	MsgpackFormat string
+
+	// Source, sourcetype and index of the events, and the event field holding
+	// the metric name, only supports splunk_hec
+	SplunkSource          string
+	SplunkSourceType      string
+	SplunkIndex           string
+	SplunkMetricNameField string
+
+	// TimestampFormat is "unix_ns", "unix_ms", "unix_s", "rfc3339" or
+	// "rfc3339nano", and TimestampTimezone the IANA time zone of the rfc3339
+	// formats, UTC if empty, only supports influx, without the rfc3339
+	// formats, and json
+	TimestampFormat   string
+	TimestampTimezone string
 }
 
 // NewSerializer a Serializer interface based on the given config.
@@ -42,26 +72,127 @@ func NewSerializer(config *Config) (Serializer, error) {
 	var serializer Serializer
 	switch config.DataFormat {
 	case "influx":
-		serializer, err = NewInfluxSerializer()
+		serializer, err = NewInfluxSerializerWithTimestamp(
+			config.TimestampFormat, config.TimestampTimezone)
 	case "graphite":
 		serializer, err = NewGraphiteSerializer(config.Prefix, config.Template)
 	case "json":
-		serializer, err = NewJsonSerializer()
+		serializer, err = NewJsonSerializerWithTimestamp(
+			config.TimestampFormat, config.TimestampTimezone)
+	case "carbon2":
+		serializer, err = NewCarbon2Serializer()
+	case "msgpack":
+		serializer, err = NewMsgpackSerializer(config.MsgpackFormat)
+	case "splunk_hec":
+		serializer, err = NewSplunkHECSerializer(config.SplunkSource,
+			config.SplunkSourceType, config.SplunkIndex,
+			config.SplunkMetricNameField)
+	}
+	if err != nil || serializer == nil {
+		return serializer, err
+	}
+
+	if len(config.FieldInclude) != 0 || len(config.FieldExclude) != 0 {
+		return newFieldFilterSerializer(serializer,
+			config.FieldInclude, config.FieldExclude)
 	}
-	return serializer, err
+	return serializer, nil
 }
 
 func NewJsonSerializer() (Serializer, error) {
 	return &json.JsonSerializer{}, nil
 }
 
+// NewJsonSerializerWithTimestamp returns a json serializer writing the
+// timestamps in format, in the time zone timezone.
+func NewJsonSerializerWithTimestamp(
+	format, timezone string,
+) (Serializer, error) {
+	loc, err := timestampLocation(format, timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &json.JsonSerializer{
+		TimestampFormat:   format,
+		TimestampLocation: loc,
+	}, nil
+}
+
+// timestampLocation checks the timestamp format, and returns the location
+// of timezone, UTC if empty.
+func timestampLocation(format, timezone string) (*time.Location, error) {
+	switch format {
+	case "", "unix_ns", "unix_ms", "unix_s", "rfc3339", "rfc3339nano":
+	default:
+		return nil, fmt.Errorf("Invalid timestamp_format %q, must be "+
+			"\"unix_ns\", \"unix_ms\", \"unix_s\", \"rfc3339\" or "+
+			"\"rfc3339nano\"", format)
+	}
+	if timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid timestamp_timezone %q, %s",
+			timezone, err)
+	}
+	return loc, nil
+}
+
 func NewInfluxSerializer() (Serializer, error) {
 	return &influx.InfluxSerializer{}, nil
 }
 
+// NewInfluxSerializerWithTimestamp returns an influx serializer writing the
+// timestamps in format. Line protocol timestamps are integers, so the
+// rfc3339 formats are rejected, and timezone is not used.
+func NewInfluxSerializerWithTimestamp(
+	format, timezone string,
+) (Serializer, error) {
+	switch format {
+	case "rfc3339", "rfc3339nano":
+		return nil, fmt.Errorf("Invalid timestamp_format %q for the influx "+
+			"data format, must be \"unix_ns\", \"unix_ms\" or \"unix_s\"",
+			format)
+	}
+	loc, err := timestampLocation(format, timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &influx.InfluxSerializer{
+		TimestampFormat:   format,
+		TimestampLocation: loc,
+	}, nil
+}
+
 func NewGraphiteSerializer(prefix, template string) (Serializer, error) {
 	return &graphite.GraphiteSerializer{
 		Prefix:   prefix,
 		Template: template,
 	}, nil
 }
+
+func NewCarbon2Serializer() (Serializer, error) {
+	return &carbon2.Carbon2Serializer{}, nil
+}
+
+func NewMsgpackSerializer(format string) (Serializer, error) {
+	switch format {
+	case "", "telegraf", "influx":
+	default:
+		return nil, fmt.Errorf("Invalid msgpack_format %q, must be "+
+			"\"telegraf\" or \"influx\"", format)
+	}
+	return &msgpack.MsgpackSerializer{Format: format}, nil
+}
+
+func NewSplunkHECSerializer(
+	source, sourceType, index, metricNameField string,
+) (Serializer, error) {
+	return &splunkhec.SplunkHECSerializer{
+		Source:          source,
+		SourceType:      sourceType,
+		Index:           index,
+		MetricNameField: metricNameField,
+	}, nil
+}
@@ -0,0 +1,40 @@
+package serializers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSerializer_Timestamp(t *testing.T) {
+	m, err := telegraf.NewMetric("cpu", map[string]string{},
+		map[string]interface{}{"usage_idle": float64(91.5)},
+		time.Unix(1480000000, 0))
+	assert.NoError(t, err)
+
+	s, err := NewSerializer(&Config{
+		DataFormat:      "influx",
+		TimestampFormat: "unix_ms",
+	})
+	assert.NoError(t, err)
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cpu usage_idle=91.5 1480000000000"}, mS)
+
+	// line protocol timestamps are integers
+	for _, format := range []string{"rfc3339", "rfc3339nano"} {
+		_, err = NewSerializer(&Config{DataFormat: "influx",
+			TimestampFormat: format})
+		assert.Error(t, err)
+	}
+
+	_, err = NewSerializer(&Config{DataFormat: "json",
+		TimestampFormat: "unix_us"})
+	assert.Error(t, err)
+	_, err = NewSerializer(&Config{DataFormat: "json",
+		TimestampTimezone: "Mars/Olympus_Mons"})
+	assert.Error(t, err)
+}
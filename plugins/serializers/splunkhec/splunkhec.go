@@ -0,0 +1,104 @@
+package splunkhec
+
+import (
+	ejson "encoding/json"
+	"sort"
+
+	"github.com/influxdata/telegraf"
+)
+
+// SplunkHECSerializer writes metrics as events of the Splunk HTTP Event
+// Collector metric format, one event per field:
+//
+//	{"time": 1455320690, "event": "metric", "host": "tars",
+//	 "fields": {"metric_name": "cpu.usage_idle", "_value": 98.09,
+//	            "cpu": "cpu-total"}}
+//
+// The metric name is the measurement name and the field name joined by a dot,
+// in the MetricNameField key of the event fields, and the tags are added to
+// the event fields. The host tag is the host of the event. String fields are
+// skipped, booleans are written as 1 or 0.
+type SplunkHECSerializer struct {
+	// Source, SourceType and Index are added to the events if set.
+	Source     string
+	SourceType string
+	Index      string
+	// MetricNameField is the event field holding the metric name,
+	// "metric_name" if empty.
+	MetricNameField string
+}
+
+// event is a Splunk HEC event.
+type event struct {
+	Time       float64                `json:"time,omitempty"`
+	Event      string                 `json:"event"`
+	Host       string                 `json:"host,omitempty"`
+	Source     string                 `json:"source,omitempty"`
+	SourceType string                 `json:"sourcetype,omitempty"`
+	Index      string                 `json:"index,omitempty"`
+	Fields     map[string]interface{} `json:"fields"`
+}
+
+func (s *SplunkHECSerializer) Serialize(metric telegraf.Metric) ([]string, error) {
+	out := []string{}
+
+	nameField := s.MetricNameField
+	if nameField == "" {
+		nameField = "metric_name"
+	}
+
+	tags := metric.Tags()
+	fields := metric.Fields()
+	fieldNames := make([]string, 0, len(fields))
+	for name := range fields {
+		fieldNames = append(fieldNames, name)
+	}
+	sort.Strings(fieldNames)
+
+	for _, name := range fieldNames {
+		value, ok := formatValue(fields[name])
+		if !ok {
+			continue
+		}
+		e := event{
+			Event:      "metric",
+			Host:       tags["host"],
+			Source:     s.Source,
+			SourceType: s.SourceType,
+			Index:      s.Index,
+			Fields:     make(map[string]interface{}, len(tags)+2),
+		}
+		// events without a time get the time they are received at
+		if !metric.Time().IsZero() {
+			e.Time = float64(metric.UnixNano()) / 1e9
+		}
+		for k, v := range tags {
+			if k != "host" {
+				e.Fields[k] = v
+			}
+		}
+		e.Fields[nameField] = metric.Name() + "." + name
+		e.Fields["_value"] = value
+
+		serialized, err := ejson.Marshal(e)
+		if err != nil {
+			return []string{}, err
+		}
+		out = append(out, string(serialized))
+	}
+	return out, nil
+}
+
+// formatValue returns a numeric or boolean field value as a number.
+func formatValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case int64, uint64, float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return nil, false
+}
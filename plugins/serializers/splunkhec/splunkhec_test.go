@@ -0,0 +1,77 @@
+package splunkhec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/influxdata/telegraf"
+)
+
+func TestSerializeMetric(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	tags := map[string]string{
+		"host": "server01",
+		"cpu":  "cpu0",
+	}
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+		"online":     true,
+		"state":      "ok",
+	}
+	m, err := telegraf.NewMetric("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := SplunkHECSerializer{
+		Source:     "telegraf",
+		SourceType: "telegraf:metrics",
+		Index:      "metrics",
+	}
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`{"time":1500000000,"event":"metric","host":"server01",` +
+			`"source":"telegraf","sourcetype":"telegraf:metrics",` +
+			`"index":"metrics","fields":{"_value":1,"cpu":"cpu0",` +
+			`"metric_name":"cpu.online"}}`,
+		`{"time":1500000000,"event":"metric","host":"server01",` +
+			`"source":"telegraf","sourcetype":"telegraf:metrics",` +
+			`"index":"metrics","fields":{"_value":91.5,"cpu":"cpu0",` +
+			`"metric_name":"cpu.usage_idle"}}`,
+	}, mS)
+}
+
+func TestSerializeMetricNameField(t *testing.T) {
+	now := time.Unix(1500000000, 500000000)
+	m, err := telegraf.NewMetric("mem",
+		map[string]string{},
+		map[string]interface{}{"used": int64(1024)},
+		now)
+	assert.NoError(t, err)
+
+	s := SplunkHECSerializer{MetricNameField: "name"}
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`{"time":1500000000.5,"event":"metric",` +
+			`"fields":{"_value":1024,"name":"mem.used"}}`,
+	}, mS)
+}
+
+func TestSerializeMetricNoTimestamp(t *testing.T) {
+	m, err := telegraf.NewMetric("cpu",
+		map[string]string{"host": "server01"},
+		map[string]interface{}{"usage_idle": float64(91.5)},
+		time.Time{})
+	assert.NoError(t, err)
+
+	// the collector sets the time of events without one
+	s := SplunkHECSerializer{}
+	mS, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`{"event":"metric","host":"server01","fields":{"_value":91.5,` +
+			`"metric_name":"cpu.usage_idle"}}`,
+	}, mS)
+}